@@ -43,7 +43,8 @@ func (err ErrBlobInvalidDigest) Error() string {
 }
 
 // ErrBlobMounted returned when a blob is mounted from another repository
-// instead of initiating an upload session.
+// instead of initiating an upload session. From is nil when the blob was
+// mounted from the blob store of the registry rather than a repository.
 type ErrBlobMounted struct {
 	From       reference.Canonical
 	Descriptor v1.Descriptor
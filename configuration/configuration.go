@@ -66,12 +66,90 @@ type Configuration struct {
 
 	// Policy configures registry policy options.
 	Policy Policy `yaml:"policy,omitempty"`
+
+	// TokenServer configures the built-in token server run by the
+	// token-server command.
+	TokenServer TokenServer `yaml:"tokenserver,omitempty"`
+
+	// Admin configures the administrative API.
+	Admin Admin `yaml:"admin,omitempty"`
+}
+
+// Admin configures the administrative API, served under /admin/v1/ on the
+// registry's HTTP interface.
+type Admin struct {
+	// Enabled turns on the administrative API. It requires an access
+	// controller: requests must be granted the "*" action on the "admin"
+	// resource of type "registry".
+	Enabled bool `yaml:"enabled,omitempty"`
+}
+
+// TokenServer configures the built-in token server. It issues bearer tokens
+// which a registry configured with the token access controller accepts.
+type TokenServer struct {
+	// Addr specifies the bind address for the token server.
+	Addr string `yaml:"addr,omitempty"`
+
+	// Path is the URL path on which tokens are served. Defaults to
+	// /auth/token, the default redirect path of the token access controller.
+	Path string `yaml:"path,omitempty"`
+
+	// Certificate and Key enable TLS for the token server.
+	Certificate string `yaml:"certificate,omitempty"`
+	Key         string `yaml:"key,omitempty"`
+
+	// Realm is the realm sent in basic authentication challenges.
+	Realm string `yaml:"realm,omitempty"`
+
+	// Issuer is the issuer name written into tokens. It must match the
+	// issuer configured for the token access controller.
+	Issuer string `yaml:"issuer,omitempty"`
+
+	// Service is the audience written into tokens. It must match the
+	// service configured for the token access controller.
+	Service string `yaml:"service,omitempty"`
+
+	// SigningKey is the path to a PEM encoded private key used to sign
+	// tokens.
+	SigningKey string `yaml:"signingkey,omitempty"`
+
+	// Expiration is the lifetime of access tokens.
+	Expiration time.Duration `yaml:"expiration,omitempty"`
+
+	// RefreshExpiration is the lifetime of refresh tokens.
+	RefreshExpiration time.Duration `yaml:"refreshexpiration,omitempty"`
+
+	// Authenticator configures the credential backend. Any access
+	// controller which can verify a username and password, such as
+	// htpasswd, may be used.
+	Authenticator Auth `yaml:"authenticator,omitempty"`
+
+	// ACL is the path to an access control list deciding which of the
+	// requested actions are granted. If unset, tokens grant no access.
+	ACL string `yaml:"acl,omitempty"`
 }
 
 // Policy defines configuration options for managing registry policies.
 type Policy struct {
 	// Repository configures policies for repositories
 	Repository Repository `yaml:"repository,omitempty"`
+
+	// Mount configures the mounting of blobs across repositories.
+	Mount Mount `yaml:"mount,omitempty"`
+}
+
+// Mount defines configuration options for mounting blobs into repositories.
+type Mount struct {
+	// Automatic mounts the blob named by an upload with a mount parameter
+	// but no from parameter, if it exists in the registry, instead of
+	// starting the upload. With "any", any blob stored in the registry is
+	// mounted; with "repositories", only the blobs of the Repositories the
+	// caller may pull. Blobs are never mounted automatically if empty.
+	Automatic string `yaml:"automatic,omitempty"`
+
+	// Repositories lists the repositories blobs are mounted from with the
+	// "repositories" policy, in order of preference.
+	Repositories []string `yaml:"repositories,omitempty"`
 }
 
 // Repository defines configuration options related to repository policies in the registry.
@@ -632,24 +710,84 @@ func (auth Auth) MarshalYAML() (interface{}, error) {
 type Notifications struct {
 	// EventConfig is the configuration for the event format that is sent to each Endpoint.
 	EventConfig Events `yaml:"events,omitempty"`
-	// Endpoints is a list of http configurations for endpoints that
-	// respond to webhook notifications. In the future, we may allow other
-	// kinds of endpoints, such as external queues.
+	// Endpoints is a list of configurations for endpoints that receive
+	// notifications, such as http webhooks, files, local commands or Redis
+	// streams.
 	Endpoints []Endpoint `yaml:"endpoints,omitempty"`
+	// Journal records events so that they can be replayed to endpoints.
+	Journal Journal `yaml:"journal,omitempty"`
+}
+
+// Journal configures where events are recorded for replay through the admin
+// API, and for how long. At most one of Directory and Storage may be set.
+type Journal struct {
+	Directory string        `yaml:"directory,omitempty"` // record events in this directory
+	Storage   bool          `yaml:"storage,omitempty"`   // record events in the registry's storage
+	MaxAge    time.Duration `yaml:"maxage,omitempty"`    // how long events are kept, 0 for ever
+	Ignore    Ignore        `yaml:"ignore,omitempty"`    // events which are not recorded
 }
 
-// Endpoint describes the configuration of an http webhook notification
-// endpoint.
+// Endpoint describes the configuration of a notification endpoint. Events are
+// posted to an http webhook unless Type selects another kind of endpoint.
 type Endpoint struct {
-	Name              string        `yaml:"name"`              // identifies the endpoint in the registry instance.
-	Disabled          bool          `yaml:"disabled"`          // disables the endpoint
-	URL               string        `yaml:"url"`               // post url for the endpoint.
-	Headers           http.Header   `yaml:"headers"`           // static headers that should be added to all requests
-	Timeout           time.Duration `yaml:"timeout"`           // HTTP timeout
-	Threshold         int           `yaml:"threshold"`         // circuit breaker threshold before backing off on failure
-	Backoff           time.Duration `yaml:"backoff"`           // backoff duration
-	IgnoredMediaTypes []string      `yaml:"ignoredmediatypes"` // target media types to ignore
-	Ignore            Ignore        `yaml:"ignore"`            // ignore event types
+	Name              string        `yaml:"name"`                    // identifies the endpoint in the registry instance.
+	Disabled          bool          `yaml:"disabled"`                // disables the endpoint
+	Type              string        `yaml:"type,omitempty"`          // http (the default), file, exec or redis
+	URL               string        `yaml:"url"`                     // post url for the endpoint.
+	Headers           http.Header   `yaml:"headers"`                 // static headers that should be added to all requests
+	Timeout           time.Duration `yaml:"timeout"`                 // HTTP timeout
+	Threshold         int           `yaml:"threshold"`               // circuit breaker threshold before backing off on failure
+	Backoff           time.Duration `yaml:"backoff"`                 // backoff duration
+	IgnoredMediaTypes []string      `yaml:"ignoredmediatypes"`       // target media types to ignore
+	Ignore            Ignore        `yaml:"ignore"`                  // ignore event types
+	Filter            Filter        `yaml:"filter"`                  // filter events by repository and tag
+	File              FileEndpoint  `yaml:"file,omitempty"`          // file endpoint parameters
+	Exec              ExecEndpoint  `yaml:"exec,omitempty"`          // exec endpoint parameters
+	Redis             RedisEndpoint `yaml:"redis,omitempty"`         // redis endpoint parameters
+	Retry             Retry         `yaml:"retry,omitempty"`         // exponential backoff and retry limits
+	DeadLetter        DeadLetter    `yaml:"deadletter,omitempty"`    // where undeliverable events are kept
+	MaxBatchSize      int           `yaml:"maxbatchsize,omitempty"`  // events posted at most in one envelope
+	MaxBatchDelay     time.Duration `yaml:"maxbatchdelay,omitempty"` // time to wait for an envelope to fill up
+	Gzip              bool          `yaml:"gzip,omitempty"`          // compress request bodies with gzip
+}
+
+// Retry configures exponential backoff with jitter for an endpoint, in place
+// of its threshold and backoff circuit breaker, and limits how long each
+// event is retried. Events which exceed either limit are dead-lettered.
+type Retry struct {
+	InitialBackoff time.Duration `yaml:"initialbackoff"` // backoff after the first failure
+	MaxBackoff     time.Duration `yaml:"maxbackoff"`     // upper bound of the backoff
+	MaxAttempts    int           `yaml:"maxattempts"`    // attempts per event, 0 for no limit
+	MaxAge         time.Duration `yaml:"maxage"`         // age after which an event is not retried, 0 for no limit
+}
+
+// DeadLetter configures where events which could not be delivered to an
+// endpoint are kept, so that they can be listed and replayed through the
+// admin API. At most one of Directory and Storage may be set.
+type DeadLetter struct {
+	Directory string `yaml:"directory,omitempty"` // keep dead letters in this directory
+	Storage   bool   `yaml:"storage,omitempty"`   // keep dead letters in the registry's storage
+}
+
+// FileEndpoint configures an endpoint which appends events to a file as
+// newline-delimited JSON.
+type FileEndpoint struct {
+	Path       string `yaml:"path"`       // path of the file
+	MaxSize    int64  `yaml:"maxsize"`    // size in bytes at which the file is rotated, 0 to never rotate
+	MaxBackups int    `yaml:"maxbackups"` // number of rotated files to keep
+}
+
+// ExecEndpoint configures an endpoint which runs a local command for every
+// event, with the event envelope on its standard input.
+type ExecEndpoint struct {
+	Command []string `yaml:"command"` // the command and its arguments
+}
+
+// RedisEndpoint configures an endpoint which adds events to a Redis stream,
+// using the registry's redis configuration.
+type RedisEndpoint struct {
+	Stream string `yaml:"stream"` // name of the stream
+	MaxLen int64  `yaml:"maxlen"` // approximate maximum length of the stream, 0 for no limit
 }
 
 // Events configures notification events.
@@ -663,6 +801,20 @@ type Ignore struct {
 	Actions    []string `yaml:"actions"`    // ignore action types
 }
 
+// Filter configures the repositories and tags an endpoint receives events
+// for. Repositories are matched by glob and tags by regular expression.
+type Filter struct {
+	Repositories FilterRules `yaml:"repositories"` // repository globs
+	Tags         FilterRules `yaml:"tags"`         // tag regular expressions
+}
+
+// FilterRules includes events matching any of Include, or every event if
+// Include is empty, then excludes events matching any of Exclude.
+type FilterRules struct {
+	Include []string `yaml:"include,omitempty"`
+	Exclude []string `yaml:"exclude,omitempty"`
+}
+
 // Middleware configures named middlewares to be applied at injection points.
 type Middleware struct {
 	// Name the middleware registers itself as
@@ -688,10 +840,157 @@ type Proxy struct {
 	// If set, Username and Password are ignored.
 	Exec *ExecConfig `yaml:"exec,omitempty"`
 
+	// TLS configures the TLS connections to the remote registry, such as a
+	// registry using a private PKI.
+	TLS ProxyTLS `yaml:"tls,omitempty"`
+
+	// HTTPProxy is the URL of the HTTP(S) proxy the remote registry is
+	// reached through. If not set, the proxy is taken from the HTTP_PROXY,
+	// HTTPS_PROXY and NO_PROXY environment variables.
+	HTTPProxy string `yaml:"httpproxy,omitempty"`
+
+	// Timeouts bounds the stages of the requests to the remote registry.
+	Timeouts ProxyTimeouts `yaml:"timeouts,omitempty"`
+
 	// TTL is the expiry time of the content and will be cleaned up when it expires
 	// if not set, defaults to 7 * 24 hours
 	// If set to zero, will never expire cache
 	TTL *time.Duration `yaml:"ttl,omitempty"`
+
+	// Coalescing lets the registry instances sharing the redis server
+	// fetch each blob from the remote registry only once.
+	Coalescing Coalescing `yaml:"coalescing,omitempty"`
+
+	// RateLimit configures the throttling of the manifest fetches from the
+	// remote registry when its rate limit runs low.
+	RateLimit RateLimit `yaml:"ratelimit,omitempty"`
+
+	// Pins lists the repositories ("name") and tags ("name:tag") whose
+	// content never expires from the cache.
+	Pins []string `yaml:"pins,omitempty"`
+
+	// WarmUp configures the pre-fetching of content from the remote
+	// registry.
+	WarmUp WarmUp `yaml:"warmup,omitempty"`
+
+	// Scheduler configures how the expiry of the cached content is tracked.
+	Scheduler Scheduler `yaml:"scheduler,omitempty"`
+}
+
+// Scheduler configures the scheduler expiring the content of a pull-through
+// cache once its TTL has passed. The scheduler keeps the expiry of every
+// cached blob and manifest in a store, and only holds the ones expiring soon
+// in memory.
+type Scheduler struct {
+	// Store is where the expiries are kept: "storage", the default, journals
+	// them in the registry's storage, for a single registry instance;
+	// "redis" keeps them in the registry's redis server, shared by the
+	// registry instances using it.
+	Store string `yaml:"store,omitempty"`
+
+	// Prefix namespaces the keys of the "redis" store, shared by the
+	// registry instances with the same prefix. Defaults to the URL of the
+	// remote registry.
+	Prefix string `yaml:"prefix,omitempty"`
+
+	// Window is how far ahead the expiries are loaded from the store into
+	// memory. Defaults to 5m.
+	Window time.Duration `yaml:"window,omitempty"`
+
+	// Resolution is the precision of the expiries: content expires at most
+	// this late. Defaults to 1s.
+	Resolution time.Duration `yaml:"resolution,omitempty"`
+
+	// MaxEntries bounds the number of expiries held in memory. Defaults to
+	// 100000.
+	MaxEntries int `yaml:"maxentries,omitempty"`
+}
+
+// ProxyTLS configures the TLS connections of a pull-through cache to the
+// remote registry.
+type ProxyTLS struct {
+	// CAs lists the PEM files of the certificate authorities trusted for
+	// the remote registry, in addition to the system ones.
+	CAs []string `yaml:"cas,omitempty"`
+
+	// Certificate and Key are the paths of the PEM files of the client
+	// certificate and its key presented to a remote registry requiring
+	// mutual TLS.
+	Certificate string `yaml:"certificate,omitempty"`
+	Key         string `yaml:"key,omitempty"`
+
+	// InsecureSkipVerify disables the verification of the certificate of
+	// the remote registry. It is meant for test setups only.
+	InsecureSkipVerify bool `yaml:"insecureskipverify,omitempty"`
+}
+
+// ProxyTimeouts bounds the stages of the requests of a pull-through cache to
+// the remote registry. Unset timeouts default to the ones of Go's default
+// HTTP transport. Blob transfers themselves are not bounded.
+type ProxyTimeouts struct {
+	// Dial bounds the connection to the remote registry. Defaults to 30s.
+	Dial time.Duration `yaml:"dial,omitempty"`
+
+	// TLSHandshake bounds the TLS handshake. Defaults to 10s.
+	TLSHandshake time.Duration `yaml:"tlshandshake,omitempty"`
+
+	// ResponseHeader bounds the wait for the headers of a response once
+	// the request is sent. Defaults to no timeout.
+	ResponseHeader time.Duration `yaml:"responseheader,omitempty"`
+
+	// IdleConn is how long an idle connection is kept open. Defaults to
+	// 90s.
+	IdleConn time.Duration `yaml:"idleconn,omitempty"`
+}
+
+// WarmUp configures a job fetching tags from the remote registry into the
+// cache, with the manifests, index children and blobs they reference, so that
+// they are served from the cache when first pulled.
+type WarmUp struct {
+	// References lists the tags to fetch, as "name:tag".
+	References []string `yaml:"references,omitempty"`
+
+	// File is the path of a file listing more tags to fetch, one per line.
+	// Empty lines and lines starting with # are ignored. It is read on each
+	// run of the job.
+	File string `yaml:"file,omitempty"`
+
+	// Interval is how often the job runs. The job runs when the registry
+	// starts, and then only when requested through the admin API unless an
+	// interval is set.
+	Interval time.Duration `yaml:"interval,omitempty"`
+}
+
+// RateLimit configures how a pull-through cache spends the rate limit the
+// remote registry reports in the RateLimit-Limit and RateLimit-Remaining
+// headers of its responses, such as the pull limit of Docker Hub.
+type RateLimit struct {
+	// Threshold is the remaining budget under which manifest fetches are
+	// throttled until the budget is replenished. Defaults to 0: fetches are
+	// throttled once the budget is exhausted.
+	Threshold int `yaml:"threshold,omitempty"`
+
+	// MaxWait is how long a throttled manifest fetch waits for the budget
+	// to be replenished before failing. Defaults to 0: throttled fetches
+	// fail at once.
+	MaxWait time.Duration `yaml:"maxwait,omitempty"`
+}
+
+// Coalescing configures the coordination of the upstream blob fetches of the
+// registry instances of a pull-through cache, through the registry's redis
+// server. While one instance fetches a blob, the others wait for it to be
+// stored rather than fetching it too.
+type Coalescing struct {
+	// Enabled turns on coalescing. It requires redis to be configured.
+	Enabled bool `yaml:"enabled"`
+
+	// LockTTL is how long a fetch is held by an instance which stopped
+	// renewing it, for instance because it died. Defaults to 30s.
+	LockTTL time.Duration `yaml:"lockttl,omitempty"`
+
+	// WaitTimeout is how long an instance waits for another to fetch a blob
+	// before fetching it itself, without storing it. Defaults to 5m.
+	WaitTimeout time.Duration `yaml:"waittimeout,omitempty"`
 }
 
 // ExecConfig defines the configuration for executing a command as a credential helper.
@@ -53,6 +53,15 @@ var configStruct = Configuration{
 					MediaTypes: []string{"application/octet-stream"},
 					Actions:    []string{"pull"},
 				},
+				Filter: Filter{
+					Repositories: FilterRules{
+						Include: []string{"team-a/**"},
+						Exclude: []string{"team-a/scratch/**"},
+					},
+					Tags: FilterRules{
+						Exclude: []string{"-rc[0-9]*$"},
+					},
+				},
 			},
 		},
 	},
@@ -138,6 +147,12 @@ notifications:
            - application/octet-stream
         actions:
            - pull
+      filter:
+        repositories:
+          include: ["team-a/**"]
+          exclude: ["team-a/scratch/**"]
+        tags:
+          exclude: ["-rc[0-9]*$"]
 http:
   tls:
     clientcas:
@@ -191,6 +206,12 @@ notifications:
            - application/octet-stream
         actions:
            - pull
+      filter:
+        repositories:
+          include: ["team-a/**"]
+          exclude: ["team-a/scratch/**"]
+        tags:
+          exclude: ["-rc[0-9]*$"]
 http:
   headers:
     X-Content-Type-Options: [nosniff]
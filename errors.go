@@ -43,6 +43,16 @@ func (err ErrRepositoryUnknown) Error() string {
 	return fmt.Sprintf("unknown repository name=%s", err.Name)
 }
 
+// ErrRepositoryExists is returned when a repository would be created where
+// one already exists.
+type ErrRepositoryExists struct {
+	Name string
+}
+
+func (err ErrRepositoryExists) Error() string {
+	return fmt.Sprintf("repository name exists: %s", err.Name)
+}
+
 // ErrRepositoryNameInvalid should be used to denote an invalid repository
 // name. Reason may set, indicating the cause of invalidity.
 type ErrRepositoryNameInvalid struct {
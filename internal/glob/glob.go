@@ -0,0 +1,38 @@
+// Package glob matches repository names against glob patterns.
+package glob
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Compile turns a repository glob into a regular expression. A single "*"
+// matches within one path component, "**" matches across components and "?"
+// matches one character other than "/".
+func Compile(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("empty repository pattern")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				sb.WriteString(".*")
+				i++
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+
+	return regexp.Compile(sb.String())
+}
@@ -49,3 +49,13 @@ func RemoteIP(r *http.Request) string {
 
 	return addr
 }
+
+// PeerIP returns the IP of the connection's peer. Unlike RemoteIP it ignores
+// proxy headers, which clients may set freely, so it is suitable where the
+// address must not be spoofed.
+func PeerIP(r *http.Request) string {
+	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return ip
+	}
+	return r.RemoteAddr
+}
@@ -74,3 +74,14 @@ func TestRemoteAddr(t *testing.T) {
 	}
 	defer resp.Body.Close()
 }
+
+func TestPeerIP(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+	req.Header.Set("X-Real-Ip", "1.2.3.4")
+
+	if ip := PeerIP(req); ip != "10.0.0.1" {
+		t.Fatalf("expected the connection's address, got %q", ip)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"mime"
 
+	"github.com/distribution/reference"
 	"github.com/opencontainers/go-digest"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 )
@@ -49,6 +50,16 @@ type ManifestEnumerator interface {
 	Enumerate(ctx context.Context, ingester func(digest.Digest) error) error
 }
 
+// ManifestCopier copies manifests between repositories without them being
+// pushed again.
+type ManifestCopier interface {
+	// Copy links the manifest dgst of the repository from into the
+	// repository, along with the manifests it references and their blobs.
+	// The manifests linked are returned children first, the manifest dgst
+	// last. The options apply to the manifest dgst.
+	Copy(ctx context.Context, from reference.Named, dgst digest.Digest, options ...ManifestServiceOption) ([]Manifest, error)
+}
+
 // Describable is an interface for descriptors.
 //
 // Implementations of Describable are generally objects which can be
@@ -22,7 +22,13 @@ type bridge struct {
 	sink              events.Sink
 }
 
-var _ Listener = &bridge{}
+var (
+	_ Listener         = &bridge{}
+	_ TagMoveListener  = &bridge{}
+	_ RepoCopyListener = &bridge{}
+	_ UploadListener   = &bridge{}
+	_ SweepListener    = &bridge{}
+)
 
 // URLBuilder defines a subset of url builder to be used by the event listener.
 type URLBuilder interface {
@@ -104,7 +110,10 @@ func (b *bridge) BlobMounted(repo reference.Named, desc v1.Descriptor, fromRepo
 	if err != nil {
 		return err
 	}
-	event.Target.FromRepository = fromRepo.Name()
+	// A blob mounted from the blob store of the registry has no repository.
+	if fromRepo != nil {
+		event.Target.FromRepository = fromRepo.Name()
+	}
 	return b.sink.Write(*event)
 }
 
@@ -112,6 +121,29 @@ func (b *bridge) BlobDeleted(repo reference.Named, dgst digest.Digest) error {
 	return b.createBlobDeleteEventAndWrite(EventActionDelete, repo, dgst)
 }
 
+func (b *bridge) TagMoved(repo reference.Named, tag string, desc v1.Descriptor, previous digest.Digest) error {
+	event := b.createEvent(EventActionMove)
+	event.Target.MediaType = desc.MediaType
+	event.Target.Digest = desc.Digest
+	event.Target.Size = desc.Size
+	event.Target.Length = desc.Size
+	event.Target.Repository = repo.Name()
+	event.Target.Tag = tag
+	event.Target.PreviousDigest = previous
+
+	ref, err := reference.WithDigest(repo, desc.Digest)
+	if err != nil {
+		return err
+	}
+
+	event.Target.URL, err = b.ub.BuildManifestURL(ref)
+	if err != nil {
+		return err
+	}
+
+	return b.sink.Write(*event)
+}
+
 func (b *bridge) TagDeleted(repo reference.Named, tag string) error {
 	event := b.createEvent(EventActionDelete)
 	event.Target.Repository = repo.Name()
@@ -127,6 +159,59 @@ func (b *bridge) RepoDeleted(repo reference.Named) error {
 	return b.sink.Write(*event)
 }
 
+func (b *bridge) RepoCopied(repo reference.Named, fromRepo reference.Named) error {
+	return b.createRepoCopyEventAndWrite(EventActionCopy, repo, fromRepo)
+}
+
+func (b *bridge) RepoRenamed(repo reference.Named, fromRepo reference.Named) error {
+	return b.createRepoCopyEventAndWrite(EventActionRename, repo, fromRepo)
+}
+
+func (b *bridge) BlobUploadStarted(repo reference.Named, id string) error {
+	return b.createUploadEventAndWrite(EventActionUpload, repo, id)
+}
+
+func (b *bridge) BlobUploadCancelled(repo reference.Named, id string) error {
+	return b.createUploadEventAndWrite(EventActionCancel, repo, id)
+}
+
+func (b *bridge) BlobUploadPurged(repo reference.Named, id string) error {
+	return b.createUploadEventAndWrite(EventActionPurge, repo, id)
+}
+
+func (b *bridge) ManifestSwept(repo reference.Named, dgst digest.Digest) error {
+	return b.createManifestDeleteEventAndWrite(EventActionSweep, repo, dgst)
+}
+
+func (b *bridge) LayerSwept(repo reference.Named, dgst digest.Digest) error {
+	return b.createBlobDeleteEventAndWrite(EventActionSweep, repo, dgst)
+}
+
+// BlobSwept records blob data removed from storage by the garbage collector.
+// The event has no repository, as the data was no longer linked to any.
+func (b *bridge) BlobSwept(dgst digest.Digest) error {
+	event := b.createEvent(EventActionSweep)
+	event.Target.Digest = dgst
+
+	return b.sink.Write(*event)
+}
+
+func (b *bridge) createRepoCopyEventAndWrite(action string, repo reference.Named, fromRepo reference.Named) error {
+	event := b.createEvent(action)
+	event.Target.Repository = repo.Name()
+	event.Target.FromRepository = fromRepo.Name()
+
+	return b.sink.Write(*event)
+}
+
+func (b *bridge) createUploadEventAndWrite(action string, repo reference.Named, id string) error {
+	event := b.createEvent(action)
+	event.Target.Repository = repo.Name()
+	event.Target.UploadID = id
+
+	return b.sink.Write(*event)
+}
+
 func (b *bridge) createManifestDeleteEventAndWrite(action string, repo reference.Named, dgst digest.Digest) error {
 	event := b.createEvent(action)
 	event.Target.Repository = repo.Name()
@@ -1,6 +1,7 @@
 package notifications
 
 import (
+	"reflect"
 	"testing"
 
 	"github.com/distribution/distribution/v3"
@@ -141,6 +142,107 @@ func TestEventBridgeRepoDeleted(t *testing.T) {
 	}
 }
 
+func TestEventBridgeRepoCopied(t *testing.T) {
+	var actions []string
+	l := createTestEnv(t, testSinkFn(func(event events.Event) error {
+		checkDeleted(t, "", event)
+		if event.(Event).Target.FromRepository != "test/source" {
+			t.Fatalf("unexpected source repository on event target: %q", event.(Event).Target.FromRepository)
+		}
+		actions = append(actions, event.(Event).Action)
+		return nil
+	}))
+
+	repoRef, _ := reference.WithName(repo)
+	fromRef, _ := reference.WithName("test/source")
+	if err := l.(RepoCopyListener).RepoCopied(repoRef, fromRef); err != nil {
+		t.Fatalf("unexpected error notifying repo copy: %v", err)
+	}
+	if err := l.(RepoCopyListener).RepoRenamed(repoRef, fromRef); err != nil {
+		t.Fatalf("unexpected error notifying repo rename: %v", err)
+	}
+
+	expected := []string{EventActionCopy, EventActionRename}
+	if !reflect.DeepEqual(actions, expected) {
+		t.Fatalf("unexpected actions: %v != %v", actions, expected)
+	}
+}
+
+func TestEventBridgeTagMoved(t *testing.T) {
+	previous := digest.FromString("previous")
+	l := createTestEnv(t, testSinkFn(func(event events.Event) error {
+		checkCommonManifest(t, EventActionMove, event)
+		if event.(Event).Target.Tag != tag {
+			t.Fatalf("unexpected tag on event target: %q != %q", event.(Event).Target.Tag, tag)
+		}
+		if event.(Event).Target.PreviousDigest != previous {
+			t.Fatalf("unexpected previous digest on event target: %q != %q", event.(Event).Target.PreviousDigest, previous)
+		}
+		return nil
+	}))
+
+	repoRef, _ := reference.WithName(repo)
+	desc := v1.Descriptor{MediaType: v1.MediaTypeImageManifest, Digest: dgst, Size: int64(len(payload))}
+	if err := l.(TagMoveListener).TagMoved(repoRef, tag, desc, previous); err != nil {
+		t.Fatalf("unexpected error notifying tag move: %v", err)
+	}
+}
+
+func TestEventBridgeBlobUploads(t *testing.T) {
+	var actions []string
+	l := createTestEnv(t, testSinkFn(func(event events.Event) error {
+		checkDeleted(t, "", event)
+		if event.(Event).Target.UploadID != "upload" {
+			t.Fatalf("unexpected upload id on event target: %q", event.(Event).Target.UploadID)
+		}
+		actions = append(actions, event.(Event).Action)
+		return nil
+	}))
+
+	repoRef, _ := reference.WithName(repo)
+	ul := l.(UploadListener)
+	for _, notify := range []func(reference.Named, string) error{ul.BlobUploadStarted, ul.BlobUploadCancelled, ul.BlobUploadPurged} {
+		if err := notify(repoRef, "upload"); err != nil {
+			t.Fatalf("unexpected error notifying upload: %v", err)
+		}
+	}
+
+	expected := []string{EventActionUpload, EventActionCancel, EventActionPurge}
+	if !reflect.DeepEqual(actions, expected) {
+		t.Fatalf("unexpected actions: %v != %v", actions, expected)
+	}
+}
+
+func TestEventBridgeSwept(t *testing.T) {
+	var swept []Event
+	l := createTestEnv(t, testSinkFn(func(event events.Event) error {
+		if event.(Event).Action != EventActionSweep {
+			t.Fatalf("unexpected event action: %q", event.(Event).Action)
+		}
+		if event.(Event).Target.Digest != dgst {
+			t.Fatalf("unexpected digest on event target: %q != %q", event.(Event).Target.Digest, dgst)
+		}
+		swept = append(swept, event.(Event))
+		return nil
+	}))
+
+	repoRef, _ := reference.WithName(repo)
+	sl := l.(SweepListener)
+	if err := sl.ManifestSwept(repoRef, dgst); err != nil {
+		t.Fatalf("unexpected error notifying manifest sweep: %v", err)
+	}
+	if err := sl.LayerSwept(repoRef, dgst); err != nil {
+		t.Fatalf("unexpected error notifying layer sweep: %v", err)
+	}
+	if err := sl.BlobSwept(dgst); err != nil {
+		t.Fatalf("unexpected error notifying blob sweep: %v", err)
+	}
+
+	if len(swept) != 3 || swept[0].Target.Repository != repo || swept[1].Target.Repository != repo || swept[2].Target.Repository != "" {
+		t.Fatalf("unexpected sweep events: %#v", swept)
+	}
+}
+
 func createTestEnv(t *testing.T, fn testSinkFn) Listener {
 	mfst := schema2.Manifest{
 		Versioned: specs.Versioned{SchemaVersion: 2},
@@ -0,0 +1,122 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path"
+	"sort"
+	"time"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+)
+
+// ErrDeadLetterUnknown is returned when a dead letter does not exist.
+var ErrDeadLetterUnknown = errors.New("dead letter unknown")
+
+// deadLetterRoot is where dead letters are kept, under a directory per
+// endpoint:
+//
+//	<root>/<endpoint>/<event id>
+const deadLetterRoot = "/docker/registry/v2/notifications/deadletters"
+
+// DeadLetter is an event which could not be delivered to an endpoint.
+type DeadLetter struct {
+	// Event is the undelivered event.
+	Event Event `json:"event"`
+
+	// Endpoint is the name of the endpoint the event was meant for.
+	Endpoint string `json:"endpoint"`
+
+	// Error is the error of the last delivery attempt.
+	Error string `json:"error"`
+
+	// Attempts is the number of delivery attempts made.
+	Attempts int `json:"attempts"`
+
+	// Time is when the event was given up on.
+	Time time.Time `json:"time"`
+}
+
+// DeadLetterStore keeps dead letters in a storage driver.
+type DeadLetterStore struct {
+	driver storagedriver.StorageDriver
+}
+
+// NewDeadLetterStore returns a DeadLetterStore keeping dead letters in
+// driver.
+func NewDeadLetterStore(driver storagedriver.StorageDriver) *DeadLetterStore {
+	return &DeadLetterStore{driver: driver}
+}
+
+func deadLetterPath(endpoint, id string) string {
+	return path.Join(deadLetterRoot, endpoint, id)
+}
+
+// Put stores a dead letter, replacing any earlier dead letter of the same
+// event.
+func (s *DeadLetterStore) Put(ctx context.Context, dl DeadLetter) error {
+	if dl.Event.ID == "" {
+		return errors.New("dead letter event has no id")
+	}
+	p, err := json.Marshal(dl)
+	if err != nil {
+		return err
+	}
+	return s.driver.PutContent(ctx, deadLetterPath(dl.Endpoint, dl.Event.ID), p)
+}
+
+// Get returns the dead letter of the event with the given id.
+func (s *DeadLetterStore) Get(ctx context.Context, endpoint, id string) (*DeadLetter, error) {
+	p, err := s.driver.GetContent(ctx, deadLetterPath(endpoint, id))
+	if err != nil {
+		if errors.As(err, &storagedriver.PathNotFoundError{}) {
+			return nil, ErrDeadLetterUnknown
+		}
+		return nil, err
+	}
+	var dl DeadLetter
+	if err := json.Unmarshal(p, &dl); err != nil {
+		return nil, err
+	}
+	return &dl, nil
+}
+
+// List returns the dead letters of endpoint, oldest first.
+func (s *DeadLetterStore) List(ctx context.Context, endpoint string) ([]DeadLetter, error) {
+	paths, err := s.driver.List(ctx, path.Join(deadLetterRoot, endpoint))
+	if err != nil {
+		if errors.As(err, &storagedriver.PathNotFoundError{}) {
+			return []DeadLetter{}, nil
+		}
+		return nil, err
+	}
+
+	dls := make([]DeadLetter, 0, len(paths))
+	for _, p := range paths {
+		dl, err := s.Get(ctx, endpoint, path.Base(p))
+		if err != nil {
+			if errors.Is(err, ErrDeadLetterUnknown) {
+				// Replayed while listing.
+				continue
+			}
+			return nil, err
+		}
+		dls = append(dls, *dl)
+	}
+	sort.Slice(dls, func(i, j int) bool {
+		return dls[i].Time.Before(dls[j].Time)
+	})
+	return dls, nil
+}
+
+// Delete removes the dead letter of the event with the given id.
+func (s *DeadLetterStore) Delete(ctx context.Context, endpoint, id string) error {
+	if err := s.driver.Delete(ctx, deadLetterPath(endpoint, id)); err != nil {
+		if errors.As(err, &storagedriver.PathNotFoundError{}) {
+			return ErrDeadLetterUnknown
+		}
+		return err
+	}
+	return nil
+}
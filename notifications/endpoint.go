@@ -18,6 +18,29 @@ type EndpointConfig struct {
 	IgnoredMediaTypes []string
 	Transport         *http.Transport `json:"-"`
 	Ignore            configuration.Ignore
+	Filter            *Filter `json:"-"`
+
+	// Delivery, if set, delivers events in place of posting them to the
+	// endpoint's url, for endpoints which are not http webhooks.
+	Delivery events.Sink `json:"-"`
+
+	// Retry, if set, retries with exponential backoff in place of the
+	// Threshold and Backoff circuit breaker and limits how long each event
+	// is retried.
+	Retry configuration.Retry
+
+	// DeadLetters, if set, keeps the events given up on under Retry.
+	DeadLetters *DeadLetterStore `json:"-"`
+
+	// MaxBatchSize, when above one, has up to MaxBatchSize events posted in
+	// one envelope, waiting up to MaxBatchDelay for the envelope to fill.
+	// Batches are retried and dead-lettered as a whole. Only http endpoints
+	// batch events.
+	MaxBatchSize  int
+	MaxBatchDelay time.Duration
+
+	// Gzip compresses the requests of http endpoints.
+	Gzip bool
 }
 
 // defaults set any zero-valued fields to a reasonable default.
@@ -50,6 +73,8 @@ type Endpoint struct {
 	EndpointConfig
 
 	metrics *safeMetrics
+	queue   *eventQueue
+	circuit *circuitStrategy
 }
 
 // NewEndpoint returns a running endpoint, ready to receive events.
@@ -62,18 +87,39 @@ func NewEndpoint(name, url string, config EndpointConfig) *Endpoint {
 	endpoint.metrics = newSafeMetrics(name)
 
 	// Configures the inmemory queue, retry, http pipeline.
-	endpoint.Sink = newHTTPSink(
-		endpoint.url, endpoint.Timeout, endpoint.Headers,
-		endpoint.Transport, endpoint.metrics.httpStatusListener())
-	endpoint.Sink = events.NewRetryingSink(endpoint.Sink, events.NewBreaker(endpoint.Threshold, endpoint.Backoff))
-	endpoint.Sink = newEventQueue(endpoint.Sink, endpoint.metrics.eventQueueListener())
+	maxBatch := 1
+	if config.Delivery != nil {
+		endpoint.Sink = newDeliverySink(config.Delivery, endpoint.metrics.deliveryListener())
+	} else {
+		hs := newHTTPSink(
+			endpoint.url, endpoint.Timeout, endpoint.Headers,
+			endpoint.Transport, endpoint.metrics.httpStatusListener())
+		hs.gzip = endpoint.Gzip
+		endpoint.Sink = hs
+		if endpoint.MaxBatchSize > 1 {
+			maxBatch = endpoint.MaxBatchSize
+		}
+	}
+	endpoint.circuit = &circuitStrategy{RetryStrategy: endpoint.retryStrategy()}
+	endpoint.Sink = events.NewRetryingSink(endpoint.Sink, endpoint.circuit)
+	endpoint.queue = newBatchingEventQueue(endpoint.Sink, maxBatch, endpoint.MaxBatchDelay, endpoint.metrics.eventQueueListener())
+	endpoint.Sink = endpoint.queue
 	mediaTypes := append(config.Ignore.MediaTypes, config.IgnoredMediaTypes...)
 	endpoint.Sink = newIgnoredSink(endpoint.Sink, mediaTypes, config.Ignore.Actions)
+	endpoint.Sink = newFilteredSink(endpoint.Sink, config.Filter)
 
 	register(&endpoint)
 	return &endpoint
 }
 
+// retryStrategy returns the strategy retrying failed deliveries.
+func (e *Endpoint) retryStrategy() events.RetryStrategy {
+	if e.Retry == (configuration.Retry{}) {
+		return events.NewBreaker(e.Threshold, e.Backoff)
+	}
+	return newBackoffStrategy(e.name, e.Retry, e.DeadLetters)
+}
+
 // Name returns the name of the endpoint, generally used for debugging.
 func (e *Endpoint) Name() string {
 	return e.name
@@ -84,6 +130,28 @@ func (e *Endpoint) URL() string {
 	return e.url
 }
 
+// Pause stops delivering events to the endpoint. Events keep being queued
+// and are delivered once the endpoint is resumed.
+func (e *Endpoint) Pause() {
+	e.queue.setPaused(true)
+}
+
+// Resume restarts the delivery of events paused with Pause.
+func (e *Endpoint) Resume() {
+	e.queue.setPaused(false)
+}
+
+// Paused reports whether the endpoint is paused.
+func (e *Endpoint) Paused() bool {
+	return e.queue.isPaused()
+}
+
+// CircuitOpen reports whether the endpoint is failing: its last delivery
+// attempt failed and it is backing off or retrying.
+func (e *Endpoint) CircuitOpen() bool {
+	return e.circuit.isOpen()
+}
+
 // ReadMetrics populates em with metrics from the endpoint.
 func (e *Endpoint) ReadMetrics(em *EndpointMetrics) {
 	e.metrics.Lock()
@@ -0,0 +1,93 @@
+package notifications
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/configuration"
+	events "github.com/docker/go-events"
+)
+
+func TestEndpointPause(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		delivered []events.Event
+		fail      = true
+	)
+	delivery := testSinkFn(func(event events.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			return errors.New("unavailable")
+		}
+		delivered = append(delivered, event)
+		return nil
+	})
+	endpoint := NewEndpoint("pause", "test:", EndpointConfig{
+		Delivery: delivery,
+		Retry:    configuration.Retry{InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond},
+	})
+	defer endpoint.Close()
+
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	pending := func() int {
+		var metrics EndpointMetrics
+		endpoint.ReadMetrics(&metrics)
+		return metrics.Pending
+	}
+
+	// The circuit opens while deliveries fail.
+	if err := endpoint.Write(createTestEvent("push", "foo/bar", "manifest")); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the circuit to open", endpoint.CircuitOpen)
+
+	endpoint.Pause()
+	if !endpoint.Paused() {
+		t.Fatal("expected endpoint to be paused")
+	}
+	if err := endpoint.Write(createTestEvent("push", "foo/baz", "manifest")); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+
+	// The event being retried is delivered, the queued one is held.
+	waitFor("the retried event", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(delivered) == 1
+	})
+	if endpoint.CircuitOpen() {
+		t.Fatal("expected the circuit to close after a delivery")
+	}
+	time.Sleep(10 * time.Millisecond)
+	if p := pending(); p != 1 {
+		t.Fatalf("expected 1 pending event while paused, got %d", p)
+	}
+
+	endpoint.Resume()
+	waitFor("the queued event", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(delivered) == 2
+	})
+
+	var metrics EndpointMetrics
+	endpoint.ReadMetrics(&metrics)
+	if metrics.LastError != "unavailable" || metrics.LastErrorTime.IsZero() || metrics.LastSuccess.IsZero() {
+		t.Fatalf("expected last error and success to be recorded, got %+v", metrics)
+	}
+}
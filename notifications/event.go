@@ -5,6 +5,7 @@ import (
 	"time"
 
 	events "github.com/docker/go-events"
+	"github.com/opencontainers/go-digest"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
@@ -14,6 +15,24 @@ const (
 	EventActionPush   = "push"
 	EventActionMount  = "mount"
 	EventActionDelete = "delete"
+
+	// EventActionMove is the action of a tag re-pointed to another manifest.
+	EventActionMove = "move"
+	// EventActionUpload is the action of a blob upload being started.
+	EventActionUpload = "upload"
+	// EventActionCancel is the action of a blob upload cancelled by the
+	// client.
+	EventActionCancel = "cancel"
+	// EventActionPurge is the action of an abandoned blob upload removed by
+	// the upload purger.
+	EventActionPurge = "purge"
+	// EventActionSweep is the action of content removed by the garbage
+	// collector.
+	EventActionSweep = "sweep"
+	// EventActionCopy is the action of a repository copied to another name.
+	EventActionCopy = "copy"
+	// EventActionRename is the action of a repository renamed.
+	EventActionRename = "rename"
 )
 
 const (
@@ -64,7 +83,8 @@ type Event struct {
 		Repository string `json:"repository,omitempty"`
 
 		// FromRepository identifies the named repository which a blob was mounted
-		// from if appropriate.
+		// from, or which a repository was copied or renamed from, if
+		// appropriate.
 		FromRepository string `json:"fromRepository,omitempty"`
 
 		// URL provides a direct link to the content.
@@ -73,6 +93,14 @@ type Event struct {
 		// Tag provides the tag
 		Tag string `json:"tag,omitempty"`
 
+		// PreviousDigest identifies the manifest a moved tag pointed to
+		// before.
+		PreviousDigest digest.Digest `json:"previousDigest,omitempty"`
+
+		// UploadID identifies the blob upload of upload, cancel and purge
+		// events.
+		UploadID string `json:"uploadID,omitempty"`
+
 		// References provides the references descriptors.
 		References []v1.Descriptor `json:"references,omitempty"`
 	} `json:"target,omitempty"`
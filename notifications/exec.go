@@ -0,0 +1,86 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	events "github.com/docker/go-events"
+)
+
+// defaultExecTimeout bounds commands when the endpoint sets no timeout.
+// Starting a process is slower than a request, so it is longer than the
+// default of http endpoints.
+const defaultExecTimeout = 10 * time.Second
+
+// execSink runs a local command for every event, writing the event envelope
+// to its standard input. Like the http sink, it makes a single attempt and
+// reliability should be provided by the caller.
+type execSink struct {
+	command []string
+	timeout time.Duration
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewExecSink returns a sink running command for every event. The command is
+// killed if it runs for longer than timeout, or defaultExecTimeout if timeout
+// is not positive, and the event fails if the command exits with a non-zero
+// status.
+func NewExecSink(command []string, timeout time.Duration) (events.Sink, error) {
+	if len(command) == 0 || command[0] == "" {
+		return nil, fmt.Errorf("exec sink requires a command")
+	}
+	if timeout <= 0 {
+		timeout = defaultExecTimeout
+	}
+	return &execSink{command: command, timeout: timeout}, nil
+}
+
+// Write runs the command with the envelope of the event on its standard
+// input.
+func (es *execSink) Write(event events.Event) error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	if es.closed {
+		return ErrSinkClosed
+	}
+
+	p, err := json.Marshal(Envelope{Events: []events.Event{event}})
+	if err != nil {
+		return fmt.Errorf("%v: error marshaling event envelope: %v", es, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), es.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, es.command[0], es.command[1:]...)
+	cmd.Stdin = bytes.NewReader(p)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %v: %s", es, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// Close the sink. Commands which are already running are not interrupted.
+func (es *execSink) Close() error {
+	es.mu.Lock()
+	defer es.mu.Unlock()
+
+	if es.closed {
+		return fmt.Errorf("execsink: already closed")
+	}
+	es.closed = true
+	return nil
+}
+
+func (es *execSink) String() string {
+	return fmt.Sprintf("execSink{%s}", strings.Join(es.command, " "))
+}
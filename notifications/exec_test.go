@@ -0,0 +1,64 @@
+package notifications
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExecSink(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "envelope.json")
+
+	sink, err := NewExecSink([]string{"sh", "-c", `cat > "$0"`, out}, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := createTestEvent("push", "library/test", "manifest")
+	if err := sink.Write(event); err != nil {
+		t.Fatalf("error writing event: %v", err)
+	}
+
+	p, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var envelope struct {
+		Events []Event `json:"events"`
+	}
+	if err := json.Unmarshal(p, &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if len(envelope.Events) != 1 || envelope.Events[0].ID != event.ID {
+		t.Fatalf("unexpected envelope %s", p)
+	}
+
+	failing, err := NewExecSink([]string{"sh", "-c", "echo rejected >&2; exit 3"}, 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := failing.Write(event); err == nil {
+		t.Fatal("expected a failing command to fail the event")
+	}
+
+	hanging, err := NewExecSink([]string{"sleep", "10"}, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := hanging.Write(event); err == nil {
+		t.Fatal("expected a command running past the timeout to fail the event")
+	}
+
+	unbounded, err := NewExecSink([]string{"true"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timeout := unbounded.(*execSink).timeout; timeout != defaultExecTimeout {
+		t.Fatalf("expected the default timeout %v, got %v", defaultExecTimeout, timeout)
+	}
+
+	if _, err := NewExecSink(nil, time.Second); err == nil {
+		t.Fatal("expected an empty command to be rejected")
+	}
+}
@@ -0,0 +1,132 @@
+package notifications
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	events "github.com/docker/go-events"
+)
+
+// fileSink appends events to a file as newline-delimited JSON, one event per
+// line. Once the file would grow past maxSize it is rotated: the file is
+// renamed to path.1, path.1 to path.2 and so on, keeping at most maxBackups
+// rotated files.
+type fileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	closed bool
+}
+
+// NewFileSink returns a sink appending events to the file at path, rotating
+// it once it reaches maxSize bytes. A maxSize of zero disables rotation.
+func NewFileSink(path string, maxSize int64, maxBackups int) (events.Sink, error) {
+	if path == "" {
+		return nil, fmt.Errorf("file sink requires a path")
+	}
+	if maxSize < 0 || maxBackups < 0 {
+		return nil, fmt.Errorf("file sink requires a non-negative maxsize and maxbackups")
+	}
+
+	fs := &fileSink{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := fs.open(); err != nil {
+		return nil, err
+	}
+	return fs, nil
+}
+
+func (fs *fileSink) open() error {
+	f, err := os.OpenFile(fs.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	fs.f = f
+	fs.size = fi.Size()
+	return nil
+}
+
+// Write appends the event to the file, rotating it first if the event would
+// take it past its maximum size.
+func (fs *fileSink) Write(event events.Event) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.closed {
+		return ErrSinkClosed
+	}
+
+	p, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%v: error marshaling event: %v", fs, err)
+	}
+	p = append(p, '\n')
+
+	if fs.maxSize > 0 && fs.size > 0 && fs.size+int64(len(p)) > fs.maxSize {
+		if err := fs.rotate(); err != nil {
+			return fmt.Errorf("%v: error rotating file: %v", fs, err)
+		}
+	}
+
+	n, err := fs.f.Write(p)
+	fs.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("%v: error writing event: %v", fs, err)
+	}
+	return nil
+}
+
+// rotate moves the current file aside and opens a new one.
+func (fs *fileSink) rotate() error {
+	if err := fs.f.Close(); err != nil {
+		return err
+	}
+
+	if fs.maxBackups == 0 {
+		if err := os.Remove(fs.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return fs.open()
+	}
+
+	for i := fs.maxBackups - 1; i > 0; i-- {
+		err := os.Rename(fmt.Sprintf("%s.%d", fs.path, i), fmt.Sprintf("%s.%d", fs.path, i+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(fs.path, fs.path+".1"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return fs.open()
+}
+
+// Close closes the file.
+func (fs *fileSink) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.closed {
+		return fmt.Errorf("filesink: already closed")
+	}
+	fs.closed = true
+	return fs.f.Close()
+}
+
+func (fs *fileSink) String() string {
+	return fmt.Sprintf("fileSink{%s}", fs.path)
+}
@@ -0,0 +1,80 @@
+package notifications
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+
+	event := createTestEvent("push", "library/test", "manifest")
+	line, err := json.Marshal(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lineSize := int64(len(line) + 1)
+
+	// Room for two events per file, keeping one rotated file.
+	sink, err := NewFileSink(path, 2*lineSize, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := sink.Write(event); err != nil {
+			t.Fatalf("error writing event: %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write(event); err != ErrSinkClosed {
+		t.Fatalf("expected ErrSinkClosed, got %v", err)
+	}
+
+	for _, tc := range []struct {
+		path  string
+		lines int
+	}{
+		{path, 1},
+		{path + ".1", 2},
+	} {
+		f, err := os.Open(tc.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+
+		lines := 0
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var e Event
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+				t.Fatalf("%s: invalid line %q: %v", tc.path, scanner.Text(), err)
+			}
+			if e.ID != event.ID {
+				t.Fatalf("%s: unexpected event %+v", tc.path, e)
+			}
+			lines++
+		}
+		if lines != tc.lines {
+			t.Fatalf("%s: expected %d events, got %d", tc.path, tc.lines, lines)
+		}
+	}
+	if _, err := os.Stat(path + ".2"); !os.IsNotExist(err) {
+		t.Fatalf("expected a single rotated file, stat returned %v", err)
+	}
+
+	// Reopening appends to the existing file.
+	sink, err = NewFileSink(path, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	if fs := sink.(*fileSink); fs.size != lineSize {
+		t.Fatalf("expected size %d after reopening, got %d", lineSize, fs.size)
+	}
+}
@@ -0,0 +1,94 @@
+package notifications
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/internal/glob"
+)
+
+// Filter selects events by repository and tag.
+type Filter struct {
+	repositories patterns
+	tags         patterns
+}
+
+// NewFilter compiles a filter from its configuration. It returns nil if the
+// configuration filters nothing.
+func NewFilter(config configuration.Filter) (*Filter, error) {
+	repositories, err := compilePatterns(config.Repositories, glob.Compile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository filter: %v", err)
+	}
+	tags, err := compilePatterns(config.Tags, regexp.Compile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag filter: %v", err)
+	}
+	if repositories.empty() && tags.empty() {
+		return nil, nil
+	}
+	return &Filter{repositories: repositories, tags: tags}, nil
+}
+
+// Match returns whether the event passes the filter. Tag filters only apply
+// to events carrying a tag.
+func (f *Filter) Match(event Event) bool {
+	if !f.repositories.match(event.Target.Repository) {
+		return false
+	}
+	if event.Target.Tag != "" && !f.tags.match(event.Target.Tag) {
+		return false
+	}
+	return true
+}
+
+// patterns are compiled include and exclude rules.
+type patterns struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+func compilePatterns(rules configuration.FilterRules, compile func(string) (*regexp.Regexp, error)) (patterns, error) {
+	var p patterns
+	for _, pattern := range rules.Include {
+		re, err := compile(pattern)
+		if err != nil {
+			return patterns{}, err
+		}
+		p.include = append(p.include, re)
+	}
+	for _, pattern := range rules.Exclude {
+		re, err := compile(pattern)
+		if err != nil {
+			return patterns{}, err
+		}
+		p.exclude = append(p.exclude, re)
+	}
+	return p, nil
+}
+
+func (p patterns) empty() bool {
+	return len(p.include) == 0 && len(p.exclude) == 0
+}
+
+// match returns whether s matches an include pattern, or there are none, and
+// matches no exclude pattern.
+func (p patterns) match(s string) bool {
+	included := len(p.include) == 0
+	for _, re := range p.include {
+		if re.MatchString(s) {
+			included = true
+			break
+		}
+	}
+	if !included {
+		return false
+	}
+	for _, re := range p.exclude {
+		if re.MatchString(s) {
+			return false
+		}
+	}
+	return true
+}
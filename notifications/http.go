@@ -2,6 +2,7 @@ package notifications
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -15,7 +16,8 @@ import (
 // very lightweight in that it only makes an attempt at an http request.
 // Reliability should be provided by the caller.
 type httpSink struct {
-	url string
+	url  string
+	gzip bool // compress request bodies
 
 	mu        sync.Mutex
 	closed    bool
@@ -65,7 +67,7 @@ func (hs *httpSink) Write(event events.Event) error {
 	}
 
 	envelope := Envelope{
-		Events: []events.Event{event},
+		Events: batchEvents(event),
 	}
 
 	// TODO(stevvooe): It is not ideal to keep re-encoding the request body on
@@ -80,8 +82,15 @@ func (hs *httpSink) Write(event events.Event) error {
 		return fmt.Errorf("%v: error marshaling event envelope: %v", hs, err)
 	}
 
-	body := bytes.NewReader(p)
-	resp, err := hs.client.Post(hs.url, EventsMediaType, body)
+	req, err := hs.newRequest(p)
+	if err != nil {
+		for _, listener := range hs.listeners {
+			listener.err(err, event)
+		}
+		return fmt.Errorf("%v: error creating request: %v", hs, err)
+	}
+
+	resp, err := hs.client.Do(req)
 	if err != nil {
 		for _, listener := range hs.listeners {
 			listener.err(err, event)
@@ -112,6 +121,32 @@ func (hs *httpSink) Write(event events.Event) error {
 	}
 }
 
+// newRequest returns the request posting the encoded envelope p.
+func (hs *httpSink) newRequest(p []byte) (*http.Request, error) {
+	var body bytes.Buffer
+	if hs.gzip {
+		zw := gzip.NewWriter(&body)
+		if _, err := zw.Write(p); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+	} else {
+		body.Write(p)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, hs.url, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", EventsMediaType)
+	if hs.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	return req, nil
+}
+
 // Close the endpoint
 func (hs *httpSink) Close() error {
 	hs.mu.Lock()
@@ -1,6 +1,7 @@
 package notifications
 
 import (
+	"compress/gzip"
 	"crypto/tls"
 	"encoding/json"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/distribution/distribution/v3/manifest/schema2"
 	events "github.com/docker/go-events"
@@ -184,8 +186,17 @@ func TestHTTPSink(t *testing.T) {
 			t.Logf("write error: %v", err)
 		}
 
-		if !reflect.DeepEqual(metrics.EndpointMetrics, expectedMetrics) {
-			t.Fatalf("metrics not as expected: %#v != %#v", metrics.EndpointMetrics, expectedMetrics)
+		actual := metrics.EndpointMetrics
+		if tc.isFailure || tc.isError {
+			if actual.LastError == "" || actual.LastErrorTime.IsZero() {
+				t.Fatalf("expected the last error to be recorded: %#v", actual)
+			}
+		} else if actual.LastSuccess.IsZero() {
+			t.Fatalf("expected the last success to be recorded: %#v", actual)
+		}
+		actual.LastError, actual.LastErrorTime, actual.LastSuccess = "", time.Time{}, time.Time{}
+		if !reflect.DeepEqual(actual, expectedMetrics) {
+			t.Fatalf("metrics not as expected: %#v != %#v", actual, expectedMetrics)
 		}
 	}
 
@@ -199,6 +210,57 @@ func TestHTTPSink(t *testing.T) {
 	}
 }
 
+// TestHTTPSinkBatchGzip checks that a batch is posted as one compressed
+// envelope.
+func TestHTTPSinkBatchGzip(t *testing.T) {
+	received := make(chan []Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("unexpected content encoding: %q", r.Header.Get("Content-Encoding"))
+		}
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("error reading compressed body: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var envelope struct {
+			Events []Event `json:"events"`
+		}
+		if err := json.NewDecoder(zr).Decode(&envelope); err != nil {
+			t.Errorf("error decoding request body: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- envelope.Events
+	}))
+	defer server.Close()
+
+	metrics := newSafeMetrics("")
+	sink := newHTTPSink(server.URL, 0, nil, nil,
+		&endpointMetricsHTTPStatusListener{safeMetrics: metrics})
+	sink.gzip = true
+
+	batch := eventBatch{
+		createTestEvent("push", "library/test", "blob"),
+		createTestEvent("pull", "library/test", "manifest"),
+	}
+	if err := sink.Write(batch); err != nil {
+		t.Fatalf("unexpected error writing batch: %v", err)
+	}
+
+	got := <-received
+	if len(got) != 2 || got[0].Action != "push" || got[1].Action != "pull" {
+		t.Fatalf("unexpected envelope: %+v", got)
+	}
+
+	metrics.Lock()
+	defer metrics.Unlock()
+	if metrics.Successes != 2 || metrics.Statuses["200 OK"] != 1 {
+		t.Fatalf("unexpected metrics: %+v", metrics.EndpointMetrics)
+	}
+}
+
 func createTestEvent(action, repo, typ string) Event {
 	event := createEvent(action)
 
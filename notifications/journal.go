@@ -0,0 +1,215 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distribution/distribution/v3/configuration"
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	events "github.com/docker/go-events"
+)
+
+// journalRoot is where the journal records events, by repository and by
+// hour:
+//
+//	<root>/<repository>/_events/<2006010215>/<unix nanoseconds>-<event id>
+//
+// The "_events" component cannot be taken for a repository path component.
+const (
+	journalRoot       = "/docker/registry/v2/notifications/journal"
+	journalEventsDir  = "_events"
+	journalHourFormat = "2006010215"
+)
+
+// MaxReplayRange is the longest time range the events of which Events
+// returns in one call.
+const MaxReplayRange = 7 * 24 * time.Hour
+
+// ErrReplayRange is returned by Events for a time range longer than
+// MaxReplayRange.
+var ErrReplayRange = fmt.Errorf("time range longer than %v", MaxReplayRange)
+
+// Journal records events in a storage driver so that the events of a
+// repository over a time range can be replayed. It is a sink, to be fed
+// every event of the registry.
+type Journal struct {
+	driver storagedriver.StorageDriver
+	maxAge time.Duration
+	now    func() time.Time
+
+	mu     sync.Mutex
+	hour   string              // the hour pruned holds repositories for
+	pruned map[string]struct{} // the repositories pruned in hour
+	closed bool
+}
+
+// NewJournal returns a journal recording events in driver. Events older than
+// maxAge are pruned, unless maxAge is zero.
+func NewJournal(driver storagedriver.StorageDriver, maxAge time.Duration) *Journal {
+	return &Journal{
+		driver: driver,
+		maxAge: maxAge,
+		now:    time.Now,
+		pruned: make(map[string]struct{}),
+	}
+}
+
+// NewJournalSink returns a sink queueing events for the journal, and leaving
+// out the ignored events.
+func NewJournalSink(journal *Journal, ignore configuration.Ignore) events.Sink {
+	sink := events.Sink(newEventQueue(journal))
+	return newIgnoredSink(sink, ignore.MediaTypes, ignore.Actions)
+}
+
+// Write records an event. Events other than notifications events, or without
+// a repository, are ignored.
+func (j *Journal) Write(event events.Event) error {
+	e, ok := event.(Event)
+	if !ok || e.Target.Repository == "" {
+		return nil
+	}
+
+	j.mu.Lock()
+	if j.closed {
+		j.mu.Unlock()
+		return ErrSinkClosed
+	}
+	j.mu.Unlock()
+
+	ts := e.Timestamp
+	if ts.IsZero() {
+		ts = j.now()
+	}
+	ts = ts.UTC()
+
+	p, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("%v: error marshaling event: %v", j, err)
+	}
+
+	ctx := context.Background()
+	dir := j.hourPath(e.Target.Repository, ts)
+	// The name only orders events; keep ids from adding path components.
+	name := fmt.Sprintf("%020d-%s", ts.UnixNano(), strings.ReplaceAll(e.ID, "/", "_"))
+	if err := j.driver.PutContent(ctx, path.Join(dir, name), p); err != nil {
+		return fmt.Errorf("%v: error recording event: %v", j, err)
+	}
+
+	j.prune(ctx, e.Target.Repository, ts.Format(journalHourFormat))
+	return nil
+}
+
+// prune removes the hours of a repository older than the maximum age, the
+// first time an event is recorded for the repository in a given hour.
+func (j *Journal) prune(ctx context.Context, repository, hour string) {
+	if j.maxAge <= 0 {
+		return
+	}
+
+	// Only the repositories of the current hour are remembered, so the set
+	// stays bounded by the repositories written to within an hour.
+	j.mu.Lock()
+	if hour != j.hour {
+		j.hour = hour
+		j.pruned = make(map[string]struct{})
+	}
+	_, done := j.pruned[repository]
+	j.pruned[repository] = struct{}{}
+	j.mu.Unlock()
+	if done {
+		return
+	}
+
+	base := path.Join(journalRoot, repository, journalEventsDir)
+	hours, err := j.driver.List(ctx, base)
+	if err != nil {
+		return
+	}
+	cutoff := j.now().Add(-j.maxAge)
+	for _, p := range hours {
+		t, err := time.Parse(journalHourFormat, path.Base(p))
+		if err != nil || !t.Add(time.Hour).Before(cutoff) {
+			continue
+		}
+		// Another instance may be pruning the same hour.
+		if err := j.driver.Delete(ctx, p); err != nil && !errors.As(err, &storagedriver.PathNotFoundError{}) {
+			return
+		}
+	}
+}
+
+// Events returns the events recorded for repository from the time from up to,
+// but not including, the time to, oldest first. The range may not be longer
+// than MaxReplayRange, so that a request cannot list storage without bound.
+func (j *Journal) Events(ctx context.Context, repository string, from, to time.Time) ([]Event, error) {
+	if to.Sub(from) > MaxReplayRange {
+		return nil, ErrReplayRange
+	}
+	var found []Event
+	from, to = from.UTC(), to.UTC()
+	for hour := from.Truncate(time.Hour); hour.Before(to); hour = hour.Add(time.Hour) {
+		dir := j.hourPath(repository, hour)
+		paths, err := j.driver.List(ctx, dir)
+		if err != nil {
+			if errors.As(err, &storagedriver.PathNotFoundError{}) {
+				continue
+			}
+			return nil, err
+		}
+		sort.Strings(paths)
+
+		for _, p := range paths {
+			stamp, _, _ := strings.Cut(path.Base(p), "-")
+			nanos, err := strconv.ParseInt(stamp, 10, 64)
+			if err != nil {
+				continue
+			}
+			if ts := time.Unix(0, nanos); ts.Before(from) || !ts.Before(to) {
+				continue
+			}
+
+			content, err := j.driver.GetContent(ctx, p)
+			if err != nil {
+				if errors.As(err, &storagedriver.PathNotFoundError{}) {
+					// Pruned while reading.
+					continue
+				}
+				return nil, err
+			}
+			var e Event
+			if err := json.Unmarshal(content, &e); err != nil {
+				return nil, fmt.Errorf("%v: error reading %s: %v", j, p, err)
+			}
+			found = append(found, e)
+		}
+	}
+	return found, nil
+}
+
+func (j *Journal) hourPath(repository string, t time.Time) string {
+	return path.Join(journalRoot, repository, journalEventsDir, t.UTC().Format(journalHourFormat))
+}
+
+// Close stops recording events.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.closed {
+		return fmt.Errorf("journal: already closed")
+	}
+	j.closed = true
+	return nil
+}
+
+func (j *Journal) String() string {
+	return "journal"
+}
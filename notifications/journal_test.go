@@ -0,0 +1,73 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+)
+
+func TestJournal(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	now := start
+	journal := NewJournal(inmemory.New(), 2*time.Hour)
+	journal.now = func() time.Time { return now }
+
+	write := func(id, repo string, ts time.Time) {
+		event := createTestEvent("push", repo, "manifest")
+		event.ID = id
+		event.Timestamp = ts
+		if err := journal.Write(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids := func(repo string, from, to time.Time) []string {
+		found, err := journal.Events(ctx, repo, from, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, e := range found {
+			ids = append(ids, e.ID)
+		}
+		return ids
+	}
+
+	write("a", "foo/bar", start)
+	write("b", "foo/bar", start.Add(40*time.Minute))
+	write("c", "foo/bar", start.Add(time.Minute))
+	write("other", "foo/baz", start.Add(time.Minute))
+
+	if got := ids("foo/bar", start, start.Add(time.Hour)); len(got) != 3 || got[0] != "a" || got[1] != "c" || got[2] != "b" {
+		t.Fatalf("expected events a, c and b in order, got %v", got)
+	}
+	// The range includes from and excludes to.
+	if got := ids("foo/bar", start.Add(time.Minute), start.Add(40*time.Minute)); len(got) != 1 || got[0] != "c" {
+		t.Fatalf("expected event c, got %v", got)
+	}
+	if got := ids("foo/bar", start.Add(-time.Hour), start.Add(-time.Minute)); len(got) != 0 {
+		t.Fatalf("expected no events, got %v", got)
+	}
+
+	// Writing into a new hour prunes the hours older than the maximum age.
+	now = start.Add(4 * time.Hour)
+	write("d", "foo/bar", now)
+	if got := ids("foo/bar", start.Add(-time.Hour), now.Add(time.Hour)); len(got) != 1 || got[0] != "d" {
+		t.Fatalf("expected old events to be pruned, got %v", got)
+	}
+	if got := ids("foo/baz", start, now); len(got) != 1 {
+		t.Fatalf("expected other repositories to be left alone, got %v", got)
+	}
+
+	if _, err := journal.Events(ctx, "foo/bar", now.Add(-MaxReplayRange-time.Hour), now); !errors.Is(err, ErrReplayRange) {
+		t.Fatalf("expected %v, got %v", ErrReplayRange, err)
+	}
+
+	// Only the repositories written to in the current hour are remembered.
+	if len(journal.pruned) != 1 {
+		t.Fatalf("expected one repository to be remembered, got %v", journal.pruned)
+	}
+}
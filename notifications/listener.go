@@ -34,6 +34,38 @@ type RepoListener interface {
 	RepoDeleted(repo reference.Named) error
 }
 
+// RepoCopyListener describes a listener that can respond to repositories
+// being copied or renamed. It is optional and checked for on the listener
+// the events are dispatched to.
+type RepoCopyListener interface {
+	RepoCopied(repo reference.Named, fromRepo reference.Named) error
+	RepoRenamed(repo reference.Named, fromRepo reference.Named) error
+}
+
+// TagMoveListener describes a listener that can respond to tags being moved
+// to another manifest. It is optional: the previous target of a tag is only
+// looked up, at the cost of a read per tag push, for listeners implementing
+// it.
+type TagMoveListener interface {
+	TagMoved(repo reference.Named, tag string, desc v1.Descriptor, previous digest.Digest) error
+}
+
+// UploadListener describes a listener that can respond to blob upload
+// events. It is optional and checked for on the listener given to Listen.
+type UploadListener interface {
+	BlobUploadStarted(repo reference.Named, id string) error
+	BlobUploadCancelled(repo reference.Named, id string) error
+	BlobUploadPurged(repo reference.Named, id string) error
+}
+
+// SweepListener describes a listener that can respond to the removals of the
+// garbage collector. It is optional and satisfies storage.GCListener.
+type SweepListener interface {
+	ManifestSwept(repo reference.Named, dgst digest.Digest) error
+	LayerSwept(repo reference.Named, dgst digest.Digest) error
+	BlobSwept(dgst digest.Digest) error
+}
+
 // Listener combines all repository events into a single interface.
 type Listener interface {
 	ManifestListener
@@ -127,6 +159,30 @@ func (msl *manifestServiceListener) Put(ctx context.Context, sm distribution.Man
 	return dgst, err
 }
 
+// Copy forwards to the manifest service, if it copies manifests, reporting
+// each manifest copied as pushed.
+func (msl *manifestServiceListener) Copy(ctx context.Context, from reference.Named, dgst digest.Digest, options ...distribution.ManifestServiceOption) ([]distribution.Manifest, error) {
+	mc, ok := msl.ManifestService.(distribution.ManifestCopier)
+	if !ok {
+		return nil, distribution.ErrUnsupported
+	}
+	copied, err := mc.Copy(ctx, from, dgst, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, sm := range copied {
+		var opts []distribution.ManifestServiceOption
+		if i == len(copied)-1 {
+			opts = options
+		}
+		if err := msl.parent.listener.ManifestPushed(msl.parent.Repository.Named(), sm, opts...); err != nil {
+			dcontext.GetLogger(ctx).Errorf("error dispatching manifest push to listener: %v", err)
+		}
+	}
+	return copied, nil
+}
+
 type blobServiceListener struct {
 	distribution.BlobStore
 	parent *repositoryListener
@@ -199,6 +255,11 @@ func (bsl *blobServiceListener) Create(ctx context.Context, options ...distribut
 		}
 		return nil, err
 	}
+	if ul, ok := bsl.parent.listener.(UploadListener); ok && err == nil {
+		if err := ul.BlobUploadStarted(bsl.parent.Repository.Named(), wr.ID()); err != nil {
+			dcontext.GetLogger(ctx).Errorf("error dispatching blob upload to listener: %v", err)
+		}
+	}
 	return bsl.decorateWriter(wr), err
 }
 
@@ -241,6 +302,17 @@ func (bwl *blobWriterListener) Commit(ctx context.Context, desc v1.Descriptor) (
 	return committed, err
 }
 
+func (bwl *blobWriterListener) Cancel(ctx context.Context) error {
+	err := bwl.BlobWriter.Cancel(ctx)
+	if ul, ok := bwl.parent.parent.listener.(UploadListener); ok && err == nil {
+		if err := ul.BlobUploadCancelled(bwl.parent.parent.Repository.Named(), bwl.ID()); err != nil {
+			dcontext.GetLogger(ctx).Errorf("error dispatching blob upload cancel to listener: %v", err)
+		}
+	}
+
+	return err
+}
+
 type tagServiceListener struct {
 	distribution.TagService
 	parent *repositoryListener
@@ -253,6 +325,49 @@ func (rl *repositoryListener) Tags(ctx context.Context) distribution.TagService
 	}
 }
 
+func (tagSL *tagServiceListener) Tag(ctx context.Context, tag string, desc v1.Descriptor) error {
+	tml, ok := tagSL.parent.listener.(TagMoveListener)
+	if !ok {
+		return tagSL.TagService.Tag(ctx, tag, desc)
+	}
+
+	// A tag pointing elsewhere is moved rather than created.
+	previous, err := tagSL.TagService.Get(ctx, tag)
+	if err != nil {
+		if _, ok := err.(distribution.ErrTagUnknown); !ok {
+			return err
+		}
+	}
+	if err := tagSL.TagService.Tag(ctx, tag, desc); err != nil {
+		return err
+	}
+	if previous.Digest == "" || previous.Digest == desc.Digest {
+		return nil
+	}
+	if err := tml.TagMoved(tagSL.parent.Repository.Named(), tag, desc, previous.Digest); err != nil {
+		dcontext.GetLogger(ctx).Errorf("error dispatching tag move to listener: %v", err)
+	}
+	return nil
+}
+
+// Page forwards to the tag service, if it pages tags.
+func (tagSL *tagServiceListener) Page(ctx context.Context, tags []string, last string) (int, error) {
+	tds, ok := tagSL.TagService.(distribution.TagDetailService)
+	if !ok {
+		return 0, distribution.ErrUnsupported
+	}
+	return tds.Page(ctx, tags, last)
+}
+
+// Details forwards to the tag service, if it details tags.
+func (tagSL *tagServiceListener) Details(ctx context.Context, tags []string) ([]distribution.TagDetail, error) {
+	tds, ok := tagSL.TagService.(distribution.TagDetailService)
+	if !ok {
+		return nil, distribution.ErrUnsupported
+	}
+	return tds.Details(ctx, tags)
+}
+
 func (tagSL *tagServiceListener) Untag(ctx context.Context, tag string) error {
 	if err := tagSL.TagService.Untag(ctx, tag); err != nil {
 		return err
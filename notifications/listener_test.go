@@ -47,12 +47,15 @@ func TestListener(t *testing.T) {
 	checkTestRepository(t, repository, remover)
 
 	expectedOps := map[string]int{
-		"manifest:push":   1,
+		"manifest:push":   2,
 		"manifest:pull":   1,
-		"manifest:delete": 1,
+		"manifest:delete": 2,
 		"layer:push":      3,
 		"layer:pull":      3,
 		"layer:delete":    3,
+		"upload:start":    4,
+		"upload:cancel":   1,
+		"tag:move":        1,
 		"tag:delete":      1,
 		"repo:delete":     1,
 	}
@@ -62,6 +65,102 @@ func TestListener(t *testing.T) {
 	}
 }
 
+// TestListenerOptional checks that the optional listener interfaces are only
+// dispatched to when implemented.
+func TestListenerOptional(t *testing.T) {
+	ctx := dcontext.Background()
+
+	registry, err := storage.NewRegistry(ctx, inmemory.New(), storage.EnableDelete)
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+	tl := &testListener{
+		ops: make(map[string]int),
+	}
+
+	repoRef, _ := reference.WithName("foo/bar")
+	repository, err := registry.Repository(ctx, repoRef)
+	if err != nil {
+		t.Fatalf("unexpected error getting repo: %v", err)
+	}
+	// Embedding only the Listener hides the optional methods of tl.
+	repository, remover := Listen(repository, registry.(distribution.RepositoryRemover), struct{ Listener }{tl})
+
+	checkTestRepository(t, repository, remover)
+
+	for _, op := range []string{"upload:start", "upload:cancel", "tag:move"} {
+		if n := tl.ops[op]; n != 0 {
+			t.Fatalf("unexpected %s count: %d", op, n)
+		}
+	}
+	if tl.ops["tag:delete"] != 1 {
+		t.Fatalf("unexpected tag:delete count: %d", tl.ops["tag:delete"])
+	}
+}
+
+func TestListenerManifestCopy(t *testing.T) {
+	ctx := dcontext.Background()
+
+	registry, err := storage.NewRegistry(ctx, inmemory.New())
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+
+	sourceRef, _ := reference.WithName("foo/source")
+	source, err := registry.Repository(ctx, sourceRef)
+	if err != nil {
+		t.Fatalf("unexpected error getting repo: %v", err)
+	}
+	config := []byte(`{"name": "foo"}`)
+	configDgst := digest.FromBytes(config)
+	if err := testutil.PushBlob(ctx, source, bytes.NewReader(config), configDgst); err != nil {
+		t.Fatal(err)
+	}
+	sm, err := schema2.FromStruct(schema2.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: schema2.MediaTypeManifest,
+		Config: v1.Descriptor{
+			MediaType: "foo/bar",
+			Digest:    configDgst,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sourceManifests, err := source.Manifests(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dgst, err := sourceManifests.Put(ctx, sm)
+	if err != nil {
+		t.Fatalf("unexpected error putting the manifest: %v", err)
+	}
+
+	tl := &testListener{
+		ops: make(map[string]int),
+	}
+	targetRef, _ := reference.WithName("foo/target")
+	target, err := registry.Repository(ctx, targetRef)
+	if err != nil {
+		t.Fatalf("unexpected error getting repo: %v", err)
+	}
+	target, _ = Listen(target, nil, tl)
+	manifests, err := target.Manifests(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manifests.(distribution.ManifestCopier).Copy(ctx, sourceRef, dgst); err != nil {
+		t.Fatalf("unexpected error copying the manifest: %v", err)
+	}
+
+	expectedOps := map[string]int{
+		"manifest:push": 1,
+	}
+	if !reflect.DeepEqual(tl.ops, expectedOps) {
+		t.Fatalf("counts do not match:\n%v\n !=\n%v", tl.ops, expectedOps)
+	}
+}
+
 type testListener struct {
 	ops map[string]int
 }
@@ -101,6 +200,11 @@ func (tl *testListener) BlobDeleted(repo reference.Named, d digest.Digest) error
 	return nil
 }
 
+func (tl *testListener) TagMoved(repo reference.Named, tag string, desc v1.Descriptor, previous digest.Digest) error {
+	tl.ops["tag:move"]++
+	return nil
+}
+
 func (tl *testListener) TagDeleted(repo reference.Named, tag string) error {
 	tl.ops["tag:delete"]++
 	return nil
@@ -111,6 +215,46 @@ func (tl *testListener) RepoDeleted(repo reference.Named) error {
 	return nil
 }
 
+func (tl *testListener) RepoCopied(repo reference.Named, fromRepo reference.Named) error {
+	tl.ops["repo:copy"]++
+	return nil
+}
+
+func (tl *testListener) RepoRenamed(repo reference.Named, fromRepo reference.Named) error {
+	tl.ops["repo:rename"]++
+	return nil
+}
+
+func (tl *testListener) BlobUploadStarted(repo reference.Named, id string) error {
+	tl.ops["upload:start"]++
+	return nil
+}
+
+func (tl *testListener) BlobUploadCancelled(repo reference.Named, id string) error {
+	tl.ops["upload:cancel"]++
+	return nil
+}
+
+func (tl *testListener) BlobUploadPurged(repo reference.Named, id string) error {
+	tl.ops["upload:purge"]++
+	return nil
+}
+
+func (tl *testListener) ManifestSwept(repo reference.Named, d digest.Digest) error {
+	tl.ops["manifest:sweep"]++
+	return nil
+}
+
+func (tl *testListener) LayerSwept(repo reference.Named, d digest.Digest) error {
+	tl.ops["layer:sweep"]++
+	return nil
+}
+
+func (tl *testListener) BlobSwept(d digest.Digest) error {
+	tl.ops["blob:sweep"]++
+	return nil
+}
+
 // checkTestRepository takes the registry through all of its operations,
 // carrying out generic checks.
 func checkTestRepository(t *testing.T, repository distribution.Repository, remover distribution.RepositoryRemover) {
@@ -132,6 +276,15 @@ func checkTestRepository(t *testing.T, repository distribution.Repository, remov
 
 	blobs := repository.Blobs(ctx)
 
+	// start an upload and give up on it
+	wr, err := blobs.Create(ctx)
+	if err != nil {
+		t.Fatalf("error creating upload: %v", err)
+	}
+	if err := wr.Cancel(ctx); err != nil {
+		t.Fatalf("error cancelling upload: %v", err)
+	}
+
 	// push config blob
 	if err := testutil.PushBlob(ctx, repository, configReader, configDgst); err != nil {
 		t.Fatal(err)
@@ -211,14 +364,35 @@ func checkTestRepository(t *testing.T, repository distribution.Repository, remov
 		t.Fatalf("unexpected error fetching manifest: %v", err)
 	}
 
+	// Re-pointing the tag to another manifest moves it.
+	other := m
+	other.Layers = m.Layers[:1]
+	osm, err := schema2.FromStruct(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherDgst, err := manifests.Put(ctx, osm)
+	if err != nil {
+		t.Fatalf("unexpected error putting the manifest: %v", err)
+	}
+	if err := repository.Tags(ctx).Tag(ctx, tag, v1.Descriptor{Digest: otherDgst}); err != nil {
+		t.Fatalf("unexpected error moving tag: %v", err)
+	}
+	// Tagging the same manifest again is not a move.
+	if err := repository.Tags(ctx).Tag(ctx, tag, v1.Descriptor{Digest: otherDgst}); err != nil {
+		t.Fatalf("unexpected error tagging manifest: %v", err)
+	}
+
 	err = repository.Tags(ctx).Untag(ctx, tag)
 	if err != nil {
 		t.Fatalf("unexpected error deleting tag: %v", err)
 	}
 
-	err = manifests.Delete(ctx, dgst)
-	if err != nil {
-		t.Fatalf("unexpected error deleting blob: %v", err)
+	for _, d := range []digest.Digest{dgst, otherDgst} {
+		err = manifests.Delete(ctx, d)
+		if err != nil {
+			t.Fatalf("unexpected error deleting manifest: %v", err)
+		}
 	}
 
 	for _, d := range blobDigests {
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"sync"
+	"time"
 
 	prometheus "github.com/distribution/distribution/v3/metrics"
 	events "github.com/docker/go-events"
@@ -81,6 +82,10 @@ type EndpointMetrics struct {
 	Failures  int            // total events failed
 	Errors    int            // total events errored
 	Statuses  map[string]int // status code histogram, per call event
+
+	LastError     string    // the last failure or error
+	LastErrorTime time.Time // when the last failure or error happened
+	LastSuccess   time.Time // when an event was last written successfully
 }
 
 // safeMetrics guards the metrics implementation with a lock and provides a
@@ -99,6 +104,12 @@ func newSafeMetrics(name string) *safeMetrics {
 	return &sm
 }
 
+// lastError records a failure or error. The lock must be held.
+func (sm *safeMetrics) lastError(msg string) {
+	sm.LastError = msg
+	sm.LastErrorTime = time.Now()
+}
+
 // httpStatusListener returns the listener for the http sink that updates the
 // relevant counters.
 func (sm *safeMetrics) httpStatusListener() httpStatusListener {
@@ -107,6 +118,14 @@ func (sm *safeMetrics) httpStatusListener() httpStatusListener {
 	}
 }
 
+// deliveryListener returns the listener for sinks other than http, which
+// report no status codes.
+func (sm *safeMetrics) deliveryListener() deliveryListener {
+	return &endpointMetricsDeliveryListener{
+		safeMetrics: sm,
+	}
+}
+
 // eventQueueListener returns a listener that maintains queue related counters.
 func (sm *safeMetrics) eventQueueListener() eventQueueListener {
 	return &endpointMetricsEventQueueListener{
@@ -125,29 +144,61 @@ var _ httpStatusListener = &endpointMetricsHTTPStatusListener{}
 func (emsl *endpointMetricsHTTPStatusListener) success(status int, event events.Event) {
 	emsl.safeMetrics.Lock()
 	defer emsl.safeMetrics.Unlock()
+	n := len(batchEvents(event))
 	emsl.Statuses[fmt.Sprintf("%d %s", status, http.StatusText(status))]++
-	emsl.Successes++
+	emsl.Successes += n
+	emsl.LastSuccess = time.Now()
 
 	statusCounter.WithValues(fmt.Sprintf("%d %s", status, http.StatusText(status)), emsl.EndpointName).Inc(1)
-	eventsCounter.WithValues("Successes", emsl.EndpointName).Inc(1)
+	eventsCounter.WithValues("Successes", emsl.EndpointName).Inc(float64(n))
 }
 
 func (emsl *endpointMetricsHTTPStatusListener) failure(status int, event events.Event) {
 	emsl.safeMetrics.Lock()
 	defer emsl.safeMetrics.Unlock()
+	n := len(batchEvents(event))
 	emsl.Statuses[fmt.Sprintf("%d %s", status, http.StatusText(status))]++
-	emsl.Failures++
+	emsl.Failures += n
+	emsl.lastError(fmt.Sprintf("%d %s", status, http.StatusText(status)))
 
 	statusCounter.WithValues(fmt.Sprintf("%d %s", status, http.StatusText(status)), emsl.EndpointName).Inc(1)
-	eventsCounter.WithValues("Failures", emsl.EndpointName).Inc(1)
+	eventsCounter.WithValues("Failures", emsl.EndpointName).Inc(float64(n))
 }
 
 func (emsl *endpointMetricsHTTPStatusListener) err(err error, event events.Event) {
 	emsl.safeMetrics.Lock()
 	defer emsl.safeMetrics.Unlock()
-	emsl.Errors++
+	n := len(batchEvents(event))
+	emsl.Errors += n
+	emsl.lastError(err.Error())
+
+	eventsCounter.WithValues("Errors", emsl.EndpointName).Inc(float64(n))
+}
+
+// endpointMetricsDeliveryListener increments counters related to sinks other
+// than http for the relevant events.
+type endpointMetricsDeliveryListener struct {
+	*safeMetrics
+}
+
+var _ deliveryListener = &endpointMetricsDeliveryListener{}
+
+func (emdl *endpointMetricsDeliveryListener) success(event events.Event) {
+	emdl.safeMetrics.Lock()
+	defer emdl.safeMetrics.Unlock()
+	emdl.Successes++
+	emdl.LastSuccess = time.Now()
+
+	eventsCounter.WithValues("Successes", emdl.EndpointName).Inc(1)
+}
+
+func (emdl *endpointMetricsDeliveryListener) err(err error, event events.Event) {
+	emdl.safeMetrics.Lock()
+	defer emdl.safeMetrics.Unlock()
+	emdl.Errors++
+	emdl.lastError(err.Error())
 
-	eventsCounter.WithValues("Errors", emsl.EndpointName).Inc(1)
+	eventsCounter.WithValues("Errors", emdl.EndpointName).Inc(1)
 }
 
 // endpointMetricsEventQueueListener maintains the incoming events counter and
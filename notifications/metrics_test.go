@@ -9,20 +9,19 @@ import (
 func TestMetricsExpvar(t *testing.T) {
 	endpointsVar := expvar.Get("registry").(*expvar.Map).Get("notifications").(*expvar.Map).Get("endpoints")
 
-	var v interface{}
-	if err := json.Unmarshal([]byte(endpointsVar.String()), &v); err != nil {
-		t.Fatalf("unexpected error unmarshaling endpoints: %v", err)
-	}
-	if v != nil {
-		t.Fatalf("expected nil, got %#v", v)
+	// Other tests may have registered endpoints already.
+	count := func() int {
+		var v []interface{}
+		if err := json.Unmarshal([]byte(endpointsVar.String()), &v); err != nil {
+			t.Fatalf("unexpected error unmarshaling endpoints: %v", err)
+		}
+		return len(v)
 	}
+	before := count()
 
 	NewEndpoint("x", "y", EndpointConfig{})
 
-	if err := json.Unmarshal([]byte(endpointsVar.String()), &v); err != nil {
-		t.Fatalf("unexpected error unmarshaling endpoints: %v", err)
-	}
-	if slice, ok := v.([]interface{}); !ok || len(slice) != 1 {
-		t.Logf("expected one-element []interface{}, got %#v", v)
+	if after := count(); after != before+1 {
+		t.Fatalf("expected %d endpoints, got %d", before+1, after)
 	}
 }
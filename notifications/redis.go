@@ -0,0 +1,103 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	events "github.com/docker/go-events"
+	"github.com/redis/go-redis/v9"
+)
+
+// defaultRedisTimeout bounds each write when the endpoint sets no timeout,
+// like the default of http endpoints.
+const defaultRedisTimeout = time.Second
+
+// redisStreamSink adds events to a Redis stream. Each entry carries the event
+// as JSON in its "event" field, along with its action and repository so that
+// consumers can select entries without decoding them.
+type redisStreamSink struct {
+	client  redis.UniversalClient
+	stream  string
+	maxLen  int64
+	timeout time.Duration
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// NewRedisStreamSink returns a sink adding events to stream. If maxLen is
+// positive, the stream is trimmed to approximately maxLen entries. The client
+// is shared and is not closed with the sink. Writes time out after timeout,
+// or defaultRedisTimeout if timeout is not positive.
+func NewRedisStreamSink(client redis.UniversalClient, stream string, maxLen int64, timeout time.Duration) (events.Sink, error) {
+	if client == nil {
+		return nil, fmt.Errorf("redis sink requires redis to be configured")
+	}
+	if stream == "" {
+		return nil, fmt.Errorf("redis sink requires a stream")
+	}
+	if timeout <= 0 {
+		timeout = defaultRedisTimeout
+	}
+	return &redisStreamSink{
+		client:  client,
+		stream:  stream,
+		maxLen:  maxLen,
+		timeout: timeout,
+	}, nil
+}
+
+// Write adds the event to the stream.
+func (rs *redisStreamSink) Write(event events.Event) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if rs.closed {
+		return ErrSinkClosed
+	}
+
+	p, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("%v: error marshaling event: %v", rs, err)
+	}
+	values := map[string]interface{}{"event": p}
+	if e, ok := event.(Event); ok {
+		values["action"] = e.Action
+		values["repository"] = e.Target.Repository
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rs.timeout)
+	defer cancel()
+
+	args := &redis.XAddArgs{
+		Stream: rs.stream,
+		Values: values,
+	}
+	if rs.maxLen > 0 {
+		args.MaxLen = rs.maxLen
+		args.Approx = true
+	}
+	if err := rs.client.XAdd(ctx, args).Err(); err != nil {
+		return fmt.Errorf("%v: error adding event: %v", rs, err)
+	}
+	return nil
+}
+
+// Close the sink.
+func (rs *redisStreamSink) Close() error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if rs.closed {
+		return fmt.Errorf("redissink: already closed")
+	}
+	rs.closed = true
+	return nil
+}
+
+func (rs *redisStreamSink) String() string {
+	return fmt.Sprintf("redisStreamSink{%s}", rs.stream)
+}
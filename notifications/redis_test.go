@@ -0,0 +1,54 @@
+package notifications
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TestRedisStreamSink exercises a live redis instance.
+func TestRedisStreamSink(t *testing.T) {
+	redisAddr := os.Getenv("TEST_REGISTRY_NOTIFICATIONS_REDIS_ADDR")
+	if redisAddr == "" {
+		t.Skip("please set TEST_REGISTRY_NOTIFICATIONS_REDIS_ADDR to test the redis stream sink")
+	}
+
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: redisAddr})
+	defer client.Close()
+	if err := client.Del(ctx, "test:events").Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	sink, err := NewRedisStreamSink(client, "test:events", 100, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := createTestEvent("push", "library/test", "manifest")
+	if err := sink.Write(event); err != nil {
+		t.Fatalf("error writing event: %v", err)
+	}
+
+	entries, err := client.XRange(ctx, "test:events", "-", "+").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected one entry, got %d", len(entries))
+	}
+	values := entries[0].Values
+	if values["action"] != "push" || values["repository"] != "library/test" {
+		t.Fatalf("unexpected entry %v", values)
+	}
+	var e Event
+	if err := json.Unmarshal([]byte(values["event"].(string)), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.ID != event.ID {
+		t.Fatalf("unexpected event %+v", e)
+	}
+}
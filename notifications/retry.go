@@ -0,0 +1,196 @@
+package notifications
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	events "github.com/docker/go-events"
+)
+
+// defaultMaxBackoff bounds the backoff of an endpoint retrying with
+// exponential backoff when no maximum is configured.
+const defaultMaxBackoff = 5 * time.Minute
+
+// backoffStrategy is an events.RetryStrategy backing off exponentially, with
+// jitter, while an endpoint keeps failing. Unlike events.Breaker, it gives up
+// on an event once it has been attempted too many times or has become too
+// old, handing it to a dead-letter store if one is configured.
+type backoffStrategy struct {
+	endpoint    string
+	initial     time.Duration
+	max         time.Duration
+	maxAttempts int
+	maxAge      time.Duration
+	deadLetters *DeadLetterStore
+
+	mu       sync.Mutex
+	failures int            // consecutive failures of the endpoint
+	attempts map[string]int // failed attempts of each event
+	now      func() time.Time
+	jitter   func(time.Duration) time.Duration
+}
+
+// newBackoffStrategy returns a retry strategy for the named endpoint.
+// deadLetters may be nil, in which case events given up on are dropped.
+func newBackoffStrategy(endpoint string, config configuration.Retry, deadLetters *DeadLetterStore) *backoffStrategy {
+	bs := &backoffStrategy{
+		endpoint:    endpoint,
+		initial:     config.InitialBackoff,
+		max:         config.MaxBackoff,
+		maxAttempts: config.MaxAttempts,
+		maxAge:      config.MaxAge,
+		deadLetters: deadLetters,
+		attempts:    make(map[string]int),
+		now:         time.Now,
+		jitter: func(d time.Duration) time.Duration {
+			return time.Duration(rand.Int63n(int64(d) + 1))
+		},
+	}
+	if bs.initial <= 0 {
+		bs.initial = time.Second
+	}
+	if bs.max <= 0 {
+		bs.max = defaultMaxBackoff
+	}
+	if bs.max < bs.initial {
+		bs.max = bs.initial
+	}
+	return bs
+}
+
+// Proceed returns the backoff before the next attempt: nothing while the
+// endpoint is healthy, then initial, doubling on every consecutive failure
+// up to max. Half of the backoff is randomized so that registry instances
+// do not retry in lockstep.
+func (bs *backoffStrategy) Proceed(event events.Event) time.Duration {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	if bs.failures == 0 {
+		return 0
+	}
+
+	d := bs.initial
+	for i := 1; i < bs.failures && d < bs.max; i++ {
+		d *= 2
+	}
+	if d > bs.max {
+		d = bs.max
+	}
+	return d/2 + bs.jitter(d/2)
+}
+
+// Failure records a failed attempt and reports whether the event should be
+// given up on. A batch of events is given up on as a whole, by the attempts
+// and age of its first, oldest, event.
+func (bs *backoffStrategy) Failure(event events.Event, err error) bool {
+	bs.mu.Lock()
+	bs.failures++
+
+	var batch []Event
+	for _, event := range batchEvents(event) {
+		if e, ok := event.(Event); ok {
+			batch = append(batch, e)
+		}
+	}
+	if len(batch) == 0 {
+		// Not one of ours; keep retrying it like the breaker would.
+		bs.mu.Unlock()
+		return false
+	}
+
+	e := batch[0]
+	bs.attempts[e.ID]++
+	attempts := bs.attempts[e.ID]
+	expired := bs.maxAge > 0 && !e.Timestamp.IsZero() && bs.now().Sub(e.Timestamp) > bs.maxAge
+	if !expired && (bs.maxAttempts <= 0 || attempts < bs.maxAttempts) {
+		bs.mu.Unlock()
+		return false
+	}
+	delete(bs.attempts, e.ID)
+	bs.mu.Unlock()
+
+	for _, e := range batch {
+		bs.deadLetter(e, err, attempts)
+	}
+	return true
+}
+
+// Success resets the backoff.
+func (bs *backoffStrategy) Success(event events.Event) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+
+	bs.failures = 0
+	if e, ok := batchEvents(event)[0].(Event); ok {
+		delete(bs.attempts, e.ID)
+	}
+}
+
+// deadLetter keeps an event given up on in the dead-letter store.
+func (bs *backoffStrategy) deadLetter(e Event, err error, attempts int) {
+	logger := dcontext.GetLoggerWithFields(context.Background(), map[interface{}]interface{}{
+		"endpoint": bs.endpoint,
+		"event.id": e.ID,
+		"attempts": attempts,
+	})
+	if bs.deadLetters == nil {
+		logger.WithError(err).Warn("giving up on event")
+		return
+	}
+
+	dl := DeadLetter{
+		Event:    e,
+		Endpoint: bs.endpoint,
+		Error:    err.Error(),
+		Attempts: attempts,
+		Time:     bs.now(),
+	}
+	if perr := bs.deadLetters.Put(context.Background(), dl); perr != nil {
+		logger.WithError(perr).Error("error storing dead letter, dropping event")
+		return
+	}
+	logger.WithError(err).Warn("giving up on event, stored as dead letter")
+}
+
+func (bs *backoffStrategy) String() string {
+	return "backoff{" + bs.endpoint + "}"
+}
+
+// circuitStrategy wraps the retry strategy of an endpoint to report whether
+// the endpoint is failing. The circuit opens when the strategy first backs
+// off and closes on the next successful delivery.
+type circuitStrategy struct {
+	events.RetryStrategy
+
+	mu   sync.Mutex
+	open bool
+}
+
+func (cs *circuitStrategy) Proceed(event events.Event) time.Duration {
+	d := cs.RetryStrategy.Proceed(event)
+	if d > 0 {
+		cs.mu.Lock()
+		cs.open = true
+		cs.mu.Unlock()
+	}
+	return d
+}
+
+func (cs *circuitStrategy) Success(event events.Event) {
+	cs.RetryStrategy.Success(event)
+
+	cs.mu.Lock()
+	cs.open = false
+	cs.mu.Unlock()
+}
+
+func (cs *circuitStrategy) isOpen() bool {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.open
+}
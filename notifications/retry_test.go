@@ -0,0 +1,143 @@
+package notifications
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	events "github.com/docker/go-events"
+)
+
+func TestBackoffStrategy(t *testing.T) {
+	bs := newBackoffStrategy("test", configuration.Retry{
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Second,
+	}, nil)
+	// Take the whole jittered half, so backoffs are at their maximum.
+	bs.jitter = func(d time.Duration) time.Duration { return d }
+
+	event := Event{ID: "event"}
+	if d := bs.Proceed(event); d != 0 {
+		t.Fatalf("unexpected backoff before any failure: %v", d)
+	}
+	for _, expected := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if bs.Failure(event, errors.New("failed")) {
+			t.Fatal("unexpected drop without limits")
+		}
+		if d := bs.Proceed(event); d != expected {
+			t.Fatalf("expected backoff of %v, got %v", expected, d)
+		}
+	}
+
+	bs.Success(event)
+	if d := bs.Proceed(event); d != 0 {
+		t.Fatalf("unexpected backoff after success: %v", d)
+	}
+	if len(bs.attempts) != 0 {
+		t.Fatalf("expected attempts to be forgotten, have %v", bs.attempts)
+	}
+}
+
+func TestBackoffStrategyDeadLetters(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	store := NewDeadLetterStore(inmemory.New())
+	bs := newBackoffStrategy("test", configuration.Retry{
+		InitialBackoff: time.Millisecond,
+		MaxAttempts:    3,
+		MaxAge:         time.Hour,
+	}, store)
+	bs.now = func() time.Time { return now }
+
+	// The event is given up on at its third attempt.
+	fresh := Event{ID: "fresh", Timestamp: now}
+	for i := 0; i < 2; i++ {
+		if bs.Failure(fresh, errors.New("failed")) {
+			t.Fatalf("unexpected drop after %d attempts", i+1)
+		}
+	}
+	if !bs.Failure(fresh, errors.New("failed")) {
+		t.Fatal("expected event to be dropped after 3 attempts")
+	}
+	dl, err := store.Get(ctx, "test", "fresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dl.Attempts != 3 || dl.Error != "failed" || dl.Endpoint != "test" || !dl.Time.Equal(now) {
+		t.Fatalf("unexpected dead letter: %+v", dl)
+	}
+
+	// Old events are given up on at once.
+	old := Event{ID: "old", Timestamp: now.Add(-2 * time.Hour)}
+	now = now.Add(time.Minute)
+	if !bs.Failure(old, errors.New("failed")) {
+		t.Fatal("expected old event to be dropped")
+	}
+
+	dls, err := store.List(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dls) != 2 || dls[0].Event.ID != "fresh" || dls[1].Event.ID != "old" {
+		t.Fatalf("expected dead letters oldest first, got %+v", dls)
+	}
+
+	if err := store.Delete(ctx, "test", "fresh"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, "test", "fresh"); !errors.Is(err, ErrDeadLetterUnknown) {
+		t.Fatalf("expected unknown dead letter, got %v", err)
+	}
+	if err := store.Delete(ctx, "test", "fresh"); !errors.Is(err, ErrDeadLetterUnknown) {
+		t.Fatalf("expected unknown dead letter, got %v", err)
+	}
+	if dls, err := store.List(ctx, "empty"); err != nil || len(dls) != 0 {
+		t.Fatalf("expected no dead letters, got %v: %v", dls, err)
+	}
+}
+
+// TestRetryingSinkDeadLetters checks that a retrying sink gives up on an
+// event it cannot deliver rather than blocking the endpoint.
+func TestRetryingSinkDeadLetters(t *testing.T) {
+	store := NewDeadLetterStore(inmemory.New())
+	failing := testSinkFn(func(event events.Event) error {
+		return errors.New("unreachable")
+	})
+	sink := events.NewRetryingSink(failing, newBackoffStrategy("test", configuration.Retry{
+		InitialBackoff: time.Millisecond,
+		MaxAttempts:    2,
+	}, store))
+	defer sink.Close()
+
+	if err := sink.Write(Event{ID: "event"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(context.Background(), "test", "event"); err != nil {
+		t.Fatalf("expected dead letter: %v", err)
+	}
+}
+
+// TestBackoffStrategyBatch checks that a batch is given up on as a whole.
+func TestBackoffStrategyBatch(t *testing.T) {
+	store := NewDeadLetterStore(inmemory.New())
+	bs := newBackoffStrategy("test", configuration.Retry{MaxAttempts: 2}, store)
+
+	batch := eventBatch{Event{ID: "first"}, Event{ID: "second"}}
+	if bs.Failure(batch, errors.New("failed")) {
+		t.Fatal("unexpected drop after the first attempt")
+	}
+	if !bs.Failure(batch, errors.New("failed")) {
+		t.Fatal("expected batch to be dropped after 2 attempts")
+	}
+
+	dls, err := store.List(context.Background(), "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dls) != 2 || dls[0].Attempts != 2 || dls[1].Attempts != 2 {
+		t.Fatalf("expected every event of the batch to be dead-lettered, got %+v", dls)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"container/list"
 	"fmt"
 	"sync"
+	"time"
 
 	events "github.com/docker/go-events"
 	"github.com/sirupsen/logrus"
@@ -19,6 +20,24 @@ type eventQueue struct {
 	cond      *sync.Cond
 	mu        sync.Mutex
 	closed    bool
+	paused    bool
+
+	// maxBatch, when above one, has events written to the sink as batches
+	// of up to maxBatch events, waiting up to maxDelay for a batch to fill.
+	maxBatch int
+	maxDelay time.Duration
+}
+
+// eventBatch is written to the sink of a batching queue in place of a single
+// event. Sinks which do not know of batches should not be fed by one.
+type eventBatch []events.Event
+
+// batchEvents returns the events of event, which may be a batch.
+func batchEvents(event events.Event) []events.Event {
+	if batch, ok := event.(eventBatch); ok {
+		return batch
+	}
+	return []events.Event{event}
 }
 
 // eventQueueListener is called when various events happen on the queue.
@@ -30,10 +49,19 @@ type eventQueueListener interface {
 // newEventQueue returns a queue to the provided sink. If the updater is non-
 // nil, it will be called to update pending metrics on ingress and egress.
 func newEventQueue(sink events.Sink, listeners ...eventQueueListener) *eventQueue {
+	return newBatchingEventQueue(sink, 1, 0, listeners...)
+}
+
+// newBatchingEventQueue returns a queue writing batches of up to maxBatch
+// events to the provided sink. Once an event is queued, the queue waits up to
+// maxDelay for more before writing the batch.
+func newBatchingEventQueue(sink events.Sink, maxBatch int, maxDelay time.Duration, listeners ...eventQueueListener) *eventQueue {
 	eq := eventQueue{
 		sink:      sink,
 		events:    list.New(),
 		listeners: listeners,
+		maxBatch:  maxBatch,
+		maxDelay:  maxDelay,
 	}
 
 	eq.cond = sync.NewCond(&eq.mu)
@@ -85,15 +113,70 @@ func (eq *eventQueue) run() {
 		if event == nil {
 			return // nil block means event queue is closed.
 		}
+		if eq.maxBatch > 1 {
+			event = eq.fill(event)
+		}
 
 		if err := eq.sink.Write(event); err != nil {
 			logrus.Warnf("eventqueue: error writing events to %v, these events will be lost: %v", eq.sink, err)
 		}
 
-		for _, listener := range eq.listeners {
-			listener.egress(event)
+		for _, event := range batchEvents(event) {
+			for _, listener := range eq.listeners {
+				listener.egress(event)
+			}
+		}
+	}
+}
+
+// fill returns a batch of first and the events queued after it, waiting up to
+// the maximum delay for the batch to fill up.
+func (eq *eventQueue) fill(first events.Event) events.Event {
+	eq.mu.Lock()
+	defer eq.mu.Unlock()
+
+	if eq.maxDelay > 0 {
+		var expired, done bool
+		timer := time.AfterFunc(eq.maxDelay, func() {
+			eq.mu.Lock()
+			defer eq.mu.Unlock()
+			// Once filled, a broadcast could wake a closing queue early.
+			if !done {
+				expired = true
+				eq.cond.Broadcast()
+			}
+		})
+		for !expired && !eq.closed && eq.events.Len() < eq.maxBatch-1 {
+			eq.cond.Wait()
 		}
+		done = true
+		timer.Stop()
+	}
+
+	batch := eventBatch{first}
+	for len(batch) < eq.maxBatch && eq.events.Len() > 0 {
+		front := eq.events.Front()
+		batch = append(batch, front.Value.(events.Event))
+		eq.events.Remove(front)
 	}
+	return batch
+}
+
+// setPaused stops or restarts the delivery of queued events.
+func (eq *eventQueue) setPaused(paused bool) {
+	eq.mu.Lock()
+	defer eq.mu.Unlock()
+
+	eq.paused = paused
+	eq.cond.Broadcast()
+}
+
+// isPaused reports whether the delivery of queued events is stopped.
+func (eq *eventQueue) isPaused() bool {
+	eq.mu.Lock()
+	defer eq.mu.Unlock()
+
+	return eq.paused
 }
 
 // next encompasses the critical section of the run loop. When the queue is
@@ -103,8 +186,10 @@ func (eq *eventQueue) next() events.Event {
 	eq.mu.Lock()
 	defer eq.mu.Unlock()
 
-	for eq.events.Len() < 1 {
-		if eq.closed {
+	// While paused, events are held in the queue, unless it is being
+	// closed and flushed.
+	for eq.events.Len() < 1 || (eq.paused && !eq.closed) {
+		if eq.events.Len() < 1 && eq.closed {
 			eq.cond.Broadcast()
 			return nil
 		}
@@ -128,7 +213,7 @@ type ignoredSink struct {
 }
 
 func newIgnoredSink(sink events.Sink, ignored []string, ignoreActions []string) events.Sink {
-	if len(ignored) == 0 {
+	if len(ignored) == 0 && len(ignoreActions) == 0 {
 		return sink
 	}
 
@@ -162,3 +247,63 @@ func (imts *ignoredSink) Write(event events.Event) error {
 func (imts *ignoredSink) Close() error {
 	return nil
 }
+
+// filteredSink discards events which do not pass the endpoint's filter and
+// passes the rest along.
+type filteredSink struct {
+	events.Sink
+	filter *Filter
+}
+
+func newFilteredSink(sink events.Sink, filter *Filter) events.Sink {
+	if filter == nil {
+		return sink
+	}
+
+	return &filteredSink{
+		Sink:   sink,
+		filter: filter,
+	}
+}
+
+// Write discards events which do not pass the filter and passes the rest
+// along.
+func (fs *filteredSink) Write(event events.Event) error {
+	if !fs.filter.Match(event.(Event)) {
+		return nil
+	}
+
+	return fs.Sink.Write(event)
+}
+
+// deliveryListener is called with the outcome of writes to a sink other than
+// http.
+type deliveryListener interface {
+	success(event events.Event)
+	err(err error, event events.Event)
+}
+
+// deliverySink reports the outcome of writes to the sink delivering an
+// endpoint's events.
+type deliverySink struct {
+	events.Sink
+	listener deliveryListener
+}
+
+func newDeliverySink(sink events.Sink, listener deliveryListener) events.Sink {
+	return &deliverySink{
+		Sink:     sink,
+		listener: listener,
+	}
+}
+
+// Write passes the event to the sink and reports the outcome.
+func (ds *deliverySink) Write(event events.Event) error {
+	if err := ds.Sink.Write(event); err != nil {
+		ds.listener.err(err, event)
+		return err
+	}
+
+	ds.listener.success(event)
+	return nil
+}
@@ -8,6 +8,7 @@ import (
 
 	events "github.com/docker/go-events"
 
+	"github.com/distribution/distribution/v3/configuration"
 	"github.com/sirupsen/logrus"
 )
 
@@ -79,6 +80,7 @@ func TestIgnoredSink(t *testing.T) {
 		{ignoreMediaTypes: []string{"blob", "manifest"}, ignoreActions: []string{"other"}},
 		{ignoreMediaTypes: []string{"other"}, ignoreActions: []string{"pull"}, expected: blob},
 		{ignoreMediaTypes: []string{"other"}, ignoreActions: []string{"pull", "push"}},
+		{ignoreActions: []string{"push"}},
 	}
 
 	for _, tc := range tests {
@@ -103,6 +105,8 @@ func TestIgnoredSink(t *testing.T) {
 		{ignoreMediaTypes: []string{"blob", "manifest"}, ignoreActions: []string{"other"}},
 		{ignoreMediaTypes: []string{"other"}, ignoreActions: []string{"push"}, expected: manifest},
 		{ignoreMediaTypes: []string{"other"}, ignoreActions: []string{"pull", "push"}},
+		{ignoreActions: []string{"push"}, expected: manifest},
+		{ignoreActions: []string{"pull"}},
 	}
 
 	for _, tc := range tests {
@@ -121,6 +125,107 @@ func TestIgnoredSink(t *testing.T) {
 	}
 }
 
+func TestFilteredSink(t *testing.T) {
+	filter, err := NewFilter(configuration.Filter{
+		Repositories: configuration.FilterRules{
+			Include: []string{"team-a/**", "shared"},
+			Exclude: []string{"team-a/scratch/*"},
+		},
+		Tags: configuration.FilterRules{
+			Include: []string{"^v[0-9]"},
+			Exclude: []string{"-rc[0-9]*$"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		repository string
+		tag        string
+		expected   bool
+	}{
+		{repository: "team-a/app", expected: true},
+		{repository: "team-a/app/sub", tag: "v1.0", expected: true},
+		{repository: "shared", expected: true},
+		{repository: "team-b/app"},
+		{repository: "team-a/scratch/app"},
+		{repository: "team-a/app", tag: "latest"},
+		{repository: "team-a/app", tag: "v2.0-rc1"},
+	} {
+		event := createTestEvent("push", tc.repository, "manifest")
+		event.Target.Tag = tc.tag
+
+		ts := &testSink{}
+		s := newFilteredSink(ts, filter)
+		if err := s.Write(event); err != nil {
+			t.Fatalf("error writing event: %v", err)
+		}
+
+		ts.mu.Lock()
+		if written := ts.count == 1; written != tc.expected {
+			t.Errorf("%s:%s: expected written=%v, got %v", tc.repository, tc.tag, tc.expected, written)
+		}
+		ts.mu.Unlock()
+	}
+
+	if filter, err := NewFilter(configuration.Filter{}); err != nil || filter != nil {
+		t.Fatalf("expected no filter for an empty configuration, got %v: %v", filter, err)
+	}
+	if _, err := NewFilter(configuration.Filter{Tags: configuration.FilterRules{Include: []string{"("}}}); err == nil {
+		t.Fatal("expected invalid tag pattern to be rejected")
+	}
+}
+
+func TestEventQueueBatching(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches [][]events.Event
+	)
+	metrics := newSafeMetrics("")
+	eq := newBatchingEventQueue(testSinkFn(func(event events.Event) error {
+		mu.Lock()
+		defer mu.Unlock()
+		batches = append(batches, batchEvents(event))
+		return nil
+	}), 3, time.Hour, metrics.eventQueueListener())
+
+	// A full batch is written at once, without waiting for the delay.
+	for i := 0; i < 4; i++ {
+		if err := eq.Write(createTestEvent("push", "library/test", "blob")); err != nil {
+			t.Fatalf("error writing event: %v", err)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(batches)
+		mu.Unlock()
+		if n > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for a full batch")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Closing flushes the partial batch.
+	checkClose(t, eq)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(batches) != 2 || len(batches[0]) != 3 || len(batches[1]) != 1 {
+		t.Fatalf("unexpected batches: %v", batches)
+	}
+
+	metrics.Lock()
+	defer metrics.Unlock()
+	if metrics.Events != 4 || metrics.Pending != 0 {
+		t.Fatalf("unexpected queue metrics: %+v", metrics.EndpointMetrics)
+	}
+}
+
 type testSink struct {
 	event  events.Event
 	count  int
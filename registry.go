@@ -49,6 +49,14 @@ type Namespace interface {
 	BlobStatter() BlobStatter
 }
 
+// RepositoryPrefixLister lists the catalog of repositories under a prefix,
+// without reading the repositories outside of it.
+type RepositoryPrefixLister interface {
+	// RepositoriesWithPrefix fills 'repos' like Repositories, with the
+	// repositories whose name starts with 'prefix'.
+	RepositoriesWithPrefix(ctx context.Context, repos []string, prefix, last string) (n int, err error)
+}
+
 // RepositoryEnumerator describes an operation to enumerate repositories
 type RepositoryEnumerator interface {
 	Enumerate(ctx context.Context, ingester func(string) error) error
@@ -59,6 +67,14 @@ type RepositoryRemover interface {
 	Remove(ctx context.Context, name reference.Named) error
 }
 
+// RepositoryCopier copies a repository to another name, linking the blobs of
+// the repository rather than copying them.
+type RepositoryCopier interface {
+	// Copy links the layers, manifests and tags of the repository 'from'
+	// into the repository 'to', which must not exist.
+	Copy(ctx context.Context, from, to reference.Named) error
+}
+
 // ManifestServiceOption is a function argument for Manifest Service methods
 type ManifestServiceOption interface {
 	Apply(ManifestService) error
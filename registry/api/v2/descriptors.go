@@ -1536,6 +1536,252 @@ var routeDescriptors = []RouteDescriptor{
 			},
 		},
 	},
+	{
+		Name:        RouteNameTagDetails,
+		Path:        "/v2/{name:" + reference.NameRegexp.String() + "}/_ext/tags",
+		Entity:      "Tag Details",
+		Description: "Retrieve the tags of a repository with the manifests they point to. This is an extension to the distribution specification.",
+		Methods: []MethodDescriptor{
+			{
+				Method:      http.MethodGet,
+				Description: "Fetch a page of the tags under the repository identified by `name`.",
+				Requests: []RequestDescriptor{
+					{
+						Name:           "Tag Details",
+						Description:    "Return a portion of the tags of the repository, with the digest, media type and size of the manifest each tag points to and when the tag last moved if `detail` is `true`.",
+						Headers:        []ParameterDescriptor{hostHeader, authHeader},
+						PathParameters: []ParameterDescriptor{nameParameterDescriptor},
+						QueryParameters: append([]ParameterDescriptor{
+							{
+								Name:        "detail",
+								Type:        "boolean",
+								Description: "Describe the manifest each tag points to.",
+								Format:      "<boolean>",
+								Required:    false,
+							},
+							{
+								Name:        "sort",
+								Type:        "string",
+								Description: "Order of the tags: `name`, the default, or `time`, the most recently moved first.",
+								Format:      "name|time",
+								Required:    false,
+							},
+							{
+								Name:        "lastmodified",
+								Type:        "string",
+								Description: "When sorting by `time`, the time the `last` tag moved, as set in the `Link` header. Result set will include the tags moved before it, or at the same time and lexically after last.",
+								Format:      "<time>",
+								Required:    false,
+							},
+						}, paginationParameters...),
+						Successes: []ResponseDescriptor{
+							{
+								StatusCode:  http.StatusOK,
+								Description: "A page of the tags of the named repository.",
+								Headers: []ParameterDescriptor{
+									{
+										Name:        "Content-Length",
+										Type:        "integer",
+										Description: "Length of the JSON response body.",
+										Format:      "<length>",
+									},
+									linkHeader,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json",
+									Format: `{
+    "name": <name>,
+    "tags": [
+        {
+            "name": <tag>,
+            "digest": <digest>,
+            "mediaType": <media type>,
+            "size": <size>,
+            "lastModified": <time>
+        },
+        ...
+    ]
+}`,
+								},
+							},
+						},
+						Failures: []ResponseDescriptor{
+							invalidPaginationResponseDescriptor,
+							unauthorizedResponseDescriptor,
+							repositoryNotFoundResponseDescriptor,
+							deniedResponseDescriptor,
+							tooManyRequestsDescriptor,
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:        RouteNameRepositoryCopy,
+		Path:        "/v2/{name:" + reference.NameRegexp.String() + "}/_ext/copy",
+		Entity:      "Repository Copy",
+		Description: "Copy or rename a repository in the registry, linking its layers, manifests and tags to the new name without copying the blob data. This is an extension to the distribution specification.",
+		Methods: []MethodDescriptor{
+			{
+				Method:      http.MethodPost,
+				Description: "Copy the repository `from` to `name`, which must not exist. With `move`, the repository `from` is removed once copied, which requires deletes to be enabled.",
+				Requests: []RequestDescriptor{
+					{
+						Headers:        []ParameterDescriptor{hostHeader, authHeader},
+						PathParameters: []ParameterDescriptor{nameParameterDescriptor},
+						QueryParameters: []ParameterDescriptor{
+							{
+								Name:        "from",
+								Type:        "query",
+								Format:      "<repository name>",
+								Regexp:      reference.NameRegexp,
+								Description: "Name of the repository to copy.",
+								Required:    true,
+							},
+							{
+								Name:        "move",
+								Type:        "boolean",
+								Format:      "<boolean>",
+								Description: "Remove the repository `from` once copied.",
+							},
+						},
+						Successes: []ResponseDescriptor{
+							{
+								Description: "The repository was copied, or renamed.",
+								StatusCode:  http.StatusCreated,
+								Headers: []ParameterDescriptor{
+									{
+										Name:        "Location",
+										Type:        "url",
+										Format:      "<url>",
+										Description: "The location of the tags of the repository `name`.",
+									},
+									contentLengthZeroHeader,
+								},
+							},
+						},
+						Failures: []ResponseDescriptor{
+							{
+								Name:        "Repository Exists",
+								StatusCode:  http.StatusConflict,
+								Description: "The repository `name` already exists.",
+								Body: BodyDescriptor{
+									ContentType: "application/json",
+									Format:      errorsBody,
+								},
+							},
+							{
+								Name:        "Not allowed",
+								Description: "Repositories may not be renamed as deletes are disabled, or the registry is a pull through cache.",
+								StatusCode:  http.StatusMethodNotAllowed,
+								ErrorCodes: []errcode.ErrorCode{
+									errcode.ErrorCodeUnsupported,
+								},
+							},
+							unauthorizedResponseDescriptor,
+							repositoryNotFoundResponseDescriptor,
+							deniedResponseDescriptor,
+							tooManyRequestsDescriptor,
+						},
+					},
+				},
+			},
+		},
+	},
+	{
+		Name:        RouteNameManifestCopy,
+		Path:        "/v2/{name:" + reference.NameRegexp.String() + "}/_ext/manifests/{reference:" + reference.TagRegexp.String() + "|" + digest.DigestRegexp.String() + "}",
+		Entity:      "Manifest Copy",
+		Description: "Copy a manifest from another repository, linking the manifests and blobs it references without pushing them again. This is an extension to the distribution specification.",
+		Methods: []MethodDescriptor{
+			{
+				Method:      http.MethodPost,
+				Description: "Copy the manifest `ref` of the repository `from` to `name`, tagging it if `reference` is a tag. The manifests of an image index are copied with it.",
+				Requests: []RequestDescriptor{
+					{
+						Headers: []ParameterDescriptor{hostHeader, authHeader},
+						PathParameters: []ParameterDescriptor{
+							nameParameterDescriptor,
+							referenceParameterDescriptor,
+						},
+						QueryParameters: []ParameterDescriptor{
+							{
+								Name:        "from",
+								Type:        "query",
+								Format:      "<repository name>",
+								Regexp:      reference.NameRegexp,
+								Description: "Name of the repository to copy the manifest from.",
+								Required:    true,
+							},
+							{
+								Name:        "ref",
+								Type:        "query",
+								Format:      "<tag or digest>",
+								Description: "Tag or digest of the manifest to copy, `reference` if omitted. If `reference` is a digest, the manifest copied must have that digest.",
+							},
+						},
+						Successes: []ResponseDescriptor{
+							{
+								Description: "The manifest was copied to `name`, and tagged if `reference` is a tag.",
+								StatusCode:  http.StatusCreated,
+								Headers: []ParameterDescriptor{
+									{
+										Name:        "Location",
+										Type:        "url",
+										Description: "The canonical location url of the manifest copied.",
+										Format:      "<url>",
+									},
+									contentLengthZeroHeader,
+									digestHeader,
+								},
+							},
+						},
+						Failures: []ResponseDescriptor{
+							{
+								Name:        "Invalid Copy",
+								Description: "The copy was invalid in some way, as described by the error codes.",
+								StatusCode:  http.StatusBadRequest,
+								Body: BodyDescriptor{
+									ContentType: "application/json",
+									Format:      errorsBody,
+								},
+								ErrorCodes: []errcode.ErrorCode{
+									errcode.ErrorCodeNameInvalid,
+									errcode.ErrorCodeTagInvalid,
+									errcode.ErrorCodeDigestInvalid,
+									errcode.ErrorCodeManifestBlobUnknown,
+								},
+							},
+							{
+								Name:        "No Such Manifest",
+								Description: "The manifest to copy is unknown to the repository `from`.",
+								StatusCode:  http.StatusNotFound,
+								ErrorCodes: []errcode.ErrorCode{
+									errcode.ErrorCodeManifestUnknown,
+								},
+								Body: BodyDescriptor{
+									ContentType: "application/json",
+									Format:      errorsBody,
+								},
+							},
+							{
+								Name:        "Not allowed",
+								Description: "Manifests may not be copied as the registry is a pull through cache.",
+								StatusCode:  http.StatusMethodNotAllowed,
+								ErrorCodes: []errcode.ErrorCode{
+									errcode.ErrorCodeUnsupported,
+								},
+							},
+							unauthorizedResponseDescriptor,
+							deniedResponseDescriptor,
+							tooManyRequestsDescriptor,
+						},
+					},
+				},
+			},
+		},
+	},
 	{
 		Name:        RouteNameCatalog,
 		Path:        "/v2/_catalog",
@@ -1605,6 +1851,104 @@ var routeDescriptors = []RouteDescriptor{
 							invalidPaginationResponseDescriptor,
 						},
 					},
+					{
+						Name:        "Catalog Fetch By Prefix",
+						Description: "Return a portion of the repositories whose name starts with a prefix, or which lie in a namespace. This is an extension to the distribution specification.",
+						QueryParameters: append([]ParameterDescriptor{
+							{
+								Name:        "prefix",
+								Type:        "string",
+								Description: "Return the repositories whose name starts with prefix.",
+								Format:      "<prefix>",
+								Required:    false,
+							},
+							{
+								Name:        "namespace",
+								Type:        "string",
+								Description: "Return the repositories under the namespace. It may not be set with prefix.",
+								Format:      "<name>",
+								Required:    false,
+							},
+							{
+								Name:        "token",
+								Type:        "string",
+								Description: "Continuation token from the Link header of the previous response.",
+								Format:      "<token>",
+								Required:    false,
+							},
+						}, paginationParameters...),
+						Successes: []ResponseDescriptor{
+							{
+								StatusCode: http.StatusOK,
+								Body: BodyDescriptor{
+									ContentType: "application/json",
+									Format: `{
+	"repositories": [
+		<name>,
+		...
+	]
+}`,
+								},
+								Headers: []ParameterDescriptor{
+									{
+										Name:        "Content-Length",
+										Type:        "integer",
+										Description: "Length of the JSON response body.",
+										Format:      "<length>",
+									},
+									{
+										Name:        "Link",
+										Type:        "link",
+										Description: "RFC5988 compliant rel='next' with URL to next result set, if available",
+										Format:      `<<url>?n=<last n value>&prefix=<prefix>&token=<token>>; rel="next"`,
+									},
+								},
+							},
+						},
+						Failures: []ResponseDescriptor{
+							invalidPaginationResponseDescriptor,
+						},
+					},
+				},
+			},
+		},
+	},
+	// The route of a repository comes last, as the names of repositories
+	// may end as the paths of the other routes.
+	{
+		Name:        RouteNameRepository,
+		Path:        "/v2/{name:" + reference.NameRegexp.String() + "}/",
+		Entity:      "Repository",
+		Description: "Operate on a whole repository. This is an extension to the distribution specification.",
+		Methods: []MethodDescriptor{
+			{
+				Method:      http.MethodDelete,
+				Description: "Delete the repository identified by `name`, with its tags, manifests and layer links. The repositories nested under `name` are kept. The blob data is removed by the garbage collector.",
+				Requests: []RequestDescriptor{
+					{
+						Headers:        []ParameterDescriptor{hostHeader, authHeader},
+						PathParameters: []ParameterDescriptor{nameParameterDescriptor},
+						Successes: []ResponseDescriptor{
+							{
+								StatusCode: http.StatusAccepted,
+								Headers:    []ParameterDescriptor{contentLengthZeroHeader},
+							},
+						},
+						Failures: []ResponseDescriptor{
+							{
+								Name:        "Not allowed",
+								Description: "Repository delete is not allowed because the registry is configured as a pull-through cache or `delete` has been disabled.",
+								StatusCode:  http.StatusMethodNotAllowed,
+								ErrorCodes: []errcode.ErrorCode{
+									errcode.ErrorCodeUnsupported,
+								},
+							},
+							unauthorizedResponseDescriptor,
+							repositoryNotFoundResponseDescriptor,
+							deniedResponseDescriptor,
+							tooManyRequestsDescriptor,
+						},
+					},
 				},
 			},
 		},
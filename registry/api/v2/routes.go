@@ -12,10 +12,14 @@ const (
 	RouteNameBase            = "base"
 	RouteNameManifest        = "manifest"
 	RouteNameTags            = "tags"
+	RouteNameTagDetails      = "tag-details"
 	RouteNameBlob            = "blob"
 	RouteNameBlobUpload      = "blob-upload"
 	RouteNameBlobUploadChunk = "blob-upload-chunk"
 	RouteNameCatalog         = "catalog"
+	RouteNameRepository      = "repository"
+	RouteNameRepositoryCopy  = "repository-copy"
+	RouteNameManifestCopy    = "manifest-copy"
 )
 
 var (
@@ -87,6 +87,35 @@ func TestRouter(t *testing.T) {
 				"name": "docker.com/foo/bar/baz",
 			},
 		},
+		{
+			RouteName:  RouteNameRepository,
+			RequestURI: "/v2/foo/bar/",
+			Vars: map[string]string{
+				"name": "foo/bar",
+			},
+		},
+		{
+			RouteName:  RouteNameRepositoryCopy,
+			RequestURI: "/v2/foo/bar/_ext/copy",
+			Vars: map[string]string{
+				"name": "foo/bar",
+			},
+		},
+		{
+			RouteName:  RouteNameManifestCopy,
+			RequestURI: "/v2/foo/bar/_ext/manifests/tag",
+			Vars: map[string]string{
+				"name":      "foo/bar",
+				"reference": "tag",
+			},
+		},
+		{
+			RouteName:  RouteNameTagDetails,
+			RequestURI: "/v2/foo/bar/_ext/tags",
+			Vars: map[string]string{
+				"name": "foo/bar",
+			},
+		},
 		{
 			RouteName:  RouteNameBlob,
 			RequestURI: "/v2/foo/bar/blobs/sha256:abcdef0919234",
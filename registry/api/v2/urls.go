@@ -139,6 +139,68 @@ func (ub *URLBuilder) BuildTagsURL(name reference.Named, values ...url.Values) (
 	return appendValuesURL(tagsURL, values...).String(), nil
 }
 
+// BuildTagDetailsURL constructs a url to list the tags in the named
+// repository, with the manifests they point to.
+func (ub *URLBuilder) BuildTagDetailsURL(name reference.Named, values ...url.Values) (string, error) {
+	route := ub.cloneRoute(RouteNameTagDetails)
+
+	tagDetailsURL, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return appendValuesURL(tagDetailsURL, values...).String(), nil
+}
+
+// BuildRepositoryURL constructs a url to operate on the named repository.
+func (ub *URLBuilder) BuildRepositoryURL(name reference.Named) (string, error) {
+	route := ub.cloneRoute(RouteNameRepository)
+
+	repositoryURL, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return repositoryURL.String(), nil
+}
+
+// BuildRepositoryCopyURL constructs a url to copy a repository to the named
+// one.
+func (ub *URLBuilder) BuildRepositoryCopyURL(name reference.Named, values ...url.Values) (string, error) {
+	route := ub.cloneRoute(RouteNameRepositoryCopy)
+
+	copyURL, err := route.URL("name", name.Name())
+	if err != nil {
+		return "", err
+	}
+
+	return appendValuesURL(copyURL, values...).String(), nil
+}
+
+// BuildManifestCopyURL constructs a url to copy a manifest to the one
+// identified by name and reference. The argument reference may be either a
+// tag or digest.
+func (ub *URLBuilder) BuildManifestCopyURL(ref reference.Named, values ...url.Values) (string, error) {
+	route := ub.cloneRoute(RouteNameManifestCopy)
+
+	tagOrDigest := ""
+	switch v := ref.(type) {
+	case reference.Tagged:
+		tagOrDigest = v.Tag()
+	case reference.Digested:
+		tagOrDigest = v.Digest().String()
+	default:
+		return "", fmt.Errorf("reference must have a tag or digest")
+	}
+
+	copyURL, err := route.URL("name", ref.Name(), "reference", tagOrDigest)
+	if err != nil {
+		return "", err
+	}
+
+	return appendValuesURL(copyURL, values...).String(), nil
+}
+
 // BuildManifestURL constructs a url for the manifest identified by name and
 // reference. The argument reference may be either a tag or digest.
 func (ub *URLBuilder) BuildManifestURL(ref reference.Named) (string, error) {
@@ -54,6 +54,16 @@ func makeURLBuilderTestCases(urlBuilder *URLBuilder) []urlBuilderTestCase {
 				})
 			},
 		},
+		{
+			description:  "test tag details url with detail query parameter",
+			expectedPath: "/v2/foo/bar/_ext/tags?detail=true",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				return urlBuilder.BuildTagDetailsURL(fooBarRef, url.Values{
+					"detail": []string{"true"},
+				})
+			},
+		},
 		{
 			description:  "test manifest url tagged ref",
 			expectedPath: "/v2/foo/bar/manifests/tag",
@@ -63,6 +73,17 @@ func makeURLBuilderTestCases(urlBuilder *URLBuilder) []urlBuilderTestCase {
 				return urlBuilder.BuildManifestURL(ref)
 			},
 		},
+		{
+			description:  "test manifest copy url tagged ref",
+			expectedPath: "/v2/foo/bar/_ext/manifests/tag?from=foo%2Fbaz",
+			expectedErr:  nil,
+			build: func() (string, error) {
+				ref, _ := reference.WithTag(fooBarRef, "tag")
+				return urlBuilder.BuildManifestCopyURL(ref, url.Values{
+					"from": []string{"foo/baz"},
+				})
+			},
+		},
 		{
 			description:  "test manifest url bare ref",
 			expectedPath: "",
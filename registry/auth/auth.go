@@ -32,9 +32,12 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
 )
 
 var (
@@ -43,6 +46,14 @@ var (
 
 	// ErrAuthenticationFailure returned when authentication fails.
 	ErrAuthenticationFailure = errors.New("authentication failure")
+
+	// ErrAccessDenied is returned when an authenticated request is not
+	// permitted to perform the requested actions.
+	ErrAccessDenied = errors.New("access denied")
+
+	// ErrTooManyAttempts is returned when a user or client has been locked
+	// out after repeated authentication failures.
+	ErrTooManyAttempts = errors.New("too many failed authentication attempts")
 )
 
 // InitFunc is the type of an AccessController factory function and is used
@@ -79,6 +90,11 @@ type Access struct {
 type Grant struct {
 	User      UserInfo   // The authenticated user for the request.
 	Resources []Resource // The list of resources which have been authorized for the request.
+
+	// Issuer is the access controller which issued the grant. It is set by
+	// access controllers which delegate to others, such as a chain, so that
+	// later questions about the grant reach the controller which issued it.
+	Issuer AccessController
 }
 
 // Challenge is a special error type which is used for HTTP 401 Unauthorized
@@ -114,6 +130,37 @@ type CredentialAuthenticator interface {
 	AuthenticateUser(username, password string) error
 }
 
+// UserChecker is implemented by credential authenticators which can tell
+// whether an account exists without its credentials, for instance to stop
+// honoring the refresh tokens of an account since removed.
+type UserChecker interface {
+	UserExists(username string) (bool, error)
+}
+
+// CatalogFilter is implemented by access controllers which can restrict the
+// catalog to the repositories a grant may see. When the registry's access
+// controller implements it, the catalog lists only the repositories
+// FilterCatalog returns, in the order given.
+type CatalogFilter interface {
+	FilterCatalog(ctx context.Context, grant *Grant, repositories []string) ([]string, error)
+}
+
+// AdminEnforcer is implemented by access controllers which only grant the
+// "*" action on the "admin" resource of type "registry" to clients explicitly
+// allowed it, rather than to every authenticated client. The registry only
+// serves its admin API behind an access controller enforcing admin access.
+type AdminEnforcer interface {
+	EnforcesAdmin() bool
+}
+
+// StorageConsumer is implemented by access controllers which keep state,
+// such as issued credentials, in the registry's storage. The registry calls
+// UseStorage once with its storage driver, after the access controller has
+// been constructed and before it serves any request.
+type StorageConsumer interface {
+	UseStorage(driver storagedriver.StorageDriver)
+}
+
 // Register is used to register an InitFunc for
 // an AccessController backend with the given name.
 func Register(name string, initFunc InitFunc) error {
@@ -135,3 +182,43 @@ func GetAccessController(name string, options map[string]interface{}) (AccessCon
 
 	return nil, fmt.Errorf("no access controller registered with name: %s", name)
 }
+
+// ParseControllerConfig unpacks a single-entry map naming an access
+// controller and its options, as found when access controllers are
+// configured within the options of another access controller.
+func ParseControllerConfig(item interface{}) (string, map[string]interface{}, error) {
+	var entries map[string]interface{}
+	switch m := item.(type) {
+	case map[string]interface{}:
+		entries = m
+	case map[interface{}]interface{}:
+		entries = make(map[string]interface{}, len(m))
+		for k, v := range m {
+			entries[fmt.Sprint(k)] = v
+		}
+	default:
+		return "", nil, fmt.Errorf("invalid configuration %v", item)
+	}
+	if len(entries) != 1 {
+		return "", nil, fmt.Errorf("must provide exactly one type, provided %d", len(entries))
+	}
+
+	for name, v := range entries {
+		params := map[string]interface{}{}
+		switch p := v.(type) {
+		case nil:
+		case map[string]interface{}:
+			params = p
+		case map[interface{}]interface{}:
+			for k, v := range p {
+				params[fmt.Sprint(k)] = v
+			}
+		default:
+			return "", nil, fmt.Errorf("invalid options for %s: %v", name, v)
+		}
+		return name, params, nil
+	}
+
+	// unreachable
+	return "", nil, errors.New("empty configuration")
+}
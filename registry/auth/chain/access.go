@@ -0,0 +1,204 @@
+// Package chain provides an access controller which consults an ordered list
+// of other access controllers.
+//
+// The first controller to grant a request wins. A controller which responds
+// with a challenge passes the request on to the next one; if every
+// controller responds with a challenge, all of their challenges are
+// advertised to the client. Any other error stops the chain.
+//
+//	auth:
+//	  chain:
+//	    controllers:
+//	      - token:
+//	          realm: https://auth.example.com/token
+//	          ...
+//	      - htpasswd:
+//	          realm: basic-realm
+//	          path: /path/to/htpasswd
+package chain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/distribution/distribution/v3/registry/auth"
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	if err := auth.Register("chain", auth.InitFunc(newAccessController)); err != nil {
+		logrus.Errorf("failed to register chain auth: %v", err)
+	}
+}
+
+// accessController tries each of its controllers in order.
+type accessController struct {
+	controllers []auth.AccessController
+}
+
+var (
+	_ auth.AccessController = &accessController{}
+	_ auth.StorageConsumer  = &accessController{}
+	_ auth.CatalogFilter    = &accessController{}
+	_ io.Closer             = &accessController{}
+	_ auth.AdminEnforcer    = &accessController{}
+)
+
+// New returns an access controller which consults controllers in order.
+func New(controllers ...auth.AccessController) auth.AccessController {
+	return &accessController{controllers: controllers}
+}
+
+func newAccessController(options map[string]interface{}) (auth.AccessController, error) {
+	list, ok := options["controllers"].([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf(`"controllers" must be a non-empty list for chain access controller`)
+	}
+
+	controllers := make([]auth.AccessController, 0, len(list))
+	for i, item := range list {
+		name, params, err := auth.ParseControllerConfig(item)
+		if err != nil {
+			return nil, fmt.Errorf("chain access controller %d: %v", i, err)
+		}
+		if name == "chain" {
+			return nil, fmt.Errorf("chain access controller %d: chains cannot be nested", i)
+		}
+
+		ac, err := auth.GetAccessController(name, params)
+		if err != nil {
+			return nil, fmt.Errorf("chain access controller %d (%s): %v", i, name, err)
+		}
+		controllers = append(controllers, ac)
+	}
+
+	return New(controllers...), nil
+}
+
+// UseStorage passes the storage driver on to every controller in the chain
+// which keeps state in storage.
+func (ac *accessController) UseStorage(driver storagedriver.StorageDriver) {
+	for _, controller := range ac.controllers {
+		if sc, ok := controller.(auth.StorageConsumer); ok {
+			sc.UseStorage(driver)
+		}
+	}
+}
+
+// Close closes every controller in the chain which holds resources, such as
+// background goroutines.
+func (ac *accessController) Close() error {
+	var errs []error
+	for _, controller := range ac.controllers {
+		if c, ok := controller.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// FilterCatalog filters the catalog through the controller which issued the
+// grant. If that controller does not filter the catalog, every repository is
+// visible.
+func (ac *accessController) FilterCatalog(ctx context.Context, grant *auth.Grant, repositories []string) ([]string, error) {
+	if cf, ok := grant.Issuer.(auth.CatalogFilter); ok {
+		return cf.FilterCatalog(ctx, grant, repositories)
+	}
+	return repositories, nil
+}
+
+// EnforcesAdmin returns whether every controller in the chain enforces
+// admin access, since any of them may grant a request.
+func (ac *accessController) EnforcesAdmin() bool {
+	for _, controller := range ac.controllers {
+		if ae, ok := controller.(auth.AdminEnforcer); !ok || !ae.EnforcesAdmin() {
+			return false
+		}
+	}
+	return true
+}
+
+// Authorized returns the grant of the first controller which authorizes the
+// request.
+func (ac *accessController) Authorized(req *http.Request, accessRecords ...auth.Access) (*auth.Grant, error) {
+	var challenges []auth.Challenge
+	for _, controller := range ac.controllers {
+		grant, err := controller.Authorized(req, accessRecords...)
+		if err == nil {
+			if grant != nil && grant.Issuer == nil {
+				grant.Issuer = controller
+			}
+			return grant, nil
+		}
+
+		var ch auth.Challenge
+		if !errors.As(err, &ch) {
+			return nil, err
+		}
+		challenges = append(challenges, ch)
+	}
+
+	return nil, challenge{challenges: challenges}
+}
+
+// challenge combines the challenges of every controller in the chain.
+type challenge struct {
+	challenges []auth.Challenge
+}
+
+var _ auth.Challenge = challenge{}
+
+// SetHeaders adds the headers of every challenge in the chain. Each
+// challenge writes to its own header map first, so that challenges which
+// set rather than add WWW-Authenticate do not overwrite one another. No
+// headers are set when a controller denied the request: the client
+// authenticated, and the other controllers' challenges would only invite
+// it to retry with other credentials.
+func (ch challenge) SetHeaders(r *http.Request, w http.ResponseWriter) {
+	if errors.Is(ch, auth.ErrAccessDenied) {
+		return
+	}
+	for _, c := range ch.challenges {
+		hw := headerWriter{header: http.Header{}}
+		c.SetHeaders(r, hw)
+		for k, values := range hw.header {
+			for _, v := range values {
+				w.Header().Add(k, v)
+			}
+		}
+	}
+}
+
+func (ch challenge) Error() string {
+	errs := make([]string, 0, len(ch.challenges))
+	for _, c := range ch.challenges {
+		errs = append(errs, c.Error())
+	}
+	return fmt.Sprintf("chain authentication challenge: %s", strings.Join(errs, "; "))
+}
+
+// Unwrap returns the challenges of the chain, so that errors.Is and
+// errors.As see the errors of every controller.
+func (ch challenge) Unwrap() []error {
+	errs := make([]error, 0, len(ch.challenges))
+	for _, c := range ch.challenges {
+		errs = append(errs, c)
+	}
+	return errs
+}
+
+// headerWriter is an http.ResponseWriter which only records headers.
+type headerWriter struct {
+	header http.Header
+}
+
+func (hw headerWriter) Header() http.Header         { return hw.header }
+func (hw headerWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (hw headerWriter) WriteHeader(int)             {}
@@ -0,0 +1,158 @@
+package chain
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/distribution/distribution/v3/registry/auth"
+	_ "github.com/distribution/distribution/v3/registry/auth/silly"
+)
+
+type testChallenge struct {
+	header string
+}
+
+func (ch testChallenge) SetHeaders(r *http.Request, w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", ch.header)
+}
+
+func (ch testChallenge) Error() string { return "challenge " + ch.header }
+
+type testController struct {
+	user string
+	err  error
+}
+
+func (tc testController) Authorized(req *http.Request, access ...auth.Access) (*auth.Grant, error) {
+	if tc.err != nil {
+		return nil, tc.err
+	}
+	return &auth.Grant{User: auth.UserInfo{Name: tc.user}}, nil
+}
+
+func TestChainFallsThroughChallenges(t *testing.T) {
+	ac := New(
+		testController{err: testChallenge{header: `Bearer realm="token"`}},
+		testController{user: "robot"},
+		testController{user: "unreachable"},
+	)
+
+	grant, err := ac.Authorized(httptest.NewRequest(http.MethodGet, "/v2/", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grant.User.Name != "robot" {
+		t.Fatalf("expected grant from second controller, got %q", grant.User.Name)
+	}
+}
+
+// filteringController only lets its user see repositories starting with its
+// user name.
+type filteringController struct {
+	testController
+}
+
+func (fc filteringController) FilterCatalog(ctx context.Context, grant *auth.Grant, repositories []string) ([]string, error) {
+	var visible []string
+	for _, name := range repositories {
+		if strings.HasPrefix(name, grant.User.Name+"/") {
+			visible = append(visible, name)
+		}
+	}
+	return visible, nil
+}
+
+func TestChainFiltersCatalogThroughIssuer(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/v2/_catalog", nil)
+	repositories := []string{"alice/app", "bob/app"}
+
+	for _, tc := range []struct {
+		controllers []auth.AccessController
+		expected    []string
+	}{
+		{[]auth.AccessController{filteringController{testController{user: "alice"}}}, []string{"alice/app"}},
+		{[]auth.AccessController{testController{user: "alice"}, filteringController{testController{user: "bob"}}}, repositories},
+	} {
+		ac := New(tc.controllers...)
+		grant, err := ac.Authorized(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		visible, err := ac.(auth.CatalogFilter).FilterCatalog(context.Background(), grant, repositories)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(visible, tc.expected) {
+			t.Fatalf("expected %v, got %v", tc.expected, visible)
+		}
+	}
+}
+
+func TestChainCombinesChallenges(t *testing.T) {
+	ac := New(
+		testController{err: testChallenge{header: `Bearer realm="token"`}},
+		testController{err: testChallenge{header: `Basic realm="basic"`}},
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/v2/", nil)
+	_, err := ac.Authorized(req)
+	ch, ok := err.(auth.Challenge)
+	if !ok {
+		t.Fatalf("expected challenge, got %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	ch.SetHeaders(req, rec)
+	values := rec.Header().Values("WWW-Authenticate")
+	if len(values) != 2 || values[0] != `Bearer realm="token"` || values[1] != `Basic realm="basic"` {
+		t.Fatalf("unexpected challenges: %v", values)
+	}
+}
+
+func TestChainStopsOnError(t *testing.T) {
+	failure := errors.New("backend unavailable")
+	ac := New(
+		testController{err: failure},
+		testController{user: "robot"},
+	)
+
+	if _, err := ac.Authorized(httptest.NewRequest(http.MethodGet, "/v2/", nil)); err != failure {
+		t.Fatalf("expected %v, got %v", failure, err)
+	}
+}
+
+func TestNewAccessController(t *testing.T) {
+	ac, err := auth.GetAccessController("chain", map[string]interface{}{
+		"controllers": []interface{}{
+			map[interface{}]interface{}{
+				"silly": map[interface{}]interface{}{"realm": "silly-realm", "service": "silly-service"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v2/", nil)
+	req.Header.Set("Authorization", "anything")
+	if _, err := ac.Authorized(req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, invalid := range []map[string]interface{}{
+		{},
+		{"controllers": []interface{}{}},
+		{"controllers": []interface{}{map[interface{}]interface{}{"unknown": nil}}},
+		{"controllers": []interface{}{map[interface{}]interface{}{"chain": nil}}},
+		{"controllers": []interface{}{map[interface{}]interface{}{"silly": nil, "token": nil}}},
+	} {
+		if _, err := auth.GetAccessController("chain", invalid); err == nil {
+			t.Errorf("expected error for options %v", invalid)
+		}
+	}
+}
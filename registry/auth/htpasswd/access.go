@@ -9,17 +9,24 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/internal/requestutil"
 	"github.com/distribution/distribution/v3/registry/auth"
+	"github.com/distribution/distribution/v3/registry/auth/internal/acl"
+	"github.com/distribution/distribution/v3/registry/auth/internal/lockout"
 	"github.com/sirupsen/logrus"
 )
 
@@ -35,9 +42,19 @@ type accessController struct {
 	modtime  time.Time
 	mu       sync.Mutex
 	htpasswd *htpasswd
+
+	acl     *acl.File
+	lockout *lockout.Limiter
 }
 
-var _ auth.AccessController = &accessController{}
+var (
+	_ auth.AccessController        = &accessController{}
+	_ auth.CredentialAuthenticator = &accessController{}
+	_ auth.UserChecker             = &accessController{}
+	_ auth.CatalogFilter           = &accessController{}
+	_ auth.AdminEnforcer           = &accessController{}
+	_ io.Closer                    = &accessController{}
+)
 
 func newAccessController(options map[string]interface{}) (auth.AccessController, error) {
 	realm, present := options["realm"]
@@ -53,18 +70,161 @@ func newAccessController(options map[string]interface{}) (auth.AccessController,
 	if err := createHtpasswdFile(path); err != nil {
 		return nil, err
 	}
-	return &accessController{realm: realm.(string), path: path}, nil
+
+	ac := &accessController{realm: realm.(string), path: path}
+
+	if aclOpt, present := options["acl"]; present {
+		aclPath, ok := aclOpt.(string)
+		if !ok || aclPath == "" {
+			return nil, fmt.Errorf(`"acl" must be a path for htpasswd access controller`)
+		}
+		f, err := acl.NewFile(aclPath)
+		if err != nil {
+			return nil, err
+		}
+		ac.acl = f
+	}
+
+	if lockoutOpt, present := options["lockout"]; present {
+		l, err := lockout.Parse("htpasswd", lockoutOpt)
+		if err != nil {
+			return nil, fmt.Errorf("htpasswd access controller: %v", err)
+		}
+		ac.lockout = l
+	}
+
+	return ac, nil
 }
 
 func (ac *accessController) Authorized(req *http.Request, accessRecords ...auth.Access) (*auth.Grant, error) {
 	username, password, ok := req.BasicAuth()
 	if !ok {
+		if ac.acl != nil && len(accessRecords) > 0 {
+			a, err := ac.acl.Load()
+			if err != nil {
+				return nil, err
+			}
+			if a.Allowed("", accessRecords...) {
+				return &auth.Grant{}, nil
+			}
+		}
 		return nil, &challenge{
 			realm: ac.realm,
 			err:   auth.ErrInvalidCredential,
 		}
 	}
 
+	ctx := req.Context()
+	addr := requestutil.PeerIP(req)
+	if ac.lockout != nil {
+		// Locked out clients are rejected before the password is checked,
+		// so that a lockout also spares the cost of bcrypt. Like failures
+		// which cannot be recorded, a lockout which cannot be checked does
+		// not lock every client out: the password is checked as usual.
+		locked, err := ac.lockout.Check(ctx, username, addr)
+		if err != nil {
+			dcontext.GetLogger(ctx).Errorf("error checking authentication lockout: %v", err)
+		}
+		if locked > 0 {
+			return nil, &challenge{
+				realm:      ac.realm,
+				err:        auth.ErrTooManyAttempts,
+				retryAfter: locked,
+			}
+		}
+	}
+
+	if err := ac.AuthenticateUser(username, password); err != nil {
+		if !errors.Is(err, auth.ErrAuthenticationFailure) {
+			return nil, err
+		}
+		dcontext.GetLogger(ctx).Errorf("error authenticating user %q: %v", username, err)
+		if ac.lockout != nil {
+			if _, err := ac.lockout.Fail(ctx, username, addr); err != nil {
+				dcontext.GetLogger(ctx).Errorf("error recording authentication failure: %v", err)
+			}
+		}
+		return nil, &challenge{
+			realm: ac.realm,
+			err:   auth.ErrAuthenticationFailure,
+		}
+	}
+
+	if ac.lockout != nil {
+		if err := ac.lockout.Succeed(ctx, username); err != nil {
+			dcontext.GetLogger(ctx).Errorf("error resetting authentication failures: %v", err)
+		}
+	}
+
+	if ac.acl != nil {
+		a, err := ac.acl.Load()
+		if err != nil {
+			return nil, err
+		}
+		if !a.Allowed(username, accessRecords...) {
+			dcontext.GetLogger(req.Context()).Warnf("user %q denied access to %v", username, accessRecords)
+			return nil, &challenge{
+				realm: ac.realm,
+				err:   auth.ErrAccessDenied,
+			}
+		}
+	}
+
+	return &auth.Grant{User: auth.UserInfo{Name: username}}, nil
+}
+
+// Close releases the lockout store, if a lockout is configured.
+func (ac *accessController) Close() error {
+	if ac.lockout == nil {
+		return nil
+	}
+	return ac.lockout.Close()
+}
+
+// EnforcesAdmin returns whether admin access is restricted by an acl.
+// Without one, every authenticated user is granted everything.
+func (ac *accessController) EnforcesAdmin() bool {
+	return ac.acl != nil
+}
+
+// FilterCatalog returns the repositories the grant's user may pull. Without
+// an acl, every repository is visible.
+func (ac *accessController) FilterCatalog(ctx context.Context, grant *auth.Grant, repositories []string) ([]string, error) {
+	if ac.acl == nil {
+		return repositories, nil
+	}
+	a, err := ac.acl.Load()
+	if err != nil {
+		return nil, err
+	}
+	return a.Visible(grant.User.Name, repositories), nil
+}
+
+// AuthenticateUser checks the given credential against the htpasswd file,
+// reloading the file first if it has been modified since it was last read.
+func (ac *accessController) AuthenticateUser(username, password string) error {
+	localHTPasswd, err := ac.load()
+	if err != nil {
+		return err
+	}
+
+	return localHTPasswd.authenticateUser(username, password)
+}
+
+// UserExists reports whether the htpasswd file lists username, reloading the
+// file first if it has been modified since it was last read.
+func (ac *accessController) UserExists(username string) (bool, error) {
+	localHTPasswd, err := ac.load()
+	if err != nil {
+		return false, err
+	}
+
+	_, ok := localHTPasswd.entries[username]
+	return ok, nil
+}
+
+// load returns the latest parsed contents of the htpasswd file.
+func (ac *accessController) load() (*htpasswd, error) {
 	// Dynamically parsing the latest account list
 	fstat, err := os.Stat(ac.path)
 	if err != nil {
@@ -73,54 +233,57 @@ func (ac *accessController) Authorized(req *http.Request, accessRecords ...auth.
 
 	lastModified := fstat.ModTime()
 	ac.mu.Lock()
+	defer ac.mu.Unlock()
 	if ac.htpasswd == nil || !ac.modtime.Equal(lastModified) {
-		ac.modtime = lastModified
-
 		f, err := os.Open(ac.path)
 		if err != nil {
-			ac.mu.Unlock()
 			return nil, err
 		}
 		defer f.Close()
 
 		h, err := newHTPasswd(f)
 		if err != nil {
-			ac.mu.Unlock()
 			return nil, err
 		}
+		ac.modtime = lastModified
 		ac.htpasswd = h
 	}
-	localHTPasswd := ac.htpasswd
-	ac.mu.Unlock()
 
-	if err := localHTPasswd.authenticateUser(username, password); err != nil {
-		dcontext.GetLogger(req.Context()).Errorf("error authenticating user %q: %v", username, err)
-		return nil, &challenge{
-			realm: ac.realm,
-			err:   auth.ErrAuthenticationFailure,
-		}
-	}
-
-	return &auth.Grant{User: auth.UserInfo{Name: username}}, nil
+	return ac.htpasswd, nil
 }
 
 // challenge implements the auth.Challenge interface.
 type challenge struct {
 	realm string
 	err   error
+
+	// retryAfter is set when the client has been locked out.
+	retryAfter time.Duration
 }
 
 var _ auth.Challenge = challenge{}
 
-// SetHeaders sets the basic challenge header on the response.
+// SetHeaders sets the basic challenge header on the response. A user denied
+// by the acl has already authenticated and is not challenged again.
 func (ch challenge) SetHeaders(r *http.Request, w http.ResponseWriter) {
+	if errors.Is(ch.err, auth.ErrAccessDenied) {
+		return
+	}
 	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", ch.realm))
+	if ch.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(ch.retryAfter.Seconds()))))
+	}
 }
 
 func (ch challenge) Error() string {
 	return fmt.Sprintf("basic authentication challenge for realm %q: %s", ch.realm, ch.err)
 }
 
+// Unwrap returns the reason for the challenge.
+func (ch challenge) Unwrap() error {
+	return ch.err
+}
+
 // createHtpasswdFile creates and populates htpasswd file with a new user in case the file is missing
 func createHtpasswdFile(path string) error {
 	if f, err := os.Open(path); err == nil {
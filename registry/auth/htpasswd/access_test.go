@@ -2,13 +2,21 @@ package htpasswd
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
 
 	"github.com/distribution/distribution/v3/registry/auth"
+	"github.com/distribution/distribution/v3/registry/auth/internal/lockout"
 )
 
 func TestBasicAccessController(t *testing.T) {
@@ -117,6 +125,31 @@ func TestBasicAccessController(t *testing.T) {
 	}
 }
 
+func TestUserExists(t *testing.T) {
+	htpasswdPath := filepath.Join(t.TempDir(), "htpasswd")
+	if err := os.WriteFile(htpasswdPath, []byte("frodo:$2y$05$926C3y10Quzn/LnqQH86VOEVh/18T6RnLaS.khre96jLNL/7e.K5W\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ac, err := newAccessController(map[string]interface{}{
+		"realm": "test",
+		"path":  htpasswdPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uc := ac.(auth.UserChecker)
+	for user, expected := range map[string]bool{"frodo": true, "bilbo": false} {
+		exists, err := uc.UserExists(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists != expected {
+			t.Errorf("%s: expected %v, got %v", user, expected, exists)
+		}
+	}
+}
+
 func TestCreateHtpasswdFile(t *testing.T) {
 	tempFile, err := os.CreateTemp("", "htpasswd-test")
 	if err != nil {
@@ -154,3 +187,165 @@ func TestCreateHtpasswdFile(t *testing.T) {
 		t.Fatalf("failed to find default user in file %s", string(content))
 	}
 }
+
+func TestLockout(t *testing.T) {
+	dir := t.TempDir()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	htpasswdPath := filepath.Join(dir, "htpasswd")
+	if err := os.WriteFile(htpasswdPath, []byte("frodo:"+string(hash)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm": "test",
+		"path":  htpasswdPath,
+		"lockout": map[interface{}]interface{}{
+			"attempts": 2,
+			"duration": "1h",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authorize := func(password string) error {
+		req := httptest.NewRequest(http.MethodGet, "/v2/", nil)
+		req.SetBasicAuth("frodo", password)
+		_, err := ac.Authorized(req)
+		return err
+	}
+
+	if err := authorize("password"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := authorize("wrong"); !errors.Is(err, auth.ErrAuthenticationFailure) {
+			t.Fatalf("expected authentication failure, got %v", err)
+		}
+	}
+
+	// The correct password is rejected during the lockout.
+	err = authorize("password")
+	if !errors.Is(err, auth.ErrTooManyAttempts) {
+		t.Fatalf("expected lockout, got %v", err)
+	}
+	rec := httptest.NewRecorder()
+	err.(auth.Challenge).SetHeaders(httptest.NewRequest(http.MethodGet, "/v2/", nil), rec)
+	if retryAfter := rec.Header().Get("Retry-After"); retryAfter != "3600" {
+		t.Fatalf("expected Retry-After of 3600, got %q", retryAfter)
+	}
+
+	if _, err := newAccessController(map[string]interface{}{
+		"realm":   "test",
+		"path":    htpasswdPath,
+		"lockout": map[interface{}]interface{}{"attempts": "many"},
+	}); err == nil {
+		t.Fatal("expected invalid lockout option to be rejected")
+	}
+}
+
+func TestLockoutAddress(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	htpasswdPath := filepath.Join(t.TempDir(), "htpasswd")
+	if err := os.WriteFile(htpasswdPath, []byte("frodo:"+string(hash)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":   "test",
+		"path":    htpasswdPath,
+		"lockout": map[interface{}]interface{}{"addrattempts": 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A client cannot escape the lockout of its address by claiming
+	// another one in a proxy header.
+	for i, forwarded := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		req := httptest.NewRequest(http.MethodGet, "/v2/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Forwarded-For", forwarded)
+		req.SetBasicAuth(fmt.Sprintf("user%d", i), "wrong")
+		_, err := ac.Authorized(req)
+		if i < 2 && !errors.Is(err, auth.ErrAuthenticationFailure) {
+			t.Fatalf("expected authentication failure, got %v", err)
+		}
+		if i == 2 && !errors.Is(err, auth.ErrTooManyAttempts) {
+			t.Fatalf("expected the address to be locked out, got %v", err)
+		}
+	}
+}
+
+// unavailableStore is a lockout store whose backend cannot be reached.
+type unavailableStore struct {
+	closed bool
+}
+
+var errUnavailable = errors.New("lockout store unavailable")
+
+func (s *unavailableStore) Locked(ctx context.Context, key string) (time.Duration, error) {
+	return 0, errUnavailable
+}
+
+func (s *unavailableStore) Fail(ctx context.Context, key string, limit int, window, duration time.Duration) (time.Duration, error) {
+	return 0, errUnavailable
+}
+
+func (s *unavailableStore) Reset(ctx context.Context, key string) error {
+	return errUnavailable
+}
+
+func (s *unavailableStore) Close() error {
+	s.closed = true
+	return nil
+}
+
+// TestLockoutUnavailable checks that logins are checked without the lockout
+// while its store is unavailable, and that closing the controller closes the
+// store.
+func TestLockoutUnavailable(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	htpasswdPath := filepath.Join(t.TempDir(), "htpasswd")
+	if err := os.WriteFile(htpasswdPath, []byte("frodo:"+string(hash)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := &unavailableStore{}
+	ac := &accessController{
+		realm:   "test",
+		path:    htpasswdPath,
+		lockout: lockout.New("htpasswd", lockout.Policy{UserAttempts: 1, Window: time.Minute, Duration: time.Hour}, store),
+	}
+
+	authorize := func(password string) error {
+		req := httptest.NewRequest(http.MethodGet, "/v2/", nil)
+		req.SetBasicAuth("frodo", password)
+		_, err := ac.Authorized(req)
+		return err
+	}
+
+	if err := authorize("wrong"); !errors.Is(err, auth.ErrAuthenticationFailure) {
+		t.Fatalf("expected authentication failure, got %v", err)
+	}
+	if err := authorize("password"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := ac.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !store.closed {
+		t.Fatal("expected the lockout store to be closed")
+	}
+}
@@ -0,0 +1,111 @@
+package htpasswd
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/distribution/distribution/v3/registry/auth"
+)
+
+const testACL = `
+groups:
+  ci: [robot]
+rules:
+  - groups: [ci]
+    repositories: ["team-a/*"]
+    actions: [pull, push]
+anonymous:
+  - repositories: ["public/**"]
+    actions: [pull]
+`
+
+func repoAccess(name, action string) auth.Access {
+	return auth.Access{
+		Resource: auth.Resource{Type: "repository", Name: name},
+		Action:   action,
+	}
+}
+
+func TestACLAccessController(t *testing.T) {
+	dir := t.TempDir()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	htpasswdPath := filepath.Join(dir, "htpasswd")
+	if err := os.WriteFile(htpasswdPath, []byte("robot:"+string(hash)+"\nother:"+string(hash)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	aclPath := filepath.Join(dir, "acl.yml")
+	if err := os.WriteFile(aclPath, []byte(testACL), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm": "test",
+		"path":  htpasswdPath,
+		"acl":   aclPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authorized := func(user string, access ...auth.Access) error {
+		req := httptest.NewRequest(http.MethodGet, "/v2/", nil)
+		if user != "" {
+			req.SetBasicAuth(user, "password")
+		}
+		_, err := ac.Authorized(req, access...)
+		return err
+	}
+
+	if err := authorized("robot", repoAccess("team-a/app", "push")); err != nil {
+		t.Fatalf("expected robot to push to team-a/app: %v", err)
+	}
+	if err := authorized("other", repoAccess("team-a/app", "push")); err == nil {
+		t.Fatal("expected other to be denied push to team-a/app")
+	} else if ch, ok := err.(auth.Challenge); !ok {
+		t.Fatalf("expected a challenge, got %v", err)
+	} else {
+		if !errors.Is(err, auth.ErrAccessDenied) {
+			t.Fatalf("expected access to be denied, got %v", err)
+		}
+		rec := httptest.NewRecorder()
+		ch.SetHeaders(httptest.NewRequest(http.MethodGet, "/v2/", nil), rec)
+		if h := rec.Header().Get("WWW-Authenticate"); h != "" {
+			t.Fatalf("expected no basic challenge for an authenticated user, got %q", h)
+		}
+	}
+	if err := authorized("", repoAccess("public/app", "pull")); err != nil {
+		t.Fatalf("expected anonymous pull of public/app: %v", err)
+	}
+	if err := authorized(""); err == nil {
+		t.Fatal("expected anonymous request to the base route to be challenged")
+	}
+
+	// Updating the acl file takes effect without recreating the controller.
+	updated := strings.Replace(testACL, "groups: [ci]", "users: [other]", 1)
+	if err := os.WriteFile(aclPath, []byte(updated), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(aclPath, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := authorized("other", repoAccess("team-a/app", "push")); err != nil {
+		t.Fatalf("expected reloaded acl to allow other to push: %v", err)
+	}
+	if err := authorized("robot", repoAccess("team-a/app", "push")); err == nil {
+		t.Fatal("expected reloaded acl to deny robot")
+	}
+}
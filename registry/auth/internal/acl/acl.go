@@ -0,0 +1,286 @@
+// Package acl implements the access control list file shared by access
+// controllers which authenticate users but do not carry authorization
+// information themselves.
+package acl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/distribution/distribution/v3/internal/glob"
+	"github.com/distribution/distribution/v3/registry/auth"
+)
+
+// aclFile is the on-disk format of the access control list.
+//
+//	groups:
+//	  ci: [robot-a, robot-b]
+//	rules:
+//	  - users: [alice]
+//	    repositories: ["**"]
+//	    actions: ["*"]
+//	    catalog: true
+//	    admin: true
+//	  - groups: [ci]
+//	    repositories: ["team-a/**"]
+//	    actions: [pull, push]
+//	anonymous:
+//	  - repositories: ["public/**"]
+//	    actions: [pull]
+type aclFile struct {
+	Groups    map[string][]string `yaml:"groups"`
+	Rules     []aclRuleSpec       `yaml:"rules"`
+	Anonymous []aclRuleSpec       `yaml:"anonymous"`
+}
+
+type aclRuleSpec struct {
+	Users        []string `yaml:"users"`
+	Groups       []string `yaml:"groups"`
+	Repositories []string `yaml:"repositories"`
+	Actions      []string `yaml:"actions"`
+	Catalog      bool     `yaml:"catalog"`
+	Admin        bool     `yaml:"admin"`
+}
+
+// aclRule is a parsed rule. A rule applies to a user if the user is listed
+// directly, is a member of one of the listed groups, or if the users list
+// contains "*".
+type aclRule struct {
+	users        map[string]struct{}
+	repositories []*regexp.Regexp
+	actions      map[string]struct{}
+	catalog      bool
+	admin        bool
+}
+
+// ACL maps users to the repository actions they are permitted.
+type ACL struct {
+	rules     []aclRule
+	anonymous []aclRule
+}
+
+// anonymousActions are the only actions which may be granted to
+// unauthenticated requests.
+var anonymousActions = map[string]struct{}{"pull": {}}
+
+// Parse parses an access control list from rd.
+func Parse(rd io.Reader) (*ACL, error) {
+	raw, err := io.ReadAll(rd)
+	if err != nil {
+		return nil, err
+	}
+
+	var f aclFile
+	if err := yaml.UnmarshalStrict(raw, &f); err != nil {
+		return nil, fmt.Errorf("acl: %v", err)
+	}
+
+	a := &ACL{}
+	for i, spec := range f.Rules {
+		users := map[string]struct{}{}
+		for _, u := range spec.Users {
+			users[u] = struct{}{}
+		}
+		for _, g := range spec.Groups {
+			members, ok := f.Groups[g]
+			if !ok {
+				return nil, fmt.Errorf("acl: rule %d references unknown group %q", i, g)
+			}
+			for _, u := range members {
+				users[u] = struct{}{}
+			}
+		}
+		if len(users) == 0 {
+			return nil, fmt.Errorf("acl: rule %d applies to no users", i)
+		}
+
+		rule, err := newACLRule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("acl: rule %d: %v", i, err)
+		}
+		rule.users = users
+		a.rules = append(a.rules, rule)
+	}
+
+	for i, spec := range f.Anonymous {
+		if len(spec.Users) > 0 || len(spec.Groups) > 0 {
+			return nil, fmt.Errorf("acl: anonymous rule %d must not list users or groups", i)
+		}
+		if spec.Admin {
+			return nil, fmt.Errorf("acl: anonymous rule %d cannot grant admin access", i)
+		}
+		for _, action := range spec.Actions {
+			if _, ok := anonymousActions[action]; !ok {
+				return nil, fmt.Errorf("acl: anonymous rule %d: action %q cannot be granted anonymously", i, action)
+			}
+		}
+
+		rule, err := newACLRule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("acl: anonymous rule %d: %v", i, err)
+		}
+		a.anonymous = append(a.anonymous, rule)
+	}
+
+	return a, nil
+}
+
+func newACLRule(spec aclRuleSpec) (aclRule, error) {
+	rule := aclRule{
+		actions: map[string]struct{}{},
+		catalog: spec.Catalog,
+		admin:   spec.Admin,
+	}
+	for _, pattern := range spec.Repositories {
+		re, err := glob.Compile(pattern)
+		if err != nil {
+			return aclRule{}, err
+		}
+		rule.repositories = append(rule.repositories, re)
+	}
+	for _, action := range spec.Actions {
+		switch action {
+		case "pull", "push", "delete", "*":
+		default:
+			return aclRule{}, fmt.Errorf("unknown action %q", action)
+		}
+		rule.actions[action] = struct{}{}
+	}
+	return rule, nil
+}
+
+// appliesTo returns whether the rule applies to the named user.
+func (r aclRule) appliesTo(username string) bool {
+	if _, ok := r.users[username]; ok {
+		return true
+	}
+	_, ok := r.users["*"]
+	return ok
+}
+
+// permits returns whether the rule permits the access.
+func (r aclRule) permits(access auth.Access) bool {
+	switch access.Type {
+	case "repository":
+		if _, ok := r.actions["*"]; !ok {
+			if _, ok := r.actions[access.Action]; !ok {
+				return false
+			}
+		}
+		for _, re := range r.repositories {
+			if re.MatchString(access.Name) {
+				return true
+			}
+		}
+		return false
+	case "registry":
+		switch access.Name {
+		case "catalog":
+			return r.catalog
+		case "admin":
+			return r.admin
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+// Allowed returns whether username may perform every requested access. The
+// anonymous rules apply to every user, so that authenticating never loses
+// access; an empty username only checks them.
+func (a *ACL) Allowed(username string, accessRecords ...auth.Access) bool {
+	rules := a.anonymous
+	if username != "" {
+		rules = append([]aclRule(nil), a.anonymous...)
+		for _, r := range a.rules {
+			if r.appliesTo(username) {
+				rules = append(rules, r)
+			}
+		}
+	}
+
+	for _, access := range accessRecords {
+		permitted := false
+		for _, r := range rules {
+			if r.permits(access) {
+				permitted = true
+				break
+			}
+		}
+		if !permitted {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Visible returns the repositories which username may pull, in the order
+// given.
+func (a *ACL) Visible(username string, repositories []string) []string {
+	visible := make([]string, 0, len(repositories))
+	for _, name := range repositories {
+		access := auth.Access{
+			Resource: auth.Resource{Type: "repository", Name: name},
+			Action:   "pull",
+		}
+		if a.Allowed(username, access) {
+			visible = append(visible, name)
+		}
+	}
+	return visible
+}
+
+// File is an access control list loaded from disk. It is reloaded whenever
+// the file's modification time changes.
+type File struct {
+	path    string
+	mu      sync.Mutex
+	modtime time.Time
+	acl     *ACL
+}
+
+// NewFile returns a File for path, failing early if the file is missing or
+// invalid.
+func NewFile(path string) (*File, error) {
+	f := &File{path: path}
+	if _, err := f.Load(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Load returns the latest parsed contents of the file.
+func (f *File) Load() (*ACL, error) {
+	fstat, err := os.Stat(f.path)
+	if err != nil {
+		return nil, err
+	}
+
+	lastModified := fstat.ModTime()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.acl == nil || !f.modtime.Equal(lastModified) {
+		fp, err := os.Open(f.path)
+		if err != nil {
+			return nil, err
+		}
+		defer fp.Close()
+
+		a, err := Parse(fp)
+		if err != nil {
+			return nil, err
+		}
+		f.modtime = lastModified
+		f.acl = a
+	}
+
+	return f.acl, nil
+}
@@ -0,0 +1,88 @@
+package acl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/distribution/distribution/v3/registry/auth"
+)
+
+const testACL = `
+groups:
+  ci: [robot]
+rules:
+  - users: [admin]
+    repositories: ["**"]
+    actions: ["*"]
+    catalog: true
+    admin: true
+  - groups: [ci]
+    repositories: ["team-a/*"]
+    actions: [pull, push]
+  - users: ["*"]
+    repositories: ["shared/**"]
+    actions: [pull]
+anonymous:
+  - repositories: ["public/**"]
+    actions: [pull]
+`
+
+func repoAccess(name, action string) auth.Access {
+	return auth.Access{
+		Resource: auth.Resource{Type: "repository", Name: name},
+		Action:   action,
+	}
+}
+
+func TestACLAllowed(t *testing.T) {
+	a, err := Parse(strings.NewReader(testACL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	catalog := auth.Access{Resource: auth.Resource{Type: "registry", Name: "catalog"}, Action: "*"}
+	admin := auth.Access{Resource: auth.Resource{Type: "registry", Name: "admin"}, Action: "*"}
+
+	for _, tc := range []struct {
+		user     string
+		access   []auth.Access
+		expected bool
+	}{
+		{"admin", []auth.Access{repoAccess("any/deep/repo", "delete")}, true},
+		{"admin", []auth.Access{catalog}, true},
+		{"admin", []auth.Access{admin}, true},
+		{"robot", []auth.Access{repoAccess("team-a/app", "pull"), repoAccess("team-a/app", "push")}, true},
+		{"robot", []auth.Access{repoAccess("team-a/app", "delete")}, false},
+		{"robot", []auth.Access{repoAccess("team-a/app/nested", "pull")}, false},
+		{"robot", []auth.Access{repoAccess("team-b/app", "pull")}, false},
+		{"robot", []auth.Access{catalog}, false},
+		{"robot", []auth.Access{admin}, false},
+		{"someone", []auth.Access{repoAccess("shared/base/image", "pull")}, true},
+		{"someone", []auth.Access{repoAccess("shared/base/image", "push")}, false},
+		{"someone", []auth.Access{repoAccess("public/image", "pull")}, true},
+		{"robot", []auth.Access{repoAccess("public/image", "pull"), repoAccess("team-a/app", "pull")}, true},
+		{"someone", []auth.Access{repoAccess("public/image", "push")}, false},
+		{"", []auth.Access{repoAccess("public/image", "pull")}, true},
+		{"", []auth.Access{repoAccess("public/image", "push")}, false},
+		{"", []auth.Access{repoAccess("shared/image", "pull")}, false},
+	} {
+		if got := a.Allowed(tc.user, tc.access...); got != tc.expected {
+			t.Errorf("Allowed(%q, %v): expected %v, got %v", tc.user, tc.access, tc.expected, got)
+		}
+	}
+}
+
+func TestACLInvalid(t *testing.T) {
+	for _, invalid := range []string{
+		"rules:\n  - groups: [missing]\n    repositories: [foo]\n    actions: [pull]\n",
+		"rules:\n  - repositories: [foo]\n    actions: [pull]\n",
+		"rules:\n  - users: [a]\n    repositories: [foo]\n    actions: [write]\n",
+		"anonymous:\n  - repositories: [foo]\n    actions: [push]\n",
+		"anonymous:\n  - repositories: [foo]\n    actions: [pull]\n    admin: true\n",
+		"unknown: true\n",
+	} {
+		if _, err := Parse(strings.NewReader(invalid)); err == nil {
+			t.Errorf("expected error parsing acl %q", invalid)
+		}
+	}
+}
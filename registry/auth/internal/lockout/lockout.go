@@ -0,0 +1,228 @@
+// Package lockout tracks failed authentication attempts and temporarily
+// locks out users and client addresses which fail too often.
+package lockout
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	prometheus "github.com/distribution/distribution/v3/metrics"
+	"github.com/distribution/distribution/v3/registry/auth/internal/options"
+	"github.com/docker/go-metrics"
+)
+
+var (
+	// failures counts failed authentication attempts.
+	failures = prometheus.AuthNamespace.NewLabeledCounter("failures", "The number of failed authentication attempts", "controller")
+	// lockouts counts users and addresses which were locked out.
+	lockouts = prometheus.AuthNamespace.NewLabeledCounter("lockouts", "The number of lockouts after repeated authentication failures", "controller", "scope")
+	// rejected counts requests rejected because of a lockout.
+	rejected = prometheus.AuthNamespace.NewLabeledCounter("lockout_rejections", "The number of requests rejected during a lockout", "controller")
+)
+
+func init() {
+	metrics.Register(prometheus.AuthNamespace)
+}
+
+const (
+	defaultWindow   = 10 * time.Minute
+	defaultDuration = 15 * time.Minute
+	defaultKey      = "registry:lockout"
+)
+
+// Store keeps failure counts and lockouts.
+type Store interface {
+	// Locked returns how much longer key is locked out, or zero.
+	Locked(ctx context.Context, key string) (time.Duration, error)
+
+	// Fail records a failed attempt for key. Once limit failures have been
+	// recorded within window, key is locked out for duration, and Fail
+	// returns the length of the lockout.
+	Fail(ctx context.Context, key string, limit int, window, duration time.Duration) (time.Duration, error)
+
+	// Reset forgets the failed attempts of key.
+	Reset(ctx context.Context, key string) error
+}
+
+// Policy configures when lockouts happen. A zero attempt limit disables
+// tracking for that scope.
+type Policy struct {
+	// UserAttempts is the number of failures after which a user is locked
+	// out.
+	UserAttempts int
+
+	// AddrAttempts is the number of failures after which a client address
+	// is locked out.
+	AddrAttempts int
+
+	// Window is the period over which failures are counted.
+	Window time.Duration
+
+	// Duration is how long a lockout lasts.
+	Duration time.Duration
+}
+
+// Limiter applies a lockout policy for an access controller.
+type Limiter struct {
+	controller string
+	policy     Policy
+	store      Store
+}
+
+// New returns a Limiter for the named access controller.
+func New(controller string, policy Policy, store Store) *Limiter {
+	return &Limiter{controller: controller, policy: policy, store: store}
+}
+
+// Check returns how much longer username or addr are locked out, or zero if
+// neither is.
+func (l *Limiter) Check(ctx context.Context, username, addr string) (time.Duration, error) {
+	var locked time.Duration
+	for _, key := range l.keys(username, addr) {
+		d, err := l.store.Locked(ctx, key.key)
+		if err != nil {
+			return 0, err
+		}
+		if d > locked {
+			locked = d
+		}
+	}
+	if locked > 0 {
+		rejected.WithValues(l.controller).Inc(1)
+	}
+	return locked, nil
+}
+
+// Fail records a failed attempt by username from addr. If it causes a
+// lockout, the length of the lockout is returned and a security event is
+// logged.
+func (l *Limiter) Fail(ctx context.Context, username, addr string) (time.Duration, error) {
+	failures.WithValues(l.controller).Inc(1)
+
+	var locked time.Duration
+	for _, key := range l.keys(username, addr) {
+		d, err := l.store.Fail(ctx, key.key, key.limit, l.policy.Window, l.policy.Duration)
+		if err != nil {
+			return 0, err
+		}
+		if d > 0 {
+			lockouts.WithValues(l.controller, key.scope).Inc(1)
+			dcontext.GetLoggerWithFields(ctx, map[interface{}]interface{}{
+				"auth.controller":     l.controller,
+				"auth.lockout.scope":  key.scope,
+				"auth.lockout.user":   username,
+				"auth.lockout.addr":   addr,
+				"auth.lockout.length": d.String(),
+			}).Warn("authentication lockout")
+		}
+		if d > locked {
+			locked = d
+		}
+	}
+	return locked, nil
+}
+
+// Succeed forgets the failed attempts of username after it authenticated.
+// Failures recorded against the client address are kept.
+func (l *Limiter) Succeed(ctx context.Context, username string) error {
+	if l.policy.UserAttempts <= 0 || username == "" {
+		return nil
+	}
+	return l.store.Reset(ctx, "user:"+username)
+}
+
+// Close releases the store of the limiter, such as its redis client.
+func (l *Limiter) Close() error {
+	if c, ok := l.store.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+type limiterKey struct {
+	scope string
+	key   string
+	limit int
+}
+
+func (l *Limiter) keys(username, addr string) []limiterKey {
+	var keys []limiterKey
+	if l.policy.UserAttempts > 0 && username != "" {
+		keys = append(keys, limiterKey{scope: "user", key: "user:" + username, limit: l.policy.UserAttempts})
+	}
+	if l.policy.AddrAttempts > 0 && addr != "" {
+		keys = append(keys, limiterKey{scope: "addr", key: "addr:" + addr, limit: l.policy.AddrAttempts})
+	}
+	return keys
+}
+
+// Parse configures a Limiter from the "lockout" option of an access
+// controller:
+//
+//	lockout:
+//	  attempts: 5
+//	  addrattempts: 20
+//	  window: 10m
+//	  duration: 15m
+//	  redis:
+//	    addrs: [localhost:6379]
+//	    key: registry:lockout
+//
+// Failures are tracked in memory unless redis is configured.
+func Parse(controller string, v interface{}) (*Limiter, error) {
+	opts, err := options.StringMap(v)
+	if err != nil {
+		return nil, errors.New("lockout must be a map")
+	}
+
+	policy := Policy{
+		Window:   defaultWindow,
+		Duration: defaultDuration,
+	}
+	for key, dst := range map[string]*int{"attempts": &policy.UserAttempts, "addrattempts": &policy.AddrAttempts} {
+		if v, ok := opts[key]; ok {
+			n, ok := v.(int)
+			if !ok || n < 0 {
+				return nil, fmt.Errorf("lockout %s must be a non-negative integer", key)
+			}
+			*dst = n
+		}
+	}
+	if policy.UserAttempts == 0 && policy.AddrAttempts == 0 {
+		return nil, errors.New("lockout requires attempts or addrattempts")
+	}
+	for key, dst := range map[string]*time.Duration{"window": &policy.Window, "duration": &policy.Duration} {
+		if v, ok := opts[key]; ok {
+			d, err := options.Duration(v)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("lockout %s must be a positive duration", key)
+			}
+			*dst = d
+		}
+	}
+
+	var store Store = NewMemoryStore()
+	if v, ok := opts["redis"]; ok {
+		redisOpts, err := options.StringMap(v)
+		if err != nil {
+			return nil, errors.New("lockout redis must be a map")
+		}
+		key := defaultKey
+		if v, ok := redisOpts["key"]; ok {
+			if key, ok = v.(string); !ok || key == "" {
+				return nil, errors.New("lockout redis key must be a string")
+			}
+		}
+		client, err := options.RedisClient(redisOpts)
+		if err != nil {
+			return nil, fmt.Errorf("lockout redis: %v", err)
+		}
+		store = NewRedisStore(client, key)
+	}
+
+	return New(controller, policy, store), nil
+}
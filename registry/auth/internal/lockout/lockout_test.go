@@ -0,0 +1,178 @@
+package lockout
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore().(*memoryStore)
+	s.now = func() time.Time { return now }
+
+	testStore(t, s, func(d time.Duration) { now = now.Add(d) })
+
+	// Failures outside the window are forgotten.
+	for i := 0; i < 2; i++ {
+		if d, err := s.Fail(ctx, "window", 3, time.Minute, time.Hour); err != nil || d != 0 {
+			t.Fatalf("unexpected lockout %v: %v", d, err)
+		}
+	}
+	now = now.Add(2 * time.Minute)
+	if d, err := s.Fail(ctx, "window", 3, time.Minute, time.Hour); err != nil || d != 0 {
+		t.Fatalf("expected failures outside the window to be forgotten, got lockout %v: %v", d, err)
+	}
+
+	// Expired entries are pruned.
+	now = now.Add(2 * time.Hour)
+	if _, err := s.Fail(ctx, "other", 3, time.Minute, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.entries) != 1 {
+		t.Fatalf("expected expired entries to be pruned, have %d", len(s.entries))
+	}
+}
+
+// TestRedisStore exercises a live redis instance.
+func TestRedisStore(t *testing.T) {
+	redisAddr := os.Getenv("TEST_REGISTRY_AUTH_LOCKOUT_REDIS_ADDR")
+	if redisAddr == "" {
+		t.Skip("please set TEST_REGISTRY_AUTH_LOCKOUT_REDIS_ADDR to test the lockout store against redis")
+	}
+
+	l, err := Parse("test", map[interface{}]interface{}{
+		"attempts": 3,
+		"redis": map[interface{}]interface{}{
+			"addrs": []interface{}{redisAddr},
+			"key":   "test:lockout",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := l.store.(*redisStore)
+	ctx := context.Background()
+	if err := s.client.Del(ctx, s.failKey("key"), s.lockKey("key")).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	testStore(t, s, func(d time.Duration) { time.Sleep(d) })
+}
+
+// testStore checks a store locks a key out after three failures within the
+// window, for a lockout of 500ms. advance moves the clock of the store.
+func testStore(t *testing.T, s Store, advance func(time.Duration)) {
+	t.Helper()
+	ctx := context.Background()
+	window, duration := time.Minute, 500*time.Millisecond
+
+	fail := func() time.Duration {
+		d, err := s.Fail(ctx, "key", 3, window, duration)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	locked := func() time.Duration {
+		d, err := s.Locked(ctx, "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+
+	if d := fail(); d != 0 {
+		t.Fatalf("unexpected lockout after one failure: %v", d)
+	}
+	if err := s.Reset(ctx, "key"); err != nil {
+		t.Fatal(err)
+	}
+	fail()
+	fail()
+	if d := locked(); d != 0 {
+		t.Fatalf("unexpected lockout after reset: %v", d)
+	}
+	if d := fail(); d != duration {
+		t.Fatalf("expected lockout of %v, got %v", duration, d)
+	}
+	if d := locked(); d <= 0 || d > duration {
+		t.Fatalf("expected key to be locked out, got %v", d)
+	}
+
+	advance(duration + 100*time.Millisecond)
+	if d := locked(); d != 0 {
+		t.Fatalf("expected lockout to have ended, got %v", d)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	ctx := context.Background()
+	l := New("test", Policy{
+		UserAttempts: 2,
+		AddrAttempts: 3,
+		Window:       time.Minute,
+		Duration:     time.Hour,
+	}, NewMemoryStore())
+
+	for _, user := range []string{"alice", "bob"} {
+		if d, err := l.Fail(ctx, user, "192.0.2.1"); err != nil || d != 0 {
+			t.Fatalf("unexpected lockout %v: %v", d, err)
+		}
+	}
+	if err := l.Succeed(ctx, "bob"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The address is locked out on its third failure, even though each
+	// user has failed fewer times than their own limit.
+	if d, err := l.Fail(ctx, "carol", "192.0.2.1"); err != nil || d != time.Hour {
+		t.Fatalf("expected address lockout, got %v: %v", d, err)
+	}
+	if d, err := l.Check(ctx, "dave", "192.0.2.1"); err != nil || d <= 0 {
+		t.Fatalf("expected address to be locked out, got %v: %v", d, err)
+	}
+	if d, err := l.Check(ctx, "dave", "192.0.2.2"); err != nil || d != 0 {
+		t.Fatalf("unexpected lockout %v: %v", d, err)
+	}
+
+	// Users are locked out from every address.
+	if d, err := l.Fail(ctx, "alice", "192.0.2.2"); err != nil || d != time.Hour {
+		t.Fatalf("expected user lockout, got %v: %v", d, err)
+	}
+	if d, err := l.Check(ctx, "alice", "192.0.2.3"); err != nil || d <= 0 {
+		t.Fatalf("expected user to be locked out, got %v: %v", d, err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	l, err := Parse("test", map[interface{}]interface{}{
+		"attempts": 5,
+		"window":   "1m",
+		"duration": 60,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Policy{UserAttempts: 5, Window: time.Minute, Duration: time.Minute}
+	if l.policy != expected {
+		t.Fatalf("expected policy %+v, got %+v", expected, l.policy)
+	}
+
+	for _, invalid := range []interface{}{
+		"5",
+		map[interface{}]interface{}{},
+		map[interface{}]interface{}{"attempts": -1},
+		map[interface{}]interface{}{"attempts": "five"},
+		map[interface{}]interface{}{"attempts": 5, "window": "soon"},
+		map[interface{}]interface{}{"attempts": 5, "duration": "0s"},
+		map[interface{}]interface{}{"attempts": 5, "redis": map[interface{}]interface{}{}},
+		map[interface{}]interface{}{"attempts": 5, "redis": map[interface{}]interface{}{"addrs": []interface{}{"localhost:6379"}, "key": 1}},
+	} {
+		if _, err := Parse("test", invalid); err == nil {
+			t.Errorf("expected error for lockout options %v", invalid)
+		}
+	}
+}
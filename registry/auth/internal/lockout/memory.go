@@ -0,0 +1,96 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry struct {
+	failures    int
+	windowEnd   time.Time
+	lockedUntil time.Time
+}
+
+// memoryStore keeps failures in process memory. Lockouts are not shared
+// between registry instances.
+type memoryStore struct {
+	mu        sync.Mutex
+	entries   map[string]*memoryEntry
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore returns a Store which keeps failures in process memory.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		entries: make(map[string]*memoryEntry),
+		now:     time.Now,
+	}
+}
+
+func (s *memoryStore) Locked(ctx context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		return 0, nil
+	}
+	if d := e.lockedUntil.Sub(s.now()); d > 0 {
+		return d, nil
+	}
+	return 0, nil
+}
+
+func (s *memoryStore) Fail(ctx context.Context, key string, limit int, window, duration time.Duration) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.prune(now)
+
+	e, ok := s.entries[key]
+	if !ok {
+		e = &memoryEntry{}
+		s.entries[key] = e
+	}
+	if now.After(e.windowEnd) {
+		e.failures = 0
+		e.windowEnd = now.Add(window)
+	}
+	e.failures++
+	if e.failures < limit {
+		return 0, nil
+	}
+
+	e.failures = 0
+	e.windowEnd = now.Add(window)
+	e.lockedUntil = now.Add(duration)
+	return duration, nil
+}
+
+func (s *memoryStore) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		e.failures = 0
+	}
+	return nil
+}
+
+// prune drops entries whose window and lockout have both passed, so that
+// failures from many distinct users or addresses do not accumulate. It runs
+// at most once a minute.
+func (s *memoryStore) prune(now time.Time) {
+	if now.Sub(s.lastPrune) < time.Minute {
+		return
+	}
+	s.lastPrune = now
+	for key, e := range s.entries {
+		if now.After(e.windowEnd) && now.After(e.lockedUntil) {
+			delete(s.entries, key)
+		}
+	}
+}
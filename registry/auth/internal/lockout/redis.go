@@ -0,0 +1,85 @@
+package lockout
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisStore keeps failures in redis, so that lockouts apply across every
+// registry instance sharing it. Failures are counted in <prefix>:fail:{<key>},
+// which expires at the end of the window, and lockouts are held in
+// <prefix>:lock:{<key>}, which expires when the lockout ends. The braces keep
+// both keys of a client in the same slot of a redis cluster.
+type redisStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisStore returns a Store which keeps failures in redis under keys
+// starting with prefix. The store closes client when it is closed.
+func NewRedisStore(client redis.UniversalClient, prefix string) Store {
+	return &redisStore{client: client, prefix: prefix}
+}
+
+// Close closes the redis client.
+func (s *redisStore) Close() error {
+	return s.client.Close()
+}
+
+func (s *redisStore) failKey(key string) string {
+	return s.prefix + ":fail:{" + key + "}"
+}
+
+func (s *redisStore) lockKey(key string) string {
+	return s.prefix + ":lock:{" + key + "}"
+}
+
+// failScript counts a failure in KEYS[1], starting a window of ARGV[1]
+// milliseconds with the first one. Once ARGV[2] failures are counted, it
+// locks the client out by setting KEYS[2] for ARGV[3] milliseconds and
+// clears the count. It returns whether the client was locked out. Running
+// as a script, the count can neither be left without an expiry nor be
+// raced past the limit by concurrent failures.
+var failScript = redis.NewScript(`
+local failures = redis.call("INCR", KEYS[1])
+if failures == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+if failures < tonumber(ARGV[2]) then
+	return 0
+end
+redis.call("SET", KEYS[2], 1, "PX", ARGV[3])
+redis.call("DEL", KEYS[1])
+return 1
+`)
+
+func (s *redisStore) Locked(ctx context.Context, key string) (time.Duration, error) {
+	d, err := s.client.PTTL(ctx, s.lockKey(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+	// PTTL reports missing keys and keys without an expiry as negative
+	// durations.
+	if d < 0 {
+		return 0, nil
+	}
+	return d, nil
+}
+
+func (s *redisStore) Fail(ctx context.Context, key string, limit int, window, duration time.Duration) (time.Duration, error) {
+	keys := []string{s.failKey(key), s.lockKey(key)}
+	locked, err := failScript.Run(ctx, s.client, keys, window.Milliseconds(), limit, duration.Milliseconds()).Int()
+	if err != nil {
+		return 0, err
+	}
+	if locked == 0 {
+		return 0, nil
+	}
+	return duration, nil
+}
+
+func (s *redisStore) Reset(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.failKey(key)).Err()
+}
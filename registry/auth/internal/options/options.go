@@ -0,0 +1,87 @@
+// Package options parses the loosely typed values found in access controller
+// options.
+package options
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// StringMap converts a map decoded from the configuration into a map keyed
+// by strings.
+func StringMap(v interface{}) (map[string]interface{}, error) {
+	switch m := v.(type) {
+	case map[string]interface{}:
+		return m, nil
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(m))
+		for k, v := range m {
+			out[fmt.Sprint(k)] = v
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("invalid map %v", v)
+}
+
+// Duration parses a duration given either as a duration string or as a
+// number of seconds.
+func Duration(v interface{}) (time.Duration, error) {
+	switch d := v.(type) {
+	case string:
+		return time.ParseDuration(d)
+	case int:
+		return time.Duration(d) * time.Second, nil
+	case time.Duration:
+		return d, nil
+	}
+	return 0, fmt.Errorf("invalid duration %v", v)
+}
+
+// RedisClient creates a redis client from a map of options:
+//
+//	addrs: [localhost:6379]
+//	username: registry
+//	password: secret
+//	db: 0
+//
+// Other keys are ignored.
+func RedisClient(v interface{}) (redis.UniversalClient, error) {
+	options, err := StringMap(v)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &redis.UniversalOptions{}
+	if addrs, ok := options["addrs"].([]interface{}); ok {
+		for _, addr := range addrs {
+			s, ok := addr.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid address %v", addr)
+			}
+			opts.Addrs = append(opts.Addrs, s)
+		}
+	}
+	if len(opts.Addrs) == 0 {
+		return nil, errors.New("addrs must be a non-empty list")
+	}
+	if v, ok := options["username"]; ok {
+		if opts.Username, ok = v.(string); !ok {
+			return nil, errors.New("username must be a string")
+		}
+	}
+	if v, ok := options["password"]; ok {
+		if opts.Password, ok = v.(string); !ok {
+			return nil, errors.New("password must be a string")
+		}
+	}
+	if v, ok := options["db"]; ok {
+		if opts.DB, ok = v.(int); !ok {
+			return nil, errors.New("db must be an integer")
+		}
+	}
+
+	return redis.NewUniversalClient(opts), nil
+}
@@ -0,0 +1,227 @@
+// Package mtls provides an access controller which authenticates clients by
+// the TLS client certificate verified by the registry's HTTP server.
+//
+// The registry must be configured to verify client certificates, see the
+// http.tls.clientcas option. The user name is derived from the certificate
+// subject, a subject alternative name or a SPIFFE ID, and is mapped to
+// repository permissions with a required access control list.
+package mtls
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/auth"
+	"github.com/distribution/distribution/v3/registry/auth/internal/acl"
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	if err := auth.Register("mtls", auth.InitFunc(newAccessController)); err != nil {
+		logrus.Errorf("failed to register mtls auth: %v", err)
+	}
+}
+
+// ErrNoClientCertificate is returned when a request was made without a
+// verified client certificate.
+var ErrNoClientCertificate = errors.New("no verified client certificate")
+
+// identitySources maps the "identity" option to the function extracting the
+// user name from a verified leaf certificate.
+var identitySources = map[string]func(cert *x509.Certificate) string{
+	"subject": func(cert *x509.Certificate) string {
+		return cert.Subject.CommonName
+	},
+	"dns": func(cert *x509.Certificate) string {
+		if len(cert.DNSNames) > 0 {
+			return cert.DNSNames[0]
+		}
+		return ""
+	},
+	"email": func(cert *x509.Certificate) string {
+		if len(cert.EmailAddresses) > 0 {
+			return cert.EmailAddresses[0]
+		}
+		return ""
+	},
+	"uri": func(cert *x509.Certificate) string {
+		if len(cert.URIs) > 0 {
+			return cert.URIs[0].String()
+		}
+		return ""
+	},
+	"spiffe": func(cert *x509.Certificate) string {
+		// A SPIFFE X.509-SVID carries exactly one URI SAN.
+		if len(cert.URIs) == 1 && cert.URIs[0].Scheme == "spiffe" {
+			return cert.URIs[0].String()
+		}
+		return ""
+	},
+}
+
+const defaultIdentity = "subject"
+
+type accessController struct {
+	realm        string
+	identity     func(cert *x509.Certificate) string
+	trustDomains map[string]struct{}
+	acl          *acl.File
+}
+
+var (
+	_ auth.AccessController = &accessController{}
+	_ auth.CatalogFilter    = &accessController{}
+	_ auth.AdminEnforcer    = &accessController{}
+)
+
+func newAccessController(options map[string]interface{}) (auth.AccessController, error) {
+	realm, present := options["realm"]
+	if _, ok := realm.(string); !present || !ok {
+		return nil, fmt.Errorf(`"realm" must be set for mtls access controller`)
+	}
+
+	identity := defaultIdentity
+	if v, present := options["identity"]; present {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf(`"identity" must be a string for mtls access controller`)
+		}
+		identity = s
+	}
+	source, ok := identitySources[identity]
+	if !ok {
+		return nil, fmt.Errorf("unknown identity %q for mtls access controller", identity)
+	}
+
+	ac := &accessController{
+		realm:    realm.(string),
+		identity: source,
+	}
+
+	if v, present := options["trustdomains"]; present {
+		domains, ok := v.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf(`"trustdomains" must be a list for mtls access controller`)
+		}
+		ac.trustDomains = make(map[string]struct{}, len(domains))
+		for _, d := range domains {
+			domain, ok := d.(string)
+			if !ok {
+				return nil, fmt.Errorf(`"trustdomains" must be a list of strings for mtls access controller`)
+			}
+			ac.trustDomains[domain] = struct{}{}
+		}
+	}
+
+	// Any certificate issued by the client CAs verifies, so access is only
+	// granted through explicit rules.
+	path, ok := options["rules"].(string)
+	if !ok || path == "" {
+		return nil, fmt.Errorf(`"rules" must be set to a path for mtls access controller`)
+	}
+	f, err := acl.NewFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ac.acl = f
+
+	return ac, nil
+}
+
+// Authorized grants access to requests carrying a verified client
+// certificate whose identity is permitted the requested access.
+func (ac *accessController) Authorized(req *http.Request, accessRecords ...auth.Access) (*auth.Grant, error) {
+	a, err := ac.acl.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	username, err := ac.username(req)
+	if err != nil {
+		if len(accessRecords) > 0 && a.Allowed("", accessRecords...) {
+			return &auth.Grant{}, nil
+		}
+		return nil, &challenge{realm: ac.realm, err: err}
+	}
+
+	if !a.Allowed(username, accessRecords...) {
+		dcontext.GetLogger(req.Context()).Warnf("client certificate identity %q denied access to %v", username, accessRecords)
+		return nil, &challenge{realm: ac.realm, err: auth.ErrAccessDenied}
+	}
+
+	return &auth.Grant{User: auth.UserInfo{Name: username}}, nil
+}
+
+// EnforcesAdmin returns true: admin access is only granted by the rules.
+func (ac *accessController) EnforcesAdmin() bool {
+	return true
+}
+
+// FilterCatalog returns the repositories the grant's identity may pull.
+func (ac *accessController) FilterCatalog(ctx context.Context, grant *auth.Grant, repositories []string) ([]string, error) {
+	a, err := ac.acl.Load()
+	if err != nil {
+		return nil, err
+	}
+	return a.Visible(grant.User.Name, repositories), nil
+}
+
+// username returns the identity of the verified client certificate.
+func (ac *accessController) username(req *http.Request) (string, error) {
+	if req.TLS == nil || len(req.TLS.VerifiedChains) == 0 || len(req.TLS.VerifiedChains[0]) == 0 {
+		return "", ErrNoClientCertificate
+	}
+
+	leaf := req.TLS.VerifiedChains[0][0]
+	username := ac.identity(leaf)
+	if username == "" {
+		dcontext.GetLogger(req.Context()).Warnf("client certificate %q carries no usable identity", leaf.Subject)
+		return "", auth.ErrInvalidCredential
+	}
+
+	if ac.trustDomains != nil {
+		u, err := url.Parse(username)
+		if err != nil || u.Scheme != "spiffe" {
+			return "", auth.ErrInvalidCredential
+		}
+		if _, ok := ac.trustDomains[u.Host]; !ok {
+			dcontext.GetLogger(req.Context()).Warnf("client certificate identity %q is not in a trusted domain", username)
+			return "", auth.ErrAuthenticationFailure
+		}
+	}
+
+	return username, nil
+}
+
+// challenge implements the auth.Challenge interface.
+type challenge struct {
+	realm string
+	err   error
+}
+
+var _ auth.Challenge = challenge{}
+
+// SetHeaders asks the client for a certificate. TLS client authentication
+// happens during the handshake and has no registered HTTP authentication
+// scheme, so the challenge names TLS. A client whose certificate identity was
+// denied access is not challenged, and is answered with 403 Forbidden.
+func (ch challenge) SetHeaders(r *http.Request, w http.ResponseWriter) {
+	if errors.Is(ch.err, auth.ErrAccessDenied) {
+		return
+	}
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("TLS realm=%q", ch.realm))
+}
+
+func (ch challenge) Error() string {
+	return fmt.Sprintf("mtls authentication challenge for realm %q: %s", ch.realm, ch.err)
+}
+
+// Unwrap returns the reason for the challenge.
+func (ch challenge) Unwrap() error {
+	return ch.err
+}
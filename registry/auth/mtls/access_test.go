@@ -0,0 +1,153 @@
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/distribution/distribution/v3/registry/auth"
+)
+
+const testRules = `
+rules:
+  - users: ["spiffe://cluster.local/ns/ci/sa/builder"]
+    repositories: ["ci/**"]
+    actions: [pull, push]
+anonymous:
+  - repositories: ["public/*"]
+    actions: [pull]
+`
+
+// openRules grants every verified client pull access to every repository.
+const openRules = `
+rules:
+  - users: ["*"]
+    repositories: ["**"]
+    actions: [pull]
+`
+
+func writeRules(t *testing.T, rules string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.yml")
+	if err := os.WriteFile(path, []byte(rules), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func requestWithCert(cert *x509.Certificate) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "https://registry.example.com/v2/", nil)
+	req.TLS = &tls.ConnectionState{}
+	if cert != nil {
+		req.TLS.PeerCertificates = []*x509.Certificate{cert}
+		req.TLS.VerifiedChains = [][]*x509.Certificate{{cert}}
+	}
+	return req
+}
+
+func spiffeCert(t *testing.T, id string) *x509.Certificate {
+	u, err := url.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &x509.Certificate{
+		Subject: pkix.Name{CommonName: "builder"},
+		URIs:    []*url.URL{u},
+	}
+}
+
+func pull(name string) auth.Access {
+	return auth.Access{Resource: auth.Resource{Type: "repository", Name: name}, Action: "pull"}
+}
+
+func TestIdentity(t *testing.T) {
+	cert := spiffeCert(t, "spiffe://cluster.local/ns/ci/sa/builder")
+	cert.DNSNames = []string{"builder.ci.svc"}
+
+	rulesPath := writeRules(t, openRules)
+	for identity, expected := range map[string]string{
+		"subject": "builder",
+		"dns":     "builder.ci.svc",
+		"spiffe":  "spiffe://cluster.local/ns/ci/sa/builder",
+	} {
+		ac, err := newAccessController(map[string]interface{}{"realm": "test", "identity": identity, "rules": rulesPath})
+		if err != nil {
+			t.Fatal(err)
+		}
+		grant, err := ac.Authorized(requestWithCert(cert), pull("foo"))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", identity, err)
+		}
+		if grant.User.Name != expected {
+			t.Fatalf("%s: expected user %q, got %q", identity, expected, grant.User.Name)
+		}
+	}
+
+	if _, err := newAccessController(map[string]interface{}{"realm": "test", "identity": "serial", "rules": rulesPath}); err == nil {
+		t.Fatal("expected unknown identity to be rejected")
+	}
+	if _, err := newAccessController(map[string]interface{}{"realm": "test"}); err == nil {
+		t.Fatal("expected missing rules to be rejected")
+	}
+}
+
+func TestNoClientCertificate(t *testing.T) {
+	ac, err := newAccessController(map[string]interface{}{"realm": "test", "rules": writeRules(t, openRules)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := requestWithCert(nil)
+	// An unverified peer certificate must not be trusted.
+	req.TLS.PeerCertificates = []*x509.Certificate{{Subject: pkix.Name{CommonName: "admin"}}}
+
+	_, err = ac.Authorized(req, pull("foo"))
+	ch, ok := err.(auth.Challenge)
+	if !ok {
+		t.Fatalf("expected challenge, got %v", err)
+	}
+	rec := httptest.NewRecorder()
+	ch.SetHeaders(req, rec)
+	if h := rec.Header().Get("WWW-Authenticate"); h != `TLS realm="test"` {
+		t.Fatalf("unexpected WWW-Authenticate header %q", h)
+	}
+}
+
+func TestRules(t *testing.T) {
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":        "test",
+		"identity":     "spiffe",
+		"trustdomains": []interface{}{"cluster.local"},
+		"rules":        writeRules(t, testRules),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	builder := spiffeCert(t, "spiffe://cluster.local/ns/ci/sa/builder")
+	if _, err := ac.Authorized(requestWithCert(builder), pull("ci/app")); err != nil {
+		t.Fatalf("expected builder to pull ci/app: %v", err)
+	}
+	if _, err := ac.Authorized(requestWithCert(builder), pull("prod/app")); !errors.Is(err, auth.ErrAccessDenied) {
+		t.Fatalf("expected builder to be denied prod/app, got %v", err)
+	}
+
+	foreign := spiffeCert(t, "spiffe://other.domain/ns/ci/sa/builder")
+	if _, err := ac.Authorized(requestWithCert(foreign), pull("ci/app")); err == nil {
+		t.Fatal("expected certificate from untrusted domain to be rejected")
+	}
+
+	if _, err := ac.Authorized(requestWithCert(nil), pull("public/app")); err != nil {
+		t.Fatalf("expected anonymous pull of public/app: %v", err)
+	}
+	if _, err := ac.Authorized(requestWithCert(nil), pull("ci/app")); err == nil {
+		t.Fatal("expected anonymous pull of ci/app to be denied")
+	}
+}
@@ -0,0 +1,174 @@
+// Package robot provides robot accounts: credentials for automated clients,
+// such as CI pipelines, which are created and revoked at runtime through the
+// registry's admin API rather than through configuration.
+//
+// Each account has a generated secret, a list of repository permissions and
+// an optional expiry. Accounts are kept in the registry's storage, so every
+// registry instance sharing that storage sees changes immediately.
+//
+// Clients authenticate with HTTP Basic auth, using the account name with the
+// "robot+" prefix as the username and the secret as the password. Requests
+// which carry no robot credentials are answered with a challenge, so the
+// controller is usually combined with another one through the chain access
+// controller:
+//
+//	auth:
+//	  chain:
+//	    controllers:
+//	      - robot:
+//	          realm: basic-realm
+//	      - htpasswd:
+//	          realm: basic-realm
+//	          path: /path/to/htpasswd
+package robot
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/auth"
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	if err := auth.Register("robot", auth.InitFunc(newAccessController)); err != nil {
+		logrus.Errorf("failed to register robot auth: %v", err)
+	}
+}
+
+// UsernamePrefix distinguishes robot accounts from other users. A "+" is
+// used rather than a character such as "$" so that usernames need no
+// quoting in shell scripts.
+const UsernamePrefix = "robot+"
+
+// lastUsedResolution limits how often the last-used time of an account is
+// written to storage.
+const lastUsedResolution = time.Minute
+
+type accessController struct {
+	realm string
+	store *Store
+
+	mu      sync.Mutex
+	touched map[string]time.Time
+}
+
+var (
+	_ auth.AccessController = &accessController{}
+	_ auth.StorageConsumer  = &accessController{}
+	_ auth.AdminEnforcer    = &accessController{}
+)
+
+func newAccessController(options map[string]interface{}) (auth.AccessController, error) {
+	realm, ok := options["realm"].(string)
+	if !ok || realm == "" {
+		return nil, fmt.Errorf(`"realm" must be set for robot access controller`)
+	}
+
+	return &accessController{
+		realm:   realm,
+		touched: map[string]time.Time{},
+	}, nil
+}
+
+// UseStorage sets the storage the accounts are read from.
+func (ac *accessController) UseStorage(driver storagedriver.StorageDriver) {
+	ac.store = NewStore(driver)
+}
+
+// Authorized authenticates robot credentials and checks the account's
+// permissions against the requested access.
+func (ac *accessController) Authorized(req *http.Request, accessRecords ...auth.Access) (*auth.Grant, error) {
+	if ac.store == nil {
+		return nil, errors.New("robot access controller has no storage")
+	}
+
+	username, secret, ok := req.BasicAuth()
+	if !ok || !strings.HasPrefix(username, UsernamePrefix) {
+		return nil, challenge{realm: ac.realm, err: auth.ErrInvalidCredential}
+	}
+	name := strings.TrimPrefix(username, UsernamePrefix)
+
+	ctx := req.Context()
+	account, err := ac.store.Authenticate(ctx, name, secret)
+	if err != nil {
+		if !errors.Is(err, auth.ErrAuthenticationFailure) && !errors.Is(err, ErrAccountExpired) {
+			return nil, err
+		}
+		dcontext.GetLogger(ctx).Errorf("error authenticating robot account %q: %v", name, err)
+		return nil, challenge{realm: ac.realm, err: err}
+	}
+
+	ac.touch(req, name)
+
+	if !account.Allowed(accessRecords...) {
+		dcontext.GetLogger(ctx).Warnf("robot account %q denied access to %v", name, accessRecords)
+		return nil, challenge{realm: ac.realm, err: auth.ErrAccessDenied}
+	}
+
+	resources := make([]auth.Resource, 0, len(accessRecords))
+	for _, access := range accessRecords {
+		resources = append(resources, access.Resource)
+	}
+
+	return &auth.Grant{
+		User:      auth.UserInfo{Name: username},
+		Resources: resources,
+	}, nil
+}
+
+// EnforcesAdmin returns true: robot accounts are never granted admin access.
+func (ac *accessController) EnforcesAdmin() bool {
+	return true
+}
+
+// touch records the use of an account, at most once per lastUsedResolution
+// for each account.
+func (ac *accessController) touch(req *http.Request, name string) {
+	now := time.Now()
+
+	ac.mu.Lock()
+	if last, ok := ac.touched[name]; ok && now.Sub(last) < lastUsedResolution {
+		ac.mu.Unlock()
+		return
+	}
+	ac.touched[name] = now
+	ac.mu.Unlock()
+
+	if err := ac.store.Touch(req.Context(), name, now); err != nil {
+		dcontext.GetLogger(req.Context()).Warnf("error recording use of robot account %q: %v", name, err)
+	}
+}
+
+// challenge implements the auth.Challenge interface.
+type challenge struct {
+	realm string
+	err   error
+}
+
+var _ auth.Challenge = challenge{}
+
+// SetHeaders sets the basic challenge header on the response, unless the
+// account was denied access: other credentials for it would not help.
+func (ch challenge) SetHeaders(r *http.Request, w http.ResponseWriter) {
+	if errors.Is(ch.err, auth.ErrAccessDenied) {
+		return
+	}
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", ch.realm))
+}
+
+func (ch challenge) Error() string {
+	return fmt.Sprintf("basic authentication challenge for realm %q: %s", ch.realm, ch.err)
+}
+
+// Unwrap returns the underlying error, so that a denial is recognized as
+// auth.ErrAccessDenied.
+func (ch challenge) Unwrap() error {
+	return ch.err
+}
@@ -0,0 +1,178 @@
+package robot
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/registry/auth"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+)
+
+func access(name, action string) auth.Access {
+	return auth.Access{Resource: auth.Resource{Type: "repository", Name: name}, Action: action}
+}
+
+func basicRequest(username, password string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/v2/", nil)
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+	return req
+}
+
+var ciAccount = Account{
+	Name: "ci",
+	Permissions: []Permission{
+		{Repositories: []string{"team/**"}, Actions: []string{"pull", "push"}},
+		{Repositories: []string{"base/*"}, Actions: []string{"pull"}},
+	},
+}
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewStore(inmemory.New())
+
+	accounts, err := store.List(ctx)
+	if err != nil || len(accounts) != 0 {
+		t.Fatalf("expected no accounts, got %v, %v", accounts, err)
+	}
+
+	created, secret, err := store.Create(ctx, ciAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret == "" || created.Created.IsZero() {
+		t.Fatalf("unexpected account %+v with secret %q", created, secret)
+	}
+	if _, _, err := store.Create(ctx, ciAccount); !errors.Is(err, ErrAccountExists) {
+		t.Fatalf("expected %v, got %v", ErrAccountExists, err)
+	}
+
+	authenticated, err := store.Authenticate(ctx, "ci", secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !authenticated.Allowed(access("team/a/b", "push"), access("base/alpine", "pull")) {
+		t.Fatal("expected the loaded account's permissions to apply")
+	}
+	if authenticated.Allowed(access("base/a/b", "pull")) {
+		t.Fatal("expected base/* not to match across components")
+	}
+	if _, err := store.Authenticate(ctx, "ci", "wrong"); !errors.Is(err, auth.ErrAuthenticationFailure) {
+		t.Fatalf("expected %v, got %v", auth.ErrAuthenticationFailure, err)
+	}
+
+	rotated, err := store.RotateSecret(ctx, "ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Authenticate(ctx, "ci", secret); !errors.Is(err, auth.ErrAuthenticationFailure) {
+		t.Fatalf("expected old secret to be rejected, got %v", err)
+	}
+	if _, err := store.Authenticate(ctx, "ci", rotated); err != nil {
+		t.Fatal(err)
+	}
+
+	used := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := store.Touch(ctx, "ci", used); err != nil {
+		t.Fatal(err)
+	}
+	got, err := store.Get(ctx, "ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.LastUsed == nil || !got.LastUsed.Equal(used) {
+		t.Fatalf("unexpected last used time %v", got.LastUsed)
+	}
+
+	if err := store.Delete(ctx, "ci"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, "ci"); !errors.Is(err, ErrAccountUnknown) {
+		t.Fatalf("expected %v, got %v", ErrAccountUnknown, err)
+	}
+	if _, err := store.Authenticate(ctx, "ci", rotated); !errors.Is(err, auth.ErrAuthenticationFailure) {
+		t.Fatalf("expected deleted account to be rejected, got %v", err)
+	}
+
+	past := time.Now().Add(-time.Hour)
+	for _, invalid := range []Account{
+		{Name: "Upper", Permissions: ciAccount.Permissions},
+		{Name: "../escape", Permissions: ciAccount.Permissions},
+		{Name: "none"},
+		{Name: "action", Permissions: []Permission{{Repositories: []string{"a"}, Actions: []string{"write"}}}},
+		{Name: "expired", Permissions: ciAccount.Permissions, Expires: &past},
+	} {
+		if _, _, err := store.Create(ctx, invalid); !errors.Is(err, ErrAccountInvalid) {
+			t.Errorf("expected %v creating %+v, got %v", ErrAccountInvalid, invalid, err)
+		}
+	}
+}
+
+func TestAccessController(t *testing.T) {
+	ctx := context.Background()
+	driver := inmemory.New()
+	store := NewStore(driver)
+
+	_, secret, err := store.Create(ctx, ciAccount)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ac, err := auth.GetAccessController("robot", map[string]interface{}{"realm": "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ac.Authorized(basicRequest("robot+ci", secret)); err == nil {
+		t.Fatal("expected an error before storage is configured")
+	}
+	ac.(auth.StorageConsumer).UseStorage(driver)
+
+	grant, err := ac.Authorized(basicRequest("robot+ci", secret), access("team/app", "pull"), access("team/app", "push"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grant.User.Name != "robot+ci" {
+		t.Fatalf("unexpected user %q", grant.User.Name)
+	}
+
+	account, err := store.Get(ctx, "ci")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if account.LastUsed == nil {
+		t.Fatal("expected last used time to be recorded")
+	}
+
+	for _, tc := range []struct {
+		username, password string
+		access             []auth.Access
+	}{
+		{"", "", []auth.Access{access("team/app", "pull")}},
+		{"alice", secret, []auth.Access{access("team/app", "pull")}},
+		{"robot+ci", "wrong", []auth.Access{access("team/app", "pull")}},
+		{"robot+ci", secret, []auth.Access{access("base/image", "push")}},
+		{"robot+ci", secret, []auth.Access{access("other/app", "pull")}},
+		{"robot+ci", secret, []auth.Access{{Resource: auth.Resource{Type: "registry", Name: "catalog"}, Action: "*"}}},
+	} {
+		_, err := ac.Authorized(basicRequest(tc.username, tc.password), tc.access...)
+		if _, ok := err.(auth.Challenge); !ok {
+			t.Errorf("%q: expected challenge for %v, got %v", tc.username, tc.access, err)
+		}
+	}
+
+	expires := time.Now().Add(50 * time.Millisecond)
+	_, shortSecret, err := store.Create(ctx, Account{Name: "short", Permissions: ciAccount.Permissions, Expires: &expires})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Until(expires))
+	_, err = ac.Authorized(basicRequest("robot+short", shortSecret), access("team/app", "pull"))
+	if _, ok := err.(auth.Challenge); !ok || !errors.Is(err.(challenge).err, ErrAccountExpired) {
+		t.Fatalf("expected expiry challenge, got %v", err)
+	}
+}
@@ -0,0 +1,391 @@
+package robot
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/distribution/distribution/v3/internal/glob"
+	"github.com/distribution/distribution/v3/registry/auth"
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+)
+
+// storageRoot is where robot accounts are kept, next to the repositories in
+// the registry's storage:
+//
+//	<root>/v2/robots/<name>/account  - the account and its secret hash
+//	<root>/v2/robots/<name>/lastused - when the account last authenticated
+//
+// The last-used time is kept apart so that recording it never races with,
+// or resurrects, an account changed or deleted through the admin API.
+const storageRoot = "/docker/registry/v2/robots"
+
+var (
+	// ErrAccountUnknown is returned when the named account does not exist.
+	ErrAccountUnknown = errors.New("robot account unknown")
+
+	// ErrAccountExists is returned when creating an account whose name is
+	// already taken.
+	ErrAccountExists = errors.New("robot account already exists")
+
+	// ErrAccountInvalid is wrapped by the errors returned when creating an
+	// account with an invalid name, permissions or expiry.
+	ErrAccountInvalid = errors.New("invalid robot account")
+
+	// ErrAccountExpired is returned when authenticating with an account past
+	// its expiry.
+	ErrAccountExpired = errors.New("robot account expired")
+)
+
+// nameRegexp restricts account names to lower-case path-safe components.
+var nameRegexp = regexp.MustCompile(`^[a-z0-9]+(?:[._-][a-z0-9]+)*$`)
+
+// Permission grants actions on the repositories matching any of the
+// repository globs. Globs follow the access control list syntax: "*" matches
+// within one path component and "**" matches across components.
+type Permission struct {
+	Repositories []string `json:"repositories"`
+	Actions      []string `json:"actions"`
+}
+
+// Account is a robot account as returned by the admin API. The secret is
+// never stored; only its hash is.
+type Account struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	Permissions []Permission `json:"permissions"`
+	Created     time.Time    `json:"created"`
+	Expires     *time.Time   `json:"expires,omitempty"`
+	LastUsed    *time.Time   `json:"lastused,omitempty"`
+
+	// repositories holds the compiled repository globs of each permission,
+	// set when the account is loaded.
+	repositories [][]*regexp.Regexp
+}
+
+// Expired returns whether the account has expired at t.
+func (a *Account) Expired(t time.Time) bool {
+	return a.Expires != nil && !t.Before(*a.Expires)
+}
+
+// Allowed returns whether the account's permissions cover every requested
+// access. Robot accounts are never granted registry-wide resources such as
+// the catalog.
+func (a *Account) Allowed(accessRecords ...auth.Access) bool {
+	for _, access := range accessRecords {
+		if access.Type != "repository" || !a.permits(access) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *Account) permits(access auth.Access) bool {
+	for i, p := range a.Permissions {
+		if i >= len(a.repositories) || !containsAction(p.Actions, access.Action) {
+			continue
+		}
+		for _, re := range a.repositories[i] {
+			if re.MatchString(access.Name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compile compiles the repository globs of the account's permissions.
+func (a *Account) compile() error {
+	a.repositories = make([][]*regexp.Regexp, len(a.Permissions))
+	for i, p := range a.Permissions {
+		for _, pattern := range p.Repositories {
+			re, err := glob.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("%w: permission %d: %v", ErrAccountInvalid, i, err)
+			}
+			a.repositories[i] = append(a.repositories[i], re)
+		}
+	}
+	return nil
+}
+
+func containsAction(actions []string, action string) bool {
+	for _, a := range actions {
+		if a == action || a == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks the user-supplied fields of an account.
+func (a *Account) validate() error {
+	if !nameRegexp.MatchString(a.Name) {
+		return fmt.Errorf("%w: invalid name %q", ErrAccountInvalid, a.Name)
+	}
+	if len(a.Permissions) == 0 {
+		return fmt.Errorf("%w: no permissions", ErrAccountInvalid)
+	}
+	for i, p := range a.Permissions {
+		if len(p.Repositories) == 0 || len(p.Actions) == 0 {
+			return fmt.Errorf("%w: permission %d must list repositories and actions", ErrAccountInvalid, i)
+		}
+		for _, action := range p.Actions {
+			switch action {
+			case "pull", "push", "delete", "*":
+			default:
+				return fmt.Errorf("%w: permission %d: unknown action %q", ErrAccountInvalid, i, action)
+			}
+		}
+	}
+	return a.compile()
+}
+
+// record is the stored form of an account.
+type record struct {
+	Account
+	SecretHash string `json:"secrethash"`
+}
+
+// Store keeps robot accounts in a storage driver.
+type Store struct {
+	driver storagedriver.StorageDriver
+}
+
+// NewStore returns a Store backed by driver.
+func NewStore(driver storagedriver.StorageDriver) *Store {
+	return &Store{driver: driver}
+}
+
+// Create stores a new account and returns it together with its generated
+// secret. Only the name, description, permissions and expiry of account
+// are used.
+func (s *Store) Create(ctx context.Context, account Account) (*Account, string, error) {
+	if err := account.validate(); err != nil {
+		return nil, "", err
+	}
+	if account.Expires != nil && !account.Expires.After(time.Now()) {
+		return nil, "", fmt.Errorf("%w: expiry is in the past", ErrAccountInvalid)
+	}
+
+	if _, err := s.read(ctx, account.Name); err == nil {
+		return nil, "", ErrAccountExists
+	} else if !errors.Is(err, ErrAccountUnknown) {
+		return nil, "", err
+	}
+
+	secret, hash, err := newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	r := record{
+		Account: Account{
+			Name:        account.Name,
+			Description: account.Description,
+			Permissions: account.Permissions,
+			Created:     time.Now().UTC(),
+			Expires:     account.Expires,
+
+			repositories: account.repositories,
+		},
+		SecretHash: hash,
+	}
+	if err := s.write(ctx, &r); err != nil {
+		return nil, "", err
+	}
+
+	return &r.Account, secret, nil
+}
+
+// Get returns the named account.
+func (s *Store) Get(ctx context.Context, name string) (*Account, error) {
+	r, err := s.read(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &r.Account, nil
+}
+
+// List returns every account, ordered by name.
+func (s *Store) List(ctx context.Context) ([]Account, error) {
+	children, err := s.driver.List(ctx, storageRoot)
+	if err != nil {
+		if _, ok := err.(storagedriver.PathNotFoundError); ok {
+			return []Account{}, nil
+		}
+		return nil, err
+	}
+	sort.Strings(children)
+
+	accounts := make([]Account, 0, len(children))
+	for _, child := range children {
+		r, err := s.read(ctx, path.Base(child))
+		if err != nil {
+			if errors.Is(err, ErrAccountUnknown) {
+				// A last-used time recorded while the account
+				// was being deleted.
+				continue
+			}
+			return nil, err
+		}
+		accounts = append(accounts, r.Account)
+	}
+	return accounts, nil
+}
+
+// Delete removes the named account. Its secret stops working immediately.
+func (s *Store) Delete(ctx context.Context, name string) error {
+	if _, err := s.read(ctx, name); err != nil {
+		return err
+	}
+	return s.driver.Delete(ctx, accountDir(name))
+}
+
+// RotateSecret replaces the secret of the named account and returns the
+// new one. The previous secret stops working immediately.
+func (s *Store) RotateSecret(ctx context.Context, name string) (string, error) {
+	r, err := s.read(ctx, name)
+	if err != nil {
+		return "", err
+	}
+
+	secret, hash, err := newSecret()
+	if err != nil {
+		return "", err
+	}
+	r.SecretHash = hash
+	if err := s.write(ctx, r); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// Authenticate returns the named account if secret is its current secret
+// and the account has not expired.
+func (s *Store) Authenticate(ctx context.Context, name, secret string) (*Account, error) {
+	if !nameRegexp.MatchString(name) {
+		return nil, auth.ErrAuthenticationFailure
+	}
+
+	// The last-used time is not needed to authenticate, so only the
+	// account itself is read.
+	r, err := s.readAccount(ctx, name)
+	if err != nil {
+		if errors.Is(err, ErrAccountUnknown) {
+			return nil, auth.ErrAuthenticationFailure
+		}
+		return nil, err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(r.SecretHash)) != 1 {
+		return nil, auth.ErrAuthenticationFailure
+	}
+	if r.Expired(time.Now()) {
+		return nil, ErrAccountExpired
+	}
+	return &r.Account, nil
+}
+
+// Touch records t as the time the named account was last used.
+func (s *Store) Touch(ctx context.Context, name string, t time.Time) error {
+	p, err := t.UTC().MarshalText()
+	if err != nil {
+		return err
+	}
+	return s.driver.PutContent(ctx, lastUsedPath(name), p)
+}
+
+// read returns the named account with its last-used time.
+func (s *Store) read(ctx context.Context, name string) (*record, error) {
+	r, err := s.readAccount(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if p, err := s.driver.GetContent(ctx, lastUsedPath(name)); err == nil {
+		var t time.Time
+		if err := t.UnmarshalText(p); err == nil {
+			r.LastUsed = &t
+		}
+	}
+
+	return r, nil
+}
+
+// readAccount returns the named account, with its repository globs
+// compiled.
+func (s *Store) readAccount(ctx context.Context, name string) (*record, error) {
+	if !nameRegexp.MatchString(name) {
+		return nil, ErrAccountUnknown
+	}
+
+	p, err := s.driver.GetContent(ctx, accountPath(name))
+	if err != nil {
+		if _, ok := err.(storagedriver.PathNotFoundError); ok {
+			return nil, ErrAccountUnknown
+		}
+		return nil, err
+	}
+
+	var r record
+	if err := json.Unmarshal(p, &r); err != nil {
+		return nil, fmt.Errorf("invalid robot account %q: %v", name, err)
+	}
+	if err := r.compile(); err != nil {
+		return nil, fmt.Errorf("invalid robot account %q: %v", name, err)
+	}
+
+	return &r, nil
+}
+
+func (s *Store) write(ctx context.Context, r *record) error {
+	// The last-used time is stored separately.
+	stored := *r
+	stored.LastUsed = nil
+
+	p, err := json.Marshal(&stored)
+	if err != nil {
+		return err
+	}
+	return s.driver.PutContent(ctx, accountPath(r.Name), p)
+}
+
+func accountDir(name string) string {
+	return path.Join(storageRoot, name)
+}
+
+func accountPath(name string) string {
+	return path.Join(storageRoot, name, "account")
+}
+
+func lastUsedPath(name string) string {
+	return path.Join(storageRoot, name, "lastused")
+}
+
+// newSecret generates a secret and its hash. Secrets carry 256 bits of
+// entropy, so a plain SHA-256 hash is sufficient to protect them at rest
+// and keeps authentication cheap.
+func newSecret() (string, string, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(b[:])
+	return secret, hashSecret(secret), nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -135,7 +135,7 @@ func (ac authChallenge) challengeParams(r *http.Request) string {
 		str = fmt.Sprintf("%s,scope=%q", str, scope)
 	}
 
-	if ac.err == ErrInvalidToken || ac.err == ErrMalformedToken {
+	if ac.err == ErrInvalidToken || ac.err == ErrMalformedToken || ac.err == ErrTokenRevoked {
 		str = fmt.Sprintf("%s,error=%q", str, "invalid_token")
 	} else if ac.err == ErrInsufficientScope {
 		str = fmt.Sprintf("%s,error=%q", str, "insufficient_scope")
@@ -159,8 +159,14 @@ type accessController struct {
 	rootCerts         *x509.CertPool
 	trustedKeys       map[string]crypto.PublicKey
 	signingAlgorithms []jose.SignatureAlgorithm
+	revocations       *revocations
 }
 
+var (
+	_ auth.AdminEnforcer = &accessController{}
+	_ io.Closer          = &accessController{}
+)
+
 const (
 	defaultAutoRedirectPath = "/auth/token"
 )
@@ -372,6 +378,14 @@ func newAccessController(options map[string]interface{}) (auth.AccessController,
 		signAlgos = defaultSigningAlgorithms
 	}
 
+	var revoked *revocations
+	if revocationOpt, ok := options["revocation"]; ok {
+		revoked, err = parseRevocationOptions(revocationOpt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &accessController{
 		realm:             config.realm,
 		autoRedirect:      config.autoRedirect,
@@ -381,9 +395,24 @@ func newAccessController(options map[string]interface{}) (auth.AccessController,
 		rootCerts:         rootPool,
 		trustedKeys:       trustedKeys,
 		signingAlgorithms: signAlgos,
+		revocations:       revoked,
 	}, nil
 }
 
+// Close stops refreshing the revocation list, if one is configured.
+func (ac *accessController) Close() error {
+	if ac.revocations == nil {
+		return nil
+	}
+	return ac.revocations.Close()
+}
+
+// EnforcesAdmin returns true: tokens only grant the scopes the token
+// server issued them for.
+func (ac *accessController) EnforcesAdmin() bool {
+	return true
+}
+
 // Authorized handles checking whether the given request is authorized
 // for actions on resources described by the given access items.
 func (ac *accessController) Authorized(req *http.Request, accessItems ...auth.Access) (*auth.Grant, error) {
@@ -420,6 +449,11 @@ func (ac *accessController) Authorized(req *http.Request, accessItems ...auth.Ac
 		return nil, challenge
 	}
 
+	if ac.revocations != nil && ac.revocations.revoked(claims) {
+		challenge.err = ErrTokenRevoked
+		return nil, challenge
+	}
+
 	accessSet := claims.accessSet()
 	for _, access := range accessItems {
 		if !accessSet.contains(access) {
@@ -0,0 +1,309 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/distribution/distribution/v3/registry/auth/internal/options"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// ErrTokenRevoked is returned when a token, or every token of its subject,
+// has been revoked.
+var ErrTokenRevoked = errors.New("token revoked")
+
+const (
+	defaultRevocationRefresh = 30 * time.Second
+	defaultRevocationKey     = "registry:revocation"
+)
+
+// revocationFile is the on-disk format of a revocation list. Tokens are
+// revoked individually by their jti claim, or by subject. A subject with a
+// time revokes the tokens issued to it before that time; without one it
+// revokes every token of the subject.
+//
+//	jti:
+//	  - 5b7d3e1c-60f6-4c52-9f7e-4e2c0b6e8c1a
+//	subjects:
+//	  alice: "2024-05-01T00:00:00Z"
+//	  mallory: ""
+type revocationFile struct {
+	JTI      []string          `yaml:"jti"`
+	Subjects map[string]string `yaml:"subjects"`
+}
+
+// revocationList is a parsed revocation list.
+type revocationList struct {
+	jtis     map[string]struct{}
+	subjects map[string]time.Time
+}
+
+func newRevocationList(f revocationFile) (*revocationList, error) {
+	l := &revocationList{
+		jtis:     make(map[string]struct{}, len(f.JTI)),
+		subjects: make(map[string]time.Time, len(f.Subjects)),
+	}
+	for _, jti := range f.JTI {
+		l.jtis[jti] = struct{}{}
+	}
+	for subject, before := range f.Subjects {
+		var t time.Time
+		if before != "" {
+			var err error
+			t, err = time.Parse(time.RFC3339, before)
+			if err != nil {
+				return nil, fmt.Errorf("invalid revocation time for subject %q: %v", subject, err)
+			}
+		}
+		l.subjects[subject] = t
+	}
+	return l, nil
+}
+
+// revoked returns whether the token carrying claims has been revoked.
+func (l *revocationList) revoked(claims *ClaimSet) bool {
+	if claims.JWTID != "" {
+		if _, ok := l.jtis[claims.JWTID]; ok {
+			return true
+		}
+	}
+
+	before, ok := l.subjects[claims.Subject]
+	if !ok {
+		return false
+	}
+	// Tokens without an issue time cannot be shown to be newer than the
+	// revocation.
+	return before.IsZero() || claims.IssuedAt == 0 || time.Unix(claims.IssuedAt, 0).Before(before)
+}
+
+// revocationSource loads the current revocation list.
+type revocationSource interface {
+	load(ctx context.Context) (*revocationList, error)
+}
+
+// fileRevocationSource reads a revocation list file, only parsing it again
+// when its modification time changes.
+type fileRevocationSource struct {
+	path    string
+	modtime time.Time
+	list    *revocationList
+}
+
+func (s *fileRevocationSource) load(ctx context.Context) (*revocationList, error) {
+	fstat, err := os.Stat(s.path)
+	if err != nil {
+		return nil, err
+	}
+	if s.list != nil && s.modtime.Equal(fstat.ModTime()) {
+		return s.list, nil
+	}
+
+	fp, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	raw, err := io.ReadAll(fp)
+	if err != nil {
+		return nil, err
+	}
+
+	var f revocationFile
+	if err := yaml.UnmarshalStrict(raw, &f); err != nil {
+		return nil, fmt.Errorf("unable to parse revocation list %q: %v", s.path, err)
+	}
+	l, err := newRevocationList(f)
+	if err != nil {
+		return nil, err
+	}
+
+	s.modtime = fstat.ModTime()
+	s.list = l
+	return l, nil
+}
+
+// redisRevocationSource reads the revocation list from redis. Revoked token
+// IDs are members of the set <key>:jti, and revoked subjects are fields of
+// the hash <key>:subjects, with the revocation time as their value.
+type redisRevocationSource struct {
+	client redis.UniversalClient
+	key    string
+}
+
+// Close closes the redis client.
+func (s *redisRevocationSource) Close() error {
+	return s.client.Close()
+}
+
+func (s *redisRevocationSource) load(ctx context.Context) (*revocationList, error) {
+	jtis, err := s.client.SMembers(ctx, s.key+":jti").Result()
+	if err != nil {
+		return nil, err
+	}
+	subjects, err := s.client.HGetAll(ctx, s.key+":subjects").Result()
+	if err != nil {
+		return nil, err
+	}
+	return newRevocationList(revocationFile{JTI: jtis, Subjects: subjects})
+}
+
+// revocations holds the latest revocation list, refreshed periodically from
+// its source until it is closed.
+type revocations struct {
+	source revocationSource
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+
+	mu   sync.RWMutex
+	list *revocationList
+}
+
+// newRevocations loads the revocation list from source, failing if it
+// cannot be loaded, and refreshes it every interval. If a refresh fails,
+// the previous list is kept.
+func newRevocations(source revocationSource, interval time.Duration) (*revocations, error) {
+	r := &revocations{
+		source: source,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if err := r.refresh(); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := r.refresh(); err != nil {
+					logrus.Errorf("token auth: error refreshing revocation list: %v", err)
+				}
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+
+	return r, nil
+}
+
+// Close stops refreshing the revocation list and releases its source. The
+// last list loaded stays in effect.
+func (r *revocations) Close() error {
+	var err error
+	r.once.Do(func() {
+		close(r.stop)
+		<-r.done
+		if c, ok := r.source.(io.Closer); ok {
+			err = c.Close()
+		}
+	})
+	return err
+}
+
+func (r *revocations) refresh() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	l, err := r.source.load(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.list = l
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *revocations) revoked(claims *ClaimSet) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.list.revoked(claims)
+}
+
+// parseRevocationOptions configures the revocation list from the
+// "revocation" option of the token access controller:
+//
+//	revocation:
+//	  file: /path/to/revoked.yml
+//	  redis:
+//	    addrs: [localhost:6379]
+//	    username: registry
+//	    password: secret
+//	    db: 0
+//	    key: registry:revocation
+//	  refresh: 30s
+func parseRevocationOptions(v interface{}) (*revocations, error) {
+	opts, err := options.StringMap(v)
+	if err != nil {
+		return nil, errors.New("token auth requires a valid option map: revocation")
+	}
+
+	interval := defaultRevocationRefresh
+	if v, ok := opts["refresh"]; ok {
+		d, err := options.Duration(v)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("token auth requires a valid option duration: revocation.refresh")
+		}
+		interval = d
+	}
+
+	fileOpt, hasFile := opts["file"]
+	redisOpt, hasRedis := opts["redis"]
+
+	var source revocationSource
+	switch {
+	case hasFile && hasRedis:
+		return nil, errors.New("token auth revocation requires exactly one of file or redis")
+	case hasFile:
+		path, ok := fileOpt.(string)
+		if !ok || path == "" {
+			return nil, errors.New("token auth requires a valid option string: revocation.file")
+		}
+		source = &fileRevocationSource{path: path}
+	case hasRedis:
+		source, err = newRedisRevocationSource(redisOpt)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("token auth revocation requires exactly one of file or redis")
+	}
+
+	return newRevocations(source, interval)
+}
+
+func newRedisRevocationSource(v interface{}) (*redisRevocationSource, error) {
+	opts, err := options.StringMap(v)
+	if err != nil {
+		return nil, errors.New("token auth requires a valid option map: revocation.redis")
+	}
+
+	key := defaultRevocationKey
+	if v, ok := opts["key"]; ok {
+		if key, ok = v.(string); !ok || key == "" {
+			return nil, errors.New("token auth requires a valid option string: revocation.redis.key")
+		}
+	}
+
+	client, err := options.RedisClient(opts)
+	if err != nil {
+		return nil, fmt.Errorf("token auth requires a valid option map: revocation.redis: %v", err)
+	}
+
+	return &redisRevocationSource{client: client, key: key}, nil
+}
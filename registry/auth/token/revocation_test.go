@@ -0,0 +1,232 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/registry/auth"
+)
+
+func TestRevocationList(t *testing.T) {
+	l, err := newRevocationList(revocationFile{
+		JTI: []string{"leaked"},
+		Subjects: map[string]string{
+			"alice":   "2024-05-01T00:00:00Z",
+			"mallory": "",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	revocation := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		claims   ClaimSet
+		expected bool
+	}{
+		{ClaimSet{Subject: "bob", JWTID: "leaked"}, true},
+		{ClaimSet{Subject: "bob", JWTID: "other"}, false},
+		{ClaimSet{Subject: "alice", IssuedAt: revocation.Add(-time.Minute).Unix()}, true},
+		{ClaimSet{Subject: "alice", IssuedAt: revocation.Add(time.Minute).Unix()}, false},
+		{ClaimSet{Subject: "alice"}, true},
+		{ClaimSet{Subject: "mallory", IssuedAt: time.Now().Unix()}, true},
+	} {
+		if got := l.revoked(&tc.claims); got != tc.expected {
+			t.Errorf("revoked(%+v): expected %v, got %v", tc.claims, tc.expected, got)
+		}
+	}
+
+	if _, err := newRevocationList(revocationFile{Subjects: map[string]string{"alice": "yesterday"}}); err == nil {
+		t.Fatal("expected invalid revocation time to be rejected")
+	}
+}
+
+func TestRevokedToken(t *testing.T) {
+	rootKeys, err := makeRootKeys(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootCertBundleFilename, err := writeTempRootCerts(rootKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(rootCertBundleFilename)
+
+	revocationPath := filepath.Join(t.TempDir(), "revoked.yml")
+	if err := os.WriteFile(revocationPath, []byte("jti: []\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := "test-issuer.example.com"
+	service := "test-service.example.com"
+
+	ac, err := newAccessController(map[string]interface{}{
+		"realm":          "https://auth.example.com/token/",
+		"issuer":         issuer,
+		"service":        service,
+		"rootcertbundle": rootCertBundleFilename,
+		"revocation": map[interface{}]interface{}{
+			"file":    revocationPath,
+			"refresh": "1h",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ac.(*accessController).Close()
+
+	jwk, err := makeSigningKeyWithChain(rootKeys[0], 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testAccess := auth.Access{Resource: auth.Resource{Type: "repository", Name: "foo"}, Action: "pull"}
+	token, err := makeTestToken(
+		jwk, issuer, service,
+		[]*ResourceActions{{Type: testAccess.Type, Name: testAccess.Name, Actions: []string{testAccess.Action}}},
+		time.Now(), time.Now().Add(5*time.Minute),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var claims ClaimSet
+	if err := token.JWT.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/v2/foo/tags/list", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token.Raw))
+
+	if _, err := ac.Authorized(req, testAccess); err != nil {
+		t.Fatalf("expected token to be accepted: %v", err)
+	}
+
+	if err := os.WriteFile(revocationPath, []byte(fmt.Sprintf("jti: [%q]\n", claims.JWTID)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(revocationPath, future, future); err != nil {
+		t.Fatal(err)
+	}
+	if err := ac.(*accessController).revocations.refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = ac.Authorized(req, testAccess)
+	challenge, ok := err.(auth.Challenge)
+	if !ok {
+		t.Fatalf("expected challenge, got %v", err)
+	}
+	if challenge.Error() != ErrTokenRevoked.Error() {
+		t.Fatalf("expected %v, got %v", ErrTokenRevoked, challenge)
+	}
+
+	rec := httptest.NewRecorder()
+	challenge.SetHeaders(req, rec)
+	if header := rec.Header().Get("WWW-Authenticate"); !strings.Contains(header, `error="invalid_token"`) {
+		t.Fatalf("expected invalid_token challenge, got %q", header)
+	}
+}
+
+// countingSource counts the loads of an empty revocation list.
+type countingSource struct {
+	loads  int32
+	closed int32
+}
+
+func (s *countingSource) load(ctx context.Context) (*revocationList, error) {
+	atomic.AddInt32(&s.loads, 1)
+	return newRevocationList(revocationFile{})
+}
+
+func (s *countingSource) Close() error {
+	atomic.AddInt32(&s.closed, 1)
+	return nil
+}
+
+func TestRevocationsClose(t *testing.T) {
+	source := &countingSource{}
+	r, err := newRevocations(source, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for atomic.LoadInt32(&source.loads) < 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if closed := atomic.LoadInt32(&source.closed); closed != 1 {
+		t.Fatalf("expected the source to be closed once, got %d", closed)
+	}
+
+	loads := atomic.LoadInt32(&source.loads)
+	time.Sleep(20 * time.Millisecond)
+	if after := atomic.LoadInt32(&source.loads); after != loads {
+		t.Fatalf("expected no refresh after close, got %d more", after-loads)
+	}
+}
+
+func TestRevocationOptions(t *testing.T) {
+	for _, invalid := range []interface{}{
+		"revoked.yml",
+		map[interface{}]interface{}{},
+		map[interface{}]interface{}{"file": "a", "redis": map[interface{}]interface{}{"addrs": []interface{}{"localhost:6379"}}},
+		map[interface{}]interface{}{"file": filepath.Join(t.TempDir(), "missing.yml")},
+		map[interface{}]interface{}{"redis": map[interface{}]interface{}{}},
+		map[interface{}]interface{}{"file": "a", "refresh": "soon"},
+	} {
+		if _, err := parseRevocationOptions(invalid); err == nil {
+			t.Errorf("expected error for revocation options %v", invalid)
+		}
+	}
+}
+
+// TestRedisRevocationSource exercises a live redis instance.
+func TestRedisRevocationSource(t *testing.T) {
+	redisAddr := os.Getenv("TEST_REGISTRY_AUTH_TOKEN_REDIS_ADDR")
+	if redisAddr == "" {
+		t.Skip("please set TEST_REGISTRY_AUTH_TOKEN_REDIS_ADDR to test the revocation list against redis")
+	}
+
+	source, err := newRedisRevocationSource(map[interface{}]interface{}{
+		"addrs": []interface{}{redisAddr},
+		"key":   "test:revocation",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := source.client.Del(ctx, "test:revocation:jti", "test:revocation:subjects").Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.client.SAdd(ctx, "test:revocation:jti", "leaked").Err(); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.client.HSet(ctx, "test:revocation:subjects", "mallory", "").Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := source.load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !l.revoked(&ClaimSet{JWTID: "leaked"}) || !l.revoked(&ClaimSet{Subject: "mallory"}) {
+		t.Fatal("expected tokens to be revoked")
+	}
+	if l.revoked(&ClaimSet{Subject: "bob", JWTID: "other"}) {
+		t.Fatal("expected token not to be revoked")
+	}
+}
@@ -0,0 +1,55 @@
+package tokenserver
+
+import (
+	"github.com/distribution/distribution/v3/registry/auth"
+	"github.com/distribution/distribution/v3/registry/auth/internal/acl"
+	"github.com/distribution/distribution/v3/registry/auth/token"
+)
+
+// aclAuthorizer grants the requested actions permitted by an access
+// control list file, in the format used by the htpasswd access controller.
+type aclAuthorizer struct {
+	file *acl.File
+}
+
+// NewACLAuthorizer returns an Authorizer which grants the actions permitted
+// by the access control list at path. The file is reloaded whenever it
+// changes.
+func NewACLAuthorizer(path string) (Authorizer, error) {
+	f, err := acl.NewFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &aclAuthorizer{file: f}, nil
+}
+
+// Authorize implements Authorizer.
+func (a *aclAuthorizer) Authorize(account string, requested []*token.ResourceActions) ([]*token.ResourceActions, error) {
+	l, err := a.file.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	var granted []*token.ResourceActions
+	for _, ra := range requested {
+		var actions []string
+		for _, action := range ra.Actions {
+			access := auth.Access{
+				Resource: auth.Resource{Type: ra.Type, Class: ra.Class, Name: ra.Name},
+				Action:   action,
+			}
+			if l.Allowed(account, access) {
+				actions = append(actions, action)
+			}
+		}
+		if len(actions) > 0 {
+			granted = append(granted, &token.ResourceActions{
+				Type:    ra.Type,
+				Class:   ra.Class,
+				Name:    ra.Name,
+				Actions: actions,
+			})
+		}
+	}
+	return granted, nil
+}
@@ -0,0 +1,227 @@
+package tokenserver
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+
+	"github.com/distribution/distribution/v3/registry/auth/token"
+)
+
+// refreshClaims is the claim set of a refresh token. Refresh tokens are
+// addressed to the issuer itself rather than to the registry service, so the
+// registry never accepts them as bearer tokens.
+type refreshClaims struct {
+	Issuer     string             `json:"iss"`
+	Subject    string             `json:"sub"`
+	Audience   token.AudienceList `json:"aud"`
+	Expiration int64              `json:"exp"`
+	NotBefore  int64              `json:"nbf"`
+	IssuedAt   int64              `json:"iat"`
+	JWTID      string             `json:"jti"`
+	Service    string             `json:"service"`
+	ClientID   string             `json:"client_id,omitempty"`
+}
+
+// issuer signs access and refresh tokens with a single private key.
+type issuer struct {
+	name      string
+	service   string
+	key       crypto.Signer
+	keyID     string
+	algorithm jose.SignatureAlgorithm
+	signer    jose.Signer
+}
+
+func newIssuer(name, service string, key crypto.Signer) (*issuer, error) {
+	alg, err := signingAlgorithm(key)
+	if err != nil {
+		return nil, err
+	}
+
+	keyID := token.GetJWKThumbprint(key.Public())
+	if keyID == "" {
+		return nil, fmt.Errorf("unsupported signing key type %T", key)
+	}
+
+	signerOpts := (&jose.SignerOptions{}).WithType("JWT").WithHeader(jose.HeaderKey("kid"), keyID)
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: key}, signerOpts)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create token signer: %v", err)
+	}
+
+	return &issuer{
+		name:      name,
+		service:   service,
+		key:       key,
+		keyID:     keyID,
+		algorithm: alg,
+		signer:    signer,
+	}, nil
+}
+
+// accessToken returns a signed access token for subject granting access.
+func (i *issuer) accessToken(subject string, access []*token.ResourceActions, now time.Time, expiration time.Duration) (string, error) {
+	jti, err := randomID()
+	if err != nil {
+		return "", err
+	}
+
+	if access == nil {
+		access = []*token.ResourceActions{}
+	}
+
+	claims := token.ClaimSet{
+		Issuer:     i.name,
+		Subject:    subject,
+		Audience:   token.AudienceList{i.service},
+		Expiration: now.Add(expiration).Unix(),
+		NotBefore:  now.Unix(),
+		IssuedAt:   now.Unix(),
+		JWTID:      jti,
+		Access:     access,
+	}
+
+	return jwt.Signed(i.signer).Claims(claims).Serialize()
+}
+
+// refreshToken returns a signed refresh token for subject.
+func (i *issuer) refreshToken(subject, clientID string, now time.Time, expiration time.Duration) (string, error) {
+	jti, err := randomID()
+	if err != nil {
+		return "", err
+	}
+
+	claims := refreshClaims{
+		Issuer:     i.name,
+		Subject:    subject,
+		Audience:   token.AudienceList{i.name},
+		Expiration: now.Add(expiration).Unix(),
+		NotBefore:  now.Unix(),
+		IssuedAt:   now.Unix(),
+		JWTID:      jti,
+		Service:    i.service,
+		ClientID:   clientID,
+	}
+
+	return jwt.Signed(i.signer).Claims(claims).Serialize()
+}
+
+// verifyRefreshToken checks that raw is a refresh token signed by this
+// issuer for its service and returns its claims.
+func (i *issuer) verifyRefreshToken(raw string, now time.Time) (*refreshClaims, error) {
+	parsed, err := jwt.ParseSigned(raw, []jose.SignatureAlgorithm{i.algorithm})
+	if err != nil {
+		return nil, token.ErrMalformedToken
+	}
+
+	var claims refreshClaims
+	if err := parsed.Claims(i.key.Public(), &claims); err != nil {
+		return nil, token.ErrInvalidToken
+	}
+
+	if claims.Issuer != i.name || claims.Service != i.service || len(claims.Audience) != 1 || claims.Audience[0] != i.name {
+		return nil, token.ErrInvalidToken
+	}
+
+	if now.After(time.Unix(claims.Expiration, 0).Add(token.Leeway)) {
+		return nil, token.ErrInvalidToken
+	}
+
+	if claims.Subject == "" {
+		return nil, token.ErrInvalidToken
+	}
+
+	return &claims, nil
+}
+
+// JSONWebKeySet returns the public part of the signing key as a key set
+// suitable for the "jwks" option of the token access controller.
+func (i *issuer) JSONWebKeySet() jose.JSONWebKeySet {
+	return jose.JSONWebKeySet{
+		Keys: []jose.JSONWebKey{{
+			Key:       i.key.Public(),
+			KeyID:     i.keyID,
+			Algorithm: string(i.algorithm),
+			Use:       "sig",
+		}},
+	}
+}
+
+// signingAlgorithm picks the JWS algorithm matching the type of key.
+func signingAlgorithm(key crypto.Signer) (jose.SignatureAlgorithm, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return jose.RS256, nil
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return jose.ES256, nil
+		case elliptic.P384():
+			return jose.ES384, nil
+		case elliptic.P521():
+			return jose.ES512, nil
+		}
+		return "", fmt.Errorf("unsupported elliptic curve %s", k.Curve.Params().Name)
+	case ed25519.PrivateKey:
+		return jose.EdDSA, nil
+	}
+	return "", fmt.Errorf("unsupported signing key type %T", key)
+}
+
+// LoadSigningKey reads a PEM encoded RSA, ECDSA or Ed25519 private key from
+// path.
+func LoadSigningKey(path string) (crypto.Signer, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read token signing key %q: %v", path, err)
+	}
+
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in token signing key %q", path)
+	}
+
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q in token signing key %q", block.Type, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse token signing key %q: %v", path, err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("token signing key is not a private key")
+	}
+
+	return signer, nil
+}
+
+func randomID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("unable to read random bytes for jwt id: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b[:]), nil
+}
@@ -0,0 +1,435 @@
+// Package tokenserver implements a minimal token server for the Docker
+// registry token authentication protocol.
+//
+// The server authenticates clients with an auth.CredentialAuthenticator,
+// such as the htpasswd access controller, and issues bearer tokens which
+// the token access controller verifies using the key set returned by
+// Server.JSONWebKeySet. Both the GET flow described in the token
+// specification and the OAuth2 POST flow, including refresh tokens, are
+// supported.
+package tokenserver
+
+import (
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/auth"
+	"github.com/distribution/distribution/v3/registry/auth/token"
+)
+
+const (
+	// DefaultExpiration is the lifetime of access tokens when no
+	// expiration is configured.
+	DefaultExpiration = 5 * time.Minute
+
+	// DefaultRefreshExpiration is the lifetime of refresh tokens when no
+	// refresh expiration is configured.
+	DefaultRefreshExpiration = 30 * 24 * time.Hour
+)
+
+// Authorizer decides which of the requested actions are granted to an
+// account. The account is empty for anonymous requests.
+type Authorizer interface {
+	Authorize(account string, requested []*token.ResourceActions) ([]*token.ResourceActions, error)
+}
+
+// AuthorizerFunc is an adapter to allow the use of ordinary functions as
+// an Authorizer.
+type AuthorizerFunc func(account string, requested []*token.ResourceActions) ([]*token.ResourceActions, error)
+
+// Authorize calls f(account, requested).
+func (f AuthorizerFunc) Authorize(account string, requested []*token.ResourceActions) ([]*token.ResourceActions, error) {
+	return f(account, requested)
+}
+
+// denyAuthorizer grants nothing. It is the default, so that a token server
+// without a configured policy never hands out access.
+var denyAuthorizer = AuthorizerFunc(func(account string, requested []*token.ResourceActions) ([]*token.ResourceActions, error) {
+	return nil, nil
+})
+
+// Options configures a Server.
+type Options struct {
+	// Issuer is the "iss" claim of issued tokens. It must match the
+	// "issuer" option of the token access controller.
+	Issuer string
+
+	// Service is the "aud" claim of issued tokens. It must match the
+	// "service" option of the token access controller.
+	Service string
+
+	// Realm is sent in the basic authentication challenge.
+	Realm string
+
+	// SigningKey signs issued tokens.
+	SigningKey crypto.Signer
+
+	// Expiration is the lifetime of access tokens.
+	Expiration time.Duration
+
+	// RefreshExpiration is the lifetime of refresh tokens.
+	RefreshExpiration time.Duration
+
+	// Authenticator verifies client credentials. Refresh tokens are only
+	// issued and honored if it implements auth.UserChecker, so that they
+	// stop working once their account is removed.
+	Authenticator auth.CredentialAuthenticator
+
+	// Authorizer filters the requested scopes. If nil, no access is
+	// granted.
+	Authorizer Authorizer
+}
+
+// Server is an http.Handler issuing registry bearer tokens.
+type Server struct {
+	issuer            *issuer
+	realm             string
+	expiration        time.Duration
+	refreshExpiration time.Duration
+	authenticator     auth.CredentialAuthenticator
+	authorizer        Authorizer
+}
+
+// NewServer creates a token server with the given options.
+func NewServer(opts Options) (*Server, error) {
+	if opts.Issuer == "" {
+		return nil, errors.New("token server requires an issuer")
+	}
+	if opts.Service == "" {
+		return nil, errors.New("token server requires a service")
+	}
+	if opts.SigningKey == nil {
+		return nil, errors.New("token server requires a signing key")
+	}
+	if opts.Authenticator == nil {
+		return nil, errors.New("token server requires a credential authenticator")
+	}
+
+	iss, err := newIssuer(opts.Issuer, opts.Service, opts.SigningKey)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		issuer:            iss,
+		realm:             opts.Realm,
+		expiration:        opts.Expiration,
+		refreshExpiration: opts.RefreshExpiration,
+		authenticator:     opts.Authenticator,
+		authorizer:        opts.Authorizer,
+	}
+	if s.realm == "" {
+		s.realm = opts.Service
+	}
+	if s.expiration <= 0 {
+		s.expiration = DefaultExpiration
+	}
+	if s.refreshExpiration <= 0 {
+		s.refreshExpiration = DefaultRefreshExpiration
+	}
+	if s.authorizer == nil {
+		s.authorizer = denyAuthorizer
+	}
+
+	return s, nil
+}
+
+// JSONWebKeySet returns the public signing key of the server.
+func (s *Server) JSONWebKeySet() jose.JSONWebKeySet {
+	return s.issuer.JSONWebKeySet()
+}
+
+// tokenResponse is the body of a successful token request.
+type tokenResponse struct {
+	Token        string `json:"token,omitempty"`
+	AccessToken  string `json:"access_token"`
+	Scope        string `json:"scope,omitempty"`
+	ExpiresIn    int    `json:"expires_in"`
+	IssuedAt     string `json:"issued_at"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// errorResponse is the body of a failed token request. It follows the
+// OAuth2 error response format.
+type errorResponse struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// tokenRequest carries the parameters common to both token flows.
+type tokenRequest struct {
+	service  string
+	clientID string
+	scopes   []string
+	offline  bool
+	oauth    bool
+}
+
+// ServeHTTP handles GET and POST token requests.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var (
+		account string
+		treq    tokenRequest
+		err     error
+	)
+
+	switch r.Method {
+	case http.MethodGet:
+		account, treq, err = s.parseGet(r)
+	case http.MethodPost:
+		account, treq, err = s.parsePost(r)
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		s.serveError(w, r, http.StatusMethodNotAllowed, "invalid_request", "method not allowed")
+		return
+	}
+
+	if err != nil {
+		var reqErr requestError
+		switch {
+		case errors.As(err, &reqErr):
+			s.serveError(w, r, reqErr.status, reqErr.code, reqErr.description)
+		case errors.Is(err, auth.ErrAuthenticationFailure), errors.Is(err, auth.ErrInvalidCredential):
+			dcontext.GetLogger(r.Context()).Warnf("token server: authentication failed: %v", err)
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf("Basic realm=%q", s.realm))
+			s.serveError(w, r, http.StatusUnauthorized, "invalid_grant", "authentication failed")
+		default:
+			dcontext.GetLogger(r.Context()).Errorf("token server: %v", err)
+			s.serveError(w, r, http.StatusInternalServerError, "server_error", "")
+		}
+		return
+	}
+
+	if treq.service != s.issuer.service {
+		s.serveError(w, r, http.StatusBadRequest, "invalid_request", fmt.Sprintf("unknown service %q", treq.service))
+		return
+	}
+
+	requested, err := parseScopes(treq.scopes)
+	if err != nil {
+		s.serveError(w, r, http.StatusBadRequest, "invalid_scope", err.Error())
+		return
+	}
+
+	granted, err := s.authorizer.Authorize(account, requested)
+	if err != nil {
+		dcontext.GetLogger(r.Context()).Errorf("token server: error authorizing %q: %v", account, err)
+		s.serveError(w, r, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	now := time.Now()
+	accessToken, err := s.issuer.accessToken(account, granted, now, s.expiration)
+	if err != nil {
+		dcontext.GetLogger(r.Context()).Errorf("token server: error signing token: %v", err)
+		s.serveError(w, r, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	resp := tokenResponse{
+		AccessToken: accessToken,
+		ExpiresIn:   int(s.expiration / time.Second),
+		IssuedAt:    now.UTC().Format(time.RFC3339),
+	}
+	if treq.oauth {
+		resp.Scope = formatScopes(granted)
+	} else {
+		resp.Token = accessToken
+	}
+
+	if _, ok := s.authenticator.(auth.UserChecker); ok && treq.offline && account != "" {
+		resp.RefreshToken, err = s.issuer.refreshToken(account, treq.clientID, now, s.refreshExpiration)
+		if err != nil {
+			dcontext.GetLogger(r.Context()).Errorf("token server: error signing refresh token: %v", err)
+			s.serveError(w, r, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+	}
+
+	dcontext.GetLogger(r.Context()).Infof("token server: issued token for %q with scope %q", account, formatScopes(granted))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		dcontext.GetLogger(r.Context()).Errorf("token server: error encoding response: %v", err)
+	}
+}
+
+// parseGet handles the token request described by the registry token
+// authentication specification. Clients authenticate with basic
+// authentication, or not at all for anonymous access.
+func (s *Server) parseGet(r *http.Request) (string, tokenRequest, error) {
+	q := r.URL.Query()
+	treq := tokenRequest{
+		service:  q.Get("service"),
+		clientID: q.Get("client_id"),
+		scopes:   q["scope"],
+	}
+
+	if v := q.Get("offline_token"); v != "" {
+		offline, err := strconv.ParseBool(v)
+		if err != nil {
+			return "", treq, requestError{http.StatusBadRequest, "invalid_request", "invalid offline_token value"}
+		}
+		treq.offline = offline
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		if r.Header.Get("Authorization") != "" {
+			return "", treq, auth.ErrInvalidCredential
+		}
+		return "", treq, nil
+	}
+
+	if account := q.Get("account"); account != "" && account != username {
+		return "", treq, requestError{http.StatusBadRequest, "invalid_request", "account does not match credentials"}
+	}
+
+	if err := s.authenticator.AuthenticateUser(username, password); err != nil {
+		return "", treq, err
+	}
+
+	return username, treq, nil
+}
+
+// parsePost handles the OAuth2 token request with either the "password" or
+// the "refresh_token" grant type.
+func (s *Server) parsePost(r *http.Request) (string, tokenRequest, error) {
+	if err := r.ParseForm(); err != nil {
+		return "", tokenRequest{}, requestError{http.StatusBadRequest, "invalid_request", "unable to parse form"}
+	}
+
+	treq := tokenRequest{
+		service:  r.PostForm.Get("service"),
+		clientID: r.PostForm.Get("client_id"),
+		scopes:   strings.Fields(r.PostForm.Get("scope")),
+		offline:  r.PostForm.Get("access_type") == "offline",
+		oauth:    true,
+	}
+
+	if treq.clientID == "" {
+		return "", treq, requestError{http.StatusBadRequest, "invalid_request", "missing client_id"}
+	}
+
+	switch grantType := r.PostForm.Get("grant_type"); grantType {
+	case "password":
+		username := r.PostForm.Get("username")
+		if username == "" {
+			return "", treq, requestError{http.StatusBadRequest, "invalid_request", "missing username"}
+		}
+		if err := s.authenticator.AuthenticateUser(username, r.PostForm.Get("password")); err != nil {
+			return "", treq, err
+		}
+		return username, treq, nil
+	case "refresh_token":
+		raw := r.PostForm.Get("refresh_token")
+		if raw == "" {
+			return "", treq, requestError{http.StatusBadRequest, "invalid_request", "missing refresh_token"}
+		}
+		claims, err := s.issuer.verifyRefreshToken(raw, time.Now())
+		if err != nil {
+			return "", treq, requestError{http.StatusBadRequest, "invalid_grant", "invalid refresh token"}
+		}
+		if claims.ClientID != treq.clientID {
+			return "", treq, requestError{http.StatusBadRequest, "invalid_grant", "refresh token issued to another client"}
+		}
+		uc, ok := s.authenticator.(auth.UserChecker)
+		if !ok {
+			return "", treq, requestError{http.StatusBadRequest, "invalid_grant", "invalid refresh token"}
+		}
+		exists, err := uc.UserExists(claims.Subject)
+		if err != nil {
+			return "", treq, err
+		}
+		if !exists {
+			return "", treq, requestError{http.StatusBadRequest, "invalid_grant", "invalid refresh token"}
+		}
+		// The presented refresh token stays valid until its own
+		// expiration, so no new one is issued.
+		treq.offline = false
+		return claims.Subject, treq, nil
+	default:
+		return "", treq, requestError{http.StatusBadRequest, "unsupported_grant_type", fmt.Sprintf("unsupported grant_type %q", grantType)}
+	}
+}
+
+func (s *Server) serveError(w http.ResponseWriter, r *http.Request, status int, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(errorResponse{Error: code, Description: description}); err != nil {
+		dcontext.GetLogger(r.Context()).Errorf("token server: error encoding error response: %v", err)
+	}
+}
+
+// requestError is returned for malformed token requests.
+type requestError struct {
+	status      int
+	code        string
+	description string
+}
+
+func (e requestError) Error() string {
+	return fmt.Sprintf("%s: %s", e.code, e.description)
+}
+
+// parseScopes parses scopes of the form "type[(class)]:name:action[,action]".
+// The name may itself contain colons, for example when it includes a
+// registry host and port.
+func parseScopes(scopes []string) ([]*token.ResourceActions, error) {
+	var access []*token.ResourceActions
+	for _, scope := range scopes {
+		for _, s := range strings.Fields(scope) {
+			typ, rest, ok := strings.Cut(s, ":")
+			if !ok {
+				return nil, fmt.Errorf("invalid scope %q", s)
+			}
+			i := strings.LastIndex(rest, ":")
+			if i < 0 {
+				return nil, fmt.Errorf("invalid scope %q", s)
+			}
+			name, actions := rest[:i], rest[i+1:]
+			if typ == "" || name == "" || actions == "" {
+				return nil, fmt.Errorf("invalid scope %q", s)
+			}
+
+			var class string
+			if open := strings.Index(typ, "("); open > 0 && strings.HasSuffix(typ, ")") {
+				typ, class = typ[:open], typ[open+1:len(typ)-1]
+			}
+
+			access = append(access, &token.ResourceActions{
+				Type:    typ,
+				Class:   class,
+				Name:    name,
+				Actions: strings.Split(actions, ","),
+			})
+		}
+	}
+	return access, nil
+}
+
+// formatScopes is the inverse of parseScopes.
+func formatScopes(access []*token.ResourceActions) string {
+	scopes := make([]string, 0, len(access))
+	for _, a := range access {
+		if len(a.Actions) == 0 {
+			continue
+		}
+		typ := a.Type
+		if a.Class != "" {
+			typ = fmt.Sprintf("%s(%s)", a.Type, a.Class)
+		}
+		scopes = append(scopes, fmt.Sprintf("%s:%s:%s", typ, a.Name, strings.Join(a.Actions, ",")))
+	}
+	return strings.Join(scopes, " ")
+}
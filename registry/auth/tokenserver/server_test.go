@@ -0,0 +1,382 @@
+package tokenserver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/distribution/distribution/v3/registry/auth"
+	"github.com/distribution/distribution/v3/registry/auth/token"
+)
+
+type staticAuthenticator map[string]string
+
+func (a staticAuthenticator) AuthenticateUser(username, password string) error {
+	if p, ok := a[username]; !ok || p != password {
+		return auth.ErrAuthenticationFailure
+	}
+	return nil
+}
+
+func (a staticAuthenticator) UserExists(username string) (bool, error) {
+	_, ok := a[username]
+	return ok, nil
+}
+
+const (
+	testIssuer  = "test-issuer"
+	testService = "test-service"
+)
+
+const testACL = `
+rules:
+  - users: [alice]
+    repositories: ["foo/**"]
+    actions: [pull, push]
+`
+
+func newTestServer(t *testing.T) (*Server, auth.AccessController) {
+	t.Helper()
+
+	aclPath := filepath.Join(t.TempDir(), "acl.yml")
+	if err := os.WriteFile(aclPath, []byte(testACL), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	authorizer, err := NewACLAuthorizer(aclPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestServerWithAuthorizer(t, authorizer)
+}
+
+func newTestServerWithAuthorizer(t *testing.T, authorizer Authorizer) (*Server, auth.AccessController) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewServer(Options{
+		Issuer:        testIssuer,
+		Service:       testService,
+		SigningKey:    key,
+		Authenticator: staticAuthenticator{"alice": "secret"},
+		Authorizer:    authorizer,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks, err := json.Marshal(s.JSONWebKeySet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwksPath := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(jwksPath, jwks, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ac, err := auth.GetAccessController("token", map[string]interface{}{
+		"realm":   "https://auth.example.com/token",
+		"issuer":  testIssuer,
+		"service": testService,
+		"jwks":    jwksPath,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s, ac
+}
+
+func pullAccess(name string) auth.Access {
+	return auth.Access{
+		Resource: auth.Resource{Type: "repository", Name: name},
+		Action:   "pull",
+	}
+}
+
+func authorize(t *testing.T, ac auth.AccessController, bearer string, access ...auth.Access) (*auth.Grant, error) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "http://registry.example.com/v2/", nil)
+	req.Header.Set("Authorization", "Bearer "+bearer)
+	return ac.Authorized(req, access...)
+}
+
+func TestTokenServerGet(t *testing.T) {
+	s, ac := newTestServer(t)
+
+	q := url.Values{}
+	q.Set("service", testService)
+	q.Set("scope", "repository:foo/bar:pull,push")
+	q.Set("offline_token", "true")
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/token?"+q.Encode(), nil)
+	req.SetBasicAuth("alice", "secret")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp tokenResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Token == "" || resp.Token != resp.AccessToken {
+		t.Fatalf("expected token and access_token to be set and equal: %+v", resp)
+	}
+	if resp.RefreshToken == "" {
+		t.Fatal("expected a refresh token for offline_token=true")
+	}
+
+	grant, err := authorize(t, ac, resp.Token, pullAccess("foo/bar"))
+	if err != nil {
+		t.Fatalf("issued token was rejected: %v", err)
+	}
+	if grant.User.Name != "alice" {
+		t.Fatalf("unexpected user %q", grant.User.Name)
+	}
+
+	if _, err := authorize(t, ac, resp.Token, pullAccess("other/repo")); err == nil {
+		t.Fatal("expected token to be rejected for a repository outside its scope")
+	}
+
+	if _, err := authorize(t, ac, resp.RefreshToken); err == nil {
+		t.Fatal("expected refresh token to be rejected by the registry")
+	}
+}
+
+func TestTokenServerACL(t *testing.T) {
+	s, ac := newTestServer(t)
+
+	q := url.Values{}
+	q.Set("service", testService)
+	q.Set("scope", "repository:foo/bar:pull,delete")
+	q.Add("scope", "repository:other/repo:pull")
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/token?"+q.Encode(), nil)
+	req.SetBasicAuth("alice", "secret")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp tokenResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authorize(t, ac, resp.Token, pullAccess("foo/bar")); err != nil {
+		t.Fatalf("expected pull on foo/bar to be granted: %v", err)
+	}
+	deleteAccess := auth.Access{
+		Resource: auth.Resource{Type: "repository", Name: "foo/bar"},
+		Action:   "delete",
+	}
+	if _, err := authorize(t, ac, resp.Token, deleteAccess); err == nil {
+		t.Fatal("expected delete on foo/bar to be denied by the acl")
+	}
+	if _, err := authorize(t, ac, resp.Token, pullAccess("other/repo")); err == nil {
+		t.Fatal("expected pull on other/repo to be denied by the acl")
+	}
+}
+
+func TestTokenServerDefaultDeny(t *testing.T) {
+	s, ac := newTestServerWithAuthorizer(t, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/token?service="+testService+"&scope=repository:foo/bar:pull", nil)
+	req.SetBasicAuth("alice", "secret")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp tokenResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authorize(t, ac, resp.Token, pullAccess("foo/bar")); err == nil {
+		t.Fatal("expected a server without an authorizer to grant no access")
+	}
+}
+
+func TestTokenServerAnonymous(t *testing.T) {
+	s, ac := newTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/token?service="+testService+"&scope=repository:foo/bar:pull&offline_token=true", nil)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var resp tokenResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.RefreshToken != "" {
+		t.Fatal("anonymous requests must not receive a refresh token")
+	}
+	if _, err := authorize(t, ac, resp.Token, pullAccess("foo/bar")); err == nil {
+		t.Fatal("expected anonymous token to grant no access")
+	}
+}
+
+func TestTokenServerBadCredentials(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/token?service="+testService, nil)
+	req.SetBasicAuth("alice", "wrong")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, got %d", http.StatusUnauthorized, rec.Code)
+	}
+	if !strings.HasPrefix(rec.Header().Get("WWW-Authenticate"), "Basic ") {
+		t.Fatalf("expected basic challenge, got %q", rec.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func TestTokenServerUnknownService(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/token?service=other", nil)
+	req.SetBasicAuth("alice", "secret")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestTokenServerOAuth(t *testing.T) {
+	s, ac := newTestServer(t)
+
+	post := func(form url.Values) (*httptest.ResponseRecorder, tokenResponse) {
+		req := httptest.NewRequest(http.MethodPost, "/auth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+
+		var resp tokenResponse
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return rec, resp
+	}
+
+	rec, resp := post(url.Values{
+		"grant_type":  {"password"},
+		"service":     {testService},
+		"client_id":   {"test"},
+		"access_type": {"offline"},
+		"username":    {"alice"},
+		"password":    {"secret"},
+		"scope":       {"repository:foo/bar:pull"},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	if resp.RefreshToken == "" {
+		t.Fatal("expected a refresh token for access_type=offline")
+	}
+	if resp.Scope != "repository:foo/bar:pull" {
+		t.Fatalf("unexpected scope %q", resp.Scope)
+	}
+
+	rec, refreshed := post(url.Values{
+		"grant_type":    {"refresh_token"},
+		"service":       {testService},
+		"client_id":     {"test"},
+		"refresh_token": {resp.RefreshToken},
+		"scope":         {"repository:foo/baz:pull"},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+	}
+	grant, err := authorize(t, ac, refreshed.AccessToken, pullAccess("foo/baz"))
+	if err != nil {
+		t.Fatalf("refreshed token was rejected: %v", err)
+	}
+	if grant.User.Name != "alice" {
+		t.Fatalf("unexpected user %q", grant.User.Name)
+	}
+
+	rec, _ = post(url.Values{
+		"grant_type":    {"refresh_token"},
+		"service":       {testService},
+		"client_id":     {"test"},
+		"refresh_token": {resp.AccessToken},
+	})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected access token to be rejected as refresh token, got status %d", rec.Code)
+	}
+
+	// A refresh token is bound to the client it was issued to.
+	rec, _ = post(url.Values{
+		"grant_type":    {"refresh_token"},
+		"service":       {testService},
+		"client_id":     {"other"},
+		"refresh_token": {resp.RefreshToken},
+	})
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid_grant") {
+		t.Fatalf("expected refresh token of another client to be rejected, got status %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// A refresh token stops working once its account is removed.
+	delete(s.authenticator.(staticAuthenticator), "alice")
+	rec, _ = post(url.Values{
+		"grant_type":    {"refresh_token"},
+		"service":       {testService},
+		"client_id":     {"test"},
+		"refresh_token": {resp.RefreshToken},
+	})
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid_grant") {
+		t.Fatalf("expected refresh token of a removed account to be rejected, got status %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	access, err := parseScopes([]string{"repository:localhost:5000/foo:pull,push repository(plugin):bar:pull"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []token.ResourceActions{
+		{Type: "repository", Name: "localhost:5000/foo", Actions: []string{"pull", "push"}},
+		{Type: "repository", Class: "plugin", Name: "bar", Actions: []string{"pull"}},
+	}
+	if len(access) != len(expected) {
+		t.Fatalf("expected %d scopes, got %d", len(expected), len(access))
+	}
+	for i := range expected {
+		if formatScopes([]*token.ResourceActions{access[i]}) != formatScopes([]*token.ResourceActions{&expected[i]}) {
+			t.Fatalf("scope %d: expected %+v, got %+v", i, expected[i], *access[i])
+		}
+	}
+
+	for _, invalid := range []string{"repository", "repository:foo", ":foo:pull", "repository::pull"} {
+		if _, err := parseScopes([]string{invalid}); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
@@ -0,0 +1,425 @@
+// Package webhook provides an access controller which delegates
+// authorization decisions to an external HTTP service.
+//
+// Requests are optionally authenticated by another access controller,
+// configured with the "authenticator" option. The authenticated subject,
+// the requested access and details of the request are then posted as JSON
+// to the configured URL, which answers with an allow or deny decision:
+//
+//	POST /authorize
+//	{
+//	  "subject": {"name": "alice"},
+//	  "access": [{"type": "repository", "name": "team/app", "action": "push"}],
+//	  "client": {"addr": "10.0.0.1", "useragent": "docker/27.0"},
+//	  "request": {"method": "PUT", "host": "registry.example.com", "uri": "/v2/team/app/manifests/latest"}
+//	}
+//
+//	200 OK
+//	{"allowed": true}
+//
+// Decisions are cached per request body, so a cached decision is only reused
+// for a request the webhook would have seen identically.
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/internal/requestutil"
+	"github.com/distribution/distribution/v3/registry/auth"
+	opts "github.com/distribution/distribution/v3/registry/auth/internal/options"
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/sirupsen/logrus"
+)
+
+func init() {
+	if err := auth.Register("authz-webhook", auth.InitFunc(newAccessController)); err != nil {
+		logrus.Errorf("failed to register authz-webhook auth: %v", err)
+	}
+}
+
+const (
+	defaultTimeout  = 5 * time.Second
+	defaultAllowTTL = time.Minute
+	defaultDenyTTL  = 10 * time.Second
+
+	// maxCacheEntries bounds the decision cache. Expired entries are
+	// dropped when the bound is reached, and the cache is cleared if that
+	// does not free enough room.
+	maxCacheEntries = 10000
+)
+
+// Subject identifies the authenticated client. The name is empty for
+// anonymous requests.
+type Subject struct {
+	Name string `json:"name"`
+}
+
+// AccessItem is a single requested action on a resource.
+type AccessItem struct {
+	Type   string `json:"type"`
+	Class  string `json:"class,omitempty"`
+	Name   string `json:"name"`
+	Action string `json:"action"`
+}
+
+// ClientRecord describes the client making the request.
+type ClientRecord struct {
+	Addr      string `json:"addr"`
+	UserAgent string `json:"useragent"`
+}
+
+// RequestRecord describes the HTTP request being authorized.
+type RequestRecord struct {
+	Method string `json:"method"`
+	Host   string `json:"host"`
+	URI    string `json:"uri"`
+}
+
+// Request is the body posted to the webhook.
+type Request struct {
+	Subject Subject       `json:"subject"`
+	Access  []AccessItem  `json:"access"`
+	Client  ClientRecord  `json:"client"`
+	Request RequestRecord `json:"request"`
+}
+
+// Response is the decision returned by the webhook.
+type Response struct {
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+type accessController struct {
+	url           string
+	headers       http.Header
+	client        *http.Client
+	failOpen      bool
+	allowTTL      time.Duration
+	denyTTL       time.Duration
+	authenticator auth.AccessController
+
+	mu    sync.Mutex
+	cache map[string]decision
+}
+
+// decision is a cached webhook response.
+type decision struct {
+	allowed bool
+	expires time.Time
+}
+
+var (
+	_ auth.AccessController = &accessController{}
+	_ auth.StorageConsumer  = &accessController{}
+	_ auth.AdminEnforcer    = &accessController{}
+	_ io.Closer             = &accessController{}
+)
+
+func newAccessController(options map[string]interface{}) (auth.AccessController, error) {
+	url, ok := options["url"].(string)
+	if !ok || url == "" {
+		return nil, fmt.Errorf(`"url" must be set for authz-webhook access controller`)
+	}
+
+	timeout, allowTTL, denyTTL := defaultTimeout, defaultAllowTTL, defaultDenyTTL
+	for key, dst := range map[string]*time.Duration{"timeout": &timeout, "allowttl": &allowTTL, "denyttl": &denyTTL} {
+		if v, present := options[key]; present {
+			d, err := opts.Duration(v)
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("%q must be a non-negative duration for authz-webhook access controller", key)
+			}
+			*dst = d
+		}
+	}
+
+	ac := &accessController{
+		url:      url,
+		headers:  http.Header{},
+		client:   &http.Client{Timeout: timeout},
+		allowTTL: allowTTL,
+		denyTTL:  denyTTL,
+		cache:    map[string]decision{},
+	}
+
+	if v, present := options["failopen"]; present {
+		failOpen, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf(`"failopen" must be a boolean for authz-webhook access controller`)
+		}
+		ac.failOpen = failOpen
+	}
+
+	if v, present := options["headers"]; present {
+		headers, ok := v.(map[interface{}]interface{})
+		if !ok {
+			return nil, fmt.Errorf(`"headers" must be a map for authz-webhook access controller`)
+		}
+		for k, v := range headers {
+			ac.headers.Set(fmt.Sprint(k), fmt.Sprint(v))
+		}
+	}
+
+	if v, present := options["authenticator"]; present {
+		name, params, err := auth.ParseControllerConfig(v)
+		if err != nil {
+			return nil, fmt.Errorf("authz-webhook authenticator: %v", err)
+		}
+		authenticator, err := auth.GetAccessController(name, params)
+		if err != nil {
+			return nil, fmt.Errorf("authz-webhook authenticator (%s): %v", name, err)
+		}
+		ac.authenticator = authenticator
+	}
+
+	return ac, nil
+}
+
+// UseStorage passes the storage driver on to the authenticator, if it keeps
+// state in storage.
+func (ac *accessController) UseStorage(driver storagedriver.StorageDriver) {
+	if sc, ok := ac.authenticator.(auth.StorageConsumer); ok {
+		sc.UseStorage(driver)
+	}
+}
+
+// Close closes the authenticator, if it holds resources.
+func (ac *accessController) Close() error {
+	if c, ok := ac.authenticator.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// EnforcesAdmin returns whether admin access is always decided by the
+// webhook. A controller which fails open grants it when the webhook fails.
+func (ac *accessController) EnforcesAdmin() bool {
+	return !ac.failOpen
+}
+
+// Authorized authenticates the request, if an authenticator is configured,
+// and asks the webhook whether the subject may perform the requested
+// access.
+func (ac *accessController) Authorized(req *http.Request, accessRecords ...auth.Access) (*auth.Grant, error) {
+	var (
+		subject   Subject
+		authChall auth.Challenge
+	)
+	if ac.authenticator != nil {
+		grant, err := ac.authenticator.Authorized(req)
+		if err != nil {
+			if !errors.As(err, &authChall) {
+				return nil, err
+			}
+			// Credentials which were presented but rejected are
+			// challenged right away. Only a request without any is
+			// left to the webhook as anonymous; if it denies, the
+			// client is asked to authenticate.
+			if hasCredentials(req) {
+				return nil, authChall
+			}
+		} else {
+			subject.Name = grant.User.Name
+		}
+	}
+
+	allowed, err := ac.decide(req, subject, accessRecords)
+	if err != nil {
+		dcontext.GetLogger(req.Context()).Errorf("authz-webhook: %v", err)
+		if !ac.failOpen {
+			return nil, err
+		}
+		dcontext.GetLogger(req.Context()).Warnf("authz-webhook: failing open for %q", subject.Name)
+		allowed = true
+	}
+
+	if !allowed {
+		if authChall != nil {
+			return nil, authChall
+		}
+		return nil, challenge{err: auth.ErrAccessDenied}
+	}
+
+	resources := make([]auth.Resource, 0, len(accessRecords))
+	for _, access := range accessRecords {
+		resources = append(resources, access.Resource)
+	}
+
+	return &auth.Grant{
+		User:      auth.UserInfo{Name: subject.Name},
+		Resources: resources,
+	}, nil
+}
+
+// decide returns the cached decision for the request, or asks the webhook.
+func (ac *accessController) decide(req *http.Request, subject Subject, accessRecords []auth.Access) (bool, error) {
+	body := Request{
+		Subject: subject,
+		Access:  make([]AccessItem, 0, len(accessRecords)),
+		Client: ClientRecord{
+			Addr:      requestutil.PeerIP(req),
+			UserAgent: req.UserAgent(),
+		},
+		Request: RequestRecord{
+			Method: req.Method,
+			Host:   req.Host,
+			URI:    req.URL.RequestURI(),
+		},
+	}
+	for _, access := range accessRecords {
+		body.Access = append(body.Access, AccessItem{
+			Type:   access.Type,
+			Class:  access.Class,
+			Name:   access.Name,
+			Action: access.Action,
+		})
+	}
+
+	key := cacheKey(body)
+	now := time.Now()
+	if d, ok := ac.cached(key, now); ok {
+		return d.allowed, nil
+	}
+
+	resp, err := ac.post(req, body)
+	if err != nil {
+		return false, err
+	}
+	if !resp.Allowed && resp.Reason != "" {
+		dcontext.GetLogger(req.Context()).Infof("authz-webhook: denied %q: %s", subject.Name, resp.Reason)
+	}
+
+	ttl := ac.denyTTL
+	if resp.Allowed {
+		ttl = ac.allowTTL
+	}
+	ac.store(key, decision{allowed: resp.Allowed, expires: now.Add(ttl)}, now)
+
+	return resp.Allowed, nil
+}
+
+func (ac *accessController) post(req *http.Request, body Request) (*Response, error) {
+	p, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	hreq, err := http.NewRequestWithContext(req.Context(), http.MethodPost, ac.url, bytes.NewReader(p))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range ac.headers {
+		hreq.Header[k] = v
+	}
+	hreq.Header.Set("Content-Type", "application/json")
+
+	resp, err := ac.client.Do(hreq)
+	if err != nil {
+		return nil, fmt.Errorf("error calling webhook: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("webhook returned status %s", resp.Status)
+	}
+
+	var decision Response
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&decision); err != nil {
+		return nil, fmt.Errorf("error decoding webhook response: %v", err)
+	}
+
+	return &decision, nil
+}
+
+func (ac *accessController) cached(key string, now time.Time) (decision, bool) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	d, ok := ac.cache[key]
+	if !ok || now.After(d.expires) {
+		return decision{}, false
+	}
+	return d, true
+}
+
+func (ac *accessController) store(key string, d decision, now time.Time) {
+	if !d.expires.After(now) {
+		return
+	}
+
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	if len(ac.cache) >= maxCacheEntries {
+		for k, v := range ac.cache {
+			if now.After(v.expires) {
+				delete(ac.cache, k)
+			}
+		}
+		if len(ac.cache) >= maxCacheEntries {
+			ac.cache = map[string]decision{}
+		}
+	}
+	ac.cache[key] = d
+}
+
+// hasCredentials returns whether the request presents any credentials: an
+// Authorization header or a verified client certificate.
+func hasCredentials(req *http.Request) bool {
+	if req.Header.Get("Authorization") != "" {
+		return true
+	}
+	return req.TLS != nil && len(req.TLS.VerifiedChains) > 0
+}
+
+// cacheKey identifies a decision by every field posted to the webhook, with
+// the requested access independent of its order. The query string of the
+// request URI is left out: it carries the state of chunked uploads, which
+// differs for every chunk.
+func cacheKey(body Request) string {
+	items := make([]string, 0, len(body.Access))
+	for _, a := range body.Access {
+		items = append(items, fmt.Sprintf("%s(%s):%s:%s", a.Type, a.Class, a.Name, a.Action))
+	}
+	sort.Strings(items)
+	fields := []string{
+		body.Subject.Name,
+		body.Client.Addr,
+		body.Client.UserAgent,
+		body.Request.Method,
+		body.Request.Host,
+		strings.SplitN(body.Request.URI, "?", 2)[0],
+	}
+	return strings.Join(append(fields, items...), "\x00")
+}
+
+// challenge implements the auth.Challenge interface for requests denied by
+// the webhook.
+type challenge struct {
+	err error
+}
+
+var _ auth.Challenge = challenge{}
+
+// SetHeaders sets no header: the subject is already authenticated and
+// repeating the request with other credentials is not expected to help.
+func (ch challenge) SetHeaders(r *http.Request, w http.ResponseWriter) {}
+
+func (ch challenge) Error() string {
+	return fmt.Sprintf("authz-webhook: %s", ch.err)
+}
+
+// Unwrap returns the underlying error, so that a denial is recognized as
+// auth.ErrAccessDenied.
+func (ch challenge) Unwrap() error {
+	return ch.err
+}
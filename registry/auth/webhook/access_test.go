@@ -0,0 +1,226 @@
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/distribution/distribution/v3/registry/auth"
+	_ "github.com/distribution/distribution/v3/registry/auth/htpasswd"
+	_ "github.com/distribution/distribution/v3/registry/auth/silly"
+)
+
+func access(name, action string) auth.Access {
+	return auth.Access{Resource: auth.Resource{Type: "repository", Name: name}, Action: action}
+}
+
+// newPolicyServer allows user "silly" everything and anonymous pulls of public/
+// repositories.
+func newPolicyServer(t *testing.T, calls *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+
+		if r.Header.Get("X-Token") != "secret" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("error decoding webhook request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		allowed := req.Subject.Name == "silly"
+		if req.Subject.Name == "" {
+			allowed = true
+			for _, a := range req.Access {
+				if a.Action != "pull" || !strings.HasPrefix(a.Name, "public/") {
+					allowed = false
+				}
+			}
+		}
+
+		if err := json.NewEncoder(w).Encode(Response{Allowed: allowed}); err != nil {
+			t.Errorf("error encoding webhook response: %v", err)
+		}
+	}))
+}
+
+func newTestController(t *testing.T, url string, extra map[string]interface{}) auth.AccessController {
+	options := map[string]interface{}{
+		"url":     url,
+		"headers": map[interface{}]interface{}{"X-Token": "secret"},
+		"authenticator": map[interface{}]interface{}{
+			"silly": map[interface{}]interface{}{"realm": "realm", "service": "service"},
+		},
+	}
+	for k, v := range extra {
+		options[k] = v
+	}
+
+	ac, err := auth.GetAccessController("authz-webhook", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ac
+}
+
+func request(authenticated bool) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/v2/", nil)
+	if authenticated {
+		req.Header.Set("Authorization", "Bearer anything")
+	}
+	return req
+}
+
+func TestWebhookDecisions(t *testing.T) {
+	var calls int32
+	server := newPolicyServer(t, &calls)
+	defer server.Close()
+
+	ac := newTestController(t, server.URL, nil)
+
+	grant, err := ac.Authorized(request(true), access("private/app", "push"))
+	if err != nil {
+		t.Fatalf("expected authenticated push to be allowed: %v", err)
+	}
+	if grant.User.Name != "silly" {
+		t.Fatalf("unexpected user %q", grant.User.Name)
+	}
+
+	if _, err := ac.Authorized(request(false), access("public/app", "pull")); err != nil {
+		t.Fatalf("expected anonymous pull of public/app to be allowed: %v", err)
+	}
+
+	_, err = ac.Authorized(request(false), access("private/app", "pull"))
+	ch, ok := err.(auth.Challenge)
+	if !ok {
+		t.Fatalf("expected challenge for anonymous pull of private/app, got %v", err)
+	}
+	rec := httptest.NewRecorder()
+	ch.SetHeaders(request(false), rec)
+	if !strings.HasPrefix(rec.Header().Get("WWW-Authenticate"), "Bearer ") {
+		t.Fatalf("expected the authenticator's challenge, got %q", rec.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func TestWebhookCache(t *testing.T) {
+	var calls int32
+	server := newPolicyServer(t, &calls)
+	defer server.Close()
+
+	ac := newTestController(t, server.URL, nil)
+
+	for i := 0; i < 3; i++ {
+		if _, err := ac.Authorized(request(true), access("a", "pull"), access("a", "push")); err != nil {
+			t.Fatal(err)
+		}
+		// The same access in a different order hits the same entry.
+		if _, err := ac.Authorized(request(true), access("a", "push"), access("a", "pull")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected 1 webhook call, got %d", calls)
+	}
+
+	// The method and URI are posted to the webhook, so a request differing
+	// in them is decided again.
+	req := request(true)
+	req.Method = http.MethodHead
+	if _, err := ac.Authorized(req, access("a", "pull"), access("a", "push")); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 webhook calls, got %d", calls)
+	}
+
+	// The chunks of an upload differ only in their query string, and hit
+	// the same entry.
+	for _, state := range []string{"first", "second"} {
+		req := httptest.NewRequest(http.MethodPatch, "/v2/a/blobs/uploads/id?_state="+state, nil)
+		req.Header.Set("Authorization", "Bearer anything")
+		if _, err := ac.Authorized(req, access("a", "pull"), access("a", "push")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 webhook calls, got %d", calls)
+	}
+
+	uncached := newTestController(t, server.URL, map[string]interface{}{"allowttl": "0s"})
+	for i := 0; i < 3; i++ {
+		if _, err := uncached.Authorized(request(true), access("a", "pull")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if calls != 6 {
+		t.Fatalf("expected 6 webhook calls, got %d", calls)
+	}
+}
+
+func TestWebhookRejectedCredentials(t *testing.T) {
+	var calls int32
+	server := newPolicyServer(t, &calls)
+	defer server.Close()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	htpasswdPath := filepath.Join(t.TempDir(), "htpasswd")
+	if err := os.WriteFile(htpasswdPath, []byte("silly:"+string(hash)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ac := newTestController(t, server.URL, map[string]interface{}{
+		"authenticator": map[interface{}]interface{}{
+			"htpasswd": map[interface{}]interface{}{"realm": "realm", "path": htpasswdPath},
+		},
+	})
+
+	// A wrong password is challenged even for access allowed anonymously.
+	req := httptest.NewRequest(http.MethodGet, "/v2/", nil)
+	req.SetBasicAuth("silly", "wrong")
+	if _, err := ac.Authorized(req, access("public/app", "pull")); err == nil {
+		t.Fatal("expected rejected credentials to be challenged")
+	} else if _, ok := err.(auth.Challenge); !ok {
+		t.Fatalf("expected challenge, got %v", err)
+	}
+	if calls != 0 {
+		t.Fatalf("expected the webhook not to be called, got %d calls", calls)
+	}
+
+	if _, err := ac.Authorized(httptest.NewRequest(http.MethodGet, "/v2/", nil), access("public/app", "pull")); err != nil {
+		t.Fatalf("expected anonymous pull of public/app to be allowed: %v", err)
+	}
+}
+
+func TestWebhookFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	closed := newTestController(t, server.URL, nil)
+	_, err := closed.Authorized(request(true), access("a", "pull"))
+	if err == nil {
+		t.Fatal("expected fail-closed controller to deny")
+	}
+	if _, ok := err.(auth.Challenge); ok {
+		t.Fatalf("expected a plain error, got challenge %v", err)
+	}
+
+	open := newTestController(t, server.URL, map[string]interface{}{"failopen": true})
+	if _, err := open.Authorized(request(true), access("a", "pull")); err != nil {
+		t.Fatalf("expected fail-open controller to allow: %v", err)
+	}
+}
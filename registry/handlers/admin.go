@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/api/errcode"
+	"github.com/distribution/distribution/v3/registry/auth"
+	"github.com/distribution/distribution/v3/registry/auth/robot"
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+)
+
+// The admin API is served under /admin/v1/, next to the v2 API. Its routes
+// are named with the adminRoutePrefix so that they are authorized against
+// the admin resource rather than a repository.
+const (
+	adminRoutePrefix = "admin-"
+
+	routeNameAdminRobots      = adminRoutePrefix + "robots"
+	routeNameAdminRobot       = adminRoutePrefix + "robot"
+	routeNameAdminRobotSecret = adminRoutePrefix + "robot-secret"
+)
+
+const adminErrGroup = "registry.api.admin"
+
+var (
+	// errorCodeRobotUnknown is returned when the named robot account does
+	// not exist.
+	errorCodeRobotUnknown = errcode.Register(adminErrGroup, errcode.ErrorDescriptor{
+		Value:          "ROBOT_UNKNOWN",
+		Message:        "robot account unknown",
+		Description:    `Returned when the named robot account does not exist.`,
+		HTTPStatusCode: http.StatusNotFound,
+	})
+
+	// errorCodeRobotInvalid is returned when a robot account cannot be
+	// created as requested.
+	errorCodeRobotInvalid = errcode.Register(adminErrGroup, errcode.ErrorDescriptor{
+		Value:   "ROBOT_INVALID",
+		Message: "invalid robot account",
+		Description: `Returned when a robot account has an invalid name,
+		permissions or expiry.`,
+		HTTPStatusCode: http.StatusBadRequest,
+	})
+
+	// errorCodeRobotExists is returned when creating a robot account whose
+	// name is taken.
+	errorCodeRobotExists = errcode.Register(adminErrGroup, errcode.ErrorDescriptor{
+		Value:          "ROBOT_EXISTS",
+		Message:        "robot account already exists",
+		Description:    `Returned when creating a robot account whose name is taken.`,
+		HTTPStatusCode: http.StatusConflict,
+	})
+)
+
+// registerAdmin adds the admin API routes to the application's router.
+func (app *App) registerAdmin() {
+	base := path.Join("/", app.Config.HTTP.Prefix, "admin/v1")
+	app.router.Path(base + "/robots").Name(routeNameAdminRobots)
+	app.router.Path(base + "/robots/{robot}").Name(routeNameAdminRobot)
+	app.router.Path(base + "/robots/{robot}/secret").Name(routeNameAdminRobotSecret)
+
+	app.robots = robot.NewStore(app.driver)
+
+	app.register(routeNameAdminRobots, robotsDispatcher)
+	app.register(routeNameAdminRobot, robotsDispatcher)
+	app.register(routeNameAdminRobotSecret, robotsDispatcher)
+
+	app.registerAdminNotifications(base)
+	app.registerAdminCache(base)
+}
+
+// isAdminRoute returns whether the request is for the admin API.
+func isAdminRoute(r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	return route != nil && strings.HasPrefix(route.GetName(), adminRoutePrefix)
+}
+
+// appendAdminAccessRecord adds the access record for the admin API if it's
+// our current route.
+func appendAdminAccessRecord(accessRecords []auth.Access, r *http.Request) []auth.Access {
+	if isAdminRoute(r) {
+		accessRecords = append(accessRecords, auth.Access{
+			Resource: auth.Resource{
+				Type: "registry",
+				Name: "admin",
+			},
+			Action: "*",
+		})
+	}
+	return accessRecords
+}
+
+// robotsDispatcher constructs the robot account handlers of the admin API.
+func robotsDispatcher(ctx *Context, r *http.Request) http.Handler {
+	robotsHandler := &robotsHandler{
+		Context: ctx,
+		Name:    mux.Vars(r)["robot"],
+	}
+
+	switch mux.CurrentRoute(r).GetName() {
+	case routeNameAdminRobots:
+		return handlers.MethodHandler{
+			http.MethodGet:  http.HandlerFunc(robotsHandler.ListRobots),
+			http.MethodPost: http.HandlerFunc(robotsHandler.CreateRobot),
+		}
+	case routeNameAdminRobotSecret:
+		return handlers.MethodHandler{
+			http.MethodPost: http.HandlerFunc(robotsHandler.RotateSecret),
+		}
+	default:
+		return handlers.MethodHandler{
+			http.MethodGet:    http.HandlerFunc(robotsHandler.GetRobot),
+			http.MethodDelete: http.HandlerFunc(robotsHandler.DeleteRobot),
+		}
+	}
+}
+
+// robotsHandler manages robot accounts.
+type robotsHandler struct {
+	*Context
+
+	// Name is the robot account named in the request, if any.
+	Name string
+}
+
+type robotsAPIResponse struct {
+	Robots []robot.Account `json:"robots"`
+}
+
+// robotSecretAPIResponse carries a newly generated secret. The secret is
+// only ever returned once.
+type robotSecretAPIResponse struct {
+	robot.Account
+	Username string `json:"username"`
+	Secret   string `json:"secret"`
+}
+
+// ListRobots returns every robot account.
+func (rh *robotsHandler) ListRobots(w http.ResponseWriter, r *http.Request) {
+	accounts, err := rh.App.robots.List(rh)
+	if err != nil {
+		rh.appendRobotError(err)
+		return
+	}
+	serveAdminJSON(rh, w, http.StatusOK, robotsAPIResponse{Robots: accounts})
+}
+
+// CreateRobot creates a robot account and returns it with its secret.
+func (rh *robotsHandler) CreateRobot(w http.ResponseWriter, r *http.Request) {
+	var account robot.Account
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&account); err != nil {
+		rh.Errors = append(rh.Errors, errorCodeRobotInvalid.WithDetail(err.Error()))
+		return
+	}
+
+	created, secret, err := rh.App.robots.Create(rh, account)
+	if err != nil {
+		rh.appendRobotError(err)
+		return
+	}
+	serveAdminJSON(rh, w, http.StatusCreated, robotSecretAPIResponse{
+		Account:  *created,
+		Username: robot.UsernamePrefix + created.Name,
+		Secret:   secret,
+	})
+}
+
+// GetRobot returns the named robot account.
+func (rh *robotsHandler) GetRobot(w http.ResponseWriter, r *http.Request) {
+	account, err := rh.App.robots.Get(rh, rh.Name)
+	if err != nil {
+		rh.appendRobotError(err)
+		return
+	}
+	serveAdminJSON(rh, w, http.StatusOK, account)
+}
+
+// DeleteRobot deletes the named robot account, revoking its secret.
+func (rh *robotsHandler) DeleteRobot(w http.ResponseWriter, r *http.Request) {
+	if err := rh.App.robots.Delete(rh, rh.Name); err != nil {
+		rh.appendRobotError(err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// RotateSecret replaces the secret of the named robot account.
+func (rh *robotsHandler) RotateSecret(w http.ResponseWriter, r *http.Request) {
+	secret, err := rh.App.robots.RotateSecret(rh, rh.Name)
+	if err != nil {
+		rh.appendRobotError(err)
+		return
+	}
+	account, err := rh.App.robots.Get(rh, rh.Name)
+	if err != nil {
+		rh.appendRobotError(err)
+		return
+	}
+	serveAdminJSON(rh, w, http.StatusOK, robotSecretAPIResponse{
+		Account:  *account,
+		Username: robot.UsernamePrefix + account.Name,
+		Secret:   secret,
+	})
+}
+
+func (rh *robotsHandler) appendRobotError(err error) {
+	switch {
+	case errors.Is(err, robot.ErrAccountUnknown):
+		rh.Errors = append(rh.Errors, errorCodeRobotUnknown.WithDetail(map[string]string{"name": rh.Name}))
+	case errors.Is(err, robot.ErrAccountExists):
+		rh.Errors = append(rh.Errors, errorCodeRobotExists.WithDetail(err.Error()))
+	case errors.Is(err, robot.ErrAccountInvalid):
+		rh.Errors = append(rh.Errors, errorCodeRobotInvalid.WithDetail(err.Error()))
+	default:
+		rh.Errors = append(rh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+	}
+}
+
+// serveAdminJSON writes v as the JSON body of an admin API response.
+func serveAdminJSON(ctx context.Context, w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		dcontext.GetLogger(ctx).Errorf("error encoding admin response: %v", err)
+	}
+}
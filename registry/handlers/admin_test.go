@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	_ "github.com/distribution/distribution/v3/registry/auth/chain"
+	_ "github.com/distribution/distribution/v3/registry/auth/htpasswd"
+	_ "github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+)
+
+func newAdminTestServer(t *testing.T, enabled bool) *httptest.Server {
+	config := adminTestConfig(t, enabled)
+	server := httptest.NewServer(NewApp(dcontext.Background(), &config))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// adminTestACL allows the user "admin" everything, including the admin API.
+const adminTestACL = `
+rules:
+  - users: [admin]
+    repositories: ["**"]
+    actions: ["*"]
+    catalog: true
+    admin: true
+`
+
+// adminTestConfig returns the configuration of an application with robot
+// accounts, authorizing the htpasswd user "admin" for the admin API.
+func adminTestConfig(t *testing.T, enabled bool) configuration.Configuration {
+	t.Helper()
+
+	dir := t.TempDir()
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	htpasswdPath := filepath.Join(dir, "htpasswd")
+	if err := os.WriteFile(htpasswdPath, []byte("admin:"+string(hash)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	aclPath := filepath.Join(dir, "acl.yml")
+	if err := os.WriteFile(aclPath, []byte(adminTestACL), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": nil,
+			"maintenance": configuration.Parameters{"uploadpurging": map[interface{}]interface{}{
+				"enabled": false,
+			}},
+		},
+		Auth: configuration.Auth{
+			"chain": {
+				"controllers": []interface{}{
+					map[interface{}]interface{}{"robot": map[interface{}]interface{}{"realm": "robot-realm"}},
+					map[interface{}]interface{}{"htpasswd": map[interface{}]interface{}{"realm": "realm", "path": htpasswdPath, "acl": aclPath}},
+				},
+			},
+		},
+		Admin: configuration.Admin{Enabled: enabled},
+	}
+}
+
+func adminRequest(t *testing.T, method, url, body string, authorize func(*http.Request)) *http.Response {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authorize != nil {
+		authorize(req)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func asAdmin(req *http.Request) {
+	req.SetBasicAuth("admin", "password")
+}
+
+func checkStatus(t *testing.T, resp *http.Response, expected int) {
+	t.Helper()
+	if resp.StatusCode != expected {
+		t.Fatalf("%s %s: expected status %d, got %d", resp.Request.Method, resp.Request.URL, expected, resp.StatusCode)
+	}
+}
+
+func TestAdminRobots(t *testing.T) {
+	server := newAdminTestServer(t, true)
+	robots := server.URL + "/admin/v1/robots"
+
+	checkStatus(t, adminRequest(t, http.MethodGet, robots, "", nil), http.StatusUnauthorized)
+
+	resp := adminRequest(t, http.MethodGet, robots, "", asAdmin)
+	checkStatus(t, resp, http.StatusOK)
+	var list robotsAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Robots) != 0 {
+		t.Fatalf("expected no robots, got %v", list.Robots)
+	}
+
+	const account = `{"name": "ci", "permissions": [{"repositories": ["team/**"], "actions": ["pull", "push"]}]}`
+	resp = adminRequest(t, http.MethodPost, robots, account, asAdmin)
+	checkStatus(t, resp, http.StatusCreated)
+	var created robotSecretAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	if created.Username != "robot+ci" || created.Secret == "" {
+		t.Fatalf("unexpected response %+v", created)
+	}
+
+	checkStatus(t, adminRequest(t, http.MethodPost, robots, account, asAdmin), http.StatusConflict)
+	checkStatus(t, adminRequest(t, http.MethodPost, robots, `{"name": "Invalid"}`, asAdmin), http.StatusBadRequest)
+
+	// The robot account authenticates on the v2 API but is not an admin.
+	asRobot := func(req *http.Request) { req.SetBasicAuth(created.Username, created.Secret) }
+	checkStatus(t, adminRequest(t, http.MethodGet, server.URL+"/v2/team/app/tags/list", "", asRobot), http.StatusNotFound)
+
+	checkStatus(t, adminRequest(t, http.MethodGet, robots+"/ci", "", asAdmin), http.StatusOK)
+	checkStatus(t, adminRequest(t, http.MethodGet, robots+"/unknown", "", asAdmin), http.StatusNotFound)
+
+	resp = adminRequest(t, http.MethodPost, robots+"/ci/secret", "", asAdmin)
+	checkStatus(t, resp, http.StatusOK)
+	var rotated robotSecretAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rotated); err != nil {
+		t.Fatal(err)
+	}
+	if rotated.Secret == "" || rotated.Secret == created.Secret {
+		t.Fatalf("expected a new secret, got %q", rotated.Secret)
+	}
+
+	checkStatus(t, adminRequest(t, http.MethodDelete, robots+"/ci", "", asAdmin), http.StatusAccepted)
+	checkStatus(t, adminRequest(t, http.MethodGet, robots+"/ci", "", asAdmin), http.StatusNotFound)
+}
+
+func TestAdminRequiresAdminEnforcement(t *testing.T) {
+	config := adminTestConfig(t, true)
+	config.Auth = configuration.Auth{
+		"silly": configuration.Parameters{"realm": "realm", "service": "service"},
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected the admin API to be refused behind an access controller granting everything")
+		}
+	}()
+	NewApp(dcontext.Background(), &config)
+}
+
+func TestAdminDisabled(t *testing.T) {
+	server := newAdminTestServer(t, false)
+	checkStatus(t, adminRequest(t, http.MethodGet, server.URL+"/admin/v1/robots", "", asAdmin), http.StatusNotFound)
+}
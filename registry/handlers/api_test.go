@@ -17,7 +17,9 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/distribution/distribution/v3"
 	"github.com/distribution/distribution/v3/configuration"
@@ -25,6 +27,7 @@ import (
 	"github.com/distribution/distribution/v3/manifest/schema2"
 	"github.com/distribution/distribution/v3/registry/api/errcode"
 	v2 "github.com/distribution/distribution/v3/registry/api/v2"
+	"github.com/distribution/distribution/v3/registry/auth"
 	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
 	"github.com/distribution/distribution/v3/registry/storage/driver/factory"
 	_ "github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
@@ -452,6 +455,116 @@ func TestCatalogAPI(t *testing.T) {
 }
 
 // TestTagsAPI tests the /v2/<name>/tags/list endpoint
+func TestCatalogPrefixAPI(t *testing.T) {
+	env := newTestEnv(t, false)
+	defer env.Shutdown()
+
+	for _, image := range []string{"team/a", "team/b", "team/c", "other/x", "team-x/y"} {
+		createRepository(env, t, image, "sometag")
+	}
+
+	getCatalog := func(t *testing.T, catalogURL string) (*http.Response, []string) {
+		resp, err := http.Get(catalogURL)
+		if err != nil {
+			t.Fatalf("unexpected error issuing request: %v", err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+
+		var ctlg catalogAPIResponse
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&ctlg); err != nil {
+				t.Fatalf("error decoding catalog: %v", err)
+			}
+		}
+		return resp, ctlg.Repositories
+	}
+	buildCatalogURL := func(t *testing.T, values url.Values) string {
+		catalogURL, err := env.builder.BuildCatalogURL(values)
+		if err != nil {
+			t.Fatalf("unexpected error building catalog url: %v", err)
+		}
+		return catalogURL
+	}
+	nextURL := func(t *testing.T, resp *http.Response) string {
+		re := regexp.MustCompile("<(/v2/_catalog.*)>; rel=\"next\"")
+		matches := re.FindStringSubmatch(resp.Header.Get("Link"))
+		if len(matches) != 2 {
+			t.Fatalf("unexpected Link header: %q", resp.Header.Get("Link"))
+		}
+		linkURL, err := url.Parse(matches[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if linkURL.Query().Get("token") == "" || linkURL.Query().Has("last") {
+			t.Fatalf("expected a continuation token in the Link header: %q", resp.Header.Get("Link"))
+		}
+		return env.server.URL + matches[1]
+	}
+
+	t.Run("namespace", func(t *testing.T) {
+		resp, repos := getCatalog(t, buildCatalogURL(t, url.Values{"namespace": []string{"team"}, "n": []string{"2"}}))
+		checkResponse(t, "listing a namespace", resp, http.StatusOK)
+		if !reflect.DeepEqual(repos, []string{"team/a", "team/b"}) {
+			t.Fatalf("unexpected repositories: %v", repos)
+		}
+		next := nextURL(t, resp)
+		if !strings.Contains(next, "namespace=team") {
+			t.Fatalf("expected the namespace in the Link header: %q", next)
+		}
+
+		// The token stays valid as repositories are created before and
+		// after it.
+		createRepository(env, t, "team/aa", "sometag")
+		createRepository(env, t, "team/bb", "sometag")
+
+		resp, repos = getCatalog(t, next)
+		checkResponse(t, "listing a namespace", resp, http.StatusOK)
+		if !reflect.DeepEqual(repos, []string{"team/bb", "team/c"}) {
+			t.Fatalf("unexpected repositories: %v", repos)
+		}
+		if link := resp.Header.Get("Link"); link != "" {
+			resp, repos = getCatalog(t, nextURL(t, resp))
+			checkResponse(t, "listing a namespace", resp, http.StatusOK)
+			if len(repos) != 0 {
+				t.Fatalf("unexpected repositories: %v", repos)
+			}
+		}
+	})
+
+	t.Run("prefix", func(t *testing.T) {
+		for prefix, expected := range map[string][]string{
+			"team/b": {"team/b", "team/bb"},
+			"team-":  {"team-x/y"},
+			"nobody": nil,
+		} {
+			resp, repos := getCatalog(t, buildCatalogURL(t, url.Values{"prefix": []string{prefix}}))
+			checkResponse(t, "listing a prefix", resp, http.StatusOK)
+			if len(repos) != len(expected) || (len(repos) > 0 && !reflect.DeepEqual(repos, expected)) {
+				t.Fatalf("unexpected repositories of %q: %v", prefix, repos)
+			}
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		otherToken := catalogToken{Prefix: "other/", Last: "other/x"}.String()
+		for _, test := range []struct {
+			values url.Values
+			code   errcode.ErrorCode
+		}{
+			{url.Values{"namespace": []string{"team"}, "prefix": []string{"team"}}, errorCodeCatalogQueryInvalid},
+			{url.Values{"token": []string{"not a token"}}, errorCodeCatalogQueryInvalid},
+			{url.Values{"namespace": []string{"team"}, "token": []string{otherToken}}, errorCodeCatalogQueryInvalid},
+			{url.Values{"namespace": []string{"../team"}}, errcode.ErrorCodeNameInvalid},
+			{url.Values{"prefix": []string{"../team"}}, errcode.ErrorCodeNameInvalid},
+		} {
+			resp, _ := getCatalog(t, buildCatalogURL(t, test.values))
+			checkResponse(t, "listing an invalid catalog query", resp, http.StatusBadRequest)
+			// nolint:errcheck
+			checkBodyHasErrorCodes(t, "listing an invalid catalog query", resp, test.code)
+		}
+	})
+}
+
 func TestTagsAPI(t *testing.T) {
 	env := newTestEnv(t, false)
 	defer env.Shutdown()
@@ -608,6 +721,386 @@ func TestTagsAPI(t *testing.T) {
 	}
 }
 
+func TestTagDetailsAPI(t *testing.T) {
+	env := newTestEnv(t, false)
+	defer env.Shutdown()
+
+	imageName, err := reference.WithName("test")
+	if err != nil {
+		t.Fatalf("unable to parse reference: %v", err)
+	}
+
+	// Tagged in this order, the most recently tagged is b.
+	digests := make(map[string]digest.Digest)
+	for _, tag := range []string{"c", "a", "b"} {
+		digests[tag] = createRepository(env, t, imageName.Name(), tag)
+	}
+
+	getTagDetails := func(t *testing.T, name reference.Named, queryParams url.Values) (*http.Response, tagDetailsAPIResponse) {
+		tagsURL, err := env.builder.BuildTagDetailsURL(name, queryParams)
+		if err != nil {
+			t.Fatalf("unexpected error building tag details URL: %v", err)
+		}
+		resp, err := http.Get(tagsURL)
+		if err != nil {
+			t.Fatalf("unexpected error issuing request: %v", err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+
+		var body tagDetailsAPIResponse
+		if resp.StatusCode == http.StatusOK {
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Fatalf("unexpected error decoding response body: %v", err)
+			}
+		}
+		return resp, body
+	}
+	names := func(body tagDetailsAPIResponse) []string {
+		var names []string
+		for _, tag := range body.Tags {
+			names = append(names, tag.Name)
+		}
+		return names
+	}
+
+	t.Run("names", func(t *testing.T) {
+		resp, body := getTagDetails(t, imageName, nil)
+		checkResponse(t, "listing tags", resp, http.StatusOK)
+		if body.Name != imageName.Name() || !reflect.DeepEqual(names(body), []string{"a", "b", "c"}) {
+			t.Fatalf("unexpected tags: %+v", body)
+		}
+		for _, tag := range body.Tags {
+			if tag.Digest != "" || tag.LastModified != nil {
+				t.Fatalf("unexpected details without detail=true: %+v", tag)
+			}
+		}
+	})
+
+	t.Run("details by name", func(t *testing.T) {
+		resp, body := getTagDetails(t, imageName, url.Values{"detail": []string{"true"}, "n": []string{"2"}})
+		checkResponse(t, "listing tag details", resp, http.StatusOK)
+		if !reflect.DeepEqual(names(body), []string{"a", "b"}) {
+			t.Fatalf("unexpected tags: %+v", body)
+		}
+		for _, tag := range body.Tags {
+			if tag.Digest != digests[tag.Name] || tag.MediaType != schema2.MediaTypeManifest || tag.Size == 0 || tag.LastModified == nil {
+				t.Fatalf("unexpected details of %s: %+v", tag.Name, tag)
+			}
+		}
+		if link := resp.Header.Get("Link"); link != `</v2/test/_ext/tags?detail=true&last=b&n=2>; rel="next"` {
+			t.Fatalf("unexpected Link header: %q", link)
+		}
+
+		resp, body = getTagDetails(t, imageName, url.Values{"detail": []string{"true"}, "n": []string{"2"}, "last": []string{"b"}})
+		checkResponse(t, "listing tag details", resp, http.StatusOK)
+		if !reflect.DeepEqual(names(body), []string{"c"}) {
+			t.Fatalf("unexpected tags: %+v", body)
+		}
+		if link := resp.Header.Get("Link"); link != "" {
+			t.Fatalf("unexpected Link header on the last page: %q", link)
+		}
+	})
+
+	t.Run("details by time", func(t *testing.T) {
+		resp, body := getTagDetails(t, imageName, url.Values{"detail": []string{"true"}, "sort": []string{"time"}})
+		checkResponse(t, "listing tag details", resp, http.StatusOK)
+		if !reflect.DeepEqual(names(body), []string{"b", "a", "c"}) {
+			t.Fatalf("unexpected tags: %+v", body)
+		}
+
+		resp, body = getTagDetails(t, imageName, url.Values{"sort": []string{"time"}, "n": []string{"1"}, "last": []string{"b"}})
+		checkResponse(t, "listing tag details", resp, http.StatusOK)
+		if !reflect.DeepEqual(names(body), []string{"a"}) {
+			t.Fatalf("unexpected tags: %+v", body)
+		}
+		link := resp.Header.Get("Link")
+		if !strings.HasPrefix(link, "</v2/test/_ext/tags?") || !strings.HasSuffix(link, `>; rel="next"`) {
+			t.Fatalf("unexpected Link header: %q", link)
+		}
+		next, err := url.Parse(strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		params := next.Query()
+		if params.Get("last") != "a" || params.Get("n") != "1" || params.Get("sort") != "time" {
+			t.Fatalf("unexpected Link header: %q", link)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, params.Get("lastmodified")); err != nil {
+			t.Fatalf("unexpected lastmodified in Link header %q: %v", link, err)
+		}
+
+		// Moving the last tag of the page does not repeat the tags
+		// following it.
+		createRepository(env, t, imageName.Name(), "a")
+		resp, body = getTagDetails(t, imageName, params)
+		checkResponse(t, "listing tag details", resp, http.StatusOK)
+		if !reflect.DeepEqual(names(body), []string{"c"}) {
+			t.Fatalf("unexpected tags: %+v", body)
+		}
+
+		// Without its time, the last tag of the previous page must exist.
+		resp, _ = getTagDetails(t, imageName, url.Values{"sort": []string{"time"}, "last": []string{"gone"}})
+		checkResponse(t, "listing tag details", resp, http.StatusBadRequest)
+		// nolint:errcheck
+		checkBodyHasErrorCodes(t, "listing tag details", resp, errorCodeTagQueryInvalid)
+		params.Set("last", "gone")
+		resp, body = getTagDetails(t, imageName, params)
+		checkResponse(t, "listing tag details", resp, http.StatusOK)
+		if !reflect.DeepEqual(names(body), []string{"c"}) {
+			t.Fatalf("unexpected tags: %+v", body)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, test := range []struct {
+			queryParams url.Values
+			code        errcode.ErrorCode
+		}{
+			{url.Values{"sort": []string{"size"}}, errorCodeTagQueryInvalid},
+			{url.Values{"detail": []string{"maybe"}}, errorCodeTagQueryInvalid},
+			{url.Values{"sort": []string{"time"}, "lastmodified": []string{"yesterday"}}, errorCodeTagQueryInvalid},
+			{url.Values{"n": []string{"-1"}}, errcode.ErrorCodePaginationNumberInvalid},
+			{url.Values{"n": []string{strconv.Itoa(maxTagDetailEntries + 1)}}, errcode.ErrorCodePaginationNumberInvalid},
+		} {
+			resp, _ := getTagDetails(t, imageName, test.queryParams)
+			checkResponse(t, "listing tag details", resp, http.StatusBadRequest)
+			// nolint:errcheck
+			checkBodyHasErrorCodes(t, "listing tag details", resp, test.code)
+		}
+	})
+
+	t.Run("unknown repository", func(t *testing.T) {
+		unknown, _ := reference.WithName("unknown")
+		resp, _ := getTagDetails(t, unknown, nil)
+		checkResponse(t, "listing tag details", resp, http.StatusNotFound)
+		// nolint:errcheck
+		checkBodyHasErrorCodes(t, "listing tag details", resp, errcode.ErrorCodeNameUnknown)
+	})
+}
+
+func TestManifestCopyAPI(t *testing.T) {
+	env := newTestEnv(t, false)
+	defer env.Shutdown()
+
+	staging, _ := reference.WithName("app/staging")
+	prod, _ := reference.WithName("app/prod")
+	dgst := createRepository(env, t, staging.Name(), "1.2")
+
+	copyManifest := func(t *testing.T, ref reference.Named, values url.Values) *http.Response {
+		copyURL, err := env.builder.BuildManifestCopyURL(ref, values)
+		if err != nil {
+			t.Fatalf("unexpected error building manifest copy url: %v", err)
+		}
+		resp, err := http.Post(copyURL, "", nil)
+		if err != nil {
+			t.Fatalf("unexpected error copying manifest: %v", err)
+		}
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+	checkManifest := func(t *testing.T, ref reference.Named) {
+		manifestURL, err := env.builder.BuildManifestURL(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.Head(manifestURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		checkResponse(t, "fetching copied manifest", resp, http.StatusOK)
+		checkHeaders(t, resp, http.Header{"Docker-Content-Digest": []string{dgst.String()}})
+	}
+
+	canonical, _ := reference.WithDigest(prod, dgst)
+	location, _ := env.builder.BuildManifestURL(canonical)
+
+	for _, test := range []struct {
+		tag    string
+		values url.Values
+	}{
+		{"1.2", url.Values{"from": []string{staging.Name()}}},
+		{"stable", url.Values{"from": []string{staging.Name()}, "ref": []string{"1.2"}}},
+		{"pinned", url.Values{"from": []string{staging.Name()}, "ref": []string{dgst.String()}}},
+	} {
+		ref, _ := reference.WithTag(prod, test.tag)
+		resp := copyManifest(t, ref, test.values)
+		checkResponse(t, "copying manifest", resp, http.StatusCreated)
+		checkHeaders(t, resp, http.Header{
+			"Location":              []string{location},
+			"Content-Length":        []string{"0"},
+			"Docker-Content-Digest": []string{dgst.String()},
+		})
+		checkManifest(t, ref)
+	}
+
+	// A copy is itself a complete repository to copy from.
+	resp := copyManifest(t, canonical, url.Values{"from": []string{prod.Name()}, "ref": []string{"1.2"}})
+	checkResponse(t, "copying manifest by digest", resp, http.StatusCreated)
+	checkManifest(t, canonical)
+
+	unknown, _ := reference.WithTag(prod, "unknown")
+	other, _ := reference.WithDigest(prod, digest.FromString("other"))
+	for _, test := range []struct {
+		ref    reference.Named
+		values url.Values
+		status int
+		code   errcode.ErrorCode
+	}{
+		{unknown, url.Values{"from": []string{staging.Name()}}, http.StatusNotFound, errcode.ErrorCodeManifestUnknown},
+		{unknown, url.Values{"from": []string{"app/unknown"}, "ref": []string{"1.2"}}, http.StatusNotFound, errcode.ErrorCodeManifestUnknown},
+		{unknown, url.Values{"from": []string{"Invalid"}}, http.StatusBadRequest, errcode.ErrorCodeNameInvalid},
+		{unknown, url.Values{"from": []string{staging.Name()}, "ref": []string{"-invalid"}}, http.StatusBadRequest, errcode.ErrorCodeTagInvalid},
+		{other, url.Values{"from": []string{staging.Name()}, "ref": []string{"1.2"}}, http.StatusBadRequest, errcode.ErrorCodeDigestInvalid},
+	} {
+		resp := copyManifest(t, test.ref, test.values)
+		checkResponse(t, "copying manifest", resp, test.status)
+		// nolint:errcheck
+		checkBodyHasErrorCodes(t, "copying manifest", resp, test.code)
+	}
+}
+
+func TestBlobUploadAutomaticMount(t *testing.T) {
+	base, _ := reference.WithName("base/alpine")
+	app, _ := reference.WithName("app")
+
+	for _, test := range []struct {
+		name    string
+		policy  configuration.Mount
+		mounted bool
+	}{
+		{"disabled", configuration.Mount{}, false},
+		{"any", configuration.Mount{Automatic: "any"}, true},
+		{"repositories", configuration.Mount{Automatic: "repositories", Repositories: []string{"other", base.Name()}}, true},
+		{"other repositories", configuration.Mount{Automatic: "repositories", Repositories: []string{"other"}}, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			config := configuration.Configuration{
+				Storage: configuration.Storage{
+					"inmemory": configuration.Parameters{},
+					"maintenance": configuration.Parameters{"uploadpurging": map[interface{}]interface{}{
+						"enabled": false,
+					}},
+				},
+			}
+			config.HTTP.Headers = headerConfig
+			config.Policy.Mount = test.policy
+			env := newTestEnvWithConfig(t, &config)
+			defer env.Shutdown()
+
+			layer, dgst, err := testutil.CreateRandomTarFile()
+			if err != nil {
+				t.Fatalf("error creating random layer: %v", err)
+			}
+			uploadURLBase, _ := startPushLayer(t, env, base)
+			pushLayer(t, env.builder, base, dgst, uploadURLBase, layer)
+
+			startMount := func(dgst digest.Digest) *http.Response {
+				uploadURL, err := env.builder.BuildBlobUploadURL(app, url.Values{"mount": []string{dgst.String()}})
+				if err != nil {
+					t.Fatalf("unexpected error building upload url: %v", err)
+				}
+				resp, err := http.Post(uploadURL, "", nil)
+				if err != nil {
+					t.Fatalf("unexpected error starting upload: %v", err)
+				}
+				resp.Body.Close()
+				return resp
+			}
+
+			resp := startMount(dgst)
+			if !test.mounted {
+				checkResponse(t, "starting upload", resp, http.StatusAccepted)
+				return
+			}
+			checkResponse(t, "mounting blob", resp, http.StatusCreated)
+			ref, _ := reference.WithDigest(app, dgst)
+			blobURL, _ := env.builder.BuildBlobURL(ref)
+			checkHeaders(t, resp, http.Header{
+				"Location":              []string{blobURL},
+				"Docker-Content-Digest": []string{dgst.String()},
+			})
+			resp, err = http.Head(blobURL)
+			if err != nil {
+				t.Fatalf("unexpected error checking mounted blob: %v", err)
+			}
+			resp.Body.Close()
+			checkResponse(t, "checking mounted blob", resp, http.StatusOK)
+
+			resp = startMount(digest.FromString("unknown"))
+			checkResponse(t, "starting upload of an unknown blob", resp, http.StatusAccepted)
+		})
+	}
+}
+
+// recordingAccessController grants every request, recording the names of the
+// repositories it is asked to authorize.
+type recordingAccessController struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (ac *recordingAccessController) Authorized(r *http.Request, access ...auth.Access) (*auth.Grant, error) {
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+
+	var resources []auth.Resource
+	for _, a := range access {
+		ac.names = append(ac.names, a.Name)
+		resources = append(resources, a.Resource)
+	}
+	return &auth.Grant{Resources: resources}, nil
+}
+
+// TestBlobUploadAutomaticMountAuthorization checks that only the repositories
+// holding the blob mounted are authorized.
+func TestBlobUploadAutomaticMountAuthorization(t *testing.T) {
+	base, _ := reference.WithName("base/alpine")
+	app, _ := reference.WithName("app")
+
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": configuration.Parameters{},
+			"maintenance": configuration.Parameters{"uploadpurging": map[interface{}]interface{}{
+				"enabled": false,
+			}},
+		},
+	}
+	config.HTTP.Headers = headerConfig
+	config.Policy.Mount = configuration.Mount{Automatic: "repositories", Repositories: []string{"other", "third", base.Name()}}
+	env := newTestEnvWithConfig(t, &config)
+	defer env.Shutdown()
+
+	layer, dgst, err := testutil.CreateRandomTarFile()
+	if err != nil {
+		t.Fatalf("error creating random layer: %v", err)
+	}
+	uploadURLBase, _ := startPushLayer(t, env, base)
+	pushLayer(t, env.builder, base, dgst, uploadURLBase, layer)
+
+	ac := &recordingAccessController{}
+	env.app.accessController = ac
+
+	uploadURL, err := env.builder.BuildBlobUploadURL(app, url.Values{"mount": []string{dgst.String()}})
+	if err != nil {
+		t.Fatalf("unexpected error building upload url: %v", err)
+	}
+	resp, err := http.Post(uploadURL, "", nil)
+	if err != nil {
+		t.Fatalf("unexpected error starting upload: %v", err)
+	}
+	resp.Body.Close()
+	checkResponse(t, "mounting blob", resp, http.StatusCreated)
+
+	ac.mu.Lock()
+	defer ac.mu.Unlock()
+	for _, name := range ac.names {
+		if name != app.Name() && name != base.Name() {
+			t.Fatalf("unexpected authorization of %s: %v", name, ac.names)
+		}
+	}
+}
+
 func checkLink(t *testing.T, urlStr string, numEntries int, last string) url.Values {
 	re := regexp.MustCompile("<(/v2/_catalog.*)>; rel=\"next\"")
 	matches := re.FindStringSubmatch(urlStr)
@@ -5,8 +5,10 @@ import (
 	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"expvar"
 	"fmt"
+	"io"
 	"math"
 	"math/big"
 	"net"
@@ -29,6 +31,7 @@ import (
 	"github.com/distribution/distribution/v3/registry/api/errcode"
 	v2 "github.com/distribution/distribution/v3/registry/api/v2"
 	"github.com/distribution/distribution/v3/registry/auth"
+	"github.com/distribution/distribution/v3/registry/auth/robot"
 	registrymiddleware "github.com/distribution/distribution/v3/registry/middleware/registry"
 	repositorymiddleware "github.com/distribution/distribution/v3/registry/middleware/repository"
 	"github.com/distribution/distribution/v3/registry/proxy"
@@ -37,6 +40,7 @@ import (
 	rediscache "github.com/distribution/distribution/v3/registry/storage/cache/redis"
 	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
 	"github.com/distribution/distribution/v3/registry/storage/driver/factory"
+	"github.com/distribution/distribution/v3/registry/storage/driver/filesystem"
 	storagemiddleware "github.com/distribution/distribution/v3/registry/storage/driver/middleware"
 	"github.com/distribution/distribution/v3/version"
 	"github.com/distribution/reference"
@@ -69,6 +73,9 @@ type App struct {
 	repoRemover      distribution.RepositoryRemover // repoRemover provides ability to delete repos
 	accessController auth.AccessController          // main access controller for application
 
+	// robots stores the robot accounts managed through the admin API.
+	robots *robot.Store
+
 	// httpHost is a parsed representation of the http.host parameter from
 	// the configuration. Only the Scheme and Host fields are used.
 	httpHost url.URL
@@ -77,6 +84,13 @@ type App struct {
 	events struct {
 		sink   events.Sink
 		source notifications.SourceRecord
+
+		// endpoints holds the configured endpoints by name, for the
+		// admin api.
+		endpoints map[string]*notifications.Endpoint
+
+		// journal records events for replay, if configured.
+		journal *notifications.Journal
 	}
 
 	redis redis.UniversalClient
@@ -86,6 +100,9 @@ type App struct {
 
 	// readOnly is true if the registry is in a read-only maintenance mode
 	readOnly bool
+
+	// deleteEnabled is true if the content of the storage may be deleted
+	deleteEnabled bool
 }
 
 // NewApp takes a configuration and returns a configured app, ready to serve
@@ -106,9 +123,13 @@ func NewApp(ctx context.Context, config *configuration.Configuration) *App {
 	app.register(v2.RouteNameManifest, manifestDispatcher)
 	app.register(v2.RouteNameCatalog, catalogDispatcher)
 	app.register(v2.RouteNameTags, tagsDispatcher)
+	app.register(v2.RouteNameTagDetails, tagDetailsDispatcher)
 	app.register(v2.RouteNameBlob, blobDispatcher)
 	app.register(v2.RouteNameBlobUpload, blobUploadDispatcher)
 	app.register(v2.RouteNameBlobUploadChunk, blobUploadDispatcher)
+	app.register(v2.RouteNameRepository, repositoryDispatcher)
+	app.register(v2.RouteNameRepositoryCopy, repositoryCopyDispatcher)
+	app.register(v2.RouteNameManifestCopy, manifestCopyDispatcher)
 
 	// override the storage driver's UA string for registry outbound HTTP requests
 	storageParams := config.Storage.Parameters()
@@ -150,7 +171,8 @@ func NewApp(ctx context.Context, config *configuration.Configuration) *App {
 		}
 	}
 
-	startUploadPurger(app, app.driver, dcontext.GetLogger(app), purgeConfig)
+	// The upload purger works on the driver without storage middleware.
+	purgeDriver := app.driver
 
 	app.driver, err = applyStorageMiddleware(app, app.driver, config.Middleware["storage"])
 	if err != nil {
@@ -162,10 +184,12 @@ func NewApp(ctx context.Context, config *configuration.Configuration) *App {
 	if !app.isCache {
 		app.configureSecret(config)
 	}
-	app.configureEvents(config)
 	app.configureRedis(config)
+	app.configureEvents(config)
 	app.configureLogHook(config)
 
+	startUploadPurger(app, purgeDriver, dcontext.GetLogger(app), purgeConfig, app.systemBridge())
+
 	options := registrymiddleware.GetRegistryOptions()
 
 	if config.HTTP.Host != "" {
@@ -186,6 +210,7 @@ func NewApp(ctx context.Context, config *configuration.Configuration) *App {
 		if ok {
 			if deleteEnabled, ok := e.(bool); ok && deleteEnabled {
 				options = append(options, storage.EnableDelete)
+				app.deleteEnabled = true
 			}
 		}
 	}
@@ -342,13 +367,32 @@ func NewApp(ctx context.Context, config *configuration.Configuration) *App {
 		if err != nil {
 			panic(fmt.Sprintf("unable to configure authorization (%s): %v", authType, err))
 		}
+		if sc, ok := accessController.(auth.StorageConsumer); ok {
+			sc.UseStorage(app.driver)
+		}
 		app.accessController = accessController
 		dcontext.GetLogger(app).Debugf("configured %q access controller", authType)
 	}
 
+	switch config.Policy.Mount.Automatic {
+	case "", mountPolicyAny, mountPolicyRepositories:
+	default:
+		panic(fmt.Sprintf("invalid policy.mount.automatic value %q", config.Policy.Mount.Automatic))
+	}
+
+	if config.Admin.Enabled {
+		if app.accessController == nil {
+			panic("the admin API requires an access controller")
+		}
+		if ae, ok := app.accessController.(auth.AdminEnforcer); !ok || !ae.EnforcesAdmin() {
+			panic("the admin API requires an access controller which enforces admin access")
+		}
+		app.registerAdmin()
+	}
+
 	// configure as a pull through cache
 	if config.Proxy.RemoteURL != "" {
-		app.registry, err = proxy.NewRegistryPullThroughCache(ctx, app.registry, app.driver, config.Proxy)
+		app.registry, err = proxy.NewRegistryPullThroughCache(ctx, app.registry, app.driver, config.Proxy, proxy.WithRedis(app.redis))
 		if err != nil {
 			panic(err.Error())
 		}
@@ -447,12 +491,20 @@ func (app *App) RegisterHealthChecks(healthRegistries ...*health.Registry) {
 	}
 }
 
-// Shutdown close the underlying registry
+// Shutdown close the underlying registry and access controller
 func (app *App) Shutdown() error {
+	var errs []error
+	if c, ok := app.accessController.(io.Closer); ok {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
 	if r, ok := app.registry.(proxy.Closer); ok {
-		return r.Close()
+		if err := r.Close(); err != nil {
+			errs = append(errs, err)
+		}
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
 // register a handler with the application, by route name. The handler will be
@@ -487,23 +539,56 @@ func (app *App) configureEvents(configuration *configuration.Configuration) {
 	// should have at the time the iteration starts
 	// nolint:prealloc
 	var sinks []events.Sink
+	app.events.endpoints = make(map[string]*notifications.Endpoint)
 	for _, endpoint := range configuration.Notifications.Endpoints {
 		if endpoint.Disabled {
 			dcontext.GetLogger(app).Infof("endpoint %s disabled, skipping", endpoint.Name)
 			continue
 		}
 
-		dcontext.GetLogger(app).Infof("configuring endpoint %v (%v), timeout=%s, headers=%v", endpoint.Name, endpoint.URL, endpoint.Timeout, endpoint.Headers)
-		endpoint := notifications.NewEndpoint(endpoint.Name, endpoint.URL, notifications.EndpointConfig{
+		filter, err := notifications.NewFilter(endpoint.Filter)
+		if err != nil {
+			panic(fmt.Sprintf("notifications endpoint %s: %v", endpoint.Name, err))
+		}
+
+		url, delivery, err := app.endpointDelivery(endpoint)
+		if err != nil {
+			panic(fmt.Sprintf("notifications endpoint %s: %v", endpoint.Name, err))
+		}
+
+		deadLetters, err := app.endpointDeadLetters(endpoint)
+		if err != nil {
+			panic(fmt.Sprintf("notifications endpoint %s: %v", endpoint.Name, err))
+		}
+
+		dcontext.GetLogger(app).Infof("configuring endpoint %v (%v), timeout=%s, headers=%v", endpoint.Name, url, endpoint.Timeout, endpoint.Headers)
+		endpoint := notifications.NewEndpoint(endpoint.Name, url, notifications.EndpointConfig{
 			Timeout:           endpoint.Timeout,
 			Threshold:         endpoint.Threshold,
 			Backoff:           endpoint.Backoff,
 			Headers:           endpoint.Headers,
 			IgnoredMediaTypes: endpoint.IgnoredMediaTypes,
 			Ignore:            endpoint.Ignore,
+			Filter:            filter,
+			Delivery:          delivery,
+			Retry:             endpoint.Retry,
+			DeadLetters:       deadLetters,
+			MaxBatchSize:      endpoint.MaxBatchSize,
+			MaxBatchDelay:     endpoint.MaxBatchDelay,
+			Gzip:              endpoint.Gzip,
 		})
 
 		sinks = append(sinks, endpoint)
+		app.events.endpoints[endpoint.Name()] = endpoint
+	}
+
+	if journal := configuration.Notifications.Journal; journal.Directory != "" || journal.Storage {
+		driver, err := app.notificationsDriver(journal.Directory, journal.Storage)
+		if err != nil {
+			panic(fmt.Sprintf("notifications journal: %v", err))
+		}
+		app.events.journal = notifications.NewJournal(driver, journal.MaxAge)
+		sinks = append(sinks, notifications.NewJournalSink(app.events.journal, journal.Ignore))
 	}
 
 	// NOTE(stevvooe): Moving to a new queuing implementation is as easy as
@@ -530,6 +615,52 @@ func (app *App) configureEvents(configuration *configuration.Configuration) {
 	}
 }
 
+// endpointDeadLetters returns the store keeping the events a notification
+// endpoint gives up on, or nil if they are to be dropped.
+func (app *App) endpointDeadLetters(endpoint configuration.Endpoint) (*notifications.DeadLetterStore, error) {
+	if endpoint.DeadLetter.Directory == "" && !endpoint.DeadLetter.Storage {
+		return nil, nil
+	}
+	driver, err := app.notificationsDriver(endpoint.DeadLetter.Directory, endpoint.DeadLetter.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("deadletter: %v", err)
+	}
+	return notifications.NewDeadLetterStore(driver), nil
+}
+
+// notificationsDriver returns the driver keeping notifications state either
+// in a local directory or in the registry's storage.
+func (app *App) notificationsDriver(directory string, storage bool) (storagedriver.StorageDriver, error) {
+	if directory != "" && storage {
+		return nil, fmt.Errorf("directory and storage are mutually exclusive")
+	}
+	if storage {
+		return app.driver, nil
+	}
+	return filesystem.FromParameters(map[string]interface{}{
+		"rootdirectory": directory,
+	})
+}
+
+// endpointDelivery returns the url describing a notification endpoint, and
+// the sink delivering its events if it is not an http webhook.
+func (app *App) endpointDelivery(endpoint configuration.Endpoint) (string, events.Sink, error) {
+	switch endpoint.Type {
+	case "", "http":
+		return endpoint.URL, nil, nil
+	case "file":
+		sink, err := notifications.NewFileSink(endpoint.File.Path, endpoint.File.MaxSize, endpoint.File.MaxBackups)
+		return "file://" + endpoint.File.Path, sink, err
+	case "exec":
+		sink, err := notifications.NewExecSink(endpoint.Exec.Command, endpoint.Timeout)
+		return "exec:" + strings.Join(endpoint.Exec.Command, " "), sink, err
+	case "redis":
+		sink, err := notifications.NewRedisStreamSink(app.redis, endpoint.Redis.Stream, endpoint.Redis.MaxLen, endpoint.Timeout)
+		return "redis:" + endpoint.Redis.Stream, sink, err
+	}
+	return "", nil, fmt.Errorf("unknown endpoint type %q", endpoint.Type)
+}
+
 func (app *App) configureRedis(cfg *configuration.Configuration) {
 	if len(cfg.Redis.Options.Addrs) == 0 {
 		dcontext.GetLogger(app).Infof("redis not configured")
@@ -869,6 +1000,10 @@ func (app *App) authorized(w http.ResponseWriter, r *http.Request, context *Cont
 			// mounting a blob from one repository to another requires pull (GET)
 			// access to the source repository.
 			accessRecords = appendAccessRecords(accessRecords, http.MethodGet, fromRepo)
+			if isRepositoryMove(r) {
+				// renaming a repository also requires delete access to it.
+				accessRecords = appendAccessRecords(accessRecords, http.MethodDelete, fromRepo)
+			}
 		}
 	} else {
 		// Only allow the name not to be set on the base route.
@@ -885,6 +1020,7 @@ func (app *App) authorized(w http.ResponseWriter, r *http.Request, context *Cont
 			return fmt.Errorf("forbidden: no repository name")
 		}
 		accessRecords = appendCatalogAccessRecord(accessRecords, r)
+		accessRecords = appendAdminAccessRecord(accessRecords, r)
 	}
 
 	grant, err := app.accessController.Authorized(r.WithContext(context.Context), accessRecords...)
@@ -894,7 +1030,16 @@ func (app *App) authorized(w http.ResponseWriter, r *http.Request, context *Cont
 			// Add the appropriate WWW-Auth header
 			err.SetHeaders(r, w)
 
-			if err := errcode.ServeJSON(w, errcode.ErrorCodeUnauthorized.WithDetail(accessRecords)); err != nil {
+			code := errcode.ErrorCodeUnauthorized
+			switch {
+			case errors.Is(err, auth.ErrTooManyAttempts):
+				code = errcode.ErrorCodeTooManyRequests
+			case errors.Is(err, auth.ErrAccessDenied):
+				// The client authenticated but lacks access, so asking it
+				// for credentials again would not help.
+				code = errcode.ErrorCodeDenied
+			}
+			if err := errcode.ServeJSON(w, code.WithDetail(accessRecords)); err != nil {
 				dcontext.GetLogger(context).Errorf("error serving error json: %v (from %v)", err, context.Errors)
 			}
 		default:
@@ -914,6 +1059,7 @@ func (app *App) authorized(w http.ResponseWriter, r *http.Request, context *Cont
 
 	ctx := withUser(context.Context, grant.User)
 	ctx = withResources(ctx, grant.Resources)
+	ctx = withGrant(ctx, grant)
 
 	dcontext.GetLogger(ctx, userNameKey).Info("authorized request")
 	// TODO(stevvooe): This pattern needs to be cleaned up a bit. One context
@@ -923,6 +1069,16 @@ func (app *App) authorized(w http.ResponseWriter, r *http.Request, context *Cont
 	return nil
 }
 
+// mayPull returns true if the request is allowed to pull from the named
+// repository, beyond the repositories it was authorized for.
+func (app *App) mayPull(ctx context.Context, r *http.Request, name string) bool {
+	if app.accessController == nil {
+		return true
+	}
+	_, err := app.accessController.Authorized(r.WithContext(ctx), appendAccessRecords(nil, http.MethodGet, name)...)
+	return err == nil
+}
+
 // eventBridge returns a bridge for the current request, configured with the
 // correct actor and source.
 func (app *App) eventBridge(ctx *Context, r *http.Request) notifications.Listener {
@@ -934,6 +1090,27 @@ func (app *App) eventBridge(ctx *Context, r *http.Request) notifications.Listene
 	return notifications.NewBridge(ctx.urlBuilder, app.events.source, actor, request, app.events.sink, app.Config.Notifications.EventConfig.IncludeReferences)
 }
 
+// systemBridge returns a bridge for events the registry generates on its own,
+// outside of any request.
+func (app *App) systemBridge() notifications.Listener {
+	return notifications.NewBridge(nil, app.events.source, notifications.ActorRecord{}, notifications.RequestRecord{}, app.events.sink, app.Config.Notifications.EventConfig.IncludeReferences)
+}
+
+// NewEventListener returns a listener writing events to the sinks configured
+// for the registry, for commands such as the garbage collector working on
+// driver outside of a running registry. The returned sink must be closed once
+// done with, to flush pending events.
+func NewEventListener(ctx context.Context, config *configuration.Configuration, driver storagedriver.StorageDriver) (notifications.Listener, events.Sink) {
+	app := &App{
+		Config:  config,
+		Context: ctx,
+		driver:  driver,
+	}
+	app.configureRedis(config)
+	app.configureEvents(config)
+	return app.systemBridge(), app.events.sink
+}
+
 // nameRequired returns true if the route requires a name.
 func (app *App) nameRequired(r *http.Request) bool {
 	route := mux.CurrentRoute(r)
@@ -941,7 +1118,7 @@ func (app *App) nameRequired(r *http.Request) bool {
 		return true
 	}
 	routeName := route.GetName()
-	return routeName != v2.RouteNameBase && routeName != v2.RouteNameCatalog
+	return routeName != v2.RouteNameBase && routeName != v2.RouteNameCatalog && !isAdminRoute(r)
 }
 
 // apiBase implements a simple yes-man for doing overall checks against the
@@ -1061,9 +1238,32 @@ func badPurgeUploadConfig(reason string) {
 	panic(fmt.Sprintf("Unable to parse upload purge configuration: %s", reason))
 }
 
+// notifyPurged tells listener, if it listens to uploads, of the uploads kept
+// in the purged directories.
+func notifyPurged(listener notifications.Listener, log dcontext.Logger, purged []string) {
+	ul, ok := listener.(notifications.UploadListener)
+	if !ok {
+		return
+	}
+	for _, dir := range purged {
+		name, id, err := storage.UploadFromPath(dir)
+		if err != nil {
+			log.Errorf("error dispatching upload purge to listener: %v", err)
+			continue
+		}
+		named, err := reference.WithName(name)
+		if err == nil {
+			err = ul.BlobUploadPurged(named, id)
+		}
+		if err != nil {
+			log.Errorf("error dispatching upload purge to listener: %v", err)
+		}
+	}
+}
+
 // startUploadPurger schedules a goroutine which will periodically
 // check upload directories for old files and delete them
-func startUploadPurger(ctx context.Context, storageDriver storagedriver.StorageDriver, log dcontext.Logger, config map[interface{}]interface{}) {
+func startUploadPurger(ctx context.Context, storageDriver storagedriver.StorageDriver, log dcontext.Logger, config map[interface{}]interface{}, listener notifications.Listener) {
 	if config["enabled"] == false {
 		return
 	}
@@ -1123,7 +1323,10 @@ func startUploadPurger(ctx context.Context, storageDriver storagedriver.StorageD
 		time.Sleep(jitter)
 
 		for {
-			storage.PurgeUploads(ctx, storageDriver, time.Now().Add(-purgeAgeDuration), !dryRunBool)
+			deleted, _ := storage.PurgeUploads(ctx, storageDriver, time.Now().Add(-purgeAgeDuration), !dryRunBool)
+			if !dryRunBool {
+				notifyPurged(listener, log, deleted)
+			}
 			log.Infof("Starting upload purge in %s", intervalDuration)
 			time.Sleep(intervalDuration)
 		}
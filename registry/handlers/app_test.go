@@ -33,13 +33,15 @@ func TestAppDispatcher(t *testing.T) {
 	app := &App{
 		Config:   &configuration.Configuration{},
 		Context:  ctx,
-		router:   v2.Router(),
+		router:   v2.RouterWithPrefix(""),
 		driver:   driver,
 		registry: registry,
 	}
 	server := httptest.NewServer(app)
 	defer server.Close()
-	router := v2.Router()
+	// Build urls with a router of our own, since setting the host of a
+	// route on the shared router would leak into other tests.
+	router := v2.RouterWithPrefix("")
 
 	serverURL, err := url.Parse(server.URL)
 	if err != nil {
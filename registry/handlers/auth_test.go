@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/api/errcode"
+	_ "github.com/distribution/distribution/v3/registry/auth/silly"
+	_ "github.com/distribution/distribution/v3/registry/auth/webhook"
+)
+
+// checkDenied checks that a request for the tags of a repository, with the
+// credentials set by authorize, is denied with a 403 and no challenge.
+func checkDenied(t *testing.T, serverURL string, authorize func(*http.Request)) {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, serverURL+"/v2/private/app/tags/list", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authorize(req)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, resp.StatusCode)
+	}
+	if h := resp.Header.Get("WWW-Authenticate"); h != "" {
+		t.Fatalf("unexpected challenge of a denied request: %q", h)
+	}
+	var body struct {
+		Errors []errcode.Error `json:"errors"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Errors) != 1 || body.Errors[0].Code != errcode.ErrorCodeDenied {
+		t.Fatalf("expected a DENIED error, got %+v", body.Errors)
+	}
+}
+
+// TestWebhookDenied checks that a subject the authz-webhook denies receives a
+// 403 rather than a challenge.
+func TestWebhookDenied(t *testing.T) {
+	policy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write([]byte(`{"allowed": false, "reason": "private"}`)); err != nil {
+			t.Errorf("error writing webhook response: %v", err)
+		}
+	}))
+	defer policy.Close()
+
+	config := configuration.Configuration{
+		Storage: configuration.Storage{
+			"inmemory": nil,
+			"maintenance": configuration.Parameters{"uploadpurging": map[interface{}]interface{}{
+				"enabled": false,
+			}},
+		},
+		Auth: configuration.Auth{
+			"authz-webhook": {
+				"url": policy.URL,
+				"authenticator": map[interface{}]interface{}{
+					"silly": map[interface{}]interface{}{"realm": "realm", "service": "service"},
+				},
+			},
+		},
+	}
+	server := httptest.NewServer(NewApp(dcontext.Background(), &config))
+	defer server.Close()
+
+	checkDenied(t, server.URL, func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer anything")
+	})
+}
+
+// TestRobotDenied checks that a robot account requesting a repository outside
+// of its permissions receives a 403 rather than a challenge, even behind a
+// chain whose other controllers would challenge the request.
+func TestRobotDenied(t *testing.T) {
+	server := newAdminTestServer(t, true)
+
+	const account = `{"name": "ci", "permissions": [{"repositories": ["team/**"], "actions": ["pull"]}]}`
+	resp := adminRequest(t, http.MethodPost, server.URL+"/admin/v1/robots", account, asAdmin)
+	checkStatus(t, resp, http.StatusCreated)
+	var created robotSecretAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+
+	checkDenied(t, server.URL, func(req *http.Request) {
+		req.SetBasicAuth(created.Username, created.Secret)
+	})
+}
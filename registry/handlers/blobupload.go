@@ -16,6 +16,13 @@ import (
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
+// The policies mounting blobs named by uploads without a repository to mount
+// them from.
+const (
+	mountPolicyAny          = "any"
+	mountPolicyRepositories = "repositories"
+)
+
 // blobUploadDispatcher constructs and returns the blob upload handler for the
 // given request context.
 func blobUploadDispatcher(ctx *Context, r *http.Request) http.Handler {
@@ -75,6 +82,11 @@ func (buh *blobUploadHandler) StartBlobUpload(w http.ResponseWriter, r *http.Req
 		if opt != nil && err == nil {
 			options = append(options, opt)
 		}
+	} else if mountDigest != "" {
+		opt, err := buh.createAutomaticMountOption(r, mountDigest)
+		if opt != nil && err == nil {
+			options = append(options, opt)
+		}
 	}
 
 	blobs := buh.Repository.Blobs(buh)
@@ -382,6 +394,59 @@ func (buh *blobUploadHandler) createBlobMountOption(fromRepo, mountDigest string
 	return storage.WithMountFrom(canonical), nil
 }
 
+// createAutomaticMountOption looks up a blob named by its digest alone,
+// returning the option mounting it if the mount policy of the registry lets
+// the caller access it. No option is returned if the blob is not found.
+func (buh *blobUploadHandler) createAutomaticMountOption(r *http.Request, mountDigest string) (distribution.BlobCreateOption, error) {
+	policy := buh.App.Config.Policy.Mount
+	if buh.App.isCache || policy.Automatic == "" {
+		return nil, nil
+	}
+
+	dgst, err := digest.Parse(mountDigest)
+	if err != nil {
+		return nil, err
+	}
+
+	switch policy.Automatic {
+	case mountPolicyAny:
+		desc, err := buh.App.registry.BlobStatter().Stat(buh, dgst)
+		if err != nil {
+			return nil, err
+		}
+		return storage.WithMountDescriptor(desc), nil
+	case mountPolicyRepositories:
+		// The blob is looked up before the caller is authorized, so that
+		// only the repositories holding it are authorized, one at a time
+		// until one may be pulled from.
+		for _, name := range policy.Repositories {
+			if name == buh.Repository.Named().Name() {
+				continue
+			}
+			ref, err := reference.WithName(name)
+			if err != nil {
+				continue
+			}
+			repo, err := buh.App.registry.Repository(buh, ref)
+			if err != nil {
+				continue
+			}
+			if _, err := repo.Blobs(buh).Stat(buh, dgst); err != nil {
+				continue
+			}
+			if !buh.App.mayPull(buh, r, name) {
+				continue
+			}
+			canonical, err := reference.WithDigest(ref, dgst)
+			if err != nil {
+				return nil, err
+			}
+			return storage.WithMountFrom(canonical), nil
+		}
+	}
+	return nil, nil
+}
+
 // writeBlobCreatedHeaders writes the standard headers describing a newly
 // created blob. A 201 Created is written as well as the canonical URL and
 // blob digest.
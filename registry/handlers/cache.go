@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/api/errcode"
+	"github.com/distribution/distribution/v3/registry/proxy"
+	"github.com/distribution/reference"
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+)
+
+const (
+	routeNameAdminPins   = adminRoutePrefix + "pins"
+	routeNameAdminPin    = adminRoutePrefix + "pin"
+	routeNameAdminWarmUp = adminRoutePrefix + "warmup"
+)
+
+var (
+	// errorCodeCacheDisabled is returned when managing the pull through
+	// cache of a registry which is not one.
+	errorCodeCacheDisabled = errcode.Register(adminErrGroup, errcode.ErrorDescriptor{
+		Value:          "CACHE_DISABLED",
+		Message:        "registry is not a pull through cache",
+		Description:    `Returned when managing the pull through cache of a registry which is not one.`,
+		HTTPStatusCode: http.StatusNotFound,
+	})
+
+	// errorCodePinInvalid is returned when a pin is not a repository name
+	// or a tag.
+	errorCodePinInvalid = errcode.Register(adminErrGroup, errcode.ErrorDescriptor{
+		Value:          "PIN_INVALID",
+		Message:        "invalid pin",
+		Description:    `Returned when a pin is not a repository name or a tag.`,
+		HTTPStatusCode: http.StatusBadRequest,
+	})
+
+	// errorCodePinUnknown is returned when removing a pin which does not
+	// exist.
+	errorCodePinUnknown = errcode.Register(adminErrGroup, errcode.ErrorDescriptor{
+		Value:          "PIN_UNKNOWN",
+		Message:        "pin unknown",
+		Description:    `Returned when removing a pin which does not exist.`,
+		HTTPStatusCode: http.StatusNotFound,
+	})
+
+	// errorCodePinConfigured is returned when removing a pin of the
+	// configuration.
+	errorCodePinConfigured = errcode.Register(adminErrGroup, errcode.ErrorDescriptor{
+		Value:          "PIN_CONFIGURED",
+		Message:        "pin is configured",
+		Description:    `Returned when removing a pin of the configuration.`,
+		HTTPStatusCode: http.StatusConflict,
+	})
+
+	// errorCodeWarmUpInvalid is returned when a warm-up request is invalid.
+	errorCodeWarmUpInvalid = errcode.Register(adminErrGroup, errcode.ErrorDescriptor{
+		Value:   "WARMUP_INVALID",
+		Message: "invalid warm-up request",
+		Description: `Returned when a warm-up request lists a reference which
+		is neither a tag nor a digest of a repository.`,
+		HTTPStatusCode: http.StatusBadRequest,
+	})
+
+	// errorCodeWarmUpRunning is returned when starting the warm-up job
+	// while it runs.
+	errorCodeWarmUpRunning = errcode.Register(adminErrGroup, errcode.ErrorDescriptor{
+		Value:          "WARMUP_RUNNING",
+		Message:        "warm-up is running",
+		Description:    `Returned when starting the warm-up job while it runs.`,
+		HTTPStatusCode: http.StatusConflict,
+	})
+)
+
+// registerAdminCache adds the pull through cache routes to the admin API.
+func (app *App) registerAdminCache(base string) {
+	app.router.Path(base + "/cache/pins").Name(routeNameAdminPins)
+	app.router.Path(base + "/cache/pins/{pin:.+}").Name(routeNameAdminPin)
+	app.router.Path(base + "/cache/warmup").Name(routeNameAdminWarmUp)
+
+	app.register(routeNameAdminPins, cacheDispatcher)
+	app.register(routeNameAdminPin, cacheDispatcher)
+	app.register(routeNameAdminWarmUp, cacheDispatcher)
+}
+
+// cacheDispatcher constructs the pull through cache handlers of the admin
+// API.
+func cacheDispatcher(ctx *Context, r *http.Request) http.Handler {
+	cacheHandler := &cacheHandler{
+		Context: ctx,
+		Pin:     mux.Vars(r)["pin"],
+	}
+
+	switch mux.CurrentRoute(r).GetName() {
+	case routeNameAdminPins:
+		return handlers.MethodHandler{
+			http.MethodGet:  http.HandlerFunc(cacheHandler.ListPins),
+			http.MethodPost: http.HandlerFunc(cacheHandler.AddPin),
+		}
+	case routeNameAdminWarmUp:
+		return handlers.MethodHandler{
+			http.MethodGet:  http.HandlerFunc(cacheHandler.GetWarmUp),
+			http.MethodPost: http.HandlerFunc(cacheHandler.StartWarmUp),
+		}
+	default:
+		return handlers.MethodHandler{
+			http.MethodDelete: http.HandlerFunc(cacheHandler.DeletePin),
+		}
+	}
+}
+
+// cacheHandler manages the content kept by a pull through cache.
+type cacheHandler struct {
+	*Context
+
+	// Pin is the pin in the request, "name" or "name:tag", if any.
+	Pin string
+}
+
+type pinsAPIResponse struct {
+	Pins []proxy.Pin `json:"pins"`
+}
+
+// warmUpAPIRequest lists the references to fetch, instead of the configured
+// ones.
+type warmUpAPIRequest struct {
+	References []string `json:"references"`
+}
+
+// ListPins returns the pins of the configuration, then the others.
+func (ch *cacheHandler) ListPins(w http.ResponseWriter, r *http.Request) {
+	cache := ch.cache()
+	if cache == nil {
+		return
+	}
+	pins, err := cache.Pins(ch)
+	if err != nil {
+		ch.Errors = append(ch.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+	if pins == nil {
+		pins = []proxy.Pin{}
+	}
+	serveAdminJSON(ch, w, http.StatusOK, pinsAPIResponse{Pins: pins})
+}
+
+// AddPin pins a repository or a tag.
+func (ch *cacheHandler) AddPin(w http.ResponseWriter, r *http.Request) {
+	cache := ch.cache()
+	if cache == nil {
+		return
+	}
+
+	var pin proxy.Pin
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&pin); err != nil {
+		ch.Errors = append(ch.Errors, errorCodePinInvalid.WithDetail(err.Error()))
+		return
+	}
+	// Check the pin the way the configured ones are.
+	pin, err := proxy.ParsePin(pin.String())
+	if err != nil {
+		ch.appendPinError(err)
+		return
+	}
+	if err := cache.Pin(ch, pin); err != nil {
+		ch.appendPinError(err)
+		return
+	}
+	dcontext.GetLogger(ch).Infof("pinned %s", pin)
+	serveAdminJSON(ch, w, http.StatusCreated, pin)
+}
+
+// DeletePin removes a pin, letting the content it kept expire.
+func (ch *cacheHandler) DeletePin(w http.ResponseWriter, r *http.Request) {
+	cache := ch.cache()
+	if cache == nil {
+		return
+	}
+	pin, err := proxy.ParsePin(ch.Pin)
+	if err != nil {
+		ch.appendPinError(err)
+		return
+	}
+	if err := cache.Unpin(ch, pin); err != nil {
+		ch.appendPinError(err)
+		return
+	}
+	dcontext.GetLogger(ch).Infof("unpinned %s", pin)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// GetWarmUp returns the status of the last run of the warm-up job.
+func (ch *cacheHandler) GetWarmUp(w http.ResponseWriter, r *http.Request) {
+	cache := ch.cache()
+	if cache == nil {
+		return
+	}
+	serveAdminJSON(ch, w, http.StatusOK, cache.WarmUpStatus())
+}
+
+// StartWarmUp runs the warm-up job in the background, fetching the
+// references of the request, or the configured ones if there are none.
+func (ch *cacheHandler) StartWarmUp(w http.ResponseWriter, r *http.Request) {
+	cache := ch.cache()
+	if cache == nil {
+		return
+	}
+
+	var req warmUpAPIRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil && err != io.EOF {
+		ch.Errors = append(ch.Errors, errorCodeWarmUpInvalid.WithDetail(err.Error()))
+		return
+	}
+	if len(req.References) == 0 {
+		req.References = nil
+	}
+	for _, s := range req.References {
+		ref, err := reference.Parse(s)
+		if err == nil {
+			_, tagged := ref.(reference.Tagged)
+			_, digested := ref.(reference.Digested)
+			if !tagged && !digested {
+				err = errors.New("no tag or digest")
+			}
+		}
+		if err != nil {
+			ch.Errors = append(ch.Errors, errorCodeWarmUpInvalid.WithDetail(map[string]string{"reference": s, "error": err.Error()}))
+			return
+		}
+	}
+
+	status, err := cache.WarmUp(req.References)
+	if err != nil {
+		if errors.Is(err, proxy.ErrWarmUpRunning) {
+			ch.Errors = append(ch.Errors, errorCodeWarmUpRunning.WithDetail(status))
+		} else {
+			ch.Errors = append(ch.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		}
+		return
+	}
+	serveAdminJSON(ch, w, http.StatusAccepted, status)
+}
+
+// cache returns the pull through cache, or nil after recording the error if
+// the registry is not one.
+func (ch *cacheHandler) cache() proxy.Cache {
+	cache, ok := ch.App.registry.(proxy.Cache)
+	if !ok {
+		ch.Errors = append(ch.Errors, errorCodeCacheDisabled)
+		return nil
+	}
+	return cache
+}
+
+func (ch *cacheHandler) appendPinError(err error) {
+	switch {
+	case errors.Is(err, proxy.ErrPinInvalid):
+		ch.Errors = append(ch.Errors, errorCodePinInvalid.WithDetail(err.Error()))
+	case errors.Is(err, proxy.ErrPinUnknown):
+		ch.Errors = append(ch.Errors, errorCodePinUnknown.WithDetail(map[string]string{"pin": ch.Pin}))
+	case errors.Is(err, proxy.ErrPinConfigured):
+		ch.Errors = append(ch.Errors, errorCodePinConfigured.WithDetail(err.Error()))
+	default:
+		ch.Errors = append(ch.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+	}
+}
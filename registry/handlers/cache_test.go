@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/proxy"
+)
+
+func TestAdminCache(t *testing.T) {
+	// The upstream has no content: warming up fails.
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer upstream.Close()
+
+	config := adminTestConfig(t, true)
+	config.Proxy.RemoteURL = upstream.URL
+	config.Proxy.Pins = []string{"library/busybox"}
+	server := httptest.NewServer(NewApp(dcontext.Background(), &config))
+	defer server.Close()
+	pins := server.URL + "/admin/v1/cache/pins"
+	warmUp := server.URL + "/admin/v1/cache/warmup"
+
+	checkStatus(t, adminRequest(t, http.MethodGet, pins, "", nil), http.StatusUnauthorized)
+
+	listPins := func(expected ...proxy.Pin) {
+		t.Helper()
+		resp := adminRequest(t, http.MethodGet, pins, "", asAdmin)
+		checkStatus(t, resp, http.StatusOK)
+		var body pinsAPIResponse
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		if len(body.Pins) != len(expected) {
+			t.Fatalf("unexpected pins: %+v", body.Pins)
+		}
+		for i := range expected {
+			if body.Pins[i] != expected[i] {
+				t.Fatalf("unexpected pins: %+v", body.Pins)
+			}
+		}
+	}
+
+	configured := proxy.Pin{Repository: "library/busybox", Configured: true}
+	alpine := proxy.Pin{Repository: "library/alpine", Tag: "3"}
+	listPins(configured)
+
+	checkStatus(t, adminRequest(t, http.MethodPost, pins, `{"repository": "library/alpine", "tag": "3"}`, asAdmin), http.StatusCreated)
+	checkStatus(t, adminRequest(t, http.MethodPost, pins, `{"repository": "Alpine"}`, asAdmin), http.StatusBadRequest)
+	listPins(configured, alpine)
+
+	checkStatus(t, adminRequest(t, http.MethodDelete, pins+"/library/busybox", "", asAdmin), http.StatusConflict)
+	checkStatus(t, adminRequest(t, http.MethodDelete, pins+"/library/alpine:3", "", asAdmin), http.StatusAccepted)
+	checkStatus(t, adminRequest(t, http.MethodDelete, pins+"/library/alpine:3", "", asAdmin), http.StatusNotFound)
+	listPins(configured)
+
+	checkStatus(t, adminRequest(t, http.MethodPost, warmUp, `{"references": ["library/alpine"]}`, asAdmin), http.StatusBadRequest)
+	checkStatus(t, adminRequest(t, http.MethodPost, warmUp, `{"references": ["library/alpine:3"]}`, asAdmin), http.StatusAccepted)
+
+	var status proxy.WarmUpStatus
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		resp := adminRequest(t, http.MethodGet, warmUp, "", asAdmin)
+		checkStatus(t, resp, http.StatusOK)
+		if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+			t.Fatal(err)
+		}
+		if !status.Running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("warm-up did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if status.References != 1 || len(status.Errors) != 1 {
+		t.Fatalf("unexpected warm-up status: %+v", status)
+	}
+}
+
+func TestAdminCacheDisabled(t *testing.T) {
+	server := newAdminTestServer(t, true)
+
+	checkStatus(t, adminRequest(t, http.MethodGet, server.URL+"/admin/v1/cache/pins", "", asAdmin), http.StatusNotFound)
+	checkStatus(t, adminRequest(t, http.MethodPost, server.URL+"/admin/v1/cache/warmup", "", asAdmin), http.StatusNotFound)
+}
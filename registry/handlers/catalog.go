@@ -1,20 +1,34 @@
 package handlers
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strconv"
 
+	"github.com/distribution/distribution/v3"
 	"github.com/distribution/distribution/v3/registry/api/errcode"
+	"github.com/distribution/distribution/v3/registry/auth"
 	"github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/reference"
 	"github.com/gorilla/handlers"
 )
 
 const defaultReturnedEntries = 100
 
+var errorCodeCatalogQueryInvalid = errcode.Register(extErrGroup, errcode.ErrorDescriptor{
+	Value:   "CATALOG_QUERY_INVALID",
+	Message: "invalid catalog query",
+	Description: `Returned when the continuation token of a catalog listing is
+	invalid or was issued for another prefix, or when the prefix and namespace
+	parameters are both set.`,
+	HTTPStatusCode: http.StatusBadRequest,
+})
+
 func catalogDispatcher(ctx *Context, r *http.Request) http.Handler {
 	catalogHandler := &catalogHandler{
 		Context: ctx,
@@ -33,12 +47,63 @@ type catalogAPIResponse struct {
 	Repositories []string `json:"repositories"`
 }
 
+// catalogToken is the continuation token of a catalog listing, resuming it
+// after the last repository listed. As the repositories are listed in order,
+// the token stays valid however many repositories are created or deleted
+// meanwhile.
+type catalogToken struct {
+	Prefix string `json:"p,omitempty"`
+	Last   string `json:"l"`
+}
+
+func (t catalogToken) String() string {
+	p, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(p)
+}
+
+func parseCatalogToken(s string) (catalogToken, error) {
+	var t catalogToken
+	p, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return t, err
+	}
+	err = json.Unmarshal(p, &t)
+	return t, err
+}
+
+// GetCatalog returns a page of the repositories, optionally those of a
+// prefix or a namespace. The pages of a prefix or a namespace are linked by
+// a continuation token, the other ones by the last repository listed.
 func (ch *catalogHandler) GetCatalog(w http.ResponseWriter, r *http.Request) {
 	moreEntries := true
 
 	q := r.URL.Query()
 	lastEntry := q.Get("last")
 
+	prefix := q.Get("prefix")
+	if namespace := q.Get("namespace"); namespace != "" {
+		if prefix != "" {
+			ch.Errors = append(ch.Errors, errorCodeCatalogQueryInvalid.WithDetail("prefix and namespace are exclusive"))
+			return
+		}
+		if _, err := reference.WithName(namespace); err != nil {
+			ch.Errors = append(ch.Errors, errcode.ErrorCodeNameInvalid.WithDetail(map[string]string{"namespace": namespace}))
+			return
+		}
+		prefix = namespace + "/"
+	}
+
+	useToken := prefix != ""
+	if t := q.Get("token"); t != "" {
+		token, err := parseCatalogToken(t)
+		if err != nil || token.Prefix != prefix || token.Last == "" {
+			ch.Errors = append(ch.Errors, errorCodeCatalogQueryInvalid.WithDetail(map[string]string{"token": t}))
+			return
+		}
+		lastEntry = token.Last
+		useToken = true
+	}
+
 	entries := defaultReturnedEntries
 	maximumConfiguredEntries := ch.App.Config.Catalog.MaxEntries
 
@@ -64,37 +129,83 @@ func (ch *catalogHandler) GetCatalog(w http.ResponseWriter, r *http.Request) {
 		entries = maximumConfiguredEntries
 	}
 
-	repos := make([]string, entries)
-	filled := 0
+	repos := make([]string, 0, entries)
 
 	// entries is guaranteed to be >= 0 and < maximumConfiguredEntries
 	if entries == 0 {
 		moreEntries = false
 	} else {
-		returnedRepositories, err := ch.App.registry.Repositories(ch.Context, repos, lastEntry)
-		if err != nil {
-			_, pathNotFound := err.(driver.PathNotFoundError)
-			if err != io.EOF && !pathNotFound {
-				ch.Errors = append(ch.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
-				return
+		filter, grant := ch.catalogFilter()
+		batch := make([]string, entries)
+		last := lastEntry
+
+		// With a filter, batches are read until the page is full, so that
+		// pages stay full however many repositories are hidden.
+		for len(repos) < entries && moreEntries {
+			returnedRepositories, err := ch.repositories(batch, prefix, last)
+			if err != nil {
+				_, pathNotFound := err.(driver.PathNotFoundError)
+				if err != io.EOF && !pathNotFound {
+					var nameInvalid distribution.ErrRepositoryNameInvalid
+					switch {
+					case errors.As(err, &nameInvalid):
+						ch.Errors = append(ch.Errors, errcode.ErrorCodeNameInvalid.WithDetail(map[string]string{"prefix": nameInvalid.Name}))
+					case errors.Is(err, distribution.ErrUnsupported):
+						ch.Errors = append(ch.Errors, errcode.ErrorCodeUnsupported.WithDetail("the catalog cannot be listed by prefix"))
+					default:
+						ch.Errors = append(ch.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+					}
+					return
+				}
+				// err is either io.EOF or not PathNotFoundError
+				moreEntries = false
+			}
+			if returnedRepositories == 0 {
+				moreEntries = false
+				break
+			}
+			returned := batch[:returnedRepositories]
+			last = returned[len(returned)-1]
+
+			visible := returned
+			if filter != nil {
+				visible, err = filter.FilterCatalog(ch.Context, grant, returned)
+				if err != nil {
+					ch.Errors = append(ch.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+					return
+				}
 			}
-			// err is either io.EOF or not PathNotFoundError
-			moreEntries = false
+
+			if len(repos)+len(visible) > entries {
+				// The next page resumes after the last repository
+				// listed, not after the last one read.
+				visible = visible[:entries-len(repos)]
+				moreEntries = true
+			}
+			repos = append(repos, visible...)
 		}
-		filled = returnedRepositories
 	}
+	filled := len(repos)
 
 	w.Header().Set("Content-Type", "application/json")
 
 	// Add a link header if there are more entries to retrieve
 	if moreEntries {
 		lastEntry = repos[filled-1]
-		urlStr, err := createLinkEntry(r.URL.String(), entries, lastEntry)
-		if err != nil {
-			ch.Errors = append(ch.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
-			return
+		if useToken {
+			w.Header().Set("Link", createQueryLinkEntry(r.URL, url.Values{
+				"n":     []string{strconv.Itoa(entries)},
+				"token": []string{catalogToken{Prefix: prefix, Last: lastEntry}.String()},
+				"last":  nil,
+			}))
+		} else {
+			urlStr, err := createLinkEntry(r.URL.String(), entries, lastEntry)
+			if err != nil {
+				ch.Errors = append(ch.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+				return
+			}
+			w.Header().Set("Link", urlStr)
 		}
-		w.Header().Set("Link", urlStr)
 	}
 
 	enc := json.NewEncoder(w)
@@ -106,6 +217,33 @@ func (ch *catalogHandler) GetCatalog(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// repositories fills repos with the repositories following last whose name
+// starts with prefix.
+func (ch *catalogHandler) repositories(repos []string, prefix, last string) (int, error) {
+	if prefix == "" {
+		return ch.App.registry.Repositories(ch.Context, repos, last)
+	}
+	lister, ok := ch.App.registry.(distribution.RepositoryPrefixLister)
+	if !ok {
+		return 0, distribution.ErrUnsupported
+	}
+	return lister.RepositoriesWithPrefix(ch.Context, repos, prefix, last)
+}
+
+// catalogFilter returns the catalog filter of the access controller and the
+// grant of the request, or a nil filter if the catalog is not filtered.
+func (ch *catalogHandler) catalogFilter() (auth.CatalogFilter, *auth.Grant) {
+	filter, ok := ch.App.accessController.(auth.CatalogFilter)
+	if !ok {
+		return nil, nil
+	}
+	grant := authorizedGrant(ch.Context)
+	if grant == nil {
+		return nil, nil
+	}
+	return filter, grant
+}
+
 // Use the original URL from the request to create a new URL for
 // the link header
 func createLinkEntry(origURL string, maxEntries int, lastEntry string) (string, error) {
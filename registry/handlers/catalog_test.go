@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/distribution/distribution/v3/configuration"
+	_ "github.com/distribution/distribution/v3/registry/auth/htpasswd"
+	_ "github.com/distribution/distribution/v3/registry/storage/driver/filesystem"
+)
+
+const catalogTestACL = `
+rules:
+  - users: [admin]
+    repositories: ["**"]
+    actions: ["*"]
+    catalog: true
+  - users: [alice]
+    repositories: ["team-a/**"]
+    actions: [pull]
+    catalog: true
+`
+
+// TestCatalogAPIFiltered checks that the catalog only lists the repositories
+// the caller may pull, and that pages stay full when repositories are
+// hidden.
+func TestCatalogAPIFiltered(t *testing.T) {
+	dir := t.TempDir()
+	storage := configuration.Storage{
+		"filesystem": configuration.Parameters{"rootdirectory": filepath.Join(dir, "storage")},
+		"maintenance": configuration.Parameters{"uploadpurging": map[interface{}]interface{}{
+			"enabled": false,
+		}},
+	}
+
+	// Populate the storage through a registry without authentication.
+	config := configuration.Configuration{Storage: storage}
+	config.HTTP.Headers = headerConfig
+	env := newTestEnvWithConfig(t, &config)
+	allCatalog := []string{"aaa/1", "aaa/2", "aaa/3", "team-a/1", "team-a/2", "team-a/3", "zzz/1"}
+	for _, image := range allCatalog {
+		createRepository(env, t, image, "sometag")
+	}
+	env.Shutdown()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	htpasswdPath := filepath.Join(dir, "htpasswd")
+	if err := os.WriteFile(htpasswdPath, []byte("admin:"+string(hash)+"\nalice:"+string(hash)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	aclPath := filepath.Join(dir, "acl.yml")
+	if err := os.WriteFile(aclPath, []byte(catalogTestACL), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	config = configuration.Configuration{
+		Storage: storage,
+		Auth: configuration.Auth{
+			"htpasswd": configuration.Parameters{
+				"realm": "test",
+				"path":  htpasswdPath,
+				"acl":   aclPath,
+			},
+		},
+		Catalog: configuration.Catalog{MaxEntries: 100},
+	}
+	config.HTTP.Headers = headerConfig
+	env = newTestEnvWithConfig(t, &config)
+	defer env.Shutdown()
+
+	getCatalog := func(user string, values url.Values) ([]string, string) {
+		catalogURL, err := env.builder.BuildCatalogURL(values)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, err := http.NewRequest(http.MethodGet, catalogURL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.SetBasicAuth(user, "password")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		checkResponse(t, "issuing catalog api check", resp, http.StatusOK)
+
+		var ctlg catalogAPIResponse
+		if err := json.NewDecoder(resp.Body).Decode(&ctlg); err != nil {
+			t.Fatal(err)
+		}
+		return ctlg.Repositories, resp.Header.Get("Link")
+	}
+
+	repos, link := getCatalog("admin", nil)
+	if !reflect.DeepEqual(repos, allCatalog) || link != "" {
+		t.Fatalf("expected the whole catalog without a link, got %v (link %q)", repos, link)
+	}
+
+	// Every page is full although the visible repositories are spread over
+	// several batches read from storage.
+	repos, link = getCatalog("alice", url.Values{"n": []string{"2"}})
+	if expected := []string{"team-a/1", "team-a/2"}; !reflect.DeepEqual(repos, expected) {
+		t.Fatalf("expected %v, got %v", expected, repos)
+	}
+	values := checkLink(t, link, 2, "team-a/2")
+
+	repos, link = getCatalog("alice", values)
+	if expected := []string{"team-a/3"}; !reflect.DeepEqual(repos, expected) {
+		t.Fatalf("expected %v, got %v", expected, repos)
+	}
+	if link != "" {
+		t.Fatalf("unexpected link on the last page: %q", link)
+	}
+}
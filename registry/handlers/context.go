@@ -151,6 +151,20 @@ func (rc resourceContext) Value(key interface{}) interface{} {
 	return rc.Context.Value(key)
 }
 
+type grantKey struct{}
+
+// withGrant returns a context with the grant of the request.
+func withGrant(ctx context.Context, grant *auth.Grant) context.Context {
+	return context.WithValue(ctx, grantKey{}, grant)
+}
+
+// authorizedGrant returns the grant of the request, or nil if the request
+// was not authorized by an access controller.
+func authorizedGrant(ctx context.Context) *auth.Grant {
+	grant, _ := ctx.Value(grantKey{}).(*auth.Grant)
+	return grant
+}
+
 // authorizedResources returns the list of resources which have
 // been authorized for this request.
 func authorizedResources(ctx context.Context) []auth.Resource {
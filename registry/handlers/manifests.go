@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"mime"
 	"net/http"
@@ -68,6 +69,26 @@ func manifestDispatcher(ctx *Context, r *http.Request) http.Handler {
 	return mhandler
 }
 
+// manifestCopyDispatcher builds the handler of manifest copies.
+func manifestCopyDispatcher(ctx *Context, r *http.Request) http.Handler {
+	manifestHandler := &manifestHandler{
+		Context: ctx,
+	}
+	ref := getReference(ctx)
+	dgst, err := digest.Parse(ref)
+	if err != nil {
+		manifestHandler.Tag = ref
+	} else {
+		manifestHandler.Digest = dgst
+	}
+
+	mhandler := handlers.MethodHandler{}
+	if !ctx.readOnly {
+		mhandler[http.MethodPost] = http.HandlerFunc(manifestHandler.CopyManifest)
+	}
+	return mhandler
+}
+
 // manifestHandler handles http operations on image manifests.
 type manifestHandler struct {
 	*Context
@@ -121,6 +142,8 @@ func (imh *manifestHandler) GetManifest(w http.ResponseWriter, r *http.Request)
 		if err != nil {
 			if _, ok := err.(distribution.ErrTagUnknown); ok {
 				imh.Errors = append(imh.Errors, errcode.ErrorCodeManifestUnknown.WithDetail(err))
+			} else if ec, ok := tooManyRequests(err); ok {
+				imh.Errors = append(imh.Errors, ec)
 			} else {
 				imh.Errors = append(imh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
 			}
@@ -142,6 +165,8 @@ func (imh *manifestHandler) GetManifest(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		if _, ok := err.(distribution.ErrManifestUnknownRevision); ok {
 			imh.Errors = append(imh.Errors, errcode.ErrorCodeManifestUnknown.WithDetail(err))
+		} else if ec, ok := tooManyRequests(err); ok {
+			imh.Errors = append(imh.Errors, ec)
 		} else {
 			imh.Errors = append(imh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
 		}
@@ -192,6 +217,8 @@ func (imh *manifestHandler) GetManifest(w http.ResponseWriter, r *http.Request)
 		if err != nil {
 			if _, ok := err.(distribution.ErrManifestUnknownRevision); ok {
 				imh.Errors = append(imh.Errors, errcode.ErrorCodeManifestUnknown.WithDetail(err))
+			} else if ec, ok := tooManyRequests(err); ok {
+				imh.Errors = append(imh.Errors, ec)
 			} else {
 				imh.Errors = append(imh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
 			}
@@ -359,6 +386,146 @@ func (imh *manifestHandler) PutManifest(w http.ResponseWriter, r *http.Request)
 	dcontext.GetLogger(imh).Debug("Succeeded in putting manifest!")
 }
 
+// CopyManifest links the manifest ref of the repository named by the from
+// parameter, with the manifests and blobs it references, into the
+// repository, tagging it if the reference of the request is a tag.
+func (imh *manifestHandler) CopyManifest(w http.ResponseWriter, r *http.Request) {
+	dcontext.GetLogger(imh).Debug("CopyManifest")
+
+	if imh.App.isCache {
+		imh.Errors = append(imh.Errors, errcode.ErrorCodeUnsupported)
+		return
+	}
+	manifests, err := imh.Repository.Manifests(imh)
+	if err != nil {
+		imh.Errors = append(imh.Errors, err)
+		return
+	}
+	copier, ok := manifests.(distribution.ManifestCopier)
+	if !ok {
+		imh.Errors = append(imh.Errors, errcode.ErrorCodeUnsupported)
+		return
+	}
+
+	fromName := r.FormValue("from")
+	from, err := reference.WithName(fromName)
+	if err != nil {
+		imh.Errors = append(imh.Errors, errcode.ErrorCodeNameInvalid.WithDetail(map[string]string{"from": fromName}))
+		return
+	}
+	source, err := imh.App.registry.Repository(imh, from)
+	if err != nil {
+		imh.Errors = append(imh.Errors, errcode.ErrorCodeNameInvalid.WithDetail(err))
+		return
+	}
+
+	ref := r.FormValue("ref")
+	if ref == "" {
+		ref = getReference(imh)
+	}
+	dgst, err := digest.Parse(ref)
+	if err != nil {
+		if _, err := reference.WithTag(from, ref); err != nil {
+			imh.Errors = append(imh.Errors, errcode.ErrorCodeTagInvalid.WithDetail(map[string]string{"ref": ref}))
+			return
+		}
+		desc, err := source.Tags(imh).Get(imh, ref)
+		if err != nil {
+			if _, ok := err.(distribution.ErrTagUnknown); ok {
+				imh.Errors = append(imh.Errors, errcode.ErrorCodeManifestUnknown.WithDetail(err))
+			} else {
+				imh.Errors = append(imh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+			}
+			return
+		}
+		dgst = desc.Digest
+	}
+	if imh.Digest != "" && imh.Digest != dgst {
+		dcontext.GetLogger(imh).Errorf("copied manifest digest does not match: %q != %q", dgst, imh.Digest)
+		imh.Errors = append(imh.Errors, errcode.ErrorCodeDigestInvalid)
+		return
+	}
+	imh.Digest = dgst
+
+	sourceManifests, err := source.Manifests(imh)
+	if err != nil {
+		imh.Errors = append(imh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+	manifest, err := sourceManifests.Get(imh, dgst)
+	if err != nil {
+		if _, ok := err.(distribution.ErrManifestUnknownRevision); ok {
+			imh.Errors = append(imh.Errors, errcode.ErrorCodeManifestUnknown.WithDetail(err))
+		} else {
+			imh.Errors = append(imh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		}
+		return
+	}
+	if err := imh.applyResourcePolicy(manifest); err != nil {
+		imh.Errors = append(imh.Errors, err)
+		return
+	}
+
+	var options []distribution.ManifestServiceOption
+	if imh.Tag != "" {
+		options = append(options, distribution.WithTag(imh.Tag))
+	}
+
+	if _, err := copier.Copy(imh, from, dgst, options...); err != nil {
+		if err == distribution.ErrUnsupported {
+			imh.Errors = append(imh.Errors, errcode.ErrorCodeUnsupported)
+			return
+		}
+		switch err := err.(type) {
+		case distribution.ErrManifestUnknownRevision:
+			imh.Errors = append(imh.Errors, errcode.ErrorCodeManifestUnknown.WithDetail(err))
+		case distribution.ErrManifestVerification:
+			for _, verificationError := range err {
+				if blobErr, ok := verificationError.(distribution.ErrManifestBlobUnknown); ok {
+					imh.Errors = append(imh.Errors, errcode.ErrorCodeManifestBlobUnknown.WithDetail(blobErr.Digest))
+				} else {
+					imh.Errors = append(imh.Errors, errcode.ErrorCodeUnknown.WithDetail(verificationError))
+				}
+			}
+		default:
+			imh.Errors = append(imh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		}
+		return
+	}
+
+	if imh.Tag != "" {
+		mediaType, payload, err := manifest.Payload()
+		if err != nil {
+			imh.Errors = append(imh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+			return
+		}
+		desc := v1.Descriptor{
+			MediaType: mediaType,
+			Digest:    dgst,
+			Size:      int64(len(payload)),
+		}
+		if err := imh.Repository.Tags(imh).Tag(imh, imh.Tag, desc); err != nil {
+			imh.Errors = append(imh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+			return
+		}
+	}
+
+	canonical, err := reference.WithDigest(imh.Repository.Named(), dgst)
+	if err != nil {
+		imh.Errors = append(imh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+	location, err := imh.urlBuilder.BuildManifestURL(canonical)
+	if err != nil {
+		dcontext.GetLogger(imh).Errorf("error building manifest url from digest: %v", err)
+	}
+
+	w.Header().Set("Location", location)
+	w.Header().Set("Content-Length", "0")
+	w.Header().Set("Docker-Content-Digest", dgst.String())
+	w.WriteHeader(http.StatusCreated)
+}
+
 // applyResourcePolicy checks whether the resource class matches what has
 // been authorized and allowed by the policy configuration.
 func (imh *manifestHandler) applyResourcePolicy(manifest distribution.Manifest) error {
@@ -507,3 +674,23 @@ func (imh *manifestHandler) DeleteManifest(w http.ResponseWriter, r *http.Reques
 
 	w.WriteHeader(http.StatusAccepted)
 }
+
+// tooManyRequests returns the error reporting the rate limit of an upstream
+// registry being reached, which is passed on to the client of a pull through
+// cache. Upstream registries report it among the errors of their response.
+func tooManyRequests(err error) (errcode.Error, bool) {
+	var errs errcode.Errors
+	if errors.As(err, &errs) {
+		for _, err := range errs {
+			if ec, ok := tooManyRequests(err); ok {
+				return ec, true
+			}
+		}
+		return errcode.Error{}, false
+	}
+	var ec errcode.Error
+	if errors.As(err, &ec) && ec.Code == errcode.ErrorCodeTooManyRequests {
+		return ec, true
+	}
+	return errcode.Error{}, false
+}
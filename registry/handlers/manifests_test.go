@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/distribution/distribution/v3/registry/api/errcode"
+)
+
+func TestTooManyRequests(t *testing.T) {
+	for _, tc := range []struct {
+		err      error
+		expected bool
+	}{
+		{err: errcode.ErrorCodeTooManyRequests.WithMessage("slow down"), expected: true},
+		{err: errcode.Errors{errcode.ErrorCodeTooManyRequests.WithMessage("slow down")}, expected: true},
+		{err: errcode.Errors{errcode.ErrorCodeUnauthorized, errcode.ErrorCodeTooManyRequests.WithMessage("slow down")}, expected: true},
+		{err: fmt.Errorf("fetching manifest: %w", errcode.Errors{errcode.ErrorCodeTooManyRequests.WithMessage("slow down")}), expected: true},
+		{err: errcode.Errors{errcode.ErrorCodeUnknown}, expected: false},
+		{err: errcode.ErrorCodeDenied.WithMessage("denied"), expected: false},
+		{err: fmt.Errorf("unexpected"), expected: false},
+	} {
+		ec, ok := tooManyRequests(tc.err)
+		if ok != tc.expected {
+			t.Errorf("%v: expected %v, got %v", tc.err, tc.expected, ok)
+			continue
+		}
+		if ok && (ec.Code != errcode.ErrorCodeTooManyRequests || ec.Message != "slow down") {
+			t.Errorf("%v: unexpected error %v", tc.err, ec)
+		}
+	}
+}
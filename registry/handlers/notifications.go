@@ -0,0 +1,454 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/notifications"
+	"github.com/distribution/distribution/v3/registry/api/errcode"
+	"github.com/distribution/reference"
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+)
+
+const (
+	routeNameAdminEndpoints      = adminRoutePrefix + "endpoints"
+	routeNameAdminEndpoint       = adminRoutePrefix + "endpoint"
+	routeNameAdminEndpointPause  = adminRoutePrefix + "endpoint-pause"
+	routeNameAdminEndpointResume = adminRoutePrefix + "endpoint-resume"
+	routeNameAdminEndpointReplay = adminRoutePrefix + "endpoint-replay"
+	routeNameAdminDeadLetters    = adminRoutePrefix + "deadletters"
+	routeNameAdminDeadLetter     = adminRoutePrefix + "deadletter"
+)
+
+var (
+	// errorCodeEndpointUnknown is returned when the named notification
+	// endpoint is not configured.
+	errorCodeEndpointUnknown = errcode.Register(adminErrGroup, errcode.ErrorDescriptor{
+		Value:          "ENDPOINT_UNKNOWN",
+		Message:        "notification endpoint unknown",
+		Description:    `Returned when the named notification endpoint is not configured.`,
+		HTTPStatusCode: http.StatusNotFound,
+	})
+
+	// errorCodeJournalDisabled is returned when replaying events without
+	// a journal.
+	errorCodeJournalDisabled = errcode.Register(adminErrGroup, errcode.ErrorDescriptor{
+		Value:   "JOURNAL_DISABLED",
+		Message: "events are not journaled",
+		Description: `Returned when replaying events from the journal while
+		notifications have no journal configuration.`,
+		HTTPStatusCode: http.StatusNotFound,
+	})
+
+	// errorCodeReplayInvalid is returned when a replay request is invalid.
+	errorCodeReplayInvalid = errcode.Register(adminErrGroup, errcode.ErrorDescriptor{
+		Value:   "REPLAY_INVALID",
+		Message: "invalid replay request",
+		Description: `Returned when a replay request lacks a valid repository
+		or time range.`,
+		HTTPStatusCode: http.StatusBadRequest,
+	})
+
+	// errorCodeDeadLettersDisabled is returned when the named endpoint
+	// does not keep dead letters.
+	errorCodeDeadLettersDisabled = errcode.Register(adminErrGroup, errcode.ErrorDescriptor{
+		Value:   "DEAD_LETTERS_DISABLED",
+		Message: "dead letters are not kept for this endpoint",
+		Description: `Returned when the named notification endpoint has no
+		deadletter configuration.`,
+		HTTPStatusCode: http.StatusNotFound,
+	})
+
+	// errorCodeDeadLetterUnknown is returned when the dead letter does not
+	// exist.
+	errorCodeDeadLetterUnknown = errcode.Register(adminErrGroup, errcode.ErrorDescriptor{
+		Value:          "DEAD_LETTER_UNKNOWN",
+		Message:        "dead letter unknown",
+		Description:    `Returned when the dead letter of an event does not exist.`,
+		HTTPStatusCode: http.StatusNotFound,
+	})
+)
+
+// registerAdminNotifications adds the notification routes of the admin API
+// under base.
+func (app *App) registerAdminNotifications(base string) {
+	app.router.Path(base + "/notifications").Name(routeNameAdminEndpoints)
+	app.router.Path(base + "/notifications/{endpoint}").Name(routeNameAdminEndpoint)
+	app.router.Path(base + "/notifications/{endpoint}/pause").Name(routeNameAdminEndpointPause)
+	app.router.Path(base + "/notifications/{endpoint}/resume").Name(routeNameAdminEndpointResume)
+	app.router.Path(base + "/notifications/{endpoint}/replay").Name(routeNameAdminEndpointReplay)
+	app.router.Path(base + "/notifications/{endpoint}/deadletters").Name(routeNameAdminDeadLetters)
+	app.router.Path(base + "/notifications/{endpoint}/deadletters/{id:[A-Za-z0-9_-]+}").Name(routeNameAdminDeadLetter)
+
+	app.register(routeNameAdminEndpoints, endpointsDispatcher)
+	app.register(routeNameAdminEndpoint, endpointsDispatcher)
+	app.register(routeNameAdminEndpointPause, endpointsDispatcher)
+	app.register(routeNameAdminEndpointResume, endpointsDispatcher)
+	app.register(routeNameAdminEndpointReplay, endpointsDispatcher)
+	app.register(routeNameAdminDeadLetters, deadLettersDispatcher)
+	app.register(routeNameAdminDeadLetter, deadLettersDispatcher)
+}
+
+// endpointsDispatcher constructs the notification endpoint handlers of the
+// admin API.
+func endpointsDispatcher(ctx *Context, r *http.Request) http.Handler {
+	endpointsHandler := &endpointsHandler{
+		Context:  ctx,
+		Endpoint: mux.Vars(r)["endpoint"],
+	}
+
+	switch mux.CurrentRoute(r).GetName() {
+	case routeNameAdminEndpoints:
+		return handlers.MethodHandler{
+			http.MethodGet: http.HandlerFunc(endpointsHandler.ListEndpoints),
+		}
+	case routeNameAdminEndpointPause:
+		return handlers.MethodHandler{
+			http.MethodPost: http.HandlerFunc(endpointsHandler.PauseEndpoint),
+		}
+	case routeNameAdminEndpointResume:
+		return handlers.MethodHandler{
+			http.MethodPost: http.HandlerFunc(endpointsHandler.ResumeEndpoint),
+		}
+	case routeNameAdminEndpointReplay:
+		return handlers.MethodHandler{
+			http.MethodPost: http.HandlerFunc(endpointsHandler.Replay),
+		}
+	default:
+		return handlers.MethodHandler{
+			http.MethodGet: http.HandlerFunc(endpointsHandler.GetEndpoint),
+		}
+	}
+}
+
+// endpointsHandler reports on and controls notification endpoints.
+type endpointsHandler struct {
+	*Context
+
+	// Endpoint is the name of the notification endpoint in the request, if
+	// any.
+	Endpoint string
+}
+
+// endpointStatus describes the state of a notification endpoint.
+type endpointStatus struct {
+	Name          string     `json:"name"`
+	URL           string     `json:"url"`
+	Paused        bool       `json:"paused"`
+	Circuit       string     `json:"circuit"`
+	Pending       int        `json:"pending"`
+	Events        int        `json:"events"`
+	Successes     int        `json:"successes"`
+	Failures      int        `json:"failures"`
+	Errors        int        `json:"errors"`
+	LastError     string     `json:"lasterror,omitempty"`
+	LastErrorTime *time.Time `json:"lasterrortime,omitempty"`
+	LastSuccess   *time.Time `json:"lastsuccess,omitempty"`
+}
+
+type endpointsAPIResponse struct {
+	Endpoints []endpointStatus `json:"endpoints"`
+}
+
+// replayAPIRequest selects the journaled events of a repository to replay.
+// To defaults to the time of the request.
+type replayAPIRequest struct {
+	Repository string    `json:"repository"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+}
+
+// ListEndpoints returns the status of every enabled endpoint, in the order
+// of the configuration.
+func (eh *endpointsHandler) ListEndpoints(w http.ResponseWriter, r *http.Request) {
+	statuses := []endpointStatus{}
+	for _, config := range eh.App.Config.Notifications.Endpoints {
+		if endpoint, ok := eh.App.events.endpoints[config.Name]; ok {
+			statuses = append(statuses, newEndpointStatus(endpoint))
+		}
+	}
+	serveAdminJSON(eh, w, http.StatusOK, endpointsAPIResponse{Endpoints: statuses})
+}
+
+// GetEndpoint returns the status of the endpoint.
+func (eh *endpointsHandler) GetEndpoint(w http.ResponseWriter, r *http.Request) {
+	endpoint := eh.endpoint()
+	if endpoint == nil {
+		return
+	}
+	serveAdminJSON(eh, w, http.StatusOK, newEndpointStatus(endpoint))
+}
+
+// PauseEndpoint stops delivering events to the endpoint, queueing them
+// until it is resumed.
+func (eh *endpointsHandler) PauseEndpoint(w http.ResponseWriter, r *http.Request) {
+	endpoint := eh.endpoint()
+	if endpoint == nil {
+		return
+	}
+	endpoint.Pause()
+	dcontext.GetLogger(eh).Infof("notification endpoint %s paused", endpoint.Name())
+	serveAdminJSON(eh, w, http.StatusOK, newEndpointStatus(endpoint))
+}
+
+// ResumeEndpoint restarts the delivery of events to a paused endpoint.
+func (eh *endpointsHandler) ResumeEndpoint(w http.ResponseWriter, r *http.Request) {
+	endpoint := eh.endpoint()
+	if endpoint == nil {
+		return
+	}
+	endpoint.Resume()
+	dcontext.GetLogger(eh).Infof("notification endpoint %s resumed", endpoint.Name())
+	serveAdminJSON(eh, w, http.StatusOK, newEndpointStatus(endpoint))
+}
+
+// Replay queues the journaled events of a repository over a time range on
+// the endpoint. Events are subject to the filters of the endpoint.
+func (eh *endpointsHandler) Replay(w http.ResponseWriter, r *http.Request) {
+	endpoint := eh.endpoint()
+	if endpoint == nil {
+		return
+	}
+	journal := eh.App.events.journal
+	if journal == nil {
+		eh.Errors = append(eh.Errors, errorCodeJournalDisabled)
+		return
+	}
+
+	var req replayAPIRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		eh.Errors = append(eh.Errors, errorCodeReplayInvalid.WithDetail(err.Error()))
+		return
+	}
+	if req.To.IsZero() {
+		req.To = time.Now()
+	}
+	if _, err := reference.WithName(req.Repository); err != nil {
+		eh.Errors = append(eh.Errors, errorCodeReplayInvalid.WithDetail(err.Error()))
+		return
+	}
+	if req.From.IsZero() || !req.From.Before(req.To) {
+		eh.Errors = append(eh.Errors, errorCodeReplayInvalid.WithDetail("from must be set and before to"))
+		return
+	}
+	if req.To.Sub(req.From) > notifications.MaxReplayRange {
+		eh.Errors = append(eh.Errors, errorCodeReplayInvalid.WithDetail(notifications.ErrReplayRange.Error()))
+		return
+	}
+
+	found, err := journal.Events(eh, req.Repository, req.From, req.To)
+	if err != nil {
+		eh.Errors = append(eh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+	for _, event := range found {
+		if err := endpoint.Write(event); err != nil {
+			eh.Errors = append(eh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+			return
+		}
+	}
+	serveAdminJSON(eh, w, http.StatusAccepted, replayAPIResponse{Replayed: len(found)})
+}
+
+// endpoint returns the endpoint of the request, or nil after recording the
+// error if there is none.
+func (eh *endpointsHandler) endpoint() *notifications.Endpoint {
+	endpoint, ok := eh.App.events.endpoints[eh.Endpoint]
+	if !ok {
+		eh.Errors = append(eh.Errors, errorCodeEndpointUnknown.WithDetail(map[string]string{"name": eh.Endpoint}))
+		return nil
+	}
+	return endpoint
+}
+
+func newEndpointStatus(endpoint *notifications.Endpoint) endpointStatus {
+	var metrics notifications.EndpointMetrics
+	endpoint.ReadMetrics(&metrics)
+
+	status := endpointStatus{
+		Name:      endpoint.Name(),
+		URL:       endpoint.URL(),
+		Paused:    endpoint.Paused(),
+		Circuit:   "closed",
+		Pending:   metrics.Pending,
+		Events:    metrics.Events,
+		Successes: metrics.Successes,
+		Failures:  metrics.Failures,
+		Errors:    metrics.Errors,
+		LastError: metrics.LastError,
+	}
+	if endpoint.CircuitOpen() {
+		status.Circuit = "open"
+	}
+	if !metrics.LastErrorTime.IsZero() {
+		status.LastErrorTime = &metrics.LastErrorTime
+	}
+	if !metrics.LastSuccess.IsZero() {
+		status.LastSuccess = &metrics.LastSuccess
+	}
+	return status
+}
+
+// deadLettersDispatcher constructs the dead-letter handlers of the admin
+// API.
+func deadLettersDispatcher(ctx *Context, r *http.Request) http.Handler {
+	deadLettersHandler := &deadLettersHandler{
+		Context:  ctx,
+		Endpoint: mux.Vars(r)["endpoint"],
+		ID:       mux.Vars(r)["id"],
+	}
+
+	if mux.CurrentRoute(r).GetName() == routeNameAdminDeadLetters {
+		return handlers.MethodHandler{
+			http.MethodGet:  http.HandlerFunc(deadLettersHandler.ListDeadLetters),
+			http.MethodPost: http.HandlerFunc(deadLettersHandler.ReplayDeadLetters),
+		}
+	}
+	return handlers.MethodHandler{
+		http.MethodGet:    http.HandlerFunc(deadLettersHandler.GetDeadLetter),
+		http.MethodPost:   http.HandlerFunc(deadLettersHandler.ReplayDeadLetter),
+		http.MethodDelete: http.HandlerFunc(deadLettersHandler.DeleteDeadLetter),
+	}
+}
+
+// deadLettersHandler lists and replays the events a notification endpoint
+// gave up on.
+type deadLettersHandler struct {
+	*Context
+
+	// Endpoint is the name of the notification endpoint in the request.
+	Endpoint string
+
+	// ID is the event id in the request, if any.
+	ID string
+}
+
+type deadLettersAPIResponse struct {
+	DeadLetters []notifications.DeadLetter `json:"deadletters"`
+}
+
+type replayAPIResponse struct {
+	Replayed int `json:"replayed"`
+}
+
+// ListDeadLetters returns the dead letters of the endpoint, oldest first.
+func (dh *deadLettersHandler) ListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	_, store := dh.endpoint()
+	if store == nil {
+		return
+	}
+	dls, err := store.List(dh, dh.Endpoint)
+	if err != nil {
+		dh.appendDeadLetterError(err)
+		return
+	}
+	serveAdminJSON(dh, w, http.StatusOK, deadLettersAPIResponse{DeadLetters: dls})
+}
+
+// ReplayDeadLetters queues every dead letter of the endpoint for delivery
+// again.
+func (dh *deadLettersHandler) ReplayDeadLetters(w http.ResponseWriter, r *http.Request) {
+	endpoint, store := dh.endpoint()
+	if store == nil {
+		return
+	}
+	dls, err := store.List(dh, dh.Endpoint)
+	if err != nil {
+		dh.appendDeadLetterError(err)
+		return
+	}
+
+	replayed := 0
+	for _, dl := range dls {
+		if err := dh.replay(endpoint, store, dl); err != nil {
+			dh.appendDeadLetterError(err)
+			return
+		}
+		replayed++
+	}
+	serveAdminJSON(dh, w, http.StatusAccepted, replayAPIResponse{Replayed: replayed})
+}
+
+// GetDeadLetter returns a single dead letter.
+func (dh *deadLettersHandler) GetDeadLetter(w http.ResponseWriter, r *http.Request) {
+	_, store := dh.endpoint()
+	if store == nil {
+		return
+	}
+	dl, err := store.Get(dh, dh.Endpoint, dh.ID)
+	if err != nil {
+		dh.appendDeadLetterError(err)
+		return
+	}
+	serveAdminJSON(dh, w, http.StatusOK, dl)
+}
+
+// ReplayDeadLetter queues a single dead letter for delivery again.
+func (dh *deadLettersHandler) ReplayDeadLetter(w http.ResponseWriter, r *http.Request) {
+	endpoint, store := dh.endpoint()
+	if store == nil {
+		return
+	}
+	dl, err := store.Get(dh, dh.Endpoint, dh.ID)
+	if err == nil {
+		err = dh.replay(endpoint, store, *dl)
+	}
+	if err != nil {
+		dh.appendDeadLetterError(err)
+		return
+	}
+	serveAdminJSON(dh, w, http.StatusAccepted, replayAPIResponse{Replayed: 1})
+}
+
+// DeleteDeadLetter discards a dead letter without delivering it.
+func (dh *deadLettersHandler) DeleteDeadLetter(w http.ResponseWriter, r *http.Request) {
+	_, store := dh.endpoint()
+	if store == nil {
+		return
+	}
+	if err := store.Delete(dh, dh.Endpoint, dh.ID); err != nil {
+		dh.appendDeadLetterError(err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// endpoint returns the endpoint of the request and its dead-letter store,
+// or a nil store after recording the error if there is none.
+func (dh *deadLettersHandler) endpoint() (*notifications.Endpoint, *notifications.DeadLetterStore) {
+	endpoint, ok := dh.App.events.endpoints[dh.Endpoint]
+	if !ok {
+		dh.Errors = append(dh.Errors, errorCodeEndpointUnknown.WithDetail(map[string]string{"name": dh.Endpoint}))
+		return nil, nil
+	}
+	if endpoint.DeadLetters == nil {
+		dh.Errors = append(dh.Errors, errorCodeDeadLettersDisabled.WithDetail(map[string]string{"name": dh.Endpoint}))
+		return nil, nil
+	}
+	return endpoint, endpoint.DeadLetters
+}
+
+// replay queues the event of a dead letter on its endpoint and discards the
+// dead letter. The event keeps its id, so receivers can recognize it if it
+// was delivered after all.
+func (dh *deadLettersHandler) replay(endpoint *notifications.Endpoint, store *notifications.DeadLetterStore, dl notifications.DeadLetter) error {
+	if err := endpoint.Write(dl.Event); err != nil {
+		return err
+	}
+	if err := store.Delete(dh, dh.Endpoint, dl.Event.ID); err != nil && !errors.Is(err, notifications.ErrDeadLetterUnknown) {
+		return err
+	}
+	return nil
+}
+
+func (dh *deadLettersHandler) appendDeadLetterError(err error) {
+	if errors.Is(err, notifications.ErrDeadLetterUnknown) {
+		dh.Errors = append(dh.Errors, errorCodeDeadLetterUnknown.WithDetail(map[string]string{"id": dh.ID}))
+		return
+	}
+	dh.Errors = append(dh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+}
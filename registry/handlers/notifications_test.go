@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/notifications"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+)
+
+// newEventListener returns a notification endpoint passing the events it
+// receives to a channel.
+func newEventListener(t *testing.T) (*httptest.Server, <-chan notifications.Event) {
+	received := make(chan notifications.Event, 10)
+	listener := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var envelope struct {
+			Events []notifications.Event `json:"events"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&envelope); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, event := range envelope.Events {
+			received <- event
+		}
+	}))
+	t.Cleanup(listener.Close)
+	return listener, received
+}
+
+func receiveEvent(t *testing.T, received <-chan notifications.Event) notifications.Event {
+	t.Helper()
+	select {
+	case event := <-received:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+	}
+	return notifications.Event{}
+}
+
+func TestAdminNotificationEndpoints(t *testing.T) {
+	listener, received := newEventListener(t)
+
+	config := adminTestConfig(t, true)
+	config.Notifications.Endpoints = []configuration.Endpoint{
+		{Name: "listener", URL: listener.URL},
+		{Name: "disabled", URL: listener.URL, Disabled: true},
+	}
+	config.Notifications.Journal = configuration.Journal{Storage: true}
+	app := NewApp(dcontext.Background(), &config)
+	server := httptest.NewServer(app)
+	defer server.Close()
+	base := server.URL + "/admin/v1/notifications"
+
+	getStatus := func(resp *http.Response) endpointStatus {
+		t.Helper()
+		var status endpointStatus
+		if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+			t.Fatal(err)
+		}
+		return status
+	}
+
+	checkStatus(t, adminRequest(t, http.MethodGet, base, "", nil), http.StatusUnauthorized)
+	resp := adminRequest(t, http.MethodGet, base, "", asAdmin)
+	checkStatus(t, resp, http.StatusOK)
+	var list endpointsAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Endpoints) != 1 || list.Endpoints[0].Name != "listener" || list.Endpoints[0].Circuit != "closed" {
+		t.Fatalf("unexpected endpoints: %+v", list.Endpoints)
+	}
+	checkStatus(t, adminRequest(t, http.MethodGet, base+"/disabled", "", asAdmin), http.StatusNotFound)
+
+	resp = adminRequest(t, http.MethodPost, base+"/listener/pause", "", asAdmin)
+	checkStatus(t, resp, http.StatusOK)
+	if status := getStatus(resp); !status.Paused {
+		t.Fatalf("expected endpoint to be paused: %+v", status)
+	}
+
+	// Journal events for two repositories, and replay one of them while
+	// the endpoint is paused.
+	start := time.Now().Add(-time.Hour)
+	for i, repo := range []string{"foo/bar", "foo/baz"} {
+		event := notifications.Event{ID: repo, Timestamp: start.Add(time.Duration(i) * time.Minute), Action: notifications.EventActionPush}
+		event.Target.Repository = repo
+		if err := app.events.journal.Write(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	replay := base + "/listener/replay"
+	checkStatus(t, adminRequest(t, http.MethodPost, replay, `{"repository": "Foo"}`, asAdmin), http.StatusBadRequest)
+	checkStatus(t, adminRequest(t, http.MethodPost, replay, `{"repository": "foo/bar"}`, asAdmin), http.StatusBadRequest)
+	tooLong := `{"repository": "foo/bar", "from": "` + start.Add(-notifications.MaxReplayRange-time.Hour).Format(time.RFC3339) + `"}`
+	checkStatus(t, adminRequest(t, http.MethodPost, replay, tooLong, asAdmin), http.StatusBadRequest)
+	resp = adminRequest(t, http.MethodPost, replay, `{"repository": "foo/bar", "from": "`+start.Add(-time.Minute).Format(time.RFC3339)+`"}`, asAdmin)
+	checkStatus(t, resp, http.StatusAccepted)
+	var replayed replayAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&replayed); err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Replayed != 1 {
+		t.Fatalf("expected 1 replayed event, got %d", replayed.Replayed)
+	}
+
+	resp = adminRequest(t, http.MethodGet, base+"/listener", "", asAdmin)
+	checkStatus(t, resp, http.StatusOK)
+	if status := getStatus(resp); status.Pending != 1 {
+		t.Fatalf("expected the replayed event to be held while paused: %+v", status)
+	}
+
+	checkStatus(t, adminRequest(t, http.MethodPost, base+"/listener/resume", "", asAdmin), http.StatusOK)
+	if event := receiveEvent(t, received); event.ID != "foo/bar" {
+		t.Fatalf("unexpected replayed event: %+v", event)
+	}
+}
+
+func TestAdminDeadLetters(t *testing.T) {
+	listener, received := newEventListener(t)
+
+	config := adminTestConfig(t, true)
+	config.Notifications.Endpoints = []configuration.Endpoint{
+		{
+			Name:       "listener",
+			URL:        listener.URL,
+			Retry:      configuration.Retry{InitialBackoff: time.Millisecond, MaxAttempts: 1},
+			DeadLetter: configuration.DeadLetter{Storage: true},
+		},
+		{Name: "other", URL: listener.URL},
+	}
+	app := NewApp(dcontext.Background(), &config)
+	server := httptest.NewServer(app)
+	defer server.Close()
+	base := server.URL + "/admin/v1/notifications/"
+
+	event := notifications.Event{ID: "event-1", Action: notifications.EventActionPush}
+	event.Target.Repository = "foo/bar"
+	store := app.events.endpoints["listener"].DeadLetters
+	if err := store.Put(app, notifications.DeadLetter{Event: event, Endpoint: "listener", Attempts: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	checkStatus(t, adminRequest(t, http.MethodGet, base+"listener/deadletters", "", nil), http.StatusUnauthorized)
+	checkStatus(t, adminRequest(t, http.MethodGet, base+"unknown/deadletters", "", asAdmin), http.StatusNotFound)
+	checkStatus(t, adminRequest(t, http.MethodGet, base+"other/deadletters", "", asAdmin), http.StatusNotFound)
+
+	resp := adminRequest(t, http.MethodGet, base+"listener/deadletters", "", asAdmin)
+	checkStatus(t, resp, http.StatusOK)
+	var list deadLettersAPIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list.DeadLetters) != 1 || list.DeadLetters[0].Event.ID != "event-1" {
+		t.Fatalf("unexpected dead letters: %+v", list.DeadLetters)
+	}
+
+	checkStatus(t, adminRequest(t, http.MethodPost, base+"listener/deadletters/unknown", "", asAdmin), http.StatusNotFound)
+	checkStatus(t, adminRequest(t, http.MethodPost, base+"listener/deadletters/event-1", "", asAdmin), http.StatusAccepted)
+	if replayed := receiveEvent(t, received); replayed.ID != "event-1" || replayed.Target.Repository != "foo/bar" {
+		t.Fatalf("unexpected replayed event: %+v", replayed)
+	}
+	checkStatus(t, adminRequest(t, http.MethodGet, base+"listener/deadletters/event-1", "", asAdmin), http.StatusNotFound)
+}
+
+// TestNewEventListener checks that events generated outside of requests, such
+// as upload purges, reach the configured endpoints.
+func TestNewEventListener(t *testing.T) {
+	endpoint, received := newEventListener(t)
+
+	config := configuration.Configuration{}
+	config.Notifications.Endpoints = []configuration.Endpoint{
+		{Name: "listener", URL: endpoint.URL, Timeout: time.Second, Threshold: 1, Backoff: time.Second},
+	}
+	ctx := dcontext.Background()
+	listener, sink := NewEventListener(ctx, &config, inmemory.New())
+
+	notifyPurged(listener, dcontext.GetLogger(ctx), []string{
+		"/docker/registry/v2/repositories/foo/bar/_uploads/upload",
+		"/not/an/upload",
+	})
+
+	event := receiveEvent(t, received)
+	if event.Action != notifications.EventActionPurge || event.Target.Repository != "foo/bar" || event.Target.UploadID != "upload" {
+		t.Fatalf("unexpected event: %+v", event)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/notifications"
+	"github.com/distribution/distribution/v3/registry/api/errcode"
+	v2 "github.com/distribution/distribution/v3/registry/api/v2"
+	"github.com/distribution/reference"
+	"github.com/gorilla/handlers"
+	"github.com/gorilla/mux"
+)
+
+var (
+	errorCodeRepositoryExists = errcode.Register(extErrGroup, errcode.ErrorDescriptor{
+		Value:          "NAME_EXISTS",
+		Message:        "repository name already exists",
+		Description:    `Returned when a repository is copied or renamed to the name of an existing repository.`,
+		HTTPStatusCode: http.StatusConflict,
+	})
+
+	errorCodeCopyInvalid = errcode.Register(extErrGroup, errcode.ErrorDescriptor{
+		Value:          "COPY_INVALID",
+		Message:        "invalid repository copy",
+		Description:    `Returned when a repository is copied to itself, or when the move parameter of a copy is invalid.`,
+		HTTPStatusCode: http.StatusBadRequest,
+	})
+)
+
+// repositoryDispatcher constructs the handler of whole repositories.
+func repositoryDispatcher(ctx *Context, r *http.Request) http.Handler {
+	repositoryHandler := &repositoryHandler{
+		Context: ctx,
+	}
+
+	mhandler := handlers.MethodHandler{}
+	if !ctx.readOnly {
+		mhandler[http.MethodDelete] = http.HandlerFunc(repositoryHandler.DeleteRepository)
+	}
+	return mhandler
+}
+
+// repositoryCopyDispatcher constructs the handler of repository copies.
+func repositoryCopyDispatcher(ctx *Context, r *http.Request) http.Handler {
+	repositoryHandler := &repositoryHandler{
+		Context: ctx,
+	}
+
+	mhandler := handlers.MethodHandler{}
+	if !ctx.readOnly {
+		mhandler[http.MethodPost] = http.HandlerFunc(repositoryHandler.CopyRepository)
+	}
+	return mhandler
+}
+
+// repositoryHandler handles the operations on whole repositories.
+type repositoryHandler struct {
+	*Context
+}
+
+// DeleteRepository removes the repository, leaving the blob data to the
+// garbage collector.
+func (rh *repositoryHandler) DeleteRepository(w http.ResponseWriter, r *http.Request) {
+	dcontext.GetLogger(rh).Debug("DeleteRepository")
+
+	if rh.App.isCache || rh.App.repoRemover == nil {
+		rh.Errors = append(rh.Errors, errcode.ErrorCodeUnsupported)
+		return
+	}
+
+	if err := rh.RepositoryRemover.Remove(rh, rh.Repository.Named()); err != nil {
+		switch err := err.(type) {
+		case distribution.ErrRepositoryUnknown:
+			rh.Errors = append(rh.Errors, errcode.ErrorCodeNameUnknown.WithDetail(err))
+		default:
+			if err == distribution.ErrUnsupported {
+				rh.Errors = append(rh.Errors, errcode.ErrorCodeUnsupported)
+				return
+			}
+			rh.Errors = append(rh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		}
+		return
+	}
+
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// CopyRepository links the content of the repository named by the from
+// parameter into the repository of the request, removing the former if the
+// move parameter is set.
+func (rh *repositoryHandler) CopyRepository(w http.ResponseWriter, r *http.Request) {
+	dcontext.GetLogger(rh).Debug("CopyRepository")
+
+	copier, ok := rh.App.registry.(distribution.RepositoryCopier)
+	if rh.App.isCache || !ok {
+		rh.Errors = append(rh.Errors, errcode.ErrorCodeUnsupported)
+		return
+	}
+
+	fromName := r.FormValue("from")
+	from, err := reference.WithName(fromName)
+	if err != nil {
+		rh.Errors = append(rh.Errors, errcode.ErrorCodeNameInvalid.WithDetail(map[string]string{"from": fromName}))
+		return
+	}
+	to := rh.Repository.Named()
+	if from.Name() == to.Name() {
+		rh.Errors = append(rh.Errors, errorCodeCopyInvalid.WithDetail("a repository cannot be copied to itself"))
+		return
+	}
+
+	move := false
+	if m := r.FormValue("move"); m != "" {
+		move, err = strconv.ParseBool(m)
+		if err != nil {
+			rh.Errors = append(rh.Errors, errorCodeCopyInvalid.WithDetail(map[string]string{"move": m}))
+			return
+		}
+	}
+	if move && (!rh.App.deleteEnabled || rh.App.repoRemover == nil) {
+		rh.Errors = append(rh.Errors, errcode.ErrorCodeUnsupported.WithDetail("repositories cannot be renamed as deletes are disabled"))
+		return
+	}
+
+	if err := copier.Copy(rh, from, to); err != nil {
+		switch err := err.(type) {
+		case distribution.ErrRepositoryUnknown:
+			rh.Errors = append(rh.Errors, errcode.ErrorCodeNameUnknown.WithDetail(err))
+		case distribution.ErrRepositoryExists:
+			rh.Errors = append(rh.Errors, errorCodeRepositoryExists.WithDetail(err))
+		default:
+			rh.Errors = append(rh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		}
+		return
+	}
+
+	// The rename is reported as such, rather than as the deletion of the
+	// repository copied.
+	listener, _ := rh.App.eventBridge(rh.Context, r).(notifications.RepoCopyListener)
+	if move {
+		if err := rh.App.repoRemover.Remove(rh, from); err != nil {
+			rh.Errors = append(rh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+			return
+		}
+		if listener != nil {
+			if err := listener.RepoRenamed(to, from); err != nil {
+				dcontext.GetLogger(rh).Errorf("error dispatching repository rename to listener: %v", err)
+			}
+		}
+	} else if listener != nil {
+		if err := listener.RepoCopied(to, from); err != nil {
+			dcontext.GetLogger(rh).Errorf("error dispatching repository copy to listener: %v", err)
+		}
+	}
+
+	tagsURL, err := rh.urlBuilder.BuildTagsURL(to)
+	if err != nil {
+		rh.Errors = append(rh.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+	w.Header().Set("Location", tagsURL)
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(http.StatusCreated)
+}
+
+// isRepositoryMove returns true if the request renames a repository.
+func isRepositoryMove(r *http.Request) bool {
+	route := mux.CurrentRoute(r)
+	if route == nil || route.GetName() != v2.RouteNameRepositoryCopy {
+		return false
+	}
+	move, _ := strconv.ParseBool(r.FormValue("move"))
+	return move
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/distribution/distribution/v3/registry/api/errcode"
+	"github.com/distribution/reference"
+)
+
+// repositoryTags returns the response to the listing of the tags of the
+// repository.
+func repositoryTags(t *testing.T, env *testEnv, name string) *http.Response {
+	t.Helper()
+
+	named, _ := reference.WithName(name)
+	tagsURL, err := env.builder.BuildTagsURL(named)
+	if err != nil {
+		t.Fatalf("unexpected error building tags url: %v", err)
+	}
+	resp, err := http.Get(tagsURL)
+	if err != nil {
+		t.Fatalf("unexpected error listing tags: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func copyRepository(t *testing.T, env *testEnv, name string, values url.Values) *http.Response {
+	t.Helper()
+
+	named, _ := reference.WithName(name)
+	copyURL, err := env.builder.BuildRepositoryCopyURL(named, values)
+	if err != nil {
+		t.Fatalf("unexpected error building copy url: %v", err)
+	}
+	resp, err := http.Post(copyURL, "", nil)
+	if err != nil {
+		t.Fatalf("unexpected error copying repository: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func deleteRepository(t *testing.T, env *testEnv, name string) *http.Response {
+	t.Helper()
+
+	named, _ := reference.WithName(name)
+	repositoryURL, err := env.builder.BuildRepositoryURL(named)
+	if err != nil {
+		t.Fatalf("unexpected error building repository url: %v", err)
+	}
+	resp, err := httpDelete(repositoryURL)
+	if err != nil {
+		t.Fatalf("unexpected error deleting repository: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestRepositoryDelete(t *testing.T) {
+	env := newTestEnv(t, true)
+	defer env.Shutdown()
+
+	createRepository(env, t, "foo/bar", "latest")
+	createRepository(env, t, "foo/bar/nested", "latest")
+
+	resp := deleteRepository(t, env, "foo/bar")
+	checkResponse(t, "deleting repository", resp, http.StatusAccepted)
+
+	resp = repositoryTags(t, env, "foo/bar")
+	checkResponse(t, "listing tags of deleted repository", resp, http.StatusNotFound)
+	resp = repositoryTags(t, env, "foo/bar/nested")
+	checkResponse(t, "listing tags of nested repository", resp, http.StatusOK)
+
+	resp = deleteRepository(t, env, "foo/bar")
+	checkResponse(t, "deleting deleted repository", resp, http.StatusNotFound)
+	// nolint:errcheck
+	checkBodyHasErrorCodes(t, "deleting deleted repository", resp, errcode.ErrorCodeNameUnknown)
+}
+
+func TestRepositoryDeleteDisabled(t *testing.T) {
+	env := newTestEnv(t, false)
+	defer env.Shutdown()
+
+	createRepository(env, t, "foo/bar", "latest")
+
+	resp := deleteRepository(t, env, "foo/bar")
+	checkResponse(t, "deleting repository", resp, http.StatusMethodNotAllowed)
+
+	resp = repositoryTags(t, env, "foo/bar")
+	checkResponse(t, "listing tags of repository", resp, http.StatusOK)
+}
+
+func TestRepositoryCopy(t *testing.T) {
+	env := newTestEnv(t, true)
+	defer env.Shutdown()
+
+	dgst := createRepository(env, t, "source", "latest")
+
+	resp := copyRepository(t, env, "copy", url.Values{"from": []string{"source"}})
+	checkResponse(t, "copying repository", resp, http.StatusCreated)
+	copyRef, _ := reference.WithName("copy")
+	tagsURL, _ := env.builder.BuildTagsURL(copyRef)
+	checkHeaders(t, resp, http.Header{
+		"Location":       []string{tagsURL},
+		"Content-Length": []string{"0"},
+	})
+
+	for _, name := range []string{"source", "copy"} {
+		named, _ := reference.WithName(name)
+		ref, _ := reference.WithTag(named, "latest")
+		manifestURL, err := env.builder.BuildManifestURL(ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.Head(manifestURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		checkResponse(t, "fetching manifest of "+name, resp, http.StatusOK)
+		checkHeaders(t, resp, http.Header{"Docker-Content-Digest": []string{dgst.String()}})
+	}
+
+	resp = copyRepository(t, env, "copy", url.Values{"from": []string{"source"}})
+	checkResponse(t, "copying to an existing repository", resp, http.StatusConflict)
+	// nolint:errcheck
+	checkBodyHasErrorCodes(t, "copying to an existing repository", resp, errorCodeRepositoryExists)
+
+	resp = copyRepository(t, env, "moved", url.Values{"from": []string{"copy"}, "move": []string{"true"}})
+	checkResponse(t, "renaming repository", resp, http.StatusCreated)
+	resp = repositoryTags(t, env, "copy")
+	checkResponse(t, "listing tags of renamed repository", resp, http.StatusNotFound)
+	resp = repositoryTags(t, env, "moved")
+	checkResponse(t, "listing tags of repository renamed to", resp, http.StatusOK)
+
+	for _, test := range []struct {
+		name   string
+		values url.Values
+		status int
+		code   errcode.ErrorCode
+	}{
+		{"other", url.Values{"from": []string{"unknown"}}, http.StatusNotFound, errcode.ErrorCodeNameUnknown},
+		{"other", url.Values{"from": []string{"Invalid"}}, http.StatusBadRequest, errcode.ErrorCodeNameInvalid},
+		{"source", url.Values{"from": []string{"source"}}, http.StatusBadRequest, errorCodeCopyInvalid},
+		{"other", url.Values{"from": []string{"source"}, "move": []string{"maybe"}}, http.StatusBadRequest, errorCodeCopyInvalid},
+	} {
+		resp := copyRepository(t, env, test.name, test.values)
+		checkResponse(t, "copying repository", resp, test.status)
+		// nolint:errcheck
+		checkBodyHasErrorCodes(t, "copying repository", resp, test.code)
+	}
+}
+
+func TestRepositoryCopyDeleteDisabled(t *testing.T) {
+	env := newTestEnv(t, false)
+	defer env.Shutdown()
+
+	createRepository(env, t, "source", "latest")
+
+	resp := copyRepository(t, env, "moved", url.Values{"from": []string{"source"}, "move": []string{"true"}})
+	checkResponse(t, "renaming repository", resp, http.StatusMethodNotAllowed)
+
+	resp = copyRepository(t, env, "copy", url.Values{"from": []string{"source"}})
+	checkResponse(t, "copying repository", resp, http.StatusCreated)
+
+	for _, name := range []string{"source", "copy"} {
+		resp := repositoryTags(t, env, name)
+		checkResponse(t, "listing tags of "+name, resp, http.StatusOK)
+	}
+}
@@ -2,15 +2,36 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"sort"
 	"strconv"
+	"time"
 
 	"github.com/distribution/distribution/v3"
 	"github.com/distribution/distribution/v3/registry/api/errcode"
 	"github.com/gorilla/handlers"
+	"github.com/opencontainers/go-digest"
 )
 
+const (
+	// extErrGroup groups the errors of the extensions to the API.
+	extErrGroup = "registry.api.ext"
+
+	// maxTagDetailEntries is the largest page of tag details which may be
+	// requested.
+	maxTagDetailEntries = 1000
+)
+
+var errorCodeTagQueryInvalid = errcode.Register(extErrGroup, errcode.ErrorDescriptor{
+	Value:          "TAG_QUERY_INVALID",
+	Message:        "invalid tag query",
+	Description:    `Returned when the detail, sort or lastmodified parameter of a tag listing is invalid, or when the last tag of a listing by time no longer exists.`,
+	HTTPStatusCode: http.StatusBadRequest,
+})
+
 // tagsDispatcher constructs the tags handler api endpoint.
 func tagsDispatcher(ctx *Context, r *http.Request) http.Handler {
 	tagsHandler := &tagsHandler{
@@ -22,6 +43,17 @@ func tagsDispatcher(ctx *Context, r *http.Request) http.Handler {
 	}
 }
 
+// tagDetailsDispatcher constructs the tag details extension endpoint.
+func tagDetailsDispatcher(ctx *Context, r *http.Request) http.Handler {
+	tagsHandler := &tagsHandler{
+		Context: ctx,
+	}
+
+	return handlers.MethodHandler{
+		http.MethodGet: http.HandlerFunc(tagsHandler.GetTagDetails),
+	}
+}
+
 // tagsHandler handles requests for lists of tags under a repository name.
 type tagsHandler struct {
 	*Context
@@ -104,3 +136,290 @@ func (th *tagsHandler) GetTags(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+type tagDetailsAPIResponse struct {
+	Name string              `json:"name"`
+	Tags []tagDetailAPIEntry `json:"tags"`
+}
+
+type tagDetailAPIEntry struct {
+	Name         string        `json:"name"`
+	Digest       digest.Digest `json:"digest,omitempty"`
+	MediaType    string        `json:"mediaType,omitempty"`
+	Size         int64         `json:"size,omitempty"`
+	LastModified *time.Time    `json:"lastModified,omitempty"`
+}
+
+// GetTagDetails returns a page of the tags of an image name, optionally with
+// the manifests they point to. Sorted by name, the tags are paged in the
+// storage; sorted by time, they are all read to be ordered, and each page
+// follows the modification time and name of the last tag of the previous one.
+func (th *tagsHandler) GetTagDetails(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	lastEntry := q.Get("last")
+
+	var lastModified *time.Time
+	if lm := q.Get("lastmodified"); lm != "" {
+		parsed, err := time.Parse(time.RFC3339Nano, lm)
+		if err != nil {
+			th.Errors = append(th.Errors, errorCodeTagQueryInvalid.WithDetail(map[string]string{"lastmodified": lm}))
+			return
+		}
+		lastModified = &parsed
+	}
+
+	detail := false
+	if d := q.Get("detail"); d != "" {
+		parsed, err := strconv.ParseBool(d)
+		if err != nil {
+			th.Errors = append(th.Errors, errorCodeTagQueryInvalid.WithDetail(map[string]string{"detail": d}))
+			return
+		}
+		detail = parsed
+	}
+
+	byTime := false
+	switch order := q.Get("sort"); order {
+	case "", "name":
+	case "time":
+		byTime = true
+	default:
+		th.Errors = append(th.Errors, errorCodeTagQueryInvalid.WithDetail(map[string]string{"sort": order}))
+		return
+	}
+
+	entries := defaultReturnedEntries
+	if n := q.Get("n"); n != "" {
+		parsedMax, err := strconv.Atoi(n)
+		if err != nil || parsedMax < 0 || parsedMax > maxTagDetailEntries {
+			th.Errors = append(th.Errors, errcode.ErrorCodePaginationNumberInvalid.WithDetail(map[string]string{"n": n}))
+			return
+		}
+		entries = parsedMax
+	}
+
+	tagService := th.Repository.Tags(th)
+	var (
+		details     []distribution.TagDetail
+		moreEntries bool
+		err         error
+	)
+	if byTime {
+		details, moreEntries, err = th.tagDetailsByTime(tagService, entries, lastEntry, lastModified)
+	} else {
+		details, moreEntries, err = th.tagDetailsByName(tagService, entries, lastEntry, detail)
+	}
+	if err != nil {
+		switch err := err.(type) {
+		case distribution.ErrRepositoryUnknown:
+			th.Errors = append(th.Errors, errcode.ErrorCodeNameUnknown.WithDetail(map[string]string{"name": th.Repository.Named().Name()}))
+		case errcode.Error:
+			th.Errors = append(th.Errors, err)
+		default:
+			th.Errors = append(th.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		}
+		return
+	}
+
+	tags := make([]tagDetailAPIEntry, len(details))
+	for i, d := range details {
+		tags[i].Name = d.Name
+		if detail {
+			tags[i].Digest = d.Descriptor.Digest
+			tags[i].MediaType = d.Descriptor.MediaType
+			tags[i].Size = d.Descriptor.Size
+			if !d.Modified.IsZero() {
+				modified := d.Modified.UTC()
+				tags[i].LastModified = &modified
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if moreEntries && len(tags) > 0 {
+		values := url.Values{
+			"n":    []string{strconv.Itoa(entries)},
+			"last": []string{tags[len(tags)-1].Name},
+		}
+		if byTime {
+			values.Set("lastmodified", details[len(details)-1].Modified.UTC().Format(time.RFC3339Nano))
+		}
+		w.Header().Set("Link", createQueryLinkEntry(r.URL, values))
+	}
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(tagDetailsAPIResponse{
+		Name: th.Repository.Named().Name(),
+		Tags: tags,
+	}); err != nil {
+		th.Errors = append(th.Errors, errcode.ErrorCodeUnknown.WithDetail(err))
+		return
+	}
+}
+
+// tagDetailsByName returns at most n tags following last in lexical order,
+// described if detail is set, and whether more tags follow.
+func (th *tagsHandler) tagDetailsByName(tagService distribution.TagService, n int, last string, detail bool) ([]distribution.TagDetail, bool, error) {
+	if n == 0 {
+		return nil, false, nil
+	}
+
+	tags, moreEntries, err := th.pageTags(tagService, n, last)
+	if err != nil {
+		return nil, false, err
+	}
+	if !detail {
+		details := make([]distribution.TagDetail, len(tags))
+		for i, tag := range tags {
+			details[i].Name = tag
+		}
+		return details, moreEntries, nil
+	}
+
+	details, err := th.tagDetails(tagService, tags)
+	return details, moreEntries, err
+}
+
+// tagDetailsByTime returns at most n tags following last, the most recently
+// moved first, and whether more tags follow. The tags following last are
+// those moved before lastModified, or at the same time with a greater name,
+// so that tags moved between pages do not shift the others. Without
+// lastModified, last must still exist, and its modification time is used.
+func (th *tagsHandler) tagDetailsByTime(tagService distribution.TagService, n int, last string, lastModified *time.Time) ([]distribution.TagDetail, bool, error) {
+	tags, err := tagService.All(th)
+	if err != nil {
+		return nil, false, err
+	}
+	details, err := th.tagDetails(tagService, tags)
+	if err != nil {
+		return nil, false, err
+	}
+	sort.Slice(details, func(i, j int) bool {
+		if !details[i].Modified.Equal(details[j].Modified) {
+			return details[i].Modified.After(details[j].Modified)
+		}
+		return details[i].Name < details[j].Name
+	})
+
+	if last != "" {
+		if lastModified == nil {
+			i := 0
+			for i < len(details) && details[i].Name != last {
+				i++
+			}
+			if i == len(details) {
+				return nil, false, errorCodeTagQueryInvalid.WithDetail(map[string]string{"last": last})
+			}
+			lastModified = &details[i].Modified
+		}
+		details = details[sort.Search(len(details), func(i int) bool {
+			if !details[i].Modified.Equal(*lastModified) {
+				return details[i].Modified.Before(*lastModified)
+			}
+			return details[i].Name > last
+		}):]
+	}
+	if len(details) > n {
+		return details[:n], true, nil
+	}
+	return details, false, nil
+}
+
+// pageTags returns at most n tags following last in lexical order, and
+// whether more tags follow. The tags are paged in the storage if the tag
+// service supports it, or else listed in full.
+func (th *tagsHandler) pageTags(tagService distribution.TagService, n int, last string) ([]string, bool, error) {
+	if tds, ok := tagService.(distribution.TagDetailService); ok {
+		tags := make([]string, n)
+		count, err := tds.Page(th, tags, last)
+		switch err {
+		case nil:
+			return tags[:count], true, nil
+		case io.EOF:
+			return tags[:count], false, nil
+		case distribution.ErrUnsupported:
+		default:
+			return nil, false, err
+		}
+	}
+
+	tags, err := tagService.All(th)
+	if err != nil {
+		return nil, false, err
+	}
+	sort.Strings(tags)
+	tags = tags[sort.Search(len(tags), func(i int) bool { return tags[i] > last }):]
+	if len(tags) > n {
+		return tags[:n], true, nil
+	}
+	return tags, false, nil
+}
+
+// tagDetails describes the tags, skipping the ones which do not exist. The
+// tag service describes them if it supports it; otherwise each tag is
+// resolved, and its manifest read if the tag service does not describe it.
+func (th *tagsHandler) tagDetails(tagService distribution.TagService, tags []string) ([]distribution.TagDetail, error) {
+	if tds, ok := tagService.(distribution.TagDetailService); ok {
+		details, err := tds.Details(th, tags)
+		if err != distribution.ErrUnsupported {
+			return details, err
+		}
+	}
+
+	manifests, err := th.Repository.Manifests(th)
+	if err != nil {
+		return nil, err
+	}
+	details := make([]distribution.TagDetail, 0, len(tags))
+	for _, tag := range tags {
+		desc, err := tagService.Get(th, tag)
+		if err != nil {
+			if _, ok := err.(distribution.ErrTagUnknown); ok {
+				continue
+			}
+			return nil, err
+		}
+		if desc.MediaType == "" || desc.Size == 0 {
+			m, err := manifests.Get(th, desc.Digest)
+			switch err.(type) {
+			case nil:
+				mediaType, payload, err := m.Payload()
+				if err != nil {
+					return nil, err
+				}
+				desc.MediaType = mediaType
+				desc.Size = int64(len(payload))
+			case distribution.ErrManifestUnknownRevision:
+			default:
+				return nil, err
+			}
+		}
+		details = append(details, distribution.TagDetail{
+			Name:       tag,
+			Descriptor: desc,
+		})
+	}
+	return details, nil
+}
+
+// createQueryLinkEntry creates the link header to the next page of a
+// listing, replacing the parameters of the query set in next and keeping the
+// others. The parameters without values are removed.
+func createQueryLinkEntry(origURL *url.URL, next url.Values) string {
+	calledURL := *origURL
+
+	v := calledURL.Query()
+	for key, values := range next {
+		if len(values) == 0 {
+			v.Del(key)
+		} else {
+			v[key] = values
+		}
+	}
+
+	calledURL.RawQuery = v.Encode()
+
+	calledURL.Fragment = ""
+	return fmt.Sprintf("<%s>; rel=\"next\"", calledURL.String())
+}
@@ -24,12 +24,14 @@ type proxyBlobStore struct {
 	ttl            *time.Duration
 	repositoryName reference.Named
 	authChallenger authChallenger
+	coalescing     *coalescing // nil unless coalescing fetches with other instances
 }
 
 var _ distribution.BlobStore = &proxyBlobStore{}
 
-// inflight tracks currently downloading blobs
-var inflight = make(map[digest.Digest]struct{})
+// inflight tracks currently downloading blobs, closing their channel once
+// the download is over
+var inflight = make(map[digest.Digest]chan struct{})
 
 // mu protects inflight
 var mu sync.Mutex
@@ -94,24 +96,42 @@ func (pbs *proxyBlobStore) ServeBlob(ctx context.Context, w http.ResponseWriter,
 		return err
 	}
 
-	mu.Lock()
-	_, ok := inflight[dgst]
-	if ok {
-		// If the blob has been serving in other requests.
-		// Will return the blob from the remote store directly.
-		// TODO Maybe we could reuse the these blobs are serving remotely and caching locally.
-		mu.Unlock()
-		_, err := pbs.copyContent(ctx, dgst, w, w.Header())
-		return err
-	}
-	inflight[dgst] = struct{}{}
-	mu.Unlock()
-
-	defer func() {
+	if pbs.coalescing != nil {
+		// The requests of this instance wait on the fetch lock too, like
+		// those of other instances, rather than fetching the blob again.
+		unlock, served, err := pbs.coalesce(ctx, w, r, dgst)
+		if served || err != nil {
+			return err
+		}
+		if unlock == nil {
+			// The fetch is taking too long; serve the blob without
+			// storing it, as for concurrent requests below.
+			_, err := pbs.copyContent(ctx, dgst, w, w.Header())
+			return err
+		}
+		defer unlock()
+	} else {
 		mu.Lock()
-		delete(inflight, dgst)
+		_, ok := inflight[dgst]
+		if ok {
+			// If the blob has been serving in other requests.
+			// Will return the blob from the remote store directly.
+			// TODO Maybe we could reuse the these blobs are serving remotely and caching locally.
+			mu.Unlock()
+			_, err := pbs.copyContent(ctx, dgst, w, w.Header())
+			return err
+		}
+		done := make(chan struct{})
+		inflight[dgst] = done
 		mu.Unlock()
-	}()
+
+		defer func() {
+			mu.Lock()
+			delete(inflight, dgst)
+			mu.Unlock()
+			close(done)
+		}()
+	}
 
 	bw, err := pbs.localStore.Create(ctx)
 	if err != nil {
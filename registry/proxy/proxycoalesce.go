@@ -0,0 +1,167 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/google/uuid"
+	"github.com/opencontainers/go-digest"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultCoalescingLockTTL     = 30 * time.Second
+	defaultCoalescingWaitTimeout = 5 * time.Minute
+	coalescingPollInterval       = 500 * time.Millisecond
+	coalescingKeyPrefix          = "proxy:fetch:"
+)
+
+// fetchLocker lets one registry instance at a time fetch a blob from the
+// remote registry.
+type fetchLocker interface {
+	// TryLock takes the lock of the fetch of dgst, reporting false if
+	// another instance holds it. The lock is held until unlock is called,
+	// or until the lock expires when the instance dies.
+	TryLock(ctx context.Context, dgst digest.Digest) (unlock func(), ok bool, err error)
+
+	// Locked reports whether an instance holds the lock of the fetch of
+	// dgst.
+	Locked(ctx context.Context, dgst digest.Digest) (bool, error)
+}
+
+// coalescing coordinates the fetches of the registry instances sharing a
+// fetchLocker.
+type coalescing struct {
+	locker       fetchLocker
+	waitTimeout  time.Duration
+	pollInterval time.Duration
+}
+
+// coalesce takes the lock of the fetch of dgst, for the caller to fetch it on
+// behalf of every request, of this instance or another. While the lock is
+// held, it waits for the blob to be stored and serves it. It returns a nil
+// unlock, without serving the blob, if the wait timed out.
+//
+// Waiters are only served once the blob is committed: streaming the blob from
+// the copy in progress is not supported.
+func (pbs *proxyBlobStore) coalesce(ctx context.Context, w http.ResponseWriter, r *http.Request, dgst digest.Digest) (unlock func(), served bool, err error) {
+	c := pbs.coalescing
+	deadline := time.Now().Add(c.waitTimeout)
+	for {
+		unlock, ok, err := c.locker.TryLock(ctx, dgst)
+		if err != nil {
+			dcontext.GetLogger(ctx).Warnf("fetching %s without coalescing: %v", dgst, err)
+			return func() {}, false, nil
+		}
+		if ok {
+			// The blob may have been stored since it was looked up.
+			served, err := pbs.serveLocal(ctx, w, r, dgst)
+			if served || err != nil {
+				unlock()
+				return nil, served, err
+			}
+			return unlock, false, nil
+		}
+
+		for locked := true; locked; {
+			if !time.Now().Before(deadline) {
+				dcontext.GetLogger(ctx).Warnf("timed out waiting for the fetch of %s", dgst)
+				return nil, false, nil
+			}
+			select {
+			case <-ctx.Done():
+				return nil, false, ctx.Err()
+			case <-time.After(c.pollInterval):
+			}
+
+			locked, err = c.locker.Locked(ctx, dgst)
+			if err != nil {
+				dcontext.GetLogger(ctx).Warnf("fetching %s without coalescing: %v", dgst, err)
+				return func() {}, false, nil
+			}
+		}
+
+		served, err := pbs.serveLocal(ctx, w, r, dgst)
+		if served || err != nil {
+			return nil, served, err
+		}
+		// The fetch was given up on; take it over.
+	}
+}
+
+// redisFetchLocker holds fetch locks in redis, under keys which expire unless
+// renewed by the instance holding them.
+type redisFetchLocker struct {
+	client redis.UniversalClient
+	ttl    time.Duration
+}
+
+// renewScript extends the expiry of a lock, if it is still held by the
+// instance.
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// unlockScript deletes a lock, if it is still held by the instance.
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+func newRedisFetchLocker(client redis.UniversalClient, ttl time.Duration) *redisFetchLocker {
+	if ttl <= 0 {
+		ttl = defaultCoalescingLockTTL
+	}
+	return &redisFetchLocker{client: client, ttl: ttl}
+}
+
+func (rl *redisFetchLocker) key(dgst digest.Digest) string {
+	return coalescingKeyPrefix + dgst.String()
+}
+
+func (rl *redisFetchLocker) TryLock(ctx context.Context, dgst digest.Digest) (func(), bool, error) {
+	key := rl.key(dgst)
+	token := uuid.NewString()
+	ok, err := rl.client.SetNX(ctx, key, token, rl.ttl).Result()
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	// Renew the lock for as long as the fetch lasts, independently of the
+	// request, which may be cancelled before the blob is committed.
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(rl.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := renewScript.Run(context.Background(), rl.client, []string{key}, token, rl.ttl.Milliseconds()).Err(); err != nil {
+					dcontext.GetLogger(ctx).Warnf("error renewing the fetch lock of %s: %v", dgst, err)
+				}
+			}
+		}
+	}()
+
+	unlock := func() {
+		close(done)
+		if err := unlockScript.Run(context.Background(), rl.client, []string{key}, token).Err(); err != nil {
+			dcontext.GetLogger(ctx).Warnf("error releasing the fetch lock of %s: %v", dgst, err)
+		}
+	}
+	return unlock, true, nil
+}
+
+func (rl *redisFetchLocker) Locked(ctx context.Context, dgst digest.Digest) (bool, error) {
+	n, err := rl.client.Exists(ctx, rl.key(dgst)).Result()
+	return n > 0, err
+}
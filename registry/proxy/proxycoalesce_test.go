@@ -0,0 +1,221 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/go-digest"
+	"github.com/redis/go-redis/v9"
+)
+
+// memoryFetchLocker stands for the locks of a redis server shared with other
+// instances.
+type memoryFetchLocker struct {
+	mu     sync.Mutex
+	locked map[digest.Digest]bool
+}
+
+func (ml *memoryFetchLocker) TryLock(ctx context.Context, dgst digest.Digest) (func(), bool, error) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	if ml.locked[dgst] {
+		return nil, false, nil
+	}
+	ml.locked[dgst] = true
+	return func() { ml.unlock(dgst) }, true, nil
+}
+
+func (ml *memoryFetchLocker) Locked(ctx context.Context, dgst digest.Digest) (bool, error) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	return ml.locked[dgst], nil
+}
+
+func (ml *memoryFetchLocker) unlock(dgst digest.Digest) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	delete(ml.locked, dgst)
+}
+
+func serveTestBlob(t *testing.T, te *testEnv, dgst digest.Digest) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	r, err := http.NewRequest(http.MethodGet, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := te.store.ServeBlob(te.ctx, w, r, dgst); err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(w.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest.FromBytes(body) != dgst {
+		t.Fatal("mismatching blob served")
+	}
+}
+
+// TestProxyStoreCoalescing checks that an instance waits for the fetch of
+// another instance rather than fetching the blob itself.
+func TestProxyStoreCoalescing(t *testing.T) {
+	te := makeTestEnv(t, "foo/bar")
+	populate(t, te, 1, 200, 1)
+	dgst := te.inRemote[0].Digest
+
+	locker := &memoryFetchLocker{locked: make(map[digest.Digest]bool)}
+	te.store.coalescing = &coalescing{
+		locker:       locker,
+		waitTimeout:  time.Minute,
+		pollInterval: time.Millisecond,
+	}
+	remoteStats := te.RemoteStats()
+
+	// Another instance is fetching the blob, into the shared storage.
+	unlock, ok, _ := locker.TryLock(te.ctx, dgst)
+	if !ok {
+		t.Fatal("expected to take the lock")
+	}
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		p, err := te.store.remoteStore.Get(te.ctx, dgst)
+		if err == nil {
+			_, err = te.store.localStore.Put(te.ctx, "", p)
+		}
+		if err != nil {
+			t.Error(err)
+		}
+		unlock()
+	}()
+
+	serveTestBlob(t, te, dgst)
+
+	sbsMu.Lock()
+	defer sbsMu.Unlock()
+	if (*remoteStats)["open"] != 0 || (*remoteStats)["stat"] != 0 {
+		t.Fatalf("unexpected fetch from the remote: %v", *remoteStats)
+	}
+}
+
+// TestProxyStoreCoalescingLocal checks that concurrent requests of the same
+// instance wait for a single fetch, rather than fetching the blob each.
+func TestProxyStoreCoalescingLocal(t *testing.T) {
+	te := makeTestEnv(t, "foo/bar")
+	populate(t, te, 1, 1<<20, 1)
+	dgst := te.inRemote[0].Digest
+
+	te.store.coalescing = &coalescing{
+		locker:       &memoryFetchLocker{locked: make(map[digest.Digest]bool)},
+		waitTimeout:  time.Minute,
+		pollInterval: time.Millisecond,
+	}
+	remoteStats := te.RemoteStats()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serveTestBlob(t, te, dgst)
+		}()
+	}
+	wg.Wait()
+
+	sbsMu.Lock()
+	defer sbsMu.Unlock()
+	if (*remoteStats)["open"] != 1 {
+		t.Fatalf("unexpected fetches from the remote: %v", *remoteStats)
+	}
+}
+
+// TestProxyStoreCoalescingTakeOver checks that an instance fetches a blob
+// another instance gave up on, and that it serves the blob without storing it
+// if the other instance takes too long.
+func TestProxyStoreCoalescingTakeOver(t *testing.T) {
+	te := makeTestEnv(t, "foo/bar")
+	populate(t, te, 2, 200, 2)
+
+	locker := &memoryFetchLocker{locked: make(map[digest.Digest]bool)}
+	te.store.coalescing = &coalescing{
+		locker:       locker,
+		waitTimeout:  time.Minute,
+		pollInterval: time.Millisecond,
+	}
+
+	// The other instance dies without storing the blob.
+	abandoned := te.inRemote[0].Digest
+	unlock, ok, _ := locker.TryLock(te.ctx, abandoned)
+	if !ok {
+		t.Fatal("expected to take the lock")
+	}
+	time.AfterFunc(20*time.Millisecond, unlock)
+
+	serveTestBlob(t, te, abandoned)
+	if _, err := te.store.localStore.Stat(te.ctx, abandoned); err != nil {
+		t.Fatalf("expected blob taken over to be stored: %v", err)
+	}
+	if locked, _ := locker.Locked(te.ctx, abandoned); locked {
+		t.Fatal("expected the lock to be released")
+	}
+
+	// The other instance never finishes.
+	slow := te.inRemote[1].Digest
+	if _, ok, _ := locker.TryLock(te.ctx, slow); !ok {
+		t.Fatal("expected to take the lock")
+	}
+	te.store.coalescing.waitTimeout = 20 * time.Millisecond
+
+	serveTestBlob(t, te, slow)
+	if _, err := te.store.localStore.Stat(te.ctx, slow); err == nil {
+		t.Fatal("unexpected blob stored while another instance fetches it")
+	}
+}
+
+// TestRedisFetchLocker exercises a live redis instance.
+func TestRedisFetchLocker(t *testing.T) {
+	redisAddr := os.Getenv("TEST_REGISTRY_PROXY_REDIS_ADDR")
+	if redisAddr == "" {
+		t.Skip("please set TEST_REGISTRY_PROXY_REDIS_ADDR to test the redis fetch locker")
+	}
+
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: redisAddr})
+	defer client.Close()
+
+	dgst := digest.FromString("TestRedisFetchLocker")
+	if err := client.Del(ctx, coalescingKeyPrefix+dgst.String()).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	first := newRedisFetchLocker(client, 300*time.Millisecond)
+	second := newRedisFetchLocker(client, 300*time.Millisecond)
+
+	unlock, ok, err := first.TryLock(ctx, dgst)
+	if err != nil || !ok {
+		t.Fatalf("expected to take the lock: %v", err)
+	}
+	// The lock outlives its ttl while it is renewed.
+	time.Sleep(time.Second)
+	if _, ok, err := second.TryLock(ctx, dgst); err != nil || ok {
+		t.Fatalf("unexpected lock taken twice: %v", err)
+	}
+	if locked, err := second.Locked(ctx, dgst); err != nil || !locked {
+		t.Fatalf("expected the lock to be held: %v", err)
+	}
+
+	unlock()
+	if locked, err := second.Locked(ctx, dgst); err != nil || locked {
+		t.Fatalf("expected the lock to be released: %v", err)
+	}
+	unlock, ok, err = second.TryLock(ctx, dgst)
+	if err != nil || !ok {
+		t.Fatalf("expected to take the released lock: %v", err)
+	}
+	unlock()
+}
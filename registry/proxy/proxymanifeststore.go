@@ -20,6 +20,7 @@ type proxyManifestStore struct {
 	scheduler       *scheduler.TTLExpirationScheduler
 	ttl             *time.Duration
 	authChallenger  authChallenger
+	rateLimit       *rateLimit
 }
 
 var _ distribution.ManifestService = &proxyManifestStore{}
@@ -48,6 +49,12 @@ func (pms proxyManifestStore) Get(ctx context.Context, dgst digest.Digest, optio
 			return nil, err
 		}
 
+		// Manifest fetches are what upstreams such as Docker Hub count
+		// against their rate limit.
+		if err := pms.rateLimit.wait(ctx); err != nil {
+			return nil, err
+		}
+
 		manifest, err = pms.remoteManifests.Get(ctx, dgst, options...)
 		if err != nil {
 			return nil, err
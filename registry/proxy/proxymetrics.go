@@ -19,6 +19,10 @@ var (
 	pulledBytes = prometheus.ProxyNamespace.NewLabeledCounter("pulled_bytes", "The size of total bytes pulled from the upstream", "type")
 	// pushedBytes is the size of total bytes pushed to the client for blob/manifest
 	pushedBytes = prometheus.ProxyNamespace.NewLabeledCounter("pushed_bytes", "The size of total bytes pushed to the client", "type")
+	// rateLimitLimit is the upstream rate limit of the credential
+	rateLimitLimit = prometheus.ProxyNamespace.NewLabeledGauge("ratelimit_limit", "The upstream rate limit of the credential", metrics.Total, "credential")
+	// rateLimitRemaining is what is left of the upstream rate limit of the credential
+	rateLimitRemaining = prometheus.ProxyNamespace.NewLabeledGauge("ratelimit_remaining", "The remaining upstream rate limit of the credential", metrics.Total, "credential")
 )
 
 // Metrics is used to hold metric counters
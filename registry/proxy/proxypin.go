@@ -0,0 +1,268 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/proxy/scheduler"
+	"github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/reference"
+	"github.com/opencontainers/go-digest"
+)
+
+// pinsPath is where the pins added through the admin API are stored.
+const pinsPath = "/proxy-pins.json"
+
+var (
+	// ErrPinInvalid is returned for a pin which is not a repository name
+	// or a tag.
+	ErrPinInvalid = errors.New("invalid pin")
+
+	// ErrPinUnknown is returned when removing a pin which does not exist.
+	ErrPinUnknown = errors.New("pin unknown")
+
+	// ErrPinConfigured is returned when removing a pin of the
+	// configuration.
+	ErrPinConfigured = errors.New("pin is configured")
+)
+
+// Pin keeps the content of a repository, or of a tag of it, in the cache:
+// the content never expires. A pinned tag keeps the manifest it currently
+// points to, with the index children and blobs the manifest references.
+type Pin struct {
+	Repository string `json:"repository"`
+	Tag        string `json:"tag,omitempty"`
+
+	// Configured is set for the pins of the configuration, which cannot be
+	// removed through the admin API.
+	Configured bool `json:"configured,omitempty"`
+}
+
+// ParsePin parses the pin of a repository, "name", or of a tag,
+// "name:tag".
+func ParsePin(s string) (Pin, error) {
+	ref, err := reference.Parse(s)
+	if err != nil {
+		return Pin{}, fmt.Errorf("%w %q: %v", ErrPinInvalid, s, err)
+	}
+	named, ok := ref.(reference.Named)
+	if !ok {
+		return Pin{}, fmt.Errorf("%w %q: no repository name", ErrPinInvalid, s)
+	}
+	if _, ok := ref.(reference.Digested); ok {
+		return Pin{}, fmt.Errorf("%w %q: digests cannot be pinned", ErrPinInvalid, s)
+	}
+
+	pin := Pin{Repository: named.Name()}
+	if tagged, ok := ref.(reference.Tagged); ok {
+		pin.Tag = tagged.Tag()
+	}
+	return pin, nil
+}
+
+func (p Pin) String() string {
+	if p.Tag == "" {
+		return p.Repository
+	}
+	return p.Repository + ":" + p.Tag
+}
+
+// same reports whether p and other pin the same content.
+func (p Pin) same(other Pin) bool {
+	return p.Repository == other.Repository && p.Tag == other.Tag
+}
+
+// pinStore keeps the pins of the configuration, and the ones added through
+// the admin API in the storage shared by the registry instances.
+type pinStore struct {
+	driver     driver.StorageDriver
+	configured []Pin
+
+	mu sync.Mutex // serializes the updates of the stored pins
+}
+
+func newPinStore(driver driver.StorageDriver, pins []string) (*pinStore, error) {
+	ps := &pinStore{driver: driver}
+	for _, s := range pins {
+		pin, err := ParsePin(s)
+		if err != nil {
+			return nil, err
+		}
+		pin.Configured = true
+		ps.configured = append(ps.configured, pin)
+	}
+	return ps, nil
+}
+
+// list returns the configured pins, then the stored ones.
+func (ps *pinStore) list(ctx context.Context) ([]Pin, error) {
+	stored, err := ps.read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(slices.Clone(ps.configured), stored...), nil
+}
+
+// add stores the pin, unless it already exists.
+func (ps *pinStore) add(ctx context.Context, pin Pin) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	pin.Configured = false
+	if slices.ContainsFunc(ps.configured, pin.same) {
+		return nil
+	}
+	stored, err := ps.read(ctx)
+	if err != nil {
+		return err
+	}
+	if slices.ContainsFunc(stored, pin.same) {
+		return nil
+	}
+	return ps.write(ctx, append(stored, pin))
+}
+
+// remove removes a stored pin.
+func (ps *pinStore) remove(ctx context.Context, pin Pin) error {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	if slices.ContainsFunc(ps.configured, pin.same) {
+		return fmt.Errorf("%w: %s", ErrPinConfigured, pin)
+	}
+	stored, err := ps.read(ctx)
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(stored, pin.same)
+	if i < 0 {
+		return fmt.Errorf("%w: %s", ErrPinUnknown, pin)
+	}
+	return ps.write(ctx, slices.Delete(stored, i, i+1))
+}
+
+func (ps *pinStore) read(ctx context.Context) ([]Pin, error) {
+	p, err := ps.driver.GetContent(ctx, pinsPath)
+	if err != nil {
+		if _, ok := err.(driver.PathNotFoundError); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var pins []Pin
+	if err := json.Unmarshal(p, &pins); err != nil {
+		return nil, err
+	}
+	return pins, nil
+}
+
+func (ps *pinStore) write(ctx context.Context, pins []Pin) error {
+	p, err := json.Marshal(pins)
+	if err != nil {
+		return err
+	}
+	return ps.driver.PutContent(ctx, pinsPath, p)
+}
+
+// retainPinned returns scheduler.ErrRetain if the expired content is pinned,
+// so that the scheduler keeps it.
+func (pr *proxyingRegistry) retainPinned(ctx context.Context, ref reference.Canonical) error {
+	pinned, err := pr.pinned(ctx, ref)
+	if err != nil {
+		// Better keep the content a while longer than lose pinned content.
+		dcontext.GetLogger(ctx).Errorf("error checking the pins of %s: %v", ref, err)
+		return scheduler.ErrRetain
+	}
+	if pinned {
+		return scheduler.ErrRetain
+	}
+	return nil
+}
+
+// pinned reports whether the content is pinned: its repository is pinned,
+// or a pinned tag of it references the content.
+func (pr *proxyingRegistry) pinned(ctx context.Context, ref reference.Canonical) (bool, error) {
+	pins, err := pr.pins.list(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var tags []string
+	for _, pin := range pins {
+		if pin.Repository != ref.Name() {
+			continue
+		}
+		if pin.Tag == "" {
+			return true, nil
+		}
+		tags = append(tags, pin.Tag)
+	}
+	if len(tags) == 0 {
+		return false, nil
+	}
+
+	repo, err := pr.embedded.Repository(ctx, ref)
+	if err != nil {
+		return false, err
+	}
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, tag := range tags {
+		desc, err := repo.Tags(ctx).Get(ctx, tag)
+		if err != nil {
+			if _, ok := err.(distribution.ErrTagUnknown); ok {
+				continue
+			}
+			return false, err
+		}
+		found, err := references(ctx, manifests, desc.Digest, ref.Digest())
+		if err != nil || found {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+// references reports whether the manifest is target or references it,
+// directly or through its index children.
+func references(ctx context.Context, manifests distribution.ManifestService, dgst, target digest.Digest) (bool, error) {
+	if dgst == target {
+		return true, nil
+	}
+
+	m, err := manifests.Get(ctx, dgst)
+	if err != nil {
+		if _, ok := err.(distribution.ErrManifestUnknownRevision); ok {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, desc := range m.References() {
+		if desc.Digest == target {
+			return true, nil
+		}
+		if !isManifestMediaType(desc.MediaType) {
+			continue
+		}
+		found, err := references(ctx, manifests, desc.Digest, target)
+		if err != nil || found {
+			return found, err
+		}
+	}
+	return false, nil
+}
+
+// isManifestMediaType reports whether the descriptor of the media type
+// references a manifest rather than a blob.
+func isManifestMediaType(mediaType string) bool {
+	return slices.Contains(distribution.ManifestMediaTypes(), mediaType)
+}
@@ -0,0 +1,155 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/registry/proxy/scheduler"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/distribution/reference"
+	"github.com/opencontainers/go-digest"
+)
+
+func TestParsePin(t *testing.T) {
+	for _, tc := range []struct {
+		s   string
+		pin Pin
+		err bool
+	}{
+		{s: "library/busybox", pin: Pin{Repository: "library/busybox"}},
+		{s: "library/busybox:latest", pin: Pin{Repository: "library/busybox", Tag: "latest"}},
+		{s: "localhost:5000/busybox:1.36", pin: Pin{Repository: "localhost:5000/busybox", Tag: "1.36"}},
+		{s: "library/busybox@sha256:0000000000000000000000000000000000000000000000000000000000000000", err: true},
+		{s: "Busybox", err: true},
+		{s: "", err: true},
+	} {
+		pin, err := ParsePin(tc.s)
+		if tc.err {
+			if !errors.Is(err, ErrPinInvalid) {
+				t.Errorf("ParsePin(%q): expected invalid pin error, got %v", tc.s, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParsePin(%q): %v", tc.s, err)
+			continue
+		}
+		if pin != tc.pin {
+			t.Errorf("ParsePin(%q) = %+v, want %+v", tc.s, pin, tc.pin)
+		}
+		if pin.String() != tc.s {
+			t.Errorf("%+v.String() = %q, want %q", pin, pin.String(), tc.s)
+		}
+	}
+}
+
+func TestPinStore(t *testing.T) {
+	ctx := context.Background()
+	d := inmemory.New()
+	ps, err := newPinStore(d, []string{"library/busybox"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	alpine := Pin{Repository: "library/alpine", Tag: "3"}
+	for i := 0; i < 2; i++ {
+		if err := ps.add(ctx, alpine); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ps.add(ctx, Pin{Repository: "library/busybox"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The stored pins are shared with the other instances.
+	other, err := newPinStore(d, []string{"library/busybox"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pins, err := other.list(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Pin{{Repository: "library/busybox", Configured: true}, alpine}
+	if len(pins) != len(expected) || pins[0] != expected[0] || pins[1] != expected[1] {
+		t.Fatalf("unexpected pins: %+v != %+v", pins, expected)
+	}
+
+	if err := other.remove(ctx, Pin{Repository: "library/busybox"}); !errors.Is(err, ErrPinConfigured) {
+		t.Fatalf("expected configured pin error, got %v", err)
+	}
+	if err := other.remove(ctx, alpine); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.remove(ctx, alpine); !errors.Is(err, ErrPinUnknown) {
+		t.Fatalf("expected unknown pin error, got %v", err)
+	}
+	pins, err = ps.list(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pins) != 1 {
+		t.Fatalf("unexpected pins: %+v", pins)
+	}
+}
+
+func TestPinned(t *testing.T) {
+	ctx := context.Background()
+	us := newUpstreamStub(t, 100)
+	amd64, amd64Blobs := us.addManifest("")
+	arm64, arm64Blobs := us.addManifest("")
+	index := us.addIndex("latest", amd64, arm64)
+	old, oldBlobs := us.addManifest("old")
+
+	pr, _ := newStubCache(t, us, configuration.Proxy{
+		WarmUp: configuration.WarmUp{
+			References: []string{"library/busybox:latest", "library/busybox:old"},
+		},
+	})
+	waitWarmUp(t, pr)
+
+	canonical := func(name string, dgst digest.Digest) reference.Canonical {
+		named, err := reference.WithName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ref, err := reference.WithDigest(named, dgst)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ref
+	}
+	checkPinned := func(ref reference.Canonical, expected bool) {
+		t.Helper()
+		pinned, err := pr.pinned(ctx, ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pinned != expected {
+			t.Errorf("pinned(%s) = %t, want %t", ref, pinned, expected)
+		}
+		err = pr.retainPinned(ctx, ref)
+		if retained := errors.Is(err, scheduler.ErrRetain); retained != expected {
+			t.Errorf("retainPinned(%s) = %v", ref, err)
+		}
+	}
+
+	checkPinned(canonical("library/busybox", index.Digest), false)
+
+	if err := pr.Pin(ctx, Pin{Repository: "library/busybox", Tag: "latest"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, dgst := range []digest.Digest{index.Digest, amd64.Digest, arm64.Digest, amd64Blobs[1].Digest, arm64Blobs[0].Digest} {
+		checkPinned(canonical("library/busybox", dgst), true)
+	}
+	checkPinned(canonical("library/busybox", old.Digest), false)
+	checkPinned(canonical("library/busybox", oldBlobs[1].Digest), false)
+	checkPinned(canonical("library/alpine", amd64.Digest), false)
+
+	if err := pr.Pin(ctx, Pin{Repository: "library/busybox"}); err != nil {
+		t.Fatal(err)
+	}
+	checkPinned(canonical("library/busybox", oldBlobs[1].Digest), true)
+}
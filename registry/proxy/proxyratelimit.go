@@ -0,0 +1,202 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/api/errcode"
+)
+
+const (
+	// Upstream registries such as Docker Hub report the rate limit of the
+	// credential in these headers, as "<count>;w=<window in seconds>".
+	rateLimitLimitHeader     = "RateLimit-Limit"
+	rateLimitRemainingHeader = "RateLimit-Remaining"
+
+	// anonymousCredential names the rate limit of anonymous pulls.
+	anonymousCredential = "anonymous"
+
+	// defaultRateLimitBackoff is how long a budget is assumed to last when
+	// the upstream tells neither its window nor when to retry.
+	defaultRateLimitBackoff = time.Minute
+)
+
+// rateLimits tracks the upstream rate limits of the credentials of a pull
+// through cache.
+type rateLimits struct {
+	config configuration.RateLimit
+
+	mu     sync.Mutex
+	limits map[string]*rateLimit
+}
+
+func newRateLimits(config configuration.RateLimit) *rateLimits {
+	return &rateLimits{
+		config: config,
+		limits: make(map[string]*rateLimit),
+	}
+}
+
+// get returns the rate limit of the credential, shared by the repositories
+// fetched with it.
+func (rls *rateLimits) get(credential string) *rateLimit {
+	if credential == "" {
+		credential = anonymousCredential
+	}
+
+	rls.mu.Lock()
+	defer rls.mu.Unlock()
+
+	rl, ok := rls.limits[credential]
+	if !ok {
+		rl = &rateLimit{
+			credential: credential,
+			threshold:  rls.config.Threshold,
+			maxWait:    rls.config.MaxWait,
+			remaining:  -1,
+		}
+		rls.limits[credential] = rl
+	}
+	return rl
+}
+
+// rateLimit is the upstream rate limit of a credential. The budget is
+// unknown, and never throttled, until the upstream reports it. A nil
+// rateLimit is never throttled.
+type rateLimit struct {
+	credential string
+	threshold  int
+	maxWait    time.Duration
+
+	mu        sync.Mutex
+	limit     int
+	remaining int
+	reset     time.Time // when the budget is assumed to be replenished
+}
+
+// transport returns a transport recording the rate limit reported in the
+// responses of base.
+func (rl *rateLimit) transport(base http.RoundTripper) http.RoundTripper {
+	if rl == nil {
+		return base
+	}
+	return &rateLimitTransport{base: base, rateLimit: rl}
+}
+
+// update records the rate limit reported in the response.
+func (rl *rateLimit) update(resp *http.Response, now time.Time) {
+	limit, _, hasLimit := parseRateLimit(resp.Header.Get(rateLimitLimitHeader))
+	remaining, window, hasRemaining := parseRateLimit(resp.Header.Get(rateLimitRemainingHeader))
+	if resp.StatusCode == http.StatusTooManyRequests {
+		hasRemaining = true
+		remaining = 0
+		if retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && retryAfter > 0 {
+			window = time.Duration(retryAfter) * time.Second
+		}
+	}
+	if window <= 0 {
+		window = defaultRateLimitBackoff
+	}
+	if !hasLimit && !hasRemaining {
+		return
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if hasLimit {
+		rl.limit = limit
+		rateLimitLimit.WithValues(rl.credential).Set(float64(limit))
+	}
+	if hasRemaining {
+		rl.remaining = remaining
+		rl.reset = now.Add(window)
+		rateLimitRemaining.WithValues(rl.credential).Set(float64(remaining))
+	}
+}
+
+// throttled returns how long until the budget is replenished, if it is at or
+// under the threshold.
+func (rl *rateLimit) throttled(now time.Time) (time.Duration, bool) {
+	if rl == nil {
+		return 0, false
+	}
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	if rl.remaining < 0 || rl.remaining > rl.threshold || !now.Before(rl.reset) {
+		return 0, false
+	}
+	return rl.reset.Sub(now), true
+}
+
+// wait blocks while the budget is throttled, up to the configured maximum
+// wait. Past it, it fails at once with a too many requests error.
+func (rl *rateLimit) wait(ctx context.Context) error {
+	d, throttled := rl.throttled(time.Now())
+	if !throttled {
+		return nil
+	}
+	if d > rl.maxWait {
+		return errcode.ErrorCodeTooManyRequests.WithMessage(
+			fmt.Sprintf("upstream rate limit of %s reached, retry in %s", rl.credential, d.Round(time.Second)))
+	}
+
+	dcontext.GetLogger(ctx).Infof("upstream rate limit of %s reached, waiting %s", rl.credential, d)
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// rateLimitTransport records the rate limit reported by the upstream.
+type rateLimitTransport struct {
+	base      http.RoundTripper
+	rateLimit *rateLimit
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	t.rateLimit.update(resp, time.Now())
+	return resp, nil
+}
+
+// parseRateLimit parses a rate limit header such as "100;w=21600".
+func parseRateLimit(value string) (int, time.Duration, bool) {
+	if value == "" {
+		return 0, 0, false
+	}
+
+	params := strings.Split(value, ";")
+	count, err := strconv.Atoi(strings.TrimSpace(params[0]))
+	if err != nil || count < 0 {
+		return 0, 0, false
+	}
+
+	var window time.Duration
+	for _, param := range params[1:] {
+		k, v, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || k != "w" {
+			continue
+		}
+		if seconds, err := strconv.Atoi(v); err == nil && seconds > 0 {
+			window = time.Duration(seconds) * time.Second
+		}
+	}
+	return count, window, true
+}
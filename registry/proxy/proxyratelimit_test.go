@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/registry/api/errcode"
+	"github.com/opencontainers/go-digest"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	for _, tc := range []struct {
+		value  string
+		count  int
+		window time.Duration
+		ok     bool
+	}{
+		{value: "100;w=21600", count: 100, window: 6 * time.Hour, ok: true},
+		{value: "76", count: 76, ok: true},
+		{value: "0; w=60", count: 0, window: time.Minute, ok: true},
+		{value: ""},
+		{value: "many;w=60"},
+		{value: "-1"},
+	} {
+		count, window, ok := parseRateLimit(tc.value)
+		if count != tc.count || window != tc.window || ok != tc.ok {
+			t.Errorf("parseRateLimit(%q) = %d, %s, %t, want %d, %s, %t", tc.value, count, window, ok, tc.count, tc.window, tc.ok)
+		}
+	}
+}
+
+func TestProxyRateLimitThrottle(t *testing.T) {
+	ctx := context.Background()
+	us := newUpstreamStub(t, 3)
+	latestDesc, _ := us.addManifest("latest")
+	otherDesc, _ := us.addManifest("")
+	throttledDesc, _ := us.addManifest("")
+	latest, other, throttled := latestDesc.Digest, otherDesc.Digest, throttledDesc.Digest
+
+	pr, repo := newStubCache(t, us, configuration.Proxy{RateLimit: configuration.RateLimit{Threshold: 1}})
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tags := repo.Tags(ctx)
+
+	desc, err := tags.Get(ctx, "latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Digest != latest {
+		t.Fatalf("unexpected tag resolution: %s != %s", desc.Digest, latest)
+	}
+	for _, dgst := range []digest.Digest{latest, other} {
+		if _, err := manifests.Get(ctx, dgst); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rl := pr.rateLimits.get(anonymousCredential)
+	if rl.limit != 3 || rl.remaining != 1 {
+		t.Fatalf("unexpected rate limit: %d of %d remaining", rl.remaining, rl.limit)
+	}
+
+	// The budget is at the threshold: fetches fail without reaching the
+	// upstream.
+	_, err = manifests.Get(ctx, throttled)
+	if ec, ok := err.(errcode.Error); !ok || ec.Code != errcode.ErrorCodeTooManyRequests {
+		t.Fatalf("expected too many requests error, got %v", err)
+	}
+	if n := us.count(http.MethodGet); n != 2 {
+		t.Fatalf("expected 2 manifest fetches, got %d", n)
+	}
+
+	// Cached content and tag resolutions are still served.
+	heads := us.count(http.MethodHead)
+	desc, err = tags.Get(ctx, "latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Digest != latest {
+		t.Fatalf("unexpected tag resolution: %s != %s", desc.Digest, latest)
+	}
+	if n := us.count(http.MethodHead); n != heads {
+		t.Fatalf("expected the cached tag resolution, got %d upstream requests", n-heads)
+	}
+	if _, err := manifests.Get(ctx, latest); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProxyRateLimitWait(t *testing.T) {
+	ctx := context.Background()
+	us := newUpstreamStub(t, 1)
+	us.remaining = 0
+	us.retryAfter = "1"
+	desc, _ := us.addManifest("")
+	dgst := desc.Digest
+
+	_, repo := newStubCache(t, us, configuration.Proxy{RateLimit: configuration.RateLimit{MaxWait: 5 * time.Second}})
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The upstream rate limit is only known from its 429, reported among
+	// the errors of the response.
+	_, err = manifests.Get(ctx, dgst)
+	if errs, ok := err.(errcode.Errors); !ok || len(errs) != 1 || errs[0].(errcode.Error).Code != errcode.ErrorCodeTooManyRequests {
+		t.Fatalf("expected too many requests error, got %v", err)
+	}
+
+	us.mu.Lock()
+	us.remaining = 1
+	us.mu.Unlock()
+
+	// The fetch waits for the upstream to be retried.
+	start := time.Now()
+	if _, err := manifests.Get(ctx, dgst); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 500*time.Millisecond {
+		t.Fatalf("expected the fetch to wait for the upstream, took %s", elapsed)
+	}
+	if n := us.count(http.MethodGet); n != 2 {
+		t.Fatalf("expected 2 manifest fetches, got %d", n)
+	}
+}
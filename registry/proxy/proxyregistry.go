@@ -20,6 +20,7 @@ import (
 	"github.com/distribution/distribution/v3/registry/proxy/scheduler"
 	"github.com/distribution/distribution/v3/registry/storage"
 	"github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/redis/go-redis/v9"
 )
 
 var repositoryTTL = 24 * 7 * time.Hour
@@ -30,17 +31,68 @@ type proxyingRegistry struct {
 	scheduler      *scheduler.TTLExpirationScheduler
 	ttl            *time.Duration
 	remoteURL      url.URL
+	transport      http.RoundTripper // to the remote registry and its token servers
 	authChallenger authChallenger
 	basicAuth      auth.CredentialStore
+	redis          redis.UniversalClient
+	coalescing     *coalescing
+	rateLimits     *rateLimits
+	pins           *pinStore
+	warmer         *warmer
+}
+
+// Option configures a pull through cache.
+type Option func(*proxyingRegistry)
+
+// WithRedis has the pull through cache use the redis client, shared by the
+// registry instances, to coalesce their fetches and share the expiries of the
+// cached content if configured to.
+func WithRedis(client redis.UniversalClient) Option {
+	return func(pr *proxyingRegistry) {
+		pr.redis = client
+	}
 }
 
 // NewRegistryPullThroughCache creates a registry acting as a pull through cache
-func NewRegistryPullThroughCache(ctx context.Context, registry distribution.Namespace, driver driver.StorageDriver, config configuration.Proxy) (distribution.Namespace, error) {
+func NewRegistryPullThroughCache(ctx context.Context, registry distribution.Namespace, driver driver.StorageDriver, config configuration.Proxy, options ...Option) (distribution.Namespace, error) {
 	remoteURL, err := url.Parse(config.RemoteURL)
 	if err != nil {
 		return nil, err
 	}
 
+	tr, err := newTransport(config)
+	if err != nil {
+		return nil, err
+	}
+
+	pins, err := newPinStore(driver, config.Pins)
+	if err != nil {
+		return nil, err
+	}
+
+	pr := &proxyingRegistry{
+		rateLimits: newRateLimits(config.RateLimit),
+		pins:       pins,
+	}
+	for _, option := range options {
+		option(pr)
+	}
+
+	if config.Coalescing.Enabled {
+		if pr.redis == nil {
+			return nil, fmt.Errorf("proxy coalescing requires redis")
+		}
+		waitTimeout := config.Coalescing.WaitTimeout
+		if waitTimeout <= 0 {
+			waitTimeout = defaultCoalescingWaitTimeout
+		}
+		pr.coalescing = &coalescing{
+			locker:       newRedisFetchLocker(pr.redis, config.Coalescing.LockTTL),
+			waitTimeout:  waitTimeout,
+			pollInterval: coalescingPollInterval,
+		}
+	}
+
 	v := storage.NewVacuum(ctx, driver)
 
 	var s *scheduler.TTLExpirationScheduler
@@ -56,7 +108,27 @@ func NewRegistryPullThroughCache(ctx context.Context, registry distribution.Name
 	}
 
 	if ttl != nil {
-		s = scheduler.New(ctx, driver, "/scheduler-state.json")
+		options := []scheduler.Option{
+			scheduler.WithWindow(config.Scheduler.Window),
+			scheduler.WithResolution(config.Scheduler.Resolution),
+			scheduler.WithMaxEntries(config.Scheduler.MaxEntries),
+		}
+		switch config.Scheduler.Store {
+		case "", "storage":
+		case "redis":
+			if pr.redis == nil {
+				return nil, fmt.Errorf("proxy scheduler store redis requires redis")
+			}
+			prefix := config.Scheduler.Prefix
+			if prefix == "" {
+				prefix = config.RemoteURL
+			}
+			options = append(options, scheduler.WithRedis(pr.redis, prefix))
+		default:
+			return nil, fmt.Errorf("unknown proxy scheduler store %q", config.Scheduler.Store)
+		}
+
+		s = scheduler.New(ctx, driver, "/scheduler-state.json", options...)
 		s.OnBlobExpire(func(ref reference.Reference) error {
 			var r reference.Canonical
 			var ok bool
@@ -64,6 +136,10 @@ func NewRegistryPullThroughCache(ctx context.Context, registry distribution.Name
 				return fmt.Errorf("unexpected reference type : %T", ref)
 			}
 
+			if err := pr.retainPinned(ctx, r); err != nil {
+				return err
+			}
+
 			repo, err := registry.Repository(ctx, r)
 			if err != nil {
 				return err
@@ -92,6 +168,10 @@ func NewRegistryPullThroughCache(ctx context.Context, registry distribution.Name
 				return fmt.Errorf("unexpected reference type : %T", ref)
 			}
 
+			if err := pr.retainPinned(ctx, r); err != nil {
+				return err
+			}
+
 			repo, err := registry.Repository(ctx, r)
 			if err != nil {
 				return err
@@ -120,25 +200,31 @@ func NewRegistryPullThroughCache(ctx context.Context, registry distribution.Name
 			cs, err := configureExecAuth(*config.Exec)
 			return cs, cs, err
 		default:
-			return configureAuth(config.Username, config.Password, config.RemoteURL)
+			return configureAuth(config.Username, config.Password, config.RemoteURL, tr)
 		}
 	}()
 	if err != nil {
 		return nil, err
 	}
 
-	return &proxyingRegistry{
-		embedded:  registry,
-		scheduler: s,
-		ttl:       ttl,
+	pr.embedded = registry
+	pr.scheduler = s
+	pr.ttl = ttl
+	pr.remoteURL = *remoteURL
+	pr.transport = tr
+	pr.authChallenger = &remoteAuthChallenger{
 		remoteURL: *remoteURL,
-		authChallenger: &remoteAuthChallenger{
-			remoteURL: *remoteURL,
-			cm:        challenge.NewSimpleManager(),
-			cs:        cs,
-		},
-		basicAuth: b,
-	}, nil
+		transport: tr,
+		cm:        challenge.NewSimpleManager(),
+		cs:        cs,
+	}
+	pr.basicAuth = b
+
+	pr.warmer = newWarmer(ctx, pr, config.WarmUp)
+	if pr.warmer.configured() {
+		go pr.warmer.loop()
+	}
+	return pr, nil
 }
 
 func (pr *proxyingRegistry) Scope() distribution.Scope {
@@ -149,11 +235,24 @@ func (pr *proxyingRegistry) Repositories(ctx context.Context, repos []string, la
 	return pr.embedded.Repositories(ctx, repos, last)
 }
 
+func (pr *proxyingRegistry) RepositoriesWithPrefix(ctx context.Context, repos []string, prefix, last string) (n int, err error) {
+	lister, ok := pr.embedded.(distribution.RepositoryPrefixLister)
+	if !ok {
+		return 0, distribution.ErrUnsupported
+	}
+	return lister.RepositoriesWithPrefix(ctx, repos, prefix, last)
+}
+
 func (pr *proxyingRegistry) Repository(ctx context.Context, name reference.Named) (distribution.Repository, error) {
 	c := pr.authChallenger
 
+	// The rate limit is the one of the credential the upstream is pulled
+	// with, if any.
+	username, _ := pr.basicAuth.Basic(&pr.remoteURL)
+	rateLimit := pr.rateLimits.get(username)
+
 	tkopts := auth.TokenHandlerOptions{
-		Transport:   http.DefaultTransport,
+		Transport:   pr.transport,
 		Credentials: c.credentialStore(),
 		Scopes: []auth.Scope{
 			auth.RepositoryScope{
@@ -164,7 +263,7 @@ func (pr *proxyingRegistry) Repository(ctx context.Context, name reference.Named
 		Logger: dcontext.GetLogger(ctx),
 	}
 
-	tr := transport.NewTransport(http.DefaultTransport,
+	tr := transport.NewTransport(rateLimit.transport(pr.transport),
 		auth.NewAuthorizer(c.challengeManager(),
 			auth.NewTokenHandlerWithOptions(tkopts),
 			auth.NewBasicHandler(pr.basicAuth)))
@@ -196,6 +295,7 @@ func (pr *proxyingRegistry) Repository(ctx context.Context, name reference.Named
 			ttl:            pr.ttl,
 			repositoryName: name,
 			authChallenger: pr.authChallenger,
+			coalescing:     pr.coalescing,
 		},
 		manifests: &proxyManifestStore{
 			repositoryName:  name,
@@ -205,12 +305,14 @@ func (pr *proxyingRegistry) Repository(ctx context.Context, name reference.Named
 			scheduler:       pr.scheduler,
 			ttl:             pr.ttl,
 			authChallenger:  pr.authChallenger,
+			rateLimit:       rateLimit,
 		},
 		name: name,
 		tags: &proxyTagService{
 			localTags:      localRepo.Tags(ctx),
 			remoteTags:     remoteRepo.Tags(ctx),
 			authChallenger: pr.authChallenger,
+			rateLimit:      rateLimit,
 		},
 	}, nil
 }
@@ -229,9 +331,52 @@ type Closer interface {
 }
 
 func (pr *proxyingRegistry) Close() error {
+	pr.warmer.stop()
 	return pr.scheduler.Stop()
 }
 
+// Cache manages the content kept by a pull through cache.
+type Cache interface {
+	// Pins returns the pins of the configuration, then the ones added
+	// with Pin.
+	Pins(ctx context.Context) ([]Pin, error)
+
+	// Pin pins a repository or tag.
+	Pin(ctx context.Context, pin Pin) error
+
+	// Unpin removes a pin added with Pin.
+	Unpin(ctx context.Context, pin Pin) error
+
+	// WarmUp starts the warm-up job in the background, fetching the
+	// references into the cache, or the configured ones if there are none.
+	WarmUp(refs []string) (WarmUpStatus, error)
+
+	// WarmUpStatus describes the last run of the warm-up job.
+	WarmUpStatus() WarmUpStatus
+}
+
+var _ Cache = &proxyingRegistry{}
+
+func (pr *proxyingRegistry) Pins(ctx context.Context) ([]Pin, error) {
+	return pr.pins.list(ctx)
+}
+
+func (pr *proxyingRegistry) Pin(ctx context.Context, pin Pin) error {
+	return pr.pins.add(ctx, pin)
+}
+
+func (pr *proxyingRegistry) Unpin(ctx context.Context, pin Pin) error {
+	return pr.pins.remove(ctx, pin)
+}
+
+func (pr *proxyingRegistry) WarmUp(refs []string) (WarmUpStatus, error) {
+	return pr.warmer.start(refs)
+}
+
+func (pr *proxyingRegistry) WarmUpStatus() WarmUpStatus {
+	return pr.warmer.currentStatus()
+}
+
 // authChallenger encapsulates a request to the upstream to establish credential challenges
 type authChallenger interface {
 	tryEstablishChallenges(context.Context) error
@@ -241,6 +386,7 @@ type authChallenger interface {
 
 type remoteAuthChallenger struct {
 	remoteURL url.URL
+	transport http.RoundTripper
 	sync.Mutex
 	cm challenge.Manager
 	cs auth.CredentialStore
@@ -271,7 +417,7 @@ func (r *remoteAuthChallenger) tryEstablishChallenges(ctx context.Context) error
 	}
 
 	// establish challenge type with upstream
-	if err := ping(r.cm, remoteURL.String(), challengeHeader); err != nil {
+	if err := ping(r.cm, r.transport, remoteURL.String(), challengeHeader); err != nil {
 		return err
 	}
 
@@ -2,6 +2,7 @@ package proxy
 
 import (
 	"context"
+	"time"
 
 	"github.com/distribution/distribution/v3"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
@@ -12,14 +13,22 @@ type proxyTagService struct {
 	localTags      distribution.TagService
 	remoteTags     distribution.TagService
 	authChallenger authChallenger
+	rateLimit      *rateLimit
 }
 
 var _ distribution.TagService = proxyTagService{}
 
 // Get attempts to get the most recent digest for the tag by checking the remote
 // tag service first and then caching it locally.  If the remote is unavailable
-// the local association is returned
+// the local association is returned. While the upstream rate limit is
+// throttled, the local association is preferred to spare the budget.
 func (pt proxyTagService) Get(ctx context.Context, tag string) (v1.Descriptor, error) {
+	if _, throttled := pt.rateLimit.throttled(time.Now()); throttled {
+		if desc, err := pt.localTags.Get(ctx, tag); err == nil {
+			return desc, nil
+		}
+	}
+
 	err := pt.authChallenger.tryEstablishChallenges(ctx)
 	if err == nil {
 		desc, err := pt.remoteTags.Get(ctx, tag)
@@ -0,0 +1,97 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/distribution/distribution/v3/configuration"
+)
+
+// newTransport returns the transport of the requests to the remote registry
+// and its token servers: Go's default transport, with the TLS settings,
+// proxy and timeouts of the configuration.
+func newTransport(config configuration.Proxy) (*http.Transport, error) {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+
+	if config.HTTPProxy != "" {
+		proxyURL, err := url.Parse(config.HTTPProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy httpproxy: %w", err)
+		}
+		tr.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	timeouts := config.Timeouts
+	if timeouts.Dial > 0 {
+		tr.DialContext = (&net.Dialer{
+			Timeout:   timeouts.Dial,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+	if timeouts.TLSHandshake > 0 {
+		tr.TLSHandshakeTimeout = timeouts.TLSHandshake
+	}
+	if timeouts.ResponseHeader > 0 {
+		tr.ResponseHeaderTimeout = timeouts.ResponseHeader
+	}
+	if timeouts.IdleConn > 0 {
+		tr.IdleConnTimeout = timeouts.IdleConn
+	}
+
+	tlsConfig, err := newTLSConfig(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		tr.TLSClientConfig = tlsConfig
+	}
+	return tr, nil
+}
+
+// newTLSConfig returns the TLS configuration of the connections to the remote
+// registry, or nil if the default one is used.
+func newTLSConfig(config configuration.ProxyTLS) (*tls.Config, error) {
+	if len(config.CAs) == 0 && config.Certificate == "" && config.Key == "" && !config.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+
+	if len(config.CAs) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		for _, ca := range config.CAs {
+			caPem, err := os.ReadFile(ca)
+			if err != nil {
+				return nil, fmt.Errorf("failed reading proxy CA: %w", err)
+			}
+			if ok := pool.AppendCertsFromPEM(caPem); !ok {
+				return nil, fmt.Errorf("no certificate found in proxy CA %s", ca)
+			}
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.Certificate != "" || config.Key != "" {
+		if config.Certificate == "" || config.Key == "" {
+			return nil, fmt.Errorf("proxy tls requires both a certificate and a key")
+		}
+		cert, err := tls.LoadX509KeyPair(config.Certificate, config.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed loading proxy client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,193 @@
+package proxy
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/registry/storage"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+)
+
+// writePEM writes a PEM block to a file of the test's directory, returning
+// its path.
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newClientCertificate generates a self-signed client certificate, returning
+// it with the paths of its certificate and key files.
+func newClientCertificate(t *testing.T) (*x509.Certificate, string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "proxy"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, writePEM(t, "client.pem", "CERTIFICATE", der), writePEM(t, "client.key", "EC PRIVATE KEY", keyDer)
+}
+
+func newTestCache(config configuration.Proxy) error {
+	ctx := context.Background()
+	localRegistry, err := storage.NewRegistry(ctx, inmemory.New())
+	if err != nil {
+		return err
+	}
+	_, err = NewRegistryPullThroughCache(ctx, localRegistry, inmemory.New(), config)
+	return err
+}
+
+func TestProxyTransportTLS(t *testing.T) {
+	ctx := context.Background()
+	clientCert, certFile, keyFile := newClientCertificate(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	// The upstream uses a private PKI, and requires mutual TLS.
+	us := newUnstartedUpstreamStub(100)
+	us.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	us.StartTLS()
+	t.Cleanup(us.Close)
+	desc, _ := us.addManifest("latest")
+	caFile := writePEM(t, "ca.pem", "CERTIFICATE", us.Certificate().Raw)
+
+	ttl := time.Duration(0)
+	for _, tc := range []struct {
+		name   string
+		tls    configuration.ProxyTLS
+		failed bool
+	}{
+		{name: "untrusted", tls: configuration.ProxyTLS{Certificate: certFile, Key: keyFile}, failed: true},
+		{name: "no client certificate", tls: configuration.ProxyTLS{CAs: []string{caFile}}, failed: true},
+		{name: "no key", tls: configuration.ProxyTLS{CAs: []string{caFile}, Certificate: certFile}, failed: true},
+		{name: "trusted", tls: configuration.ProxyTLS{CAs: []string{caFile}, Certificate: certFile, Key: keyFile}},
+		{name: "insecure", tls: configuration.ProxyTLS{InsecureSkipVerify: true, Certificate: certFile, Key: keyFile}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config := configuration.Proxy{RemoteURL: us.URL, TTL: &ttl, TLS: tc.tls}
+			if tc.failed {
+				if err := newTestCache(config); err == nil {
+					t.Fatal("expected the upstream to be unreachable")
+				}
+				return
+			}
+
+			_, repo := newStubCache(t, us, config)
+			manifests, err := repo.Manifests(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := manifests.Get(ctx, desc.Digest); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestProxyTransportHTTPProxy(t *testing.T) {
+	ctx := context.Background()
+	us := newUpstreamStub(t, 100)
+	desc, _ := us.addManifest("latest")
+
+	var mu sync.Mutex
+	proxied := 0
+	httpProxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		proxied++
+		mu.Unlock()
+
+		r.RequestURI = ""
+		resp, err := http.DefaultTransport.RoundTrip(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		for k, v := range resp.Header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	defer httpProxy.Close()
+
+	_, repo := newStubCache(t, us, configuration.Proxy{HTTPProxy: httpProxy.URL})
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manifests.Get(ctx, desc.Digest); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if proxied < 2 {
+		t.Fatalf("expected the upstream to be reached through the proxy, %d requests proxied", proxied)
+	}
+}
+
+func TestProxyTransportTimeouts(t *testing.T) {
+	ctx := context.Background()
+	us := newUpstreamStub(t, 100)
+	desc, _ := us.addManifest("latest")
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/" {
+			time.Sleep(500 * time.Millisecond)
+		}
+		us.serveHTTP(w, r)
+	}))
+	defer slow.Close()
+	us.URL = slow.URL
+
+	_, repo := newStubCache(t, us, configuration.Proxy{
+		Timeouts: configuration.ProxyTimeouts{ResponseHeader: 50 * time.Millisecond},
+	})
+	manifests, err := repo.Manifests(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := manifests.Get(ctx, desc.Digest); err == nil {
+		t.Fatal("expected the fetch to time out")
+	}
+}
@@ -0,0 +1,328 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/reference"
+	"github.com/opencontainers/go-digest"
+)
+
+// ErrWarmUpRunning is returned when starting the warm-up job while it runs.
+var ErrWarmUpRunning = errors.New("warm-up is running")
+
+// WarmUpStatus describes the last run of the warm-up job.
+type WarmUpStatus struct {
+	Running  bool      `json:"running"`
+	Started  time.Time `json:"started,omitempty"`
+	Finished time.Time `json:"finished,omitempty"`
+
+	// References is the number of references the run fetches.
+	References int `json:"references"`
+
+	// Manifests and Blobs are the numbers of manifests and blobs found in
+	// the cache or fetched into it.
+	Manifests int `json:"manifests"`
+	Blobs     int `json:"blobs"`
+
+	// Errors lists the references which could not be fetched.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// warmer runs the warm-up job of a pull through cache, fetching tags and the
+// content they reference into the cache.
+type warmer struct {
+	pr     *proxyingRegistry
+	ctx    context.Context
+	config configuration.WarmUp
+
+	mu     sync.Mutex
+	status WarmUpStatus
+
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+func newWarmer(ctx context.Context, pr *proxyingRegistry, config configuration.WarmUp) *warmer {
+	return &warmer{
+		pr:     pr,
+		ctx:    ctx,
+		config: config,
+		done:   make(chan struct{}),
+	}
+}
+
+// configured reports whether the configuration lists references to fetch.
+func (w *warmer) configured() bool {
+	return len(w.config.References) > 0 || w.config.File != ""
+}
+
+// loop runs the job at once, then on every interval, until stopped.
+func (w *warmer) loop() {
+	var tick <-chan time.Time
+	if w.config.Interval > 0 {
+		ticker := time.NewTicker(w.config.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		if err := w.begin(); err != nil {
+			dcontext.GetLogger(w.ctx).Infof("skipping scheduled warm-up: %v", err)
+		} else {
+			w.run(nil)
+		}
+
+		select {
+		case <-tick:
+		case <-w.done:
+			return
+		}
+	}
+}
+
+func (w *warmer) stop() {
+	w.stopOnce.Do(func() {
+		close(w.done)
+	})
+}
+
+// start runs the job in the background, fetching the references, or the
+// configured ones if there are none.
+func (w *warmer) start(refs []string) (WarmUpStatus, error) {
+	if err := w.begin(); err != nil {
+		return w.currentStatus(), err
+	}
+	go w.run(refs)
+	return w.currentStatus(), nil
+}
+
+func (w *warmer) currentStatus() WarmUpStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	status := w.status
+	status.Errors = append([]string(nil), w.status.Errors...)
+	return status
+}
+
+// begin marks a run as started, unless one is running.
+func (w *warmer) begin() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.status.Running {
+		return ErrWarmUpRunning
+	}
+	w.status = WarmUpStatus{
+		Running: true,
+		Started: time.Now(),
+	}
+	return nil
+}
+
+// run fetches the references into the cache. It is started with begin.
+func (w *warmer) run(refs []string) {
+	ctx := w.ctx
+	log := dcontext.GetLogger(ctx)
+
+	defer func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		w.status.Running = false
+		w.status.Finished = time.Now()
+		log.Infof("warm-up finished: %d references, %d manifests, %d blobs, %d errors",
+			w.status.References, w.status.Manifests, w.status.Blobs, len(w.status.Errors))
+	}()
+
+	if refs == nil {
+		var err error
+		refs, err = w.references()
+		if err != nil {
+			log.Errorf("error reading warm-up references: %v", err)
+			w.failed(err.Error())
+			return
+		}
+	}
+
+	w.mu.Lock()
+	w.status.References = len(refs)
+	w.mu.Unlock()
+
+	for _, ref := range refs {
+		select {
+		case <-w.done:
+			return
+		default:
+		}
+
+		if err := w.warm(ctx, ref); err != nil {
+			log.Errorf("error warming up %s: %v", ref, err)
+			w.failed(fmt.Sprintf("%s: %v", ref, err))
+		}
+	}
+}
+
+func (w *warmer) failed(msg string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.status.Errors = append(w.status.Errors, msg)
+}
+
+func (w *warmer) count(manifests, blobs int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.status.Manifests += manifests
+	w.status.Blobs += blobs
+}
+
+// references returns the configured references, then the ones of the
+// configured file.
+func (w *warmer) references() ([]string, error) {
+	refs := append([]string(nil), w.config.References...)
+	if w.config.File == "" {
+		return refs, nil
+	}
+
+	f, err := os.Open(w.config.File)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		refs = append(refs, line)
+	}
+	return refs, scanner.Err()
+}
+
+// warm fetches a tag, "name:tag", or a manifest, "name@digest", with the
+// content it references.
+func (w *warmer) warm(ctx context.Context, s string) error {
+	ref, err := reference.Parse(s)
+	if err != nil {
+		return err
+	}
+	named, ok := ref.(reference.Named)
+	if !ok {
+		return fmt.Errorf("no repository name")
+	}
+
+	repo, err := w.pr.Repository(ctx, reference.TrimNamed(named))
+	if err != nil {
+		return err
+	}
+	pr := repo.(*proxiedRepository)
+
+	var dgst digest.Digest
+	switch ref := ref.(type) {
+	case reference.Digested:
+		dgst = ref.Digest()
+	case reference.Tagged:
+		desc, err := pr.tags.Get(ctx, ref.Tag())
+		if err != nil {
+			return err
+		}
+		dgst = desc.Digest
+	default:
+		return fmt.Errorf("no tag or digest")
+	}
+	return w.warmManifest(ctx, pr, dgst)
+}
+
+// warmManifest fetches the manifest, its index children and its blobs.
+func (w *warmer) warmManifest(ctx context.Context, repo *proxiedRepository, dgst digest.Digest) error {
+	m, err := repo.manifests.Get(ctx, dgst)
+	if err != nil {
+		return err
+	}
+	w.count(1, 0)
+
+	blobs := repo.blobStore.(*proxyBlobStore)
+	for _, desc := range m.References() {
+		if isManifestMediaType(desc.MediaType) {
+			if err := w.warmManifest(ctx, repo, desc.Digest); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := blobs.fetch(ctx, desc.Digest); err != nil {
+			return fmt.Errorf("blob %s: %w", desc.Digest, err)
+		}
+		w.count(0, 1)
+	}
+	return nil
+}
+
+// maxFetchAttempts bounds the attempts of the warm-up job to store a blob,
+// which is served without being stored while clients fetch it as well.
+const maxFetchAttempts = 3
+
+// errBlobNotStored is returned when the warm-up job could not store a blob.
+var errBlobNotStored = errors.New("blob not stored")
+
+// fetch stores the blob in the cache, unless it is already there. A blob a
+// client is fetching is waited for, rather than streamed from the remote
+// without being stored.
+func (pbs *proxyBlobStore) fetch(ctx context.Context, dgst digest.Digest) error {
+	for attempt := 0; ; attempt++ {
+		if _, err := pbs.localStore.Stat(ctx, dgst); err == nil {
+			return nil
+		}
+		if attempt == maxFetchAttempts {
+			return errBlobNotStored
+		}
+
+		mu.Lock()
+		done, ok := inflight[dgst]
+		mu.Unlock()
+		if ok {
+			select {
+			case <-done:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			continue
+		}
+
+		r, err := http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+		if err != nil {
+			return err
+		}
+		if err := pbs.ServeBlob(ctx, discardResponseWriter{header: make(http.Header)}, r, dgst); err != nil {
+			return err
+		}
+	}
+}
+
+// discardResponseWriter discards the blobs fetched without a client.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w discardResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w discardResponseWriter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (w discardResponseWriter) WriteHeader(int) {}
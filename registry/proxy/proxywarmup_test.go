@@ -0,0 +1,173 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// waitWarmUp waits for the warm-up job to finish its run, returning its
+// status.
+func waitWarmUp(t *testing.T, pr *proxyingRegistry) WarmUpStatus {
+	t.Helper()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		status := pr.WarmUpStatus()
+		if !status.Running && !status.Finished.IsZero() {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("warm-up did not finish")
+	return WarmUpStatus{}
+}
+
+func TestWarmUp(t *testing.T) {
+	ctx := context.Background()
+	us := newUpstreamStub(t, 100)
+	amd64, amd64Blobs := us.addManifest("")
+	arm64, arm64Blobs := us.addManifest("")
+	index := us.addIndex("latest", amd64, arm64)
+	pinned, pinnedBlobs := us.addManifest("")
+
+	file := filepath.Join(t.TempDir(), "references")
+	if err := os.WriteFile(file, []byte("# warmed from the file\n\nlibrary/busybox@"+pinned.Digest.String()+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	pr, repo := newStubCache(t, us, configuration.Proxy{
+		WarmUp: configuration.WarmUp{
+			References: []string{"library/busybox:latest", "library/busybox:unknown"},
+			File:       file,
+		},
+	})
+	status := waitWarmUp(t, pr)
+	if status.References != 3 || status.Manifests != 4 || status.Blobs != 6 || len(status.Errors) != 1 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+
+	// Everything is served from the cache.
+	gets := us.count(http.MethodGet)
+	local, err := pr.embedded.Repository(ctx, repo.Named())
+	if err != nil {
+		t.Fatal(err)
+	}
+	localManifests, err := local.Manifests(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dgst := range []digest.Digest{index.Digest, amd64.Digest, arm64.Digest, pinned.Digest} {
+		if _, err := localManifests.Get(ctx, dgst); err != nil {
+			t.Errorf("manifest %s not cached: %v", dgst, err)
+		}
+	}
+	for _, blobs := range [][]v1.Descriptor{amd64Blobs, arm64Blobs, pinnedBlobs} {
+		for _, desc := range blobs {
+			if _, err := local.Blobs(ctx).Stat(ctx, desc.Digest); err != nil {
+				t.Errorf("blob %s not cached: %v", desc.Digest, err)
+			}
+		}
+	}
+	desc, err := local.Tags(ctx).Get(ctx, "latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.Digest != index.Digest {
+		t.Fatalf("unexpected tag resolution: %s != %s", desc.Digest, index.Digest)
+	}
+
+	// A requested run fetches the given references, and nothing cached.
+	if _, err := pr.WarmUp([]string{"library/busybox:latest"}); err != nil {
+		t.Fatal(err)
+	}
+	status = waitWarmUp(t, pr)
+	if status.References != 1 || status.Manifests != 3 || status.Blobs != 4 || len(status.Errors) != 0 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if n := us.count(http.MethodGet); n != gets {
+		t.Fatalf("expected no manifest fetch, got %d", n-gets)
+	}
+}
+
+func TestWarmUpRunning(t *testing.T) {
+	us := newUpstreamStub(t, 100)
+	pr, _ := newStubCache(t, us, configuration.Proxy{})
+
+	if status := pr.WarmUpStatus(); status.Running || !status.Started.IsZero() {
+		t.Fatalf("unexpected status of an unconfigured warm-up: %+v", status)
+	}
+
+	if err := pr.warmer.begin(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := pr.WarmUp(nil); !errors.Is(err, ErrWarmUpRunning) {
+		t.Fatalf("expected warm-up running error, got %v", err)
+	}
+}
+
+// TestProxyStoreFetchInFlight checks that the warm-up job waits for the fetch
+// of a client, rather than streaming the blob without storing it.
+func TestProxyStoreFetchInFlight(t *testing.T) {
+	te := makeTestEnv(t, "foo/bar")
+	populate(t, te, 2, 200, 2)
+	remoteStats := te.RemoteStats()
+
+	// A client fetches the blob, which it stores.
+	stored := te.inRemote[0].Digest
+	done := make(chan struct{})
+	mu.Lock()
+	inflight[stored] = done
+	mu.Unlock()
+	time.AfterFunc(50*time.Millisecond, func() {
+		p, err := te.store.remoteStore.Get(te.ctx, stored)
+		if err == nil {
+			_, err = te.store.localStore.Put(te.ctx, "", p)
+		}
+		if err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		delete(inflight, stored)
+		mu.Unlock()
+		close(done)
+	})
+
+	if err := te.store.fetch(te.ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+	sbsMu.Lock()
+	opens := (*remoteStats)["open"]
+	sbsMu.Unlock()
+	if opens != 0 {
+		t.Fatalf("unexpected fetch from the remote: %v", opens)
+	}
+
+	// A client fetches the blob, but fails to store it.
+	failed := te.inRemote[1].Digest
+	done = make(chan struct{})
+	mu.Lock()
+	inflight[failed] = done
+	mu.Unlock()
+	time.AfterFunc(50*time.Millisecond, func() {
+		mu.Lock()
+		delete(inflight, failed)
+		mu.Unlock()
+		close(done)
+	})
+
+	if err := te.store.fetch(te.ctx, failed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := te.store.localStore.Stat(te.ctx, failed); err != nil {
+		t.Fatalf("expected blob to be stored: %v", err)
+	}
+}
@@ -0,0 +1,365 @@
+package scheduler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/storage/driver"
+)
+
+const (
+	// journalCompactionMin is the number of journaled changes below which
+	// the journal is not compacted into the state file.
+	journalCompactionMin = 10000
+
+	// journalBucketWidth is the span of expiries indexed together.
+	journalBucketWidth = time.Minute
+)
+
+// journalStore keeps the entries in the storage, as a state file listing them
+// and a journal of the changes made since the state file was written. The
+// changes are appended to the journal as a segment per flush, and the journal
+// is compacted into the state file once it has grown as large as it.
+//
+// It is meant for a single scheduler: every entry is held in memory, indexed
+// by expiry in buckets of journalBucketWidth so that loading the due entries
+// only sorts those of the buckets they fall in, and compacting rewrites the
+// whole state file. The caches of several registry instances keep their
+// entries in redis.
+type journalStore struct {
+	driver      driver.StorageDriver
+	path        string
+	journalPath string
+
+	mu      sync.Mutex
+	entries map[string]journalEntry
+	buckets map[int64]map[string]struct{} // keys by expiry bucket
+	order   []int64                       // buckets, in order
+	pending []journalRecord
+
+	flushMu       sync.Mutex // serializes the writes to the storage
+	seq           uint64     // of the last segment
+	records       int        // journaled since the state file was written
+	compactionMin int
+}
+
+// journalEntry is the compact form of an entry held by a journalStore.
+type journalEntry struct {
+	expiry    int64 // Unix time in nanoseconds
+	ttl       time.Duration
+	entryType int
+}
+
+func (e journalEntry) schedulerEntry(key string) *schedulerEntry {
+	return &schedulerEntry{
+		Key:       key,
+		Expiry:    time.Unix(0, e.expiry),
+		EntryType: e.entryType,
+		TTL:       e.ttl,
+	}
+}
+
+// journalRecord is a change of the journal, adding or replacing an entry or
+// removing it.
+type journalRecord struct {
+	schedulerEntry
+	Deleted bool `json:"Deleted,omitempty"`
+}
+
+func newJournalStore(driver driver.StorageDriver, path string) *journalStore {
+	return &journalStore{
+		driver:        driver,
+		path:          path,
+		journalPath:   path + ".journal",
+		entries:       make(map[string]journalEntry),
+		buckets:       make(map[int64]map[string]struct{}),
+		compactionMin: journalCompactionMin,
+	}
+}
+
+func (js *journalStore) open(ctx context.Context) error {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	js.entries = make(map[string]journalEntry)
+	js.buckets = make(map[int64]map[string]struct{})
+	js.order = nil
+	js.pending = nil
+	js.records = 0
+
+	p, err := js.driver.GetContent(ctx, js.path)
+	switch err.(type) {
+	case nil:
+		var state map[string]schedulerEntry
+		if err := json.Unmarshal(p, &state); err != nil {
+			return err
+		}
+		for key, entry := range state {
+			js.set(key, &entry)
+		}
+	case driver.PathNotFoundError:
+	default:
+		return err
+	}
+
+	segments, err := js.segments(ctx)
+	if err != nil {
+		return err
+	}
+	for _, segment := range segments {
+		p, err := js.driver.GetContent(ctx, js.segmentPath(segment))
+		if err != nil {
+			return err
+		}
+		dec := json.NewDecoder(bytes.NewReader(p))
+		for {
+			var record journalRecord
+			if err := dec.Decode(&record); err != nil {
+				if err != io.EOF {
+					// A segment cut short by a crash holds the changes
+					// decoded so far.
+					dcontext.GetLogger(ctx).Errorf("Error reading scheduler journal segment %d: %s", segment, err)
+				}
+				break
+			}
+			if record.Deleted {
+				js.remove(record.Key)
+			} else {
+				js.set(record.Key, &record.schedulerEntry)
+			}
+			js.records++
+		}
+		js.seq = segment
+	}
+	return nil
+}
+
+// segments returns the numbers of the segments of the journal, in order.
+func (js *journalStore) segments(ctx context.Context) ([]uint64, error) {
+	paths, err := js.driver.List(ctx, js.journalPath)
+	if err != nil {
+		if _, ok := err.(driver.PathNotFoundError); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var segments []uint64
+	for _, p := range paths {
+		segment, err := strconv.ParseUint(path.Base(p), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+	return segments, nil
+}
+
+func (js *journalStore) segmentPath(segment uint64) string {
+	return fmt.Sprintf("%s/%020d", js.journalPath, segment)
+}
+
+// all returns the entries.
+func (js *journalStore) all() []*schedulerEntry {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	entries := make([]*schedulerEntry, 0, len(js.entries))
+	for key, e := range js.entries {
+		entries = append(entries, e.schedulerEntry(key))
+	}
+	return entries
+}
+
+// set holds the entry. The store must be locked.
+func (js *journalStore) set(key string, entry *schedulerEntry) {
+	e := journalEntry{
+		expiry:    entry.Expiry.UnixNano(),
+		ttl:       entry.TTL,
+		entryType: entry.EntryType,
+	}
+	if old, ok := js.entries[key]; ok {
+		if bucketOf(old.expiry) == bucketOf(e.expiry) {
+			js.entries[key] = e
+			return
+		}
+		js.remove(key)
+	}
+	js.entries[key] = e
+
+	b := bucketOf(e.expiry)
+	keys, ok := js.buckets[b]
+	if !ok {
+		keys = make(map[string]struct{})
+		js.buckets[b] = keys
+		i := sort.Search(len(js.order), func(i int) bool { return js.order[i] >= b })
+		js.order = append(js.order, 0)
+		copy(js.order[i+1:], js.order[i:])
+		js.order[i] = b
+	}
+	keys[key] = struct{}{}
+}
+
+// remove drops the entry, if held. The store must be locked.
+func (js *journalStore) remove(key string) {
+	e, ok := js.entries[key]
+	if !ok {
+		return
+	}
+	delete(js.entries, key)
+
+	b := bucketOf(e.expiry)
+	keys := js.buckets[b]
+	delete(keys, key)
+	if len(keys) == 0 {
+		delete(js.buckets, b)
+		i := sort.Search(len(js.order), func(i int) bool { return js.order[i] >= b })
+		js.order = append(js.order[:i], js.order[i+1:]...)
+	}
+}
+
+// bucketOf returns the index bucket of an expiry in Unix nanoseconds.
+func bucketOf(expiry int64) int64 {
+	b := expiry / int64(journalBucketWidth)
+	if expiry%int64(journalBucketWidth) < 0 {
+		b--
+	}
+	return b
+}
+
+func (js *journalStore) put(ctx context.Context, entry *schedulerEntry) error {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	js.set(entry.Key, entry)
+	js.pending = append(js.pending, journalRecord{schedulerEntry: *entry})
+	return nil
+}
+
+func (js *journalStore) due(ctx context.Context, from, until time.Time, limit int) ([]*schedulerEntry, error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	// The buckets are visited in order from the one holding from, each
+	// sorted on its own, until enough entries are found.
+	i := 0
+	if !from.IsZero() {
+		first := bucketOf(from.UnixNano())
+		i = sort.Search(len(js.order), func(i int) bool { return js.order[i] >= first })
+	}
+	last := bucketOf(until.UnixNano())
+
+	var entries []*schedulerEntry
+	for ; i < len(js.order) && js.order[i] <= last && len(entries) < limit; i++ {
+		var bucket []*schedulerEntry
+		for key := range js.buckets[js.order[i]] {
+			e := js.entries[key]
+			expiry := time.Unix(0, e.expiry)
+			if (!from.IsZero() && expiry.Before(from)) || !expiry.Before(until) {
+				continue
+			}
+			bucket = append(bucket, e.schedulerEntry(key))
+		}
+		sort.Slice(bucket, func(i, j int) bool {
+			if !bucket[i].Expiry.Equal(bucket[j].Expiry) {
+				return bucket[i].Expiry.Before(bucket[j].Expiry)
+			}
+			return bucket[i].Key < bucket[j].Key
+		})
+		entries = append(entries, bucket...)
+	}
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+func (js *journalStore) claim(ctx context.Context, entry *schedulerEntry) (bool, error) {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	e, ok := js.entries[entry.Key]
+	if !ok || e.expiry != entry.Expiry.UnixNano() {
+		return false, nil
+	}
+	js.remove(entry.Key)
+	js.pending = append(js.pending, journalRecord{schedulerEntry: schedulerEntry{Key: entry.Key}, Deleted: true})
+	return true, nil
+}
+
+// flush appends the pending changes to the journal as a new segment, then
+// compacts the journal if it has grown as large as the state file.
+func (js *journalStore) flush(ctx context.Context) error {
+	js.flushMu.Lock()
+	defer js.flushMu.Unlock()
+
+	js.mu.Lock()
+	pending := js.pending
+	js.pending = nil
+	js.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, record := range pending {
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	if err := js.driver.PutContent(ctx, js.segmentPath(js.seq+1), buf.Bytes()); err != nil {
+		// Keep the changes for the next flush.
+		js.mu.Lock()
+		js.pending = append(pending, js.pending...)
+		js.mu.Unlock()
+		return err
+	}
+	js.seq++
+	js.records += len(pending)
+
+	js.mu.Lock()
+	compact := js.records >= js.compactionMin && js.records >= len(js.entries)
+	js.mu.Unlock()
+	if compact {
+		return js.compact(ctx)
+	}
+	return nil
+}
+
+// compact writes the state file and removes the journal. The changes made
+// while writing it are pending, and are journaled again on the next flush.
+// The store's flushMu must be held.
+func (js *journalStore) compact(ctx context.Context) error {
+	js.mu.Lock()
+	state := make(map[string]schedulerEntry, len(js.entries))
+	for key, e := range js.entries {
+		state[key] = *e.schedulerEntry(key)
+	}
+	js.mu.Unlock()
+
+	p, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	if err := js.driver.PutContent(ctx, js.path, p); err != nil {
+		return err
+	}
+	if err := js.driver.Delete(ctx, js.journalPath); err != nil {
+		if _, ok := err.(driver.PathNotFoundError); !ok {
+			return err
+		}
+	}
+	js.records = 0
+	return nil
+}
@@ -0,0 +1,170 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// redisKeyPrefix starts the keys of the entries, followed by the prefix
+	// of the store.
+	redisKeyPrefix = "proxy:scheduler:"
+
+	// redisMigrationBatch is the number of entries migrated from the
+	// storage at a time.
+	redisMigrationBatch = 1000
+)
+
+// redisClaimScript removes an entry if it is still scheduled to expire at the
+// given time, in milliseconds.
+var redisClaimScript = redis.NewScript(`
+local score = redis.call("ZSCORE", KEYS[1], ARGV[1])
+if score and tonumber(score) == tonumber(ARGV[2]) then
+	redis.call("ZREM", KEYS[1], ARGV[1])
+	redis.call("HDEL", KEYS[2], ARGV[1])
+	return 1
+end
+return 0
+`)
+
+// redisStore keeps the entries in redis, shared by the schedulers of the
+// registry instances using the same server and prefix: a sorted set orders the
+// keys by expiry, in milliseconds, and a hash holds the entries by key.
+type redisStore struct {
+	client redis.UniversalClient
+
+	// expiriesKey and entriesKey are the keys of the sorted set and the
+	// hash. They share a hash tag, to be in the same slot of a cluster.
+	expiriesKey string
+	entriesKey  string
+
+	// driver and path locate the entries of the storage, migrated to redis
+	// when the store is opened.
+	driver driver.StorageDriver
+	path   string
+}
+
+func newRedisStore(client redis.UniversalClient, prefix string, driver driver.StorageDriver, path string) *redisStore {
+	tag := "{" + redisKeyPrefix + prefix + "}"
+	return &redisStore{
+		client:      client,
+		expiriesKey: tag + ":expiries",
+		entriesKey:  tag + ":entries",
+		driver:      driver,
+		path:        path,
+	}
+}
+
+// open migrates the entries kept in the storage, if any, to redis.
+func (rs *redisStore) open(ctx context.Context) error {
+	js := newJournalStore(rs.driver, rs.path)
+	if err := js.open(ctx); err != nil {
+		return err
+	}
+	entries := js.all()
+	if len(entries) == 0 {
+		return nil
+	}
+
+	dcontext.GetLogger(ctx).Infof("Migrating %d scheduler entries from the storage to redis", len(entries))
+	for len(entries) > 0 {
+		n := min(len(entries), redisMigrationBatch)
+		if err := rs.putAll(ctx, entries[:n]); err != nil {
+			return err
+		}
+		entries = entries[n:]
+	}
+
+	if err := rs.driver.Delete(ctx, js.journalPath); err != nil {
+		if _, ok := err.(driver.PathNotFoundError); !ok {
+			return err
+		}
+	}
+	if err := rs.driver.Delete(ctx, rs.path); err != nil {
+		if _, ok := err.(driver.PathNotFoundError); !ok {
+			return err
+		}
+	}
+	return nil
+}
+
+func (rs *redisStore) put(ctx context.Context, entry *schedulerEntry) error {
+	return rs.putAll(ctx, []*schedulerEntry{entry})
+}
+
+func (rs *redisStore) putAll(ctx context.Context, entries []*schedulerEntry) error {
+	_, err := rs.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, entry := range entries {
+			p, err := json.Marshal(entry)
+			if err != nil {
+				return err
+			}
+			pipe.ZAdd(ctx, rs.expiriesKey, redis.Z{Score: float64(entry.Expiry.UnixMilli()), Member: entry.Key})
+			pipe.HSet(ctx, rs.entriesKey, entry.Key, p)
+		}
+		return nil
+	})
+	return err
+}
+
+// due returns the entries with their expiry in milliseconds, the precision of
+// the sorted set.
+func (rs *redisStore) due(ctx context.Context, from, until time.Time, limit int) ([]*schedulerEntry, error) {
+	low := "-inf"
+	if !from.IsZero() {
+		ms := from.UnixMilli()
+		if from.After(time.UnixMilli(ms)) {
+			ms++
+		}
+		low = strconv.FormatInt(ms, 10)
+	}
+	zs, err := rs.client.ZRangeByScoreWithScores(ctx, rs.expiriesKey, &redis.ZRangeBy{
+		Min:   low,
+		Max:   "(" + strconv.FormatInt(until.UnixMilli(), 10),
+		Count: int64(limit),
+	}).Result()
+	if err != nil || len(zs) == 0 {
+		return nil, err
+	}
+
+	keys := make([]string, len(zs))
+	for i, z := range zs {
+		keys[i], _ = z.Member.(string)
+	}
+	values, err := rs.client.HMGet(ctx, rs.entriesKey, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*schedulerEntry, 0, len(values))
+	for i, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			// Claimed since it was listed.
+			continue
+		}
+		var entry schedulerEntry
+		if err := json.Unmarshal([]byte(s), &entry); err != nil {
+			dcontext.GetLogger(ctx).Errorf("Error decoding scheduler entry for %s: %s", keys[i], err)
+			continue
+		}
+		entry.Expiry = time.UnixMilli(int64(zs[i].Score))
+		entries = append(entries, &entry)
+	}
+	return entries, nil
+}
+
+func (rs *redisStore) claim(ctx context.Context, entry *schedulerEntry) (bool, error) {
+	n, err := redisClaimScript.Run(ctx, rs.client, []string{rs.expiriesKey, rs.entriesKey}, entry.Key, entry.Expiry.UnixMilli()).Int()
+	return n == 1, err
+}
+
+// flush does nothing: the entries are stored as they change.
+func (rs *redisStore) flush(ctx context.Context) error {
+	return nil
+}
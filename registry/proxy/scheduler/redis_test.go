@@ -0,0 +1,104 @@
+package scheduler
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/distribution/reference"
+	"github.com/redis/go-redis/v9"
+)
+
+// TestRedisStore exercises a live redis instance.
+func TestRedisStore(t *testing.T) {
+	redisAddr := os.Getenv("TEST_REGISTRY_PROXY_REDIS_ADDR")
+	if redisAddr == "" {
+		t.Skip("please set TEST_REGISTRY_PROXY_REDIS_ADDR to test the redis scheduler store")
+	}
+
+	ctx := dcontext.Background()
+	client := redis.NewClient(&redis.Options{Addr: redisAddr})
+	defer client.Close()
+	rs := newRedisStore(client, "https://registry.test", nil, "")
+	if err := client.Del(ctx, rs.expiriesKey, rs.entriesKey).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// An entry of the storage is migrated.
+	fs := inmemory.New()
+	js := newJournalStore(fs, "/ttl")
+	if err := js.put(ctx, &schedulerEntry{Key: testRef(t, 0).String(), Expiry: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if err := js.flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	const n = 20
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(n)
+	expiries := map[string]int{}
+	expire := func(r reference.Reference) error {
+		mu.Lock()
+		defer mu.Unlock()
+		expiries[r.String()]++
+		wg.Done()
+		return nil
+	}
+
+	var schedulers []*TTLExpirationScheduler
+	for i := 0; i < 2; i++ {
+		s := New(ctx, fs, "/ttl", append(testOptions, WithRedis(client, "https://registry.test"))...)
+		s.OnBlobExpire(expire)
+		if err := s.Start(); err != nil {
+			t.Fatal(err)
+		}
+		defer s.Stop()
+		schedulers = append(schedulers, s)
+	}
+	if _, err := fs.Stat(ctx, js.journalPath); err == nil {
+		t.Fatal("expected the journal to be migrated")
+	}
+
+	// The entries added through one scheduler are loaded by the other,
+	// and expired by either.
+	for i := 1; i < n; i++ {
+		s := schedulers[i%2]
+		s.Lock()
+		err := s.add(testRef(t, i), 150*time.Millisecond, entryTypeBlob)
+		s.Unlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	wg.Wait()
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	for key, n := range expiries {
+		if n != 1 {
+			t.Errorf("%s expired %d times", key, n)
+		}
+	}
+	if n, err := client.ZCard(ctx, rs.expiriesKey).Result(); err != nil || n != 0 {
+		t.Fatalf("unexpected entries remaining: %d, %v", n, err)
+	}
+}
+
+func TestRedisStoreKeys(t *testing.T) {
+	hub := newRedisStore(nil, "https://registry-1.docker.io", nil, "")
+	quay := newRedisStore(nil, "https://quay.io", nil, "")
+
+	if hub.expiriesKey == quay.expiriesKey || hub.entriesKey == quay.entriesKey {
+		t.Fatalf("expected the keys of different prefixes to differ: %q, %q", hub.expiriesKey, quay.expiriesKey)
+	}
+	tag := "{proxy:scheduler:https://registry-1.docker.io}"
+	if hub.expiriesKey != tag+":expiries" || hub.entriesKey != tag+":entries" {
+		t.Fatalf("expected the keys to share the hash tag %s: %q, %q", tag, hub.expiriesKey, hub.entriesKey)
+	}
+}
@@ -2,7 +2,7 @@ package scheduler
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -10,38 +10,133 @@ import (
 	"github.com/distribution/distribution/v3/internal/dcontext"
 	"github.com/distribution/distribution/v3/registry/storage/driver"
 	"github.com/distribution/reference"
+	"github.com/redis/go-redis/v9"
 )
 
 // onTTLExpiryFunc is called when a repository's TTL expires
 type expiryFunc func(reference.Reference) error
 
+// ErrRetain is returned by an expiry function to keep the expired content,
+// such as pinned content. Its entry is scheduled to expire again after the
+// same TTL.
+var ErrRetain = errors.New("scheduler: retain entry")
+
 const (
 	entryTypeBlob = iota
 	entryTypeManifest
 	indexSaveFrequency = 5 * time.Second
+
+	// defaultRetainTTL is how long retained entries restored from a state
+	// file written without their TTL are kept.
+	defaultRetainTTL = 24 * time.Hour
+
+	defaultWindow     = 5 * time.Minute
+	defaultResolution = time.Second
+	defaultMaxEntries = 100000
+
+	// refillRetryInterval is how long the scheduler waits to load entries
+	// from the store again after failing to.
+	refillRetryInterval = 5 * time.Second
 )
 
 // schedulerEntry represents an entry in the scheduler
 // fields are exported for serialization
 type schedulerEntry struct {
-	Key       string    `json:"Key"`
-	Expiry    time.Time `json:"ExpiryData"`
-	EntryType int       `json:"EntryType"`
+	Key       string        `json:"Key"`
+	Expiry    time.Time     `json:"ExpiryData"`
+	EntryType int           `json:"EntryType"`
+	TTL       time.Duration `json:"TTL,omitempty"`
+}
+
+// entryStore keeps the entries of the scheduler, which only holds the ones
+// expiring soon in memory. The entries of a store may be shared by several
+// schedulers: each entry is expired by the scheduler which claims it.
+// Implementations are safe for concurrent use.
+type entryStore interface {
+	// open loads the store.
+	open(ctx context.Context) error
+
+	// put adds an entry, replacing the one of the same key.
+	put(ctx context.Context, entry *schedulerEntry) error
 
-	timer *time.Timer
+	// due returns the entries expiring from from, included, until until,
+	// excluded, earliest first, and at most limit of them.
+	due(ctx context.Context, from, until time.Time, limit int) ([]*schedulerEntry, error)
+
+	// claim removes the entry if it is still scheduled to expire at its
+	// expiry, reporting whether it did.
+	claim(ctx context.Context, entry *schedulerEntry) (bool, error)
+
+	// flush persists the changes to the store, if it does not as they are
+	// made.
+	flush(ctx context.Context) error
 }
 
-// New returns a new instance of the scheduler
-func New(ctx context.Context, driver driver.StorageDriver, path string) *TTLExpirationScheduler {
-	return &TTLExpirationScheduler{
-		entries:         make(map[string]*schedulerEntry),
+// Option configures a scheduler.
+type Option func(*TTLExpirationScheduler)
+
+// WithRedis keeps the entries in redis rather than in the storage, under keys
+// starting with prefix, sharing them with the schedulers of the other registry
+// instances using the same redis server and prefix.
+func WithRedis(client redis.UniversalClient, prefix string) Option {
+	return func(ttles *TTLExpirationScheduler) {
+		ttles.store = newRedisStore(client, prefix, ttles.driver, ttles.pathToStateFile)
+	}
+}
+
+// WithWindow sets how far ahead entries are loaded from the store.
+func WithWindow(window time.Duration) Option {
+	return func(ttles *TTLExpirationScheduler) {
+		if window > 0 {
+			ttles.window = window
+		}
+	}
+}
+
+// WithResolution sets the precision of the expiries: entries expire at most
+// this late.
+func WithResolution(resolution time.Duration) Option {
+	return func(ttles *TTLExpirationScheduler) {
+		if resolution > 0 {
+			ttles.resolution = resolution
+		}
+	}
+}
+
+// WithMaxEntries bounds the number of entries held in memory.
+func WithMaxEntries(n int) Option {
+	return func(ttles *TTLExpirationScheduler) {
+		if n > 0 {
+			ttles.maxEntries = n
+		}
+	}
+}
+
+// New returns a new instance of the scheduler. Unless configured otherwise,
+// its entries are journaled in the storage, next to the state file at path.
+func New(ctx context.Context, driver driver.StorageDriver, path string, options ...Option) *TTLExpirationScheduler {
+	ttles := &TTLExpirationScheduler{
 		driver:          driver,
 		pathToStateFile: path,
 		ctx:             ctx,
 		stopped:         true,
+		window:          defaultWindow,
+		resolution:      defaultResolution,
+		maxEntries:      defaultMaxEntries,
+		wake:            make(chan struct{}, 1),
 		doneChan:        make(chan struct{}),
-		saveTimer:       time.NewTicker(indexSaveFrequency),
+		loopDone:        make(chan struct{}),
+	}
+	for _, option := range options {
+		option(ttles)
+	}
+	if ttles.store == nil {
+		ttles.store = newJournalStore(driver, path)
 	}
+	if ttles.window < ttles.resolution {
+		ttles.window = ttles.resolution
+	}
+	return ttles
 }
 
 // TTLExpirationScheduler is a scheduler used to perform actions
@@ -49,20 +144,30 @@ func New(ctx context.Context, driver driver.StorageDriver, path string) *TTLExpi
 type TTLExpirationScheduler struct {
 	sync.Mutex
 
-	entries map[string]*schedulerEntry
+	store entryStore
+	wheel *timerWheel
 
 	driver          driver.StorageDriver
 	ctx             context.Context
 	pathToStateFile string
 
+	window     time.Duration
+	resolution time.Duration
+	maxEntries int
+
 	stopped bool
 
 	onBlobExpire     expiryFunc
 	onManifestExpire expiryFunc
 
-	indexDirty bool
-	saveTimer  *time.Ticker
-	doneChan   chan struct{}
+	// refillAt is when entries are loaded from the store next, and
+	// nextWake when the loop wakes up next.
+	refillAt time.Time
+	nextWake time.Time
+
+	wake     chan struct{}
+	doneChan chan struct{}
+	loopDone chan struct{}
 }
 
 // OnBlobExpire is called when a scheduled blob's TTL expires
@@ -90,8 +195,7 @@ func (ttles *TTLExpirationScheduler) AddBlob(blobRef reference.Canonical, ttl ti
 		return fmt.Errorf("scheduler not started")
 	}
 
-	ttles.add(blobRef, ttl, entryTypeBlob)
-	return nil
+	return ttles.add(blobRef, ttl, entryTypeBlob)
 }
 
 // AddManifest schedules a manifest cleanup after ttl expires
@@ -103,8 +207,7 @@ func (ttles *TTLExpirationScheduler) AddManifest(manifestRef reference.Canonical
 		return fmt.Errorf("scheduler not started")
 	}
 
-	ttles.add(manifestRef, ttl, entryTypeManifest)
-	return nil
+	return ttles.add(manifestRef, ttl, entryTypeManifest)
 }
 
 // Start starts the scheduler
@@ -112,43 +215,30 @@ func (ttles *TTLExpirationScheduler) Start() error {
 	ttles.Lock()
 	defer ttles.Unlock()
 
-	err := ttles.readState()
-	if err != nil {
-		return err
-	}
-
 	if !ttles.stopped {
 		return fmt.Errorf("scheduler already started")
 	}
 
+	if err := ttles.store.open(ttles.ctx); err != nil {
+		return err
+	}
+
 	dcontext.GetLogger(ttles.ctx).Infof("Starting cached object TTL expiration scheduler...")
 	ttles.stopped = false
+	ttles.wheel = newTimerWheel(time.Now(), ttles.resolution, ttles.window)
 
-	// Start timer for each deserialized entry
-	for _, entry := range ttles.entries {
-		entry.timer = ttles.startTimer(entry, time.Until(entry.Expiry))
-	}
-
-	// Start a ticker to periodically save the entries index
+	go ttles.loop()
 
+	// Periodically persist the changes to the store
 	go func() {
+		ticker := time.NewTicker(indexSaveFrequency)
+		defer ticker.Stop()
 		for {
 			select {
-			case <-ttles.saveTimer.C:
-				ttles.Lock()
-				if !ttles.indexDirty {
-					ttles.Unlock()
-					continue
-				}
-
-				err := ttles.writeState()
-				if err != nil {
+			case <-ticker.C:
+				if err := ttles.store.flush(ttles.ctx); err != nil {
 					dcontext.GetLogger(ttles.ctx).Errorf("Error writing scheduler state: %s", err)
-				} else {
-					ttles.indexDirty = false
 				}
-				ttles.Unlock()
-
 			case <-ttles.doneChan:
 				return
 			}
@@ -158,105 +248,224 @@ func (ttles *TTLExpirationScheduler) Start() error {
 	return nil
 }
 
-func (ttles *TTLExpirationScheduler) add(r reference.Reference, ttl time.Duration, eType int) {
+// add stores the entry, and holds it in the wheel if it expires before the
+// entries loaded from the store. The scheduler must be locked.
+func (ttles *TTLExpirationScheduler) add(r reference.Reference, ttl time.Duration, eType int) error {
 	entry := &schedulerEntry{
 		Key:       r.String(),
 		Expiry:    time.Now().Add(ttl),
 		EntryType: eType,
+		TTL:       ttl,
 	}
 	dcontext.GetLogger(ttles.ctx).Infof("Adding new scheduler entry for %s with ttl=%s", entry.Key, time.Until(entry.Expiry))
-	if oldEntry, present := ttles.entries[entry.Key]; present && oldEntry.timer != nil {
-		oldEntry.timer.Stop()
+	if err := ttles.store.put(ttles.ctx, entry); err != nil {
+		return fmt.Errorf("error storing scheduler entry for %s: %w", entry.Key, err)
 	}
-	ttles.entries[entry.Key] = entry
-	entry.timer = ttles.startTimer(entry, ttl)
-	ttles.indexDirty = true
+	ttles.schedule(entry)
+	return nil
 }
 
-func (ttles *TTLExpirationScheduler) startTimer(entry *schedulerEntry, ttl time.Duration) *time.Timer {
-	return time.AfterFunc(ttl, func() {
+// schedule holds a stored entry in the wheel if it expires before the entries
+// loaded from the store, waking the loop up if it expires first. The
+// scheduler must be locked.
+func (ttles *TTLExpirationScheduler) schedule(entry *schedulerEntry) {
+	if ttles.wheel == nil {
+		return
+	}
+	if !ttles.wheel.hold(entry, ttles.maxEntries) || !entry.Expiry.Before(ttles.nextWake) {
+		return
+	}
+	select {
+	case ttles.wake <- struct{}{}:
+	default:
+	}
+}
+
+// loop expires the entries of the wheel as they come due, and loads the
+// entries expiring next from the store.
+func (ttles *TTLExpirationScheduler) loop() {
+	defer close(ttles.loopDone)
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+		case <-ttles.wake:
+		case <-ttles.doneChan:
+			return
+		}
+
+		now := time.Now()
 		ttles.Lock()
-		defer ttles.Unlock()
-
-		var f expiryFunc
-
-		switch entry.EntryType {
-		case entryTypeBlob:
-			f = ttles.onBlobExpire
-		case entryTypeManifest:
-			f = ttles.onManifestExpire
-		default:
-			f = func(reference.Reference) error {
-				return fmt.Errorf("scheduler entry type")
-			}
+		due := ttles.wheel.advance(now)
+		if !now.Before(ttles.refillAt) {
+			ttles.refill(now)
 		}
+		ttles.Unlock()
 
-		ref, err := reference.Parse(entry.Key)
-		if err == nil {
-			if err := f(ref); err != nil {
-				dcontext.GetLogger(ttles.ctx).Errorf("Scheduler error returned from OnExpire(%s): %s", entry.Key, err)
+		for _, entry := range due {
+			select {
+			case <-ttles.doneChan:
+				return
+			default:
 			}
-		} else {
-			dcontext.GetLogger(ttles.ctx).Errorf("Error unpacking reference: %s", err)
+			ttles.expire(entry)
+		}
+
+		ttles.Lock()
+		next := ttles.wheel.next()
+		if next.IsZero() || ttles.refillAt.Before(next) {
+			next = ttles.refillAt
 		}
+		ttles.nextWake = next
+		ttles.Unlock()
 
-		delete(ttles.entries, entry.Key)
-		ttles.indexDirty = true
-	})
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(time.Until(next))
+	}
 }
 
-// Stop stops the scheduler.
-func (ttles *TTLExpirationScheduler) Stop() error {
-	ttles.Lock()
-	defer ttles.Unlock()
+// refill loads the entries expiring within the window into the wheel, as many
+// as it holds, along with the entries which should have expired a window ago:
+// the ones another scheduler sharing the store held when it stopped. The
+// scheduler must be locked.
+func (ttles *TTLExpirationScheduler) refill(now time.Time) {
+	log := dcontext.GetLogger(ttles.ctx)
+	w := ttles.wheel
+
+	room := ttles.maxEntries - w.len()
+	if room <= 0 {
+		// Load the next entries once some of the wheel's have expired.
+		ttles.refillAt = now.Add(ttles.resolution)
+		return
+	}
+	ttles.refillAt = now.Add(refillRetryInterval)
+
+	var overdue []*schedulerEntry
+	if !w.horizon.IsZero() {
+		var err error
+		overdue, err = ttles.store.due(ttles.ctx, time.Time{}, now.Add(-ttles.window), room)
+		if err != nil {
+			log.Errorf("Error loading overdue scheduler entries: %s", err)
+			return
+		}
+	}
 
-	err := ttles.writeState()
+	until := now.Add(ttles.window)
+	entries, err := ttles.store.due(ttles.ctx, w.horizon, until, room)
 	if err != nil {
-		err = fmt.Errorf("error writing scheduler state: %w", err)
+		log.Errorf("Error loading scheduler entries: %s", err)
+		return
+	}
+	if len(entries) == room {
+		// The wheel is full: the entries expiring at the same time as the
+		// last one are left out, to be loaded with it next.
+		last := entries[len(entries)-1].Expiry
+		n := len(entries)
+		for n > 0 && entries[n-1].Expiry.Equal(last) {
+			n--
+		}
+		if n > 0 {
+			entries = entries[:n]
+			until = last
+		} else {
+			until = last.Add(time.Nanosecond)
+		}
 	}
 
-	for _, entry := range ttles.entries {
-		entry.timer.Stop()
+	w.horizon = until
+	for _, entry := range append(overdue, entries...) {
+		w.hold(entry, 0)
 	}
 
-	close(ttles.doneChan)
-	ttles.saveTimer.Stop()
-	ttles.stopped = true
-	return err
+	// Load the next entries once half of the window has passed.
+	ttles.refillAt = w.horizon.Add(-ttles.window / 2)
+	if soonest := now.Add(ttles.resolution); ttles.refillAt.Before(soonest) {
+		ttles.refillAt = soonest
+	}
 }
 
-func (ttles *TTLExpirationScheduler) writeState() error {
-	jsonBytes, err := json.Marshal(ttles.entries)
-	if err != nil {
-		return err
-	}
+// expire claims the entry from the store and calls its expiry function,
+// storing it again if the function retains it.
+func (ttles *TTLExpirationScheduler) expire(entry *schedulerEntry) {
+	log := dcontext.GetLogger(ttles.ctx)
 
-	err = ttles.driver.PutContent(ttles.ctx, ttles.pathToStateFile, jsonBytes)
+	claimed, err := ttles.store.claim(ttles.ctx, entry)
 	if err != nil {
-		return err
+		log.Errorf("Error claiming scheduler entry for %s: %s", entry.Key, err)
+		return
+	}
+	if !claimed {
+		// It was rescheduled, or expired by another scheduler.
+		return
 	}
 
-	return nil
-}
-
-func (ttles *TTLExpirationScheduler) readState() error {
-	if _, err := ttles.driver.Stat(ttles.ctx, ttles.pathToStateFile); err != nil {
-		switch err := err.(type) {
-		case driver.PathNotFoundError:
-			return nil
-		default:
-			return err
+	ttles.Lock()
+	var f expiryFunc
+	switch entry.EntryType {
+	case entryTypeBlob:
+		f = ttles.onBlobExpire
+	case entryTypeManifest:
+		f = ttles.onManifestExpire
+	}
+	ttles.Unlock()
+	if f == nil {
+		f = func(reference.Reference) error {
+			return fmt.Errorf("scheduler entry type")
 		}
 	}
 
-	bytes, err := ttles.driver.GetContent(ttles.ctx, ttles.pathToStateFile)
+	ref, err := reference.Parse(entry.Key)
 	if err != nil {
-		return err
+		log.Errorf("Error unpacking reference: %s", err)
+		return
 	}
 
-	err = json.Unmarshal(bytes, &ttles.entries)
+	err = f(ref)
+	if errors.Is(err, ErrRetain) {
+		ttl := entry.TTL
+		if ttl <= 0 {
+			ttl = defaultRetainTTL
+		}
+		log.Debugf("Retaining scheduler entry for %s for ttl=%s", entry.Key, ttl)
+		retained := *entry
+		retained.Expiry = time.Now().Add(ttl)
+		retained.TTL = ttl
+		if err := ttles.store.put(ttles.ctx, &retained); err != nil {
+			log.Errorf("Error storing scheduler entry for %s: %s", entry.Key, err)
+			return
+		}
+		ttles.Lock()
+		ttles.schedule(&retained)
+		ttles.Unlock()
+		return
+	}
 	if err != nil {
-		return err
+		log.Errorf("Scheduler error returned from OnExpire(%s): %s", entry.Key, err)
+	}
+}
+
+// Stop stops the scheduler.
+func (ttles *TTLExpirationScheduler) Stop() error {
+	ttles.Lock()
+	if ttles.stopped {
+		ttles.Unlock()
+		return nil
+	}
+	ttles.stopped = true
+	close(ttles.doneChan)
+	ttles.Unlock()
+
+	<-ttles.loopDone
+
+	if err := ttles.store.flush(ttles.ctx); err != nil {
+		return fmt.Errorf("error writing scheduler state: %w", err)
 	}
 	return nil
 }
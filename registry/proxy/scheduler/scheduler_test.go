@@ -1,7 +1,11 @@
 package scheduler
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
 	"sync"
 	"testing"
 	"time"
@@ -30,6 +34,9 @@ func testRefs(t *testing.T) (reference.Reference, reference.Reference, reference
 	return ref1, ref2, ref3
 }
 
+// testOptions have the scheduler expire entries within a millisecond.
+var testOptions = []Option{WithResolution(time.Millisecond), WithWindow(100 * time.Millisecond)}
+
 func TestSchedule(t *testing.T) {
 	ref1, ref2, ref3 := testRefs(t)
 	timeUnit := time.Millisecond
@@ -40,7 +47,7 @@ func TestSchedule(t *testing.T) {
 	}
 
 	var mu sync.Mutex
-	s := New(dcontext.Background(), inmemory.New(), "/ttl")
+	s := New(dcontext.Background(), inmemory.New(), "/ttl", testOptions...)
 	deleteFunc := func(repoName reference.Reference) error {
 		if len(remainingRepos) == 0 {
 			t.Fatal("Incorrect expiry count")
@@ -62,13 +69,12 @@ func TestSchedule(t *testing.T) {
 		t.Fatalf("Error starting ttlExpirationScheduler: %s", err)
 	}
 
-	s.add(ref1, 3*timeUnit, entryTypeBlob)
-	s.add(ref2, 1*timeUnit, entryTypeBlob)
-
 	func() {
 		s.Lock()
+		defer s.Unlock()
+		s.add(ref1, 3*timeUnit, entryTypeBlob)
+		s.add(ref2, 1*timeUnit, entryTypeBlob)
 		s.add(ref3, 1*timeUnit, entryTypeBlob)
-		s.Unlock()
 	}()
 
 	// Ensure all repos are deleted
@@ -130,7 +136,7 @@ func TestRestoreOld(t *testing.T) {
 	if err != nil {
 		t.Fatal("Unable to write serialized data to fs")
 	}
-	s := New(dcontext.Background(), fs, "/ttl")
+	s := New(dcontext.Background(), fs, "/ttl", testOptions...)
 	s.OnBlobExpire(deleteFunc)
 	err = s.Start()
 	if err != nil {
@@ -170,15 +176,17 @@ func TestStopRestore(t *testing.T) {
 
 	fs := inmemory.New()
 	pathToStateFile := "/ttl"
-	s := New(dcontext.Background(), fs, pathToStateFile)
+	s := New(dcontext.Background(), fs, pathToStateFile, testOptions...)
 	s.onBlobExpire = deleteFunc
 
 	err := s.Start()
 	if err != nil {
 		t.Fatal(err)
 	}
+	s.Lock()
 	s.add(ref1, 300*timeUnit, entryTypeBlob)
 	s.add(ref2, 100*timeUnit, entryTypeBlob)
+	s.Unlock()
 
 	// Start and stop before all operations complete
 	// state will be written to fs
@@ -189,7 +197,7 @@ func TestStopRestore(t *testing.T) {
 	time.Sleep(10 * time.Millisecond)
 
 	// v2 will restore state from fs
-	s2 := New(dcontext.Background(), fs, pathToStateFile)
+	s2 := New(dcontext.Background(), fs, pathToStateFile, testOptions...)
 	s2.onBlobExpire = deleteFunc
 	err = s2.Start()
 	if err != nil {
@@ -205,7 +213,7 @@ func TestStopRestore(t *testing.T) {
 }
 
 func TestDoubleStart(t *testing.T) {
-	s := New(dcontext.Background(), inmemory.New(), "/ttl")
+	s := New(dcontext.Background(), inmemory.New(), "/ttl", testOptions...)
 	err := s.Start()
 	if err != nil {
 		t.Fatal("Unable to start scheduler")
@@ -215,3 +223,304 @@ func TestDoubleStart(t *testing.T) {
 		t.Fatal("Scheduler started twice without error")
 	}
 }
+
+func TestRetain(t *testing.T) {
+	ref1, ref2, _ := testRefs(t)
+	timeUnit := time.Millisecond
+
+	var mu sync.Mutex
+	expiries := map[string]int{}
+	s := New(dcontext.Background(), inmemory.New(), "/ttl", testOptions...)
+	s.onManifestExpire = func(r reference.Reference) error {
+		mu.Lock()
+		defer mu.Unlock()
+		expiries[r.String()]++
+		// ref1 is retained twice, then expires.
+		if r.String() == ref1.String() && expiries[r.String()] < 3 {
+			return ErrRetain
+		}
+		return nil
+	}
+	err := s.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	s.Lock()
+	s.add(ref1, 10*timeUnit, entryTypeManifest)
+	s.add(ref2, 10*timeUnit, entryTypeManifest)
+	s.Unlock()
+
+	<-time.After(200 * timeUnit)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if expiries[ref1.String()] != 3 || expiries[ref2.String()] != 1 {
+		t.Fatalf("unexpected expiries: %#v", expiries)
+	}
+
+	if entries := s.store.(*journalStore).all(); len(entries) != 0 {
+		t.Fatalf("entries remaining: %#v", entries)
+	}
+}
+
+// sharedStore is a store opened once, shared by schedulers.
+type sharedStore struct {
+	*journalStore
+}
+
+func (sharedStore) open(context.Context) error {
+	return nil
+}
+
+func testRef(t *testing.T, i int) reference.Reference {
+	ref, err := reference.Parse(fmt.Sprintf("testrepo@sha256:%064x", i))
+	if err != nil {
+		t.Fatalf("could not parse reference: %v", err)
+	}
+	return ref
+}
+
+func TestBoundedWheel(t *testing.T) {
+	const n = 20
+	timeUnit := time.Millisecond
+
+	var s *TTLExpirationScheduler
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(n)
+	expired := map[string]bool{}
+	s = New(dcontext.Background(), inmemory.New(), "/ttl", append(testOptions, WithMaxEntries(3))...)
+	s.onBlobExpire = func(r reference.Reference) error {
+		s.Lock()
+		held := s.wheel.len()
+		s.Unlock()
+		if held > 3 {
+			t.Errorf("%d entries held", held)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if expired[r.String()] {
+			t.Errorf("%s expired twice", r)
+		}
+		expired[r.String()] = true
+		wg.Done()
+		return nil
+	}
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	s.Lock()
+	for i := 0; i < n; i++ {
+		if err := s.add(testRef(t, i), time.Duration(i%4)*timeUnit, entryTypeBlob); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Unlock()
+
+	wg.Wait()
+}
+
+func TestSharedStore(t *testing.T) {
+	const n = 50
+	ctx := dcontext.Background()
+	store := newJournalStore(inmemory.New(), "/ttl")
+	for i := 0; i < n; i++ {
+		entry := &schedulerEntry{Key: testRef(t, i).String(), Expiry: time.Now().Add(10 * time.Millisecond)}
+		if err := store.put(ctx, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	wg.Add(n)
+	expiries := map[string]int{}
+	expire := func(r reference.Reference) error {
+		mu.Lock()
+		defer mu.Unlock()
+		expiries[r.String()]++
+		wg.Done()
+		return nil
+	}
+
+	// Both schedulers hold every entry, which is expired by the one
+	// claiming it.
+	for i := 0; i < 2; i++ {
+		s := New(ctx, nil, "", testOptions...)
+		s.store = sharedStore{store}
+		s.onBlobExpire = expire
+		if err := s.Start(); err != nil {
+			t.Fatal(err)
+		}
+		defer s.Stop()
+	}
+
+	wg.Wait()
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	for key, n := range expiries {
+		if n != 1 {
+			t.Errorf("%s expired %d times", key, n)
+		}
+	}
+}
+
+func TestJournalStore(t *testing.T) {
+	ctx := dcontext.Background()
+	fs := inmemory.New()
+	js := newJournalStore(fs, "/ttl")
+	js.compactionMin = 5
+	if err := js.open(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	entries := make([]*schedulerEntry, 3)
+	for i := range entries {
+		entries[i] = &schedulerEntry{
+			Key:       testRef(t, i).String(),
+			Expiry:    now.Add(time.Duration(i) * time.Hour),
+			EntryType: entryTypeManifest,
+			TTL:       time.Duration(i) * time.Hour,
+		}
+		if err := js.put(ctx, entries[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := js.flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A claim is journaled until the journal is compacted.
+	if claimed, err := js.claim(ctx, &schedulerEntry{Key: entries[0].Key, Expiry: now.Add(time.Minute)}); err != nil || claimed {
+		t.Fatalf("claimed an entry with another expiry: %t, %v", claimed, err)
+	}
+	if claimed, err := js.claim(ctx, entries[0]); err != nil || !claimed {
+		t.Fatalf("failed to claim an entry: %t, %v", claimed, err)
+	}
+	if err := js.flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(ctx, js.journalPath); err != nil {
+		t.Fatalf("expected the journal to be kept: %v", err)
+	}
+
+	reopened := newJournalStore(fs, "/ttl")
+	if err := reopened.open(ctx); err != nil {
+		t.Fatal(err)
+	}
+	due, err := reopened.due(ctx, now, now.Add(24*time.Hour), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(due) != 2 || due[0].Key != entries[1].Key || due[1].Key != entries[2].Key {
+		t.Fatalf("unexpected entries: %#v", due)
+	}
+	if !due[1].Expiry.Equal(entries[2].Expiry) || due[1].TTL != entries[2].TTL || due[1].EntryType != entryTypeManifest {
+		t.Fatalf("unexpected entry: %#v", due[1])
+	}
+
+	// The journal has grown past the threshold and the state file.
+	if err := js.put(ctx, entries[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := js.flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.Stat(ctx, js.journalPath); err == nil {
+		t.Fatal("expected the journal to be compacted")
+	}
+	var state map[string]schedulerEntry
+	p, err := fs.GetContent(ctx, "/ttl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(p, &state); err != nil {
+		t.Fatal(err)
+	}
+	if len(state) != 3 {
+		t.Fatalf("unexpected state: %#v", state)
+	}
+}
+
+// TestJournalStoreDue checks the due entries of the expiry index against
+// those of every entry, as entries are put, moved and claimed.
+func TestJournalStoreDue(t *testing.T) {
+	ctx := dcontext.Background()
+	js := newJournalStore(inmemory.New(), "/ttl")
+	if err := js.open(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	now := time.Now()
+	expiries := make(map[string]time.Time)
+	for i := 0; i < 2000; i++ {
+		key := testRef(t, rng.Intn(300)).String()
+		if rng.Intn(4) == 0 {
+			if expiry, ok := expiries[key]; ok {
+				if claimed, err := js.claim(ctx, &schedulerEntry{Key: key, Expiry: expiry}); err != nil || !claimed {
+					t.Fatalf("failed to claim an entry: %t, %v", claimed, err)
+				}
+				delete(expiries, key)
+			}
+			continue
+		}
+		expiry := now.Add(time.Duration(rng.Intn(int(10*journalBucketWidth/time.Second))) * time.Second)
+		if err := js.put(ctx, &schedulerEntry{Key: key, Expiry: expiry}); err != nil {
+			t.Fatal(err)
+		}
+		expiries[key] = expiry
+	}
+	if len(js.buckets) != len(js.order) || len(js.buckets) > 11 {
+		t.Fatalf("unexpected buckets: %d, ordered %d", len(js.buckets), len(js.order))
+	}
+
+	for _, tc := range []struct {
+		from, until time.Time
+		limit       int
+	}{
+		{time.Time{}, now.Add(time.Hour), 1000},
+		{time.Time{}, now.Add(3 * journalBucketWidth), 1000},
+		{now.Add(90 * time.Second), now.Add(5 * journalBucketWidth), 1000},
+		{now.Add(90 * time.Second), now.Add(5 * journalBucketWidth), 7},
+		{now.Add(time.Hour), now.Add(2 * time.Hour), 1000},
+	} {
+		var expected []string
+		for key, expiry := range expiries {
+			if (!tc.from.IsZero() && expiry.Before(tc.from)) || !expiry.Before(tc.until) {
+				continue
+			}
+			expected = append(expected, key)
+		}
+		sort.Slice(expected, func(i, j int) bool {
+			a, b := expiries[expected[i]], expiries[expected[j]]
+			if !a.Equal(b) {
+				return a.Before(b)
+			}
+			return expected[i] < expected[j]
+		})
+		if len(expected) > tc.limit {
+			expected = expected[:tc.limit]
+		}
+
+		due, err := js.due(ctx, tc.from, tc.until, tc.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(due) != len(expected) {
+			t.Fatalf("expected %d entries from %v until %v, got %d", len(expected), tc.from, tc.until, len(due))
+		}
+		for i, entry := range due {
+			if entry.Key != expected[i] || !entry.Expiry.Equal(expiries[expected[i]]) {
+				t.Fatalf("entry %d: expected %s at %v, got %s at %v", i, expected[i], expiries[expected[i]], entry.Key, entry.Expiry)
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package scheduler
+
+import (
+	"time"
+)
+
+// timerWheel holds the entries expiring before its horizon, at most a window
+// ahead, in a ring of slots spanning the window. Each slot holds the entries
+// expiring within one resolution of time, which come due together at the end
+// of the slot.
+type timerWheel struct {
+	resolution time.Duration
+	slots      [][]*schedulerEntry
+
+	// pos is the slot of the time from base until base plus the
+	// resolution.
+	pos  int
+	base time.Time
+
+	// entries are the entries held by key. The slots may still hold the
+	// entries they replaced, which are skipped when they come due.
+	entries map[string]*schedulerEntry
+
+	// horizon is the expiry until which the stored entries were loaded.
+	horizon time.Time
+}
+
+func newTimerWheel(now time.Time, resolution, window time.Duration) *timerWheel {
+	// The base lags behind the time by less than a slot.
+	n := int((window+resolution-1)/resolution) + 1
+	return &timerWheel{
+		resolution: resolution,
+		slots:      make([][]*schedulerEntry, n),
+		base:       now,
+		entries:    make(map[string]*schedulerEntry),
+	}
+}
+
+func (w *timerWheel) len() int {
+	return len(w.entries)
+}
+
+// hold holds the entry if it expires before the horizon, and if the wheel
+// holds fewer than max entries, or max is 0, reporting whether it did. An
+// entry left out for lack of room is loaded from the store later.
+func (w *timerWheel) hold(entry *schedulerEntry, max int) bool {
+	if current, ok := w.entries[entry.Key]; ok {
+		if current.Expiry.Equal(entry.Expiry) {
+			return false
+		}
+		delete(w.entries, entry.Key)
+	}
+	if !entry.Expiry.Before(w.horizon) {
+		return false
+	}
+	offset := 0
+	if d := entry.Expiry.Sub(w.base); d > 0 {
+		offset = int(d / w.resolution)
+	}
+	if (max > 0 && len(w.entries) >= max) || offset >= len(w.slots) {
+		w.horizon = entry.Expiry
+		return false
+	}
+
+	i := (w.pos + offset) % len(w.slots)
+	w.slots[i] = append(w.slots[i], entry)
+	w.entries[entry.Key] = entry
+	return true
+}
+
+// advance turns the wheel to the slot of now, returning the entries of the
+// slots passed.
+func (w *timerWheel) advance(now time.Time) []*schedulerEntry {
+	elapsed := int(now.Sub(w.base) / w.resolution)
+	if elapsed <= 0 {
+		return nil
+	}
+
+	var due []*schedulerEntry
+	for i := 0; i < elapsed && i < len(w.slots); i++ {
+		for _, entry := range w.slots[w.pos] {
+			if w.entries[entry.Key] == entry {
+				delete(w.entries, entry.Key)
+				due = append(due, entry)
+			}
+		}
+		w.slots[w.pos] = nil
+		w.pos = (w.pos + 1) % len(w.slots)
+	}
+	if elapsed > len(w.slots) {
+		// Every slot was passed, however long the wheel was idle.
+		w.pos = (w.pos + elapsed - len(w.slots)) % len(w.slots)
+	}
+	w.base = w.base.Add(time.Duration(elapsed) * w.resolution)
+	return due
+}
+
+// next returns when the next slot holding entries comes due, or the zero time
+// if the wheel holds none.
+func (w *timerWheel) next() time.Time {
+	if len(w.entries) == 0 {
+		return time.Time{}
+	}
+	for i := range w.slots {
+		if len(w.slots[(w.pos+i)%len(w.slots)]) > 0 {
+			return w.base.Add(time.Duration(i+1) * w.resolution)
+		}
+	}
+	return time.Time{}
+}
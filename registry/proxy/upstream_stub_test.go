@@ -0,0 +1,215 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/registry/storage"
+	"github.com/distribution/distribution/v3/registry/storage/cache/memory"
+	"github.com/distribution/distribution/v3/registry/storage/driver/inmemory"
+	"github.com/distribution/reference"
+	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// upstreamStub is an upstream registry serving manifests under a rate limit
+// the way Docker Hub does: manifest GETs are counted against the limit and
+// fail with a 429 once it is exhausted, while HEADs and blobs are free.
+type upstreamStub struct {
+	*httptest.Server
+	limit      int
+	window     int    // seconds
+	retryAfter string // of 429 responses
+
+	mu        sync.Mutex
+	remaining int
+	manifests map[digest.Digest]v1.Descriptor
+	content   map[digest.Digest][]byte // of manifests and blobs
+	tags      map[string]digest.Digest
+	requests  map[string]int // manifest requests by method
+}
+
+func newUpstreamStub(t *testing.T, limit int) *upstreamStub {
+	us := newUnstartedUpstreamStub(limit)
+	us.Start()
+	t.Cleanup(us.Close)
+	return us
+}
+
+// newUnstartedUpstreamStub returns an upstream to be started, for instance
+// with TLS.
+func newUnstartedUpstreamStub(limit int) *upstreamStub {
+	us := &upstreamStub{
+		limit:     limit,
+		window:    21600,
+		remaining: limit,
+		manifests: make(map[digest.Digest]v1.Descriptor),
+		content:   make(map[digest.Digest][]byte),
+		tags:      make(map[string]digest.Digest),
+		requests:  make(map[string]int),
+	}
+	us.Server = httptest.NewUnstartedServer(http.HandlerFunc(us.serveHTTP))
+	return us
+}
+
+// addManifest adds an image manifest with a config and a layer, tagged if
+// tag is not empty, returning its descriptor and the ones of its blobs.
+func (us *upstreamStub) addManifest(tag string) (v1.Descriptor, []v1.Descriptor) {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	n := len(us.content)
+	config := us.addContent(v1.MediaTypeImageConfig, []byte(fmt.Sprintf(`{"n":%d}`, n)))
+	layer := us.addContent(v1.MediaTypeImageLayerGzip, []byte(fmt.Sprintf("layer %d", n)))
+	desc := us.addManifestContent(tag, v1.MediaTypeImageManifest, map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     v1.MediaTypeImageManifest,
+		"config":        config,
+		"layers":        []v1.Descriptor{layer},
+	})
+	return desc, []v1.Descriptor{config, layer}
+}
+
+// addIndex adds an image index of the manifests, tagged if tag is not
+// empty.
+func (us *upstreamStub) addIndex(tag string, manifests ...v1.Descriptor) v1.Descriptor {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	return us.addManifestContent(tag, v1.MediaTypeImageIndex, map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     v1.MediaTypeImageIndex,
+		"manifests":     manifests,
+	})
+}
+
+func (us *upstreamStub) addManifestContent(tag, mediaType string, m interface{}) v1.Descriptor {
+	p, err := json.Marshal(m)
+	if err != nil {
+		panic(err)
+	}
+	desc := us.addContent(mediaType, p)
+	us.manifests[desc.Digest] = desc
+	if tag != "" {
+		us.tags[tag] = desc.Digest
+	}
+	return desc
+}
+
+func (us *upstreamStub) addContent(mediaType string, p []byte) v1.Descriptor {
+	desc := v1.Descriptor{
+		MediaType: mediaType,
+		Digest:    digest.FromBytes(p),
+		Size:      int64(len(p)),
+	}
+	us.content[desc.Digest] = p
+	return desc
+}
+
+func (us *upstreamStub) count(method string) int {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	return us.requests[method]
+}
+
+func (us *upstreamStub) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set(challengeHeader, "registry/2.0")
+	if r.URL.Path == "/v2/" {
+		return
+	}
+
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	if _, ref, ok := strings.Cut(r.URL.Path, "/blobs/"); ok {
+		us.serveContent(w, r, digest.Digest(ref), "application/octet-stream")
+		return
+	}
+	_, ref, ok := strings.Cut(r.URL.Path, "/manifests/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	us.requests[r.Method]++
+	if r.Method == http.MethodGet {
+		if us.remaining == 0 {
+			if us.retryAfter != "" {
+				w.Header().Set("Retry-After", us.retryAfter)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"errors":[{"code":"TOOMANYREQUESTS","message":"You have reached your pull rate limit."}]}`)
+			return
+		}
+		us.remaining--
+	}
+	w.Header().Set(rateLimitLimitHeader, fmt.Sprintf("%d;w=%d", us.limit, us.window))
+	w.Header().Set(rateLimitRemainingHeader, fmt.Sprintf("%d;w=%d", us.remaining, us.window))
+
+	dgst, err := digest.Parse(ref)
+	if err != nil {
+		dgst = us.tags[ref]
+	}
+	desc, ok := us.manifests[dgst]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	us.serveContent(w, r, dgst, desc.MediaType)
+}
+
+func (us *upstreamStub) serveContent(w http.ResponseWriter, r *http.Request, dgst digest.Digest, mediaType string) {
+	p, ok := us.content[dgst]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(p)))
+	w.Header().Set("Docker-Content-Digest", dgst.String())
+	if r.Method == http.MethodGet {
+		w.Write(p)
+	}
+}
+
+// newStubCache returns a pull through cache of the upstream, and its
+// repository of library/busybox.
+func newStubCache(t *testing.T, us *upstreamStub, config configuration.Proxy) (*proxyingRegistry, distribution.Repository) {
+	ctx := context.Background()
+	localRegistry, err := storage.NewRegistry(ctx, inmemory.New(),
+		storage.BlobDescriptorCacheProvider(memory.NewInMemoryBlobDescriptorCacheProvider(memory.UnlimitedSize)))
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+
+	config.RemoteURL = us.URL
+	if config.TTL == nil {
+		ttl := time.Duration(0)
+		config.TTL = &ttl
+	}
+	pr, err := NewRegistryPullThroughCache(ctx, localRegistry, inmemory.New(), config)
+	if err != nil {
+		t.Fatalf("error creating pull through cache: %v", err)
+	}
+
+	name, err := reference.WithName("library/busybox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo, err := pr.Repository(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pr.(*proxyingRegistry), repo
+}
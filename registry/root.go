@@ -3,11 +3,14 @@ package registry
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/handlers"
 	"github.com/distribution/distribution/v3/registry/storage"
 	"github.com/distribution/distribution/v3/registry/storage/driver/factory"
 	"github.com/distribution/distribution/v3/version"
+	events "github.com/docker/go-events"
 	"github.com/spf13/cobra"
 )
 
@@ -76,14 +79,39 @@ var GCCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
+		listener, sink := handlers.NewEventListener(ctx, config, driver)
+		gcListener, _ := listener.(storage.GCListener)
 		err = storage.MarkAndSweep(ctx, driver, registry, storage.GCOpts{
 			DryRun:         dryRun,
 			RemoveUntagged: removeUntagged,
 			Quiet:          quiet,
+			Listener:       gcListener,
 		})
+		flushEvents(sink)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "failed to garbage collect: %v", err)
 			os.Exit(1)
 		}
 	},
 }
+
+// gcEventsTimeout bounds the time the garbage collector waits for its events
+// to be delivered before exiting.
+const gcEventsTimeout = time.Minute
+
+// flushEvents closes the sink, waiting for pending events to be delivered.
+func flushEvents(sink events.Sink) {
+	flushed := make(chan error, 1)
+	go func() {
+		flushed <- sink.Close()
+	}()
+
+	select {
+	case err := <-flushed:
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to deliver events: %v", err)
+		}
+	case <-time.After(gcEventsTimeout):
+		fmt.Fprintf(os.Stderr, "timed out delivering events")
+	}
+}
@@ -513,6 +513,52 @@ func TestBlobMount(t *testing.T) {
 }
 
 // TestLayerUploadZeroLength uploads zero-length
+func TestBlobMountDescriptor(t *testing.T) {
+	ctx := context.Background()
+	registry, err := NewRegistry(ctx, inmemory.New())
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+
+	sourceName, _ := reference.WithName("foo/source")
+	source, err := registry.Repository(ctx, sourceName)
+	if err != nil {
+		t.Fatalf("unexpected error getting repo: %v", err)
+	}
+	desc, err := source.Blobs(ctx).Put(ctx, "application/octet-stream", []byte("mounted"))
+	if err != nil {
+		t.Fatalf("unexpected error putting blob: %v", err)
+	}
+
+	// The blob is found in the blob store of the registry, not a repository.
+	stat, err := registry.BlobStatter().Stat(ctx, desc.Digest)
+	if err != nil {
+		t.Fatalf("unexpected error statting blob: %v", err)
+	}
+
+	name, _ := reference.WithName("foo/bar")
+	repository, err := registry.Repository(ctx, name)
+	if err != nil {
+		t.Fatalf("unexpected error getting repo: %v", err)
+	}
+	bs := repository.Blobs(ctx)
+	bw, err := bs.Create(ctx, WithMountDescriptor(stat))
+	if bw != nil {
+		t.Fatal("unexpected blobwriter returned from Create call, should mount instead")
+	}
+	ebm, ok := err.(distribution.ErrBlobMounted)
+	if !ok {
+		t.Fatalf("unexpected error mounting layer: %v", err)
+	}
+	if ebm.From != nil || ebm.Descriptor.Digest != desc.Digest || ebm.Descriptor.Size != desc.Size {
+		t.Fatalf("unexpected mount: %v", ebm)
+	}
+
+	if _, err := bs.Stat(ctx, desc.Digest); err != nil {
+		t.Fatalf("unexpected error checking for existence: %v", err)
+	}
+}
+
 func TestLayerUploadZeroLength(t *testing.T) {
 	ctx := context.Background()
 	imageName, _ := reference.WithName("foo/bar")
@@ -7,6 +7,7 @@ import (
 	"path"
 	"strings"
 
+	"github.com/distribution/distribution/v3"
 	"github.com/distribution/distribution/v3/registry/storage/driver"
 	"github.com/distribution/reference"
 )
@@ -15,6 +16,13 @@ import (
 // Because it's a quite expensive operation, it should only be used when building up
 // an initial set of repositories.
 func (reg *registry) Repositories(ctx context.Context, repos []string, last string) (int, error) {
+	return reg.RepositoriesWithPrefix(ctx, repos, "", last)
+}
+
+// RepositoriesWithPrefix returns a list, or partial list, of the repositories
+// whose name starts with prefix. Only the directory holding the repositories
+// of the prefix is walked.
+func (reg *registry) RepositoriesWithPrefix(ctx context.Context, repos []string, prefix, last string) (int, error) {
 	filledBuffer := false
 	foundRepos := 0
 
@@ -27,6 +35,17 @@ func (reg *registry) Repositories(ctx context.Context, repos []string, last stri
 		return 0, err
 	}
 
+	from := root
+	if prefix != "" {
+		namespace, err := prefixNamespace(prefix)
+		if err != nil {
+			return 0, err
+		}
+		if namespace != "" {
+			from = path.Join(root, namespace)
+		}
+	}
+
 	startAfter := ""
 	if last != "" {
 		startAfter, err = pathFor(manifestsPathSpec{name: last})
@@ -35,8 +54,16 @@ func (reg *registry) Repositories(ctx context.Context, repos []string, last stri
 		}
 	}
 
-	err = reg.blobStore.driver.Walk(ctx, root, func(fileInfo driver.FileInfo) error {
+	err = reg.blobStore.driver.Walk(ctx, from, func(fileInfo driver.FileInfo) error {
+		if filledBuffer {
+			// The walk resumes in the parents of the hint once stopped.
+			return driver.ErrFilledBuffer
+		}
+
 		err := handleRepository(fileInfo, root, last, func(repoPath string) error {
+			if !strings.HasPrefix(repoPath, prefix) {
+				return nil
+			}
 			repos[foundRepos] = repoPath
 			foundRepos += 1
 			return nil
@@ -51,10 +78,25 @@ func (reg *registry) Repositories(ctx context.Context, repos []string, last stri
 			return driver.ErrFilledBuffer
 		}
 
+		if prefix != "" && fileInfo.IsDir() {
+			dir := fileInfo.Path()[len(root)+1:]
+			if !strings.HasPrefix(dir, prefix) && !strings.HasPrefix(prefix, dir+"/") {
+				if lessPath(prefix, dir) {
+					// The directories which follow are past the prefix.
+					return driver.ErrFilledBuffer
+				}
+				return driver.ErrSkipDir
+			}
+		}
+
 		return nil
 	}, driver.WithStartAfterHint(startAfter))
 
 	if err != nil {
+		if _, ok := err.(driver.PathNotFoundError); ok && prefix != "" {
+			// No repository lies under the prefix.
+			return 0, io.EOF
+		}
 		return foundRepos, err
 	}
 
@@ -67,6 +109,23 @@ func (reg *registry) Repositories(ctx context.Context, repos []string, last stri
 	return foundRepos, io.EOF
 }
 
+// prefixNamespace returns the namespace holding the repositories of prefix,
+// its complete components, validating them and the partial component which
+// follows.
+func prefixNamespace(prefix string) (string, error) {
+	namespace, partial := "", prefix
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		namespace, partial = prefix[:i], prefix[i+1:]
+		if _, err := reference.WithName(namespace); err != nil {
+			return "", distribution.ErrRepositoryNameInvalid{Name: prefix, Reason: err}
+		}
+	}
+	if strings.Trim(partial, "abcdefghijklmnopqrstuvwxyz0123456789._-") != "" {
+		return "", distribution.ErrRepositoryNameInvalid{Name: prefix, Reason: errors.New("invalid repository name prefix")}
+	}
+	return namespace, nil
+}
+
 // Enumerate applies ingester to each repository
 func (reg *registry) Enumerate(ctx context.Context, ingester func(string) error) error {
 	root, err := pathFor(repositoriesRootPathSpec{})
@@ -81,14 +140,157 @@ func (reg *registry) Enumerate(ctx context.Context, ingester func(string) error)
 	return err
 }
 
-// Remove removes a repository from storage
+// Remove removes a repository from storage. The repositories nested under
+// its name are kept.
 func (reg *registry) Remove(ctx context.Context, name reference.Named) error {
+	if !reg.deleteEnabled {
+		return distribution.ErrUnsupported
+	}
+
 	root, err := pathFor(repositoriesRootPathSpec{})
 	if err != nil {
 		return err
 	}
 	repoDir := path.Join(root, name.Name())
-	return reg.driver.Delete(ctx, repoDir)
+
+	children, err := reg.driver.List(ctx, repoDir)
+	if err != nil {
+		if _, ok := err.(driver.PathNotFoundError); ok {
+			return distribution.ErrRepositoryUnknown{Name: name.Name()}
+		}
+		return err
+	}
+
+	// The directories of the repository start with an underscore, unlike
+	// the ones of the nested repositories.
+	var own []string
+	for _, child := range children {
+		if strings.HasPrefix(path.Base(child), "_") {
+			own = append(own, child)
+		}
+	}
+	if len(own) == 0 {
+		return distribution.ErrRepositoryUnknown{Name: name.Name()}
+	}
+
+	if err := reg.clearDescriptorCache(ctx, name); err != nil {
+		return err
+	}
+
+	if len(own) == len(children) {
+		return reg.driver.Delete(ctx, repoDir)
+	}
+	for _, dir := range own {
+		if err := reg.driver.Delete(ctx, dir); err != nil {
+			if _, ok := err.(driver.PathNotFoundError); !ok {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// clearDescriptorCache clears the descriptors cached for the layers of a
+// repository, which would otherwise outlive their links, and be served by a
+// repository created again under the same name.
+func (reg *registry) clearDescriptorCache(ctx context.Context, name reference.Named) error {
+	if reg.blobDescriptorCacheProvider == nil {
+		return nil
+	}
+	descriptorCache, err := reg.blobDescriptorCacheProvider.RepositoryScoped(name.Name())
+	if err != nil {
+		return err
+	}
+	layersPath, err := pathFor(layersPathSpec{name: name.Name()})
+	if err != nil {
+		return err
+	}
+
+	err = reg.driver.Walk(ctx, layersPath, func(fileInfo driver.FileInfo) error {
+		if fileInfo.IsDir() || path.Base(fileInfo.Path()) != "link" {
+			return nil
+		}
+		dgst, err := digestFromPath(path.Dir(fileInfo.Path()))
+		if err != nil {
+			return nil
+		}
+		if err := descriptorCache.Clear(ctx, dgst); err != nil && err != distribution.ErrBlobUnknown {
+			return err
+		}
+		return nil
+	})
+	if _, ok := err.(driver.PathNotFoundError); ok {
+		return nil
+	}
+	return err
+}
+
+// Copy links the layers, manifests and tags of a repository into a new one.
+// The links are written in the order of the walk, the layers first, then the
+// manifest revisions and last the tags, so that a tag is never seen before
+// the content it points to.
+func (reg *registry) Copy(ctx context.Context, from, to reference.Named) error {
+	root, err := pathFor(repositoriesRootPathSpec{})
+	if err != nil {
+		return err
+	}
+	src := path.Join(root, from.Name())
+	dst := path.Join(root, to.Name())
+
+	if _, err := reg.driver.Stat(ctx, path.Join(src, "_manifests")); err != nil {
+		if _, ok := err.(driver.PathNotFoundError); ok {
+			return distribution.ErrRepositoryUnknown{Name: from.Name()}
+		}
+		return err
+	}
+	if exists, err := reg.repositoryExists(ctx, dst); err != nil {
+		return err
+	} else if exists {
+		return distribution.ErrRepositoryExists{Name: to.Name()}
+	}
+
+	for _, dir := range []string{"_layers", "_manifests"} {
+		err := reg.driver.Walk(ctx, path.Join(src, dir), func(fileInfo driver.FileInfo) error {
+			if fileInfo.IsDir() || path.Base(fileInfo.Path()) != "link" {
+				return nil
+			}
+			content, err := reg.driver.GetContent(ctx, fileInfo.Path())
+			if err != nil {
+				if _, ok := err.(driver.PathNotFoundError); ok {
+					// Removed since it was listed.
+					return nil
+				}
+				return err
+			}
+			return reg.driver.PutContent(ctx, dst+strings.TrimPrefix(fileInfo.Path(), src), content)
+		})
+		if err != nil {
+			if _, ok := err.(driver.PathNotFoundError); ok {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// repositoryExists reports whether the repository directory dir holds any
+// repository data, such as layers or uploads even without manifests. A
+// directory holding nothing but nested repositories is not a repository.
+func (reg *registry) repositoryExists(ctx context.Context, dir string) (bool, error) {
+	children, err := reg.driver.List(ctx, dir)
+	if err != nil {
+		if _, ok := err.(driver.PathNotFoundError); ok {
+			return false, nil
+		}
+		return false, err
+	}
+	for _, child := range children {
+		if strings.HasPrefix(path.Base(child), "_") {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 // lessPath returns true if one path a is less than path b.
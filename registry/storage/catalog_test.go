@@ -2,9 +2,11 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"math/rand"
+	"reflect"
 	"testing"
 
 	"github.com/distribution/distribution/v3"
@@ -14,6 +16,7 @@ import (
 	"github.com/distribution/distribution/v3/testutil"
 	"github.com/distribution/reference"
 	"github.com/opencontainers/go-digest"
+	v1 "github.com/opencontainers/image-spec/specs-go/v1"
 )
 
 type setupEnv struct {
@@ -175,6 +178,61 @@ func TestCatalogInParts(t *testing.T) {
 	}
 }
 
+func TestCatalogWithPrefix(t *testing.T) {
+	env := setupFS(t)
+
+	lister, ok := env.registry.(distribution.RepositoryPrefixLister)
+	if !ok {
+		t.Fatal("registry does not implement RepositoryPrefixLister interface")
+	}
+
+	for _, test := range []struct {
+		prefix   string
+		last     string
+		expected []string
+	}{
+		{prefix: "", expected: env.expected},
+		{prefix: "foo/", expected: []string{"foo/a", "foo/b", "foo/d/in"}},
+		{prefix: "foo", expected: []string{"foo/a", "foo/b", "foo/d/in", "foo-bar/a", "foo-bar/b"}},
+		{prefix: "foo/d", expected: []string{"foo/d/in"}},
+		{prefix: "foo-", expected: []string{"foo-bar/a", "foo-bar/b"}},
+		{prefix: "t", expected: []string{"test"}},
+		{prefix: "foo/", last: "foo/a", expected: []string{"foo/b", "foo/d/in"}},
+		{prefix: "foo/", last: "bar/c", expected: []string{"foo/a", "foo/b", "foo/d/in"}},
+		{prefix: "foo/", last: "test", expected: nil},
+		{prefix: "nope/", expected: nil},
+		{prefix: "z", expected: nil},
+	} {
+		// Read a repository at a time, to resume in each of them.
+		var (
+			repos []string
+			last  = test.last
+		)
+		p := make([]string, 1)
+		for {
+			n, err := lister.RepositoriesWithPrefix(env.ctx, p, test.prefix, last)
+			if err != nil && err != io.EOF {
+				t.Fatalf("prefix %q: unexpected error: %v", test.prefix, err)
+			}
+			repos = append(repos, p[:n]...)
+			if err == io.EOF {
+				break
+			}
+			last = p[n-1]
+		}
+		if !reflect.DeepEqual(repos, test.expected) {
+			t.Errorf("prefix %q after %q: expected %v, got %v", test.prefix, test.last, test.expected, repos)
+		}
+	}
+
+	for _, prefix := range []string{"../foo", "foo/../bar", "/foo", "Foo"} {
+		_, err := lister.RepositoriesWithPrefix(env.ctx, make([]string, 1), prefix, "")
+		if _, ok := err.(distribution.ErrRepositoryNameInvalid); !ok {
+			t.Errorf("prefix %q: expected ErrRepositoryNameInvalid, got %v", prefix, err)
+		}
+	}
+}
+
 func TestCatalogEnumerate(t *testing.T) {
 	env := setupFS(t)
 
@@ -197,6 +255,183 @@ func TestCatalogEnumerate(t *testing.T) {
 	}
 }
 
+func TestCatalogRemove(t *testing.T) {
+	ctx := context.Background()
+	registry, err := NewRegistry(ctx, inmemory.New(), EnableDelete)
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+	for _, repo := range []string{"foo", "foo/bar", "baz"} {
+		makeRepo(ctx, t, repo, registry)
+	}
+
+	remover := registry.(distribution.RepositoryRemover)
+	for _, repo := range []string{"foo", "baz"} {
+		named, _ := reference.WithName(repo)
+		if err := remover.Remove(ctx, named); err != nil {
+			t.Fatalf("unexpected error removing %s: %v", repo, err)
+		}
+	}
+
+	p := make([]string, 10)
+	n, err := registry.Repositories(ctx, p, "")
+	if err != io.EOF {
+		t.Fatalf("unexpected error listing repositories: %v", err)
+	}
+	if !reflect.DeepEqual(p[:n], []string{"foo/bar"}) {
+		t.Fatalf("expected the nested repository to be kept, got %v", p[:n])
+	}
+
+	for _, repo := range []string{"foo", "unknown"} {
+		named, _ := reference.WithName(repo)
+		if err := remover.Remove(ctx, named); !errors.As(err, &distribution.ErrRepositoryUnknown{}) {
+			t.Errorf("expected ErrRepositoryUnknown removing %s, got %v", repo, err)
+		}
+	}
+
+	env := setupFS(t)
+	named, _ := reference.WithName("foo/a")
+	if err := env.registry.(distribution.RepositoryRemover).Remove(env.ctx, named); err != distribution.ErrUnsupported {
+		t.Errorf("expected ErrUnsupported removing with deletes disabled, got %v", err)
+	}
+}
+
+func TestCatalogRemoveClearsDescriptorCache(t *testing.T) {
+	ctx := context.Background()
+	provider := memory.NewInMemoryBlobDescriptorCacheProvider(memory.UnlimitedSize)
+	registry, err := NewRegistry(ctx, inmemory.New(), BlobDescriptorCacheProvider(provider), EnableDelete)
+	if err != nil {
+		t.Fatalf("error creating registry: %v", err)
+	}
+	makeRepo(ctx, t, "foo", registry)
+
+	named, _ := reference.WithName("foo")
+	repo, err := registry.Repository(ctx, named)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var layers []digest.Digest
+	blobs := repo.Blobs(ctx)
+	if err := blobs.(distribution.BlobEnumerator).Enumerate(ctx, func(dgst digest.Digest) error {
+		layers = append(layers, dgst)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(layers) == 0 {
+		t.Fatal("expected the repository to hold layers")
+	}
+
+	scoped, err := provider.RepositoryScoped("foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dgst := range layers {
+		if _, err := blobs.Stat(ctx, dgst); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := scoped.Stat(ctx, dgst); err != nil {
+			t.Fatalf("expected %s to be cached: %v", dgst, err)
+		}
+	}
+
+	if err := registry.(distribution.RepositoryRemover).Remove(ctx, named); err != nil {
+		t.Fatal(err)
+	}
+	for _, dgst := range layers {
+		if _, err := scoped.Stat(ctx, dgst); err != distribution.ErrBlobUnknown {
+			t.Fatalf("expected %s to be cleared from the cache, got %v", dgst, err)
+		}
+		if _, err := blobs.Stat(ctx, dgst); err != distribution.ErrBlobUnknown {
+			t.Fatalf("expected %s to be unknown to the removed repository, got %v", dgst, err)
+		}
+	}
+}
+
+func TestCatalogCopy(t *testing.T) {
+	env := setupFS(t)
+
+	from, _ := reference.WithName("foo/a")
+	to, _ := reference.WithName("foo/copy")
+
+	repo, err := env.registry.Repository(env.ctx, from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifests, err := repo.Manifests(env.ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var revisions []digest.Digest
+	if err := manifests.(distribution.ManifestEnumerator).Enumerate(env.ctx, func(dgst digest.Digest) error {
+		revisions = append(revisions, dgst)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 1 {
+		t.Fatalf("unexpected revisions: %v", revisions)
+	}
+	if err := repo.Tags(env.ctx).Tag(env.ctx, "latest", v1.Descriptor{Digest: revisions[0]}); err != nil {
+		t.Fatal(err)
+	}
+
+	copier := env.registry.(distribution.RepositoryCopier)
+	if err := copier.Copy(env.ctx, from, to); err != nil {
+		t.Fatalf("unexpected error copying: %v", err)
+	}
+
+	copied, err := env.registry.Repository(env.ctx, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desc, err := copied.Tags(env.ctx).Get(env.ctx, "latest")
+	if err != nil {
+		t.Fatalf("unexpected error getting the copied tag: %v", err)
+	}
+	if desc.Digest != revisions[0] {
+		t.Fatalf("unexpected digest of the copied tag: %s", desc.Digest)
+	}
+	copiedManifests, err := copied.Manifests(env.ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := copiedManifests.Get(env.ctx, revisions[0])
+	if err != nil {
+		t.Fatalf("unexpected error getting the copied manifest: %v", err)
+	}
+	for _, ref := range m.References() {
+		if _, err := copied.Blobs(env.ctx).Stat(env.ctx, ref.Digest); err != nil {
+			t.Fatalf("unexpected error statting the copied layer %s: %v", ref.Digest, err)
+		}
+	}
+
+	if err := copier.Copy(env.ctx, from, to); !errors.As(err, &distribution.ErrRepositoryExists{}) {
+		t.Errorf("expected ErrRepositoryExists copying twice, got %v", err)
+	}
+	// A repository holding nothing but an upload exists.
+	partial, _ := reference.WithName("foo/partial")
+	partialRepo, err := env.registry.Repository(env.ctx, partial)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := partialRepo.Blobs(env.ctx).Create(env.ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := copier.Copy(env.ctx, from, partial); !errors.As(err, &distribution.ErrRepositoryExists{}) {
+		t.Errorf("expected ErrRepositoryExists copying into a repository with an upload, got %v", err)
+	}
+	// A directory of nested repositories does not.
+	namespace, _ := reference.WithName("foo")
+	if err := copier.Copy(env.ctx, from, namespace); err != nil {
+		t.Errorf("unexpected error copying into the parent of other repositories: %v", err)
+	}
+	unknown, _ := reference.WithName("unknown")
+	if err := copier.Copy(env.ctx, unknown, to); !errors.As(err, &distribution.ErrRepositoryUnknown{}) {
+		t.Errorf("expected ErrRepositoryUnknown copying an unknown repository, got %v", err)
+	}
+}
+
 func testEq(a, b []string, size int) bool {
 	for cnt := 0; cnt < size-1; cnt++ {
 		if a[cnt] != b[cnt] {
@@ -6,6 +6,7 @@ import (
 	"fmt"
 
 	"github.com/distribution/distribution/v3"
+	"github.com/distribution/distribution/v3/internal/dcontext"
 	"github.com/distribution/distribution/v3/registry/storage/driver"
 	"github.com/distribution/reference"
 	"github.com/opencontainers/go-digest"
@@ -21,6 +22,19 @@ type GCOpts struct {
 	DryRun         bool
 	RemoveUntagged bool
 	Quiet          bool
+
+	// Listener, if set, is told of everything the garbage collector
+	// removes.
+	Listener GCListener
+}
+
+// GCListener is told of the removals of a garbage collection, as they
+// happen. Errors returned by the listener are logged and do not stop the
+// collection.
+type GCListener interface {
+	ManifestSwept(repo reference.Named, dgst digest.Digest) error
+	LayerSwept(repo reference.Named, dgst digest.Digest) error
+	BlobSwept(dgst digest.Digest) error
 }
 
 // ManifestDel contains manifest structure which will be deleted
@@ -151,6 +165,9 @@ func MarkAndSweep(ctx context.Context, storageDriver driver.StorageDriver, regis
 			if err != nil {
 				return fmt.Errorf("failed to delete manifest %s: %v", obj.Digest, err)
 			}
+			notifySwept(ctx, opts.Listener, obj.Name, func(repo reference.Named) error {
+				return opts.Listener.ManifestSwept(repo, obj.Digest)
+			})
 		}
 	}
 	blobService := registry.Blobs()
@@ -179,6 +196,11 @@ func MarkAndSweep(ctx context.Context, storageDriver driver.StorageDriver, regis
 		if err != nil {
 			return fmt.Errorf("failed to delete blob %s: %v", dgst, err)
 		}
+		if opts.Listener != nil {
+			if err := opts.Listener.BlobSwept(dgst); err != nil {
+				dcontext.GetLogger(ctx).Errorf("error dispatching blob sweep to listener: %v", err)
+			}
+		}
 	}
 
 	for repo, dgsts := range deleteLayerSet {
@@ -193,12 +215,29 @@ func MarkAndSweep(ctx context.Context, storageDriver driver.StorageDriver, regis
 			if err != nil {
 				return fmt.Errorf("failed to delete layer link %s of repo %s: %v", dgst, repo, err)
 			}
+			notifySwept(ctx, opts.Listener, repo, func(repo reference.Named) error {
+				return opts.Listener.LayerSwept(repo, dgst)
+			})
 		}
 	}
 
 	return err
 }
 
+// notifySwept calls notify with the named repository, if there is a listener.
+func notifySwept(ctx context.Context, listener GCListener, repoName string, notify func(reference.Named) error) {
+	if listener == nil {
+		return
+	}
+	named, err := reference.WithName(repoName)
+	if err == nil {
+		err = notify(named)
+	}
+	if err != nil {
+		dcontext.GetLogger(ctx).Errorf("error dispatching sweep of %s to listener: %v", repoName, err)
+	}
+}
+
 // unmarkReferencedManifest filters out manifest present in markSet
 func unmarkReferencedManifest(manifestArr []ManifestDel, markSet map[digest.Digest]struct{}, quietOutput bool) []ManifestDel {
 	filtered := make([]ManifestDel, 0)
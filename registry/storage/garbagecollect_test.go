@@ -923,3 +923,84 @@ func TestTaggedManifestlistWithDeletedReference(t *testing.T) {
 		t.Fatalf("Garbage collection affected storage: %d != %d", len(after), 0)
 	}
 }
+
+type sweepRecorder struct {
+	manifests map[digest.Digest]string
+	layers    map[digest.Digest]string
+	blobs     map[digest.Digest]struct{}
+}
+
+func (sr *sweepRecorder) ManifestSwept(repo reference.Named, dgst digest.Digest) error {
+	sr.manifests[dgst] = repo.Name()
+	return nil
+}
+
+func (sr *sweepRecorder) LayerSwept(repo reference.Named, dgst digest.Digest) error {
+	sr.layers[dgst] = repo.Name()
+	return nil
+}
+
+func (sr *sweepRecorder) BlobSwept(dgst digest.Digest) error {
+	sr.blobs[dgst] = struct{}{}
+	return nil
+}
+
+func TestGCListener(t *testing.T) {
+	inmemoryDriver := inmemory.New()
+
+	registry := createRegistry(t, inmemoryDriver)
+	repo := makeRepository(t, registry, "palaiologos")
+	kept := uploadRandomSchema2Image(t, repo)
+	image := uploadRandomSchema2Image(t, repo)
+	if err := repo.Tags(dcontext.Background()).Tag(dcontext.Background(), "kept", v1.Descriptor{Digest: kept.manifestDigest}); err != nil {
+		t.Fatalf("failed to tag manifest: %v", err)
+	}
+
+	sr := &sweepRecorder{
+		manifests: make(map[digest.Digest]string),
+		layers:    make(map[digest.Digest]string),
+		blobs:     make(map[digest.Digest]struct{}),
+	}
+
+	// A dry run removes nothing, so there is nothing to hear of.
+	err := MarkAndSweep(dcontext.Background(), inmemoryDriver, registry, GCOpts{
+		DryRun:         true,
+		RemoveUntagged: true,
+		Quiet:          true,
+		Listener:       sr,
+	})
+	if err != nil {
+		t.Fatalf("Failed mark and sweep: %v", err)
+	}
+	if len(sr.manifests)+len(sr.layers)+len(sr.blobs) != 0 {
+		t.Fatalf("unexpected sweeps in dry run: %+v", sr)
+	}
+
+	err = MarkAndSweep(dcontext.Background(), inmemoryDriver, registry, GCOpts{
+		DryRun:         false,
+		RemoveUntagged: true,
+		Quiet:          true,
+		Listener:       sr,
+	})
+	if err != nil {
+		t.Fatalf("Failed mark and sweep: %v", err)
+	}
+
+	if repoName := sr.manifests[image.manifestDigest]; len(sr.manifests) != 1 || repoName != "palaiologos" {
+		t.Fatalf("expected untagged manifest to be swept from palaiologos, got %v", sr.manifests)
+	}
+	if _, ok := sr.blobs[image.manifestDigest]; !ok {
+		t.Fatalf("expected manifest blob to be swept, got %v", sr.blobs)
+	}
+	for layer := range image.layers {
+		if _, ok := sr.blobs[layer]; !ok {
+			t.Fatalf("expected layer blob %v to be swept, got %v", layer, sr.blobs)
+		}
+		if repoName := sr.layers[layer]; repoName != "palaiologos" {
+			t.Fatalf("expected layer link %v to be swept from palaiologos, got %v", layer, sr.layers)
+		}
+	}
+	if _, ok := sr.blobs[kept.manifestDigest]; ok {
+		t.Fatal("tagged manifest was swept")
+	}
+}
@@ -124,6 +124,25 @@ func WithMountFrom(ref reference.Canonical) distribution.BlobCreateOption {
 	})
 }
 
+// WithMountDescriptor returns a BlobCreateOption which designates that the
+// blob described should be mounted from the blob store of the registry,
+// rather than from another repository. The caller must ensure the blob may be
+// accessed, as no repository is checked.
+func WithMountDescriptor(desc v1.Descriptor) distribution.BlobCreateOption {
+	return optionFunc(func(v interface{}) error {
+		opts, ok := v.(*distribution.CreateOptions)
+		if !ok {
+			return fmt.Errorf("unexpected options type: %T", v)
+		}
+
+		opts.Mount.ShouldMount = true
+		opts.Mount.From = nil
+		opts.Mount.Stat = &desc
+
+		return nil
+	})
+}
+
 // Create begins a blob write session, returning a handle.
 func (lbs *linkedBlobStore) Create(ctx context.Context, options ...distribution.BlobCreateOption) (distribution.BlobWriter, error) {
 	dcontext.GetLogger(ctx).Debug("(*linkedBlobStore).Create")
@@ -137,8 +156,14 @@ func (lbs *linkedBlobStore) Create(ctx context.Context, options ...distribution.
 		}
 	}
 
-	if opts.Mount.ShouldMount {
-		desc, err := lbs.mount(ctx, opts.Mount.From, opts.Mount.From.Digest(), opts.Mount.Stat)
+	if opts.Mount.ShouldMount && (opts.Mount.From != nil || opts.Mount.Stat != nil) {
+		var dgst digest.Digest
+		if opts.Mount.From != nil {
+			dgst = opts.Mount.From.Digest()
+		} else {
+			dgst = opts.Mount.Stat.Digest
+		}
+		desc, err := lbs.mount(ctx, opts.Mount.From, dgst, opts.Mount.Stat)
 		if err == nil {
 			// Mount successful, no need to initiate an upload session
 			return nil, distribution.ErrBlobMounted{From: opts.Mount.From, Descriptor: desc}
@@ -10,6 +10,7 @@ import (
 	"github.com/distribution/distribution/v3/manifest/manifestlist"
 	"github.com/distribution/distribution/v3/manifest/ocischema"
 	"github.com/distribution/distribution/v3/manifest/schema2"
+	"github.com/distribution/reference"
 	"github.com/opencontainers/go-digest"
 	"github.com/opencontainers/image-spec/specs-go"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
@@ -53,7 +54,10 @@ type manifestStore struct {
 	ocischemaIndexHandler ManifestHandler
 }
 
-var _ distribution.ManifestService = &manifestStore{}
+var (
+	_ distribution.ManifestService = &manifestStore{}
+	_ distribution.ManifestCopier  = &manifestStore{}
+)
 
 func (ms *manifestStore) Exists(ctx context.Context, dgst digest.Digest) (bool, error) {
 	dcontext.GetLogger(ms.ctx).Debug("(*manifestStore).Exists")
@@ -164,3 +168,109 @@ func (ms *manifestStore) Enumerate(ctx context.Context, ingester func(digest.Dig
 	})
 	return err
 }
+
+// Copy links the manifest dgst of the repository from, the manifests it
+// references and their blobs into the repository. The manifests of an index
+// are linked before the index, so that a copy interrupted never leaves an
+// index without its manifests.
+func (ms *manifestStore) Copy(ctx context.Context, from reference.Named, dgst digest.Digest, options ...distribution.ManifestServiceOption) ([]distribution.Manifest, error) {
+	dcontext.GetLogger(ms.ctx).Debug("(*manifestStore).Copy")
+
+	repo, err := ms.repository.registry.Repository(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	source, err := repo.Manifests(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mc := &manifestCopy{
+		manifests:   ms.blobStore,
+		blobs:       ms.repository.Blobs(ctx).(*linkedBlobStore),
+		source:      source,
+		sourceBlobs: repo.Blobs(ctx),
+		seen:        make(map[digest.Digest]struct{}),
+	}
+	if err := mc.copy(ctx, dgst, false); err != nil {
+		return nil, err
+	}
+	return mc.copied, nil
+}
+
+// manifestCopy tracks the manifests linked by a copy.
+type manifestCopy struct {
+	manifests   *linkedBlobStore
+	blobs       *linkedBlobStore
+	source      distribution.ManifestService
+	sourceBlobs distribution.BlobStatter
+	seen        map[digest.Digest]struct{}
+	copied      []distribution.Manifest
+}
+
+// copy links the manifest dgst. A child of an index missing from the source
+// is skipped, as indexes may be pushed without all of their manifests.
+func (mc *manifestCopy) copy(ctx context.Context, dgst digest.Digest, child bool) error {
+	if _, ok := mc.seen[dgst]; ok {
+		return nil
+	}
+	mc.seen[dgst] = struct{}{}
+
+	manifest, err := mc.source.Get(ctx, dgst)
+	if err != nil {
+		if _, ok := err.(distribution.ErrManifestUnknownRevision); ok && child {
+			return nil
+		}
+		return err
+	}
+
+	switch manifest.(type) {
+	case *manifestlist.DeserializedManifestList, *ocischema.DeserializedImageIndex:
+		for _, desc := range manifest.References() {
+			if err := mc.copy(ctx, desc.Digest, true); err != nil {
+				return err
+			}
+		}
+	default:
+		for _, desc := range manifest.References() {
+			stat, err := mc.sourceBlobs.Stat(ctx, desc.Digest)
+			if err == distribution.ErrBlobUnknown {
+				// Non-distributable layers need not be stored.
+				if len(desc.URLs) > 0 {
+					continue
+				}
+				return distribution.ErrManifestVerification{distribution.ErrManifestBlobUnknown{Digest: desc.Digest}}
+			}
+			if err != nil {
+				return err
+			}
+			if err := mc.link(ctx, mc.blobs, stat); err != nil {
+				return err
+			}
+		}
+	}
+
+	mediaType, payload, err := manifest.Payload()
+	if err != nil {
+		return err
+	}
+	desc := v1.Descriptor{
+		MediaType: mediaType,
+		Digest:    dgst,
+		Size:      int64(len(payload)),
+	}
+	if err := mc.link(ctx, mc.manifests, desc); err != nil {
+		return err
+	}
+	mc.copied = append(mc.copied, manifest)
+	return nil
+}
+
+// link links the blob into the store, recording its descriptor as a put
+// would.
+func (mc *manifestCopy) link(ctx context.Context, lbs *linkedBlobStore, desc v1.Descriptor) error {
+	if err := lbs.linkBlob(ctx, desc); err != nil {
+		return err
+	}
+	return lbs.blobAccessController.SetDescriptor(ctx, desc.Digest, desc)
+}
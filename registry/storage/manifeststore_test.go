@@ -872,3 +872,86 @@ func TestManifestStorageCache(t *testing.T) {
 		t.Errorf("Unexpected error getting cached manifest: %v", err)
 	}
 }
+
+func TestManifestStoreCopy(t *testing.T) {
+	sourceName, _ := reference.WithName("foo/source")
+	env := newManifestStoreTestEnv(t, sourceName, "thetag",
+		BlobDescriptorCacheProvider(memory.NewInMemoryBlobDescriptorCacheProvider(memory.UnlimitedSize)))
+
+	ctx := context.Background()
+	source, err := env.repository.Manifests(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An index with an image missing, which is skipped by the copy.
+	blobStore := env.repository.Blobs(ctx)
+	var images []distribution.Manifest
+	var descriptors []v1.Descriptor
+	for i := 0; i < 3; i++ {
+		image, err := createRandomImage(t, t.Name(), v1.MediaTypeImageManifest, blobStore)
+		if err != nil {
+			t.Fatalf("unexpected error generating random image: %v", err)
+		}
+		if i < 2 {
+			if _, err := source.Put(ctx, image); err != nil {
+				t.Fatalf("unexpected error putting image: %v", err)
+			}
+			images = append(images, image)
+		}
+		descriptors = append(descriptors, createOciManifestDescriptor(t, t.Name(), image, &v1.Platform{Architecture: "amd64", OS: "linux"}))
+	}
+	index, err := ociIndexFromDesriptorsWithMediaType(descriptors, v1.MediaTypeImageIndex)
+	if err != nil {
+		t.Fatalf("unexpected error creating image index: %v", err)
+	}
+	indexDigest, err := source.Put(ctx, index)
+	if err != nil {
+		t.Fatalf("unexpected error putting image index: %v", err)
+	}
+
+	targetName, _ := reference.WithName("foo/target")
+	target, err := env.registry.Repository(ctx, targetName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targetManifests, err := target.Manifests(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copier := targetManifests.(distribution.ManifestCopier)
+
+	copied, err := copier.Copy(ctx, sourceName, indexDigest)
+	if err != nil {
+		t.Fatalf("unexpected error copying image index: %v", err)
+	}
+	if len(copied) != 3 {
+		t.Fatalf("expected 3 manifests copied, got %d", len(copied))
+	}
+	if _, payload, _ := copied[2].Payload(); digest.FromBytes(payload) != indexDigest {
+		t.Fatalf("expected the image index to be copied last")
+	}
+
+	if _, err := targetManifests.Get(ctx, indexDigest); err != nil {
+		t.Fatalf("unexpected error getting copied image index: %v", err)
+	}
+	for _, image := range images {
+		_, payload, _ := image.Payload()
+		if _, err := targetManifests.Get(ctx, digest.FromBytes(payload)); err != nil {
+			t.Fatalf("unexpected error getting copied image: %v", err)
+		}
+		for _, desc := range image.References() {
+			if _, err := target.Blobs(ctx).Stat(ctx, desc.Digest); err != nil {
+				t.Fatalf("unexpected error statting copied blob %s: %v", desc.Digest, err)
+			}
+		}
+	}
+	if exists, _ := targetManifests.Exists(ctx, descriptors[2].Digest); exists {
+		t.Fatalf("expected the missing image not to be copied")
+	}
+
+	_, err = copier.Copy(ctx, sourceName, descriptors[2].Digest)
+	if _, ok := err.(distribution.ErrManifestUnknownRevision); !ok {
+		t.Fatalf("expected ErrManifestUnknownRevision copying a missing manifest, got %v", err)
+	}
+}
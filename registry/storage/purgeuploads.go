@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"fmt"
 	"path"
 	"strings"
 	"time"
@@ -53,6 +54,25 @@ func PurgeUploads(ctx context.Context, driver storageDriver.StorageDriver, older
 	return deleted, errors
 }
 
+// UploadFromPath returns the repository name and the id of the upload kept in
+// dir, an upload directory as returned by PurgeUploads.
+func UploadFromPath(dir string) (name, id string, err error) {
+	root, err := pathFor(repositoriesRootPathSpec{})
+	if err != nil {
+		return "", "", err
+	}
+
+	rest, ok := strings.CutPrefix(dir, root+"/")
+	if !ok {
+		return "", "", fmt.Errorf("%s is not an upload directory", dir)
+	}
+	name, id, ok = strings.Cut(rest, "/_uploads/")
+	if !ok || name == "" || id == "" || strings.Contains(id, "/") {
+		return "", "", fmt.Errorf("%s is not an upload directory", dir)
+	}
+	return name, id, nil
+}
+
 // getOutstandingUploads walks the upload directory, collecting files
 // which could be eligible for deletion.  The only reliable way to
 // classify the age of a file is with the date stored in the startedAt
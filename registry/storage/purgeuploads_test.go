@@ -163,3 +163,28 @@ func TestPurgeMissingStartedAt(t *testing.T) {
 		t.Errorf("Files unexpectedly deleted: %s", deleted)
 	}
 }
+
+func TestUploadFromPath(t *testing.T) {
+	id := uuid.NewString()
+	fs, ctx := testUploadFS(t, 0, "", time.Now())
+	addUploads(ctx, t, fs, id, "library/test-repo", time.Now().Add(-1*time.Hour))
+
+	deleted, errs := PurgeUploads(ctx, fs, time.Now(), true)
+	if len(errs) != 0 || len(deleted) != 1 {
+		t.Fatalf("unexpected purge: %v, %v", deleted, errs)
+	}
+
+	name, uploadID, err := UploadFromPath(deleted[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name != "library/test-repo" || uploadID != id {
+		t.Fatalf("unexpected upload %s of %s", uploadID, name)
+	}
+
+	for _, p := range []string{"/", "/docker/registry/v2/repositories/test-repo", "/docker/registry/v2/repositories/test-repo/_uploads/" + id + "/data"} {
+		if _, _, err := UploadFromPath(p); err == nil {
+			t.Errorf("expected error for %s", p)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"io"
 	"path"
 	"sort"
 	"sync"
@@ -14,7 +16,10 @@ import (
 	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
 )
 
-var _ distribution.TagService = &tagStore{}
+var (
+	_ distribution.TagService       = &tagStore{}
+	_ distribution.TagDetailService = &tagStore{}
+)
 
 // tagStore provides methods to manage manifest tags in a backend storage driver.
 // This implementation uses the same on-disk layout as the (now deleted) tag
@@ -59,6 +64,144 @@ func (ts *tagStore) All(ctx context.Context) ([]string, error) {
 	return tags, nil
 }
 
+// Page fills tags with the tags following last, walking the tags of the
+// storage from last rather than listing them all.
+func (ts *tagStore) Page(ctx context.Context, tags []string, last string) (int, error) {
+	if len(tags) == 0 {
+		return 0, errors.New("attempted to list 0 tags")
+	}
+
+	root, err := pathFor(manifestTagsPathSpec{
+		name: ts.repository.Named().Name(),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	startAfter := ""
+	if last != "" {
+		startAfter = path.Join(root, last)
+	}
+
+	found := 0
+	filledBuffer := false
+	err = ts.blobStore.driver.Walk(ctx, root, func(fileInfo storagedriver.FileInfo) error {
+		if !fileInfo.IsDir() || path.Dir(fileInfo.Path()) != root {
+			return nil
+		}
+		tag := path.Base(fileInfo.Path())
+		if tag <= last {
+			return storagedriver.ErrSkipDir
+		}
+
+		tags[found] = tag
+		found++
+		if found == len(tags) {
+			filledBuffer = true
+			return storagedriver.ErrFilledBuffer
+		}
+		return storagedriver.ErrSkipDir
+	}, storagedriver.WithStartAfterHint(startAfter))
+	if err != nil {
+		switch err := err.(type) {
+		case storagedriver.PathNotFoundError:
+			return 0, distribution.ErrRepositoryUnknown{Name: ts.repository.Named().Name()}
+		default:
+			return found, err
+		}
+	}
+
+	if filledBuffer {
+		// There are potentially more tags to list
+		return found, nil
+	}
+	return found, io.EOF
+}
+
+// Details returns the manifests the tags point to, and when their current
+// link was written. The manifests a tag points to but which are missing
+// from the storage are only described by their digest.
+func (ts *tagStore) Details(ctx context.Context, tags []string) ([]distribution.TagDetail, error) {
+	manifests, err := ts.repository.Manifests(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(ts.concurrencyLimit)
+
+	details := make([]distribution.TagDetail, len(tags))
+	found := make([]bool, len(tags))
+	for i, tag := range tags {
+		if ctx.Err() != nil {
+			break
+		}
+
+		g.Go(func() error {
+			currentPath, err := pathFor(manifestTagCurrentPathSpec{
+				name: ts.repository.Named().Name(),
+				tag:  tag,
+			})
+			if err != nil {
+				return err
+			}
+
+			fileInfo, err := ts.blobStore.driver.Stat(ctx, currentPath)
+			if err != nil {
+				switch err.(type) {
+				case storagedriver.PathNotFoundError:
+					return nil
+				}
+				return err
+			}
+			revision, err := ts.blobStore.readlink(ctx, currentPath)
+			if err != nil {
+				switch err.(type) {
+				case storagedriver.PathNotFoundError:
+					return nil
+				}
+				return err
+			}
+
+			desc := v1.Descriptor{Digest: revision}
+			m, err := manifests.Get(ctx, revision)
+			switch err.(type) {
+			case nil:
+				mediaType, payload, err := m.Payload()
+				if err != nil {
+					return err
+				}
+				desc.MediaType = mediaType
+				desc.Size = int64(len(payload))
+			case distribution.ErrManifestUnknownRevision:
+			default:
+				return err
+			}
+
+			details[i] = distribution.TagDetail{
+				Name:       tag,
+				Descriptor: desc,
+				Modified:   fileInfo.ModTime(),
+			}
+			found[i] = true
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	n := 0
+	for i := range details {
+		if found[i] {
+			details[n] = details[i]
+			n++
+		}
+	}
+	return details[:n], nil
+}
+
 // Tag tags the digest with the given tag, updating the store to point at
 // the current tag. The digest must point to a manifest.
 func (ts *tagStore) Tag(ctx context.Context, tag string, desc v1.Descriptor) error {
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"context"
+	"io"
 	"reflect"
 	"testing"
 
@@ -166,6 +167,125 @@ func TestTagStoreAll(t *testing.T) {
 	}
 }
 
+func TestTagStorePage(t *testing.T) {
+	env := testTagStore(t)
+	ctx := env.ctx
+
+	tds, ok := env.ts.(distribution.TagDetailService)
+	if !ok {
+		t.Fatal("tagStore does not implement TagDetailService interface")
+	}
+
+	buf := make([]string, 3)
+	if _, err := tds.Page(ctx, buf, ""); err == nil {
+		t.Fatal("expected error paging the tags of an unknown repository")
+	} else if _, ok := err.(distribution.ErrRepositoryUnknown); !ok {
+		t.Fatalf("unexpected error paging the tags of an unknown repository: %v", err)
+	}
+
+	alpha := "abcdefgh"
+	desc := v1.Descriptor{Digest: "sha256:eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee"}
+	for i := len(alpha) - 1; i >= 0; i-- {
+		if err := env.ts.Tag(ctx, string(alpha[i]), desc); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var (
+		all  []string
+		last string
+	)
+	for {
+		n, err := tds.Page(ctx, buf, last)
+		if err != nil && err != io.EOF {
+			t.Fatal(err)
+		}
+		all = append(all, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if n != len(buf) {
+			t.Fatalf("unexpected page size without io.EOF: %d", n)
+		}
+		last = buf[n-1]
+	}
+	if expected := []string{"a", "b", "c", "d", "e", "f", "g", "h"}; !reflect.DeepEqual(all, expected) {
+		t.Fatalf("unexpected tags paged: %v != %v", all, expected)
+	}
+
+	n, err := tds.Page(ctx, buf, "f")
+	if err != io.EOF {
+		t.Fatalf("expected io.EOF paging the last tags: %v", err)
+	}
+	if !reflect.DeepEqual(buf[:n], []string{"g", "h"}) {
+		t.Fatalf("unexpected tags after f: %v", buf[:n])
+	}
+}
+
+func TestTagStoreDetails(t *testing.T) {
+	env := testTagStore(t)
+	ctx := env.ctx
+
+	tds, ok := env.ts.(distribution.TagDetailService)
+	if !ok {
+		t.Fatal("tagStore does not implement TagDetailService interface")
+	}
+
+	conf, err := env.bs.Put(ctx, schema2.MediaTypeImageConfig, []byte("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dm, err := schema2.FromStruct(schema2.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: schema2.MediaTypeManifest,
+		Config: v1.Descriptor{
+			Digest:    conf.Digest,
+			Size:      conf.Size,
+			MediaType: schema2.MediaTypeImageConfig,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dgst, err := env.ms.Put(ctx, dm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, payload, err := dm.Payload()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := env.ts.Tag(ctx, "latest", v1.Descriptor{Digest: dgst}); err != nil {
+		t.Fatal(err)
+	}
+	dangling := digest.Digest("sha256:eeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeeee")
+	if err := env.ts.Tag(ctx, "dangling", v1.Descriptor{Digest: dangling}); err != nil {
+		t.Fatal(err)
+	}
+
+	details, err := tds.Details(ctx, []string{"latest", "unknown", "dangling"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(details) != 2 {
+		t.Fatalf("unexpected details: %v", details)
+	}
+
+	latest := details[0]
+	if latest.Name != "latest" || latest.Descriptor.Digest != dgst ||
+		latest.Descriptor.MediaType != schema2.MediaTypeManifest || latest.Descriptor.Size != int64(len(payload)) {
+		t.Errorf("unexpected details of latest: %+v", latest)
+	}
+	if latest.Modified.IsZero() {
+		t.Error("expected the modification time of latest")
+	}
+
+	if d := details[1]; d.Name != "dangling" || d.Descriptor.Digest != dangling || d.Descriptor.MediaType != "" {
+		t.Errorf("unexpected details of dangling: %+v", d)
+	}
+}
+
 func TestTagLookup(t *testing.T) {
 	env := testTagStore(t)
 	tagStore := env.ts
@@ -0,0 +1,149 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	gorhandlers "github.com/gorilla/handlers"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/distribution/distribution/v3/configuration"
+	"github.com/distribution/distribution/v3/internal/dcontext"
+	"github.com/distribution/distribution/v3/registry/auth"
+	"github.com/distribution/distribution/v3/registry/auth/tokenserver"
+	"github.com/distribution/distribution/v3/version"
+)
+
+const defaultTokenServerPath = "/auth/token"
+
+var jwksPath string
+
+func init() {
+	RootCmd.AddCommand(TokenServerCmd)
+	TokenServerCmd.Flags().StringVarP(&jwksPath, "write-jwks", "j", "", "write the public signing key as a JSON Web Key Set to this path and exit")
+}
+
+// TokenServerCmd is the cobra command that corresponds to the token-server subcommand
+var TokenServerCmd = &cobra.Command{
+	Use:   "token-server <config>",
+	Short: "`token-server` issues bearer tokens for the token access controller",
+	Long:  "`token-server` issues bearer tokens for the token access controller.",
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := dcontext.WithVersion(dcontext.Background(), version.Version())
+
+		config, err := resolveConfiguration(args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "configuration error: %v\n", err)
+			// nolint:errcheck
+			cmd.Usage()
+			os.Exit(1)
+		}
+
+		ctx, err = configureLogging(ctx, config)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "unable to configure logging with config: %s", err)
+			os.Exit(1)
+		}
+
+		server, err := newTokenServer(config.TokenServer)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to construct token server: %v\n", err)
+			os.Exit(1)
+		}
+
+		if jwksPath != "" {
+			if err := writeJWKS(jwksPath, server); err != nil {
+				fmt.Fprintf(os.Stderr, "failed to write jwks: %v\n", err)
+				os.Exit(1)
+			}
+			return
+		}
+
+		path := config.TokenServer.Path
+		if path == "" {
+			path = defaultTokenServerPath
+		}
+		mux := http.NewServeMux()
+		mux.Handle(path, server)
+
+		var handler http.Handler = mux
+		if !config.Log.AccessLog.Disabled {
+			handler = gorhandlers.CombinedLoggingHandler(os.Stdout, handler)
+		}
+
+		addr := config.TokenServer.Addr
+		dcontext.GetLogger(ctx).Infof("token server listening on %v%s", addr, path)
+		if config.TokenServer.Certificate != "" {
+			err = http.ListenAndServeTLS(addr, config.TokenServer.Certificate, config.TokenServer.Key, handler)
+		} else {
+			err = http.ListenAndServe(addr, handler)
+		}
+		if err != nil {
+			logrus.Fatalln(err)
+		}
+	},
+}
+
+// newTokenServer constructs a token server from its configuration section.
+func newTokenServer(config configuration.TokenServer) (*tokenserver.Server, error) {
+	if config.SigningKey == "" {
+		return nil, errors.New("tokenserver.signingkey must be set")
+	}
+	key, err := tokenserver.LoadSigningKey(config.SigningKey)
+	if err != nil {
+		return nil, err
+	}
+
+	authType := config.Authenticator.Type()
+	if authType == "" {
+		return nil, errors.New("tokenserver.authenticator must be set")
+	}
+	params := map[string]interface{}{}
+	for k, v := range config.Authenticator.Parameters() {
+		params[k] = v
+	}
+	if _, ok := params["realm"]; !ok {
+		params["realm"] = config.Realm
+	}
+	ac, err := auth.GetAccessController(authType, params)
+	if err != nil {
+		return nil, fmt.Errorf("unable to configure token server authenticator (%s): %v", authType, err)
+	}
+	authenticator, ok := ac.(auth.CredentialAuthenticator)
+	if !ok {
+		return nil, fmt.Errorf("access controller %q cannot authenticate credentials", authType)
+	}
+
+	var authorizer tokenserver.Authorizer
+	if config.ACL != "" {
+		authorizer, err = tokenserver.NewACLAuthorizer(config.ACL)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load token server acl: %v", err)
+		}
+	} else {
+		logrus.Warn("tokenserver.acl is not set: issued tokens grant no access")
+	}
+
+	return tokenserver.NewServer(tokenserver.Options{
+		Issuer:            config.Issuer,
+		Service:           config.Service,
+		Realm:             config.Realm,
+		SigningKey:        key,
+		Expiration:        config.Expiration,
+		RefreshExpiration: config.RefreshExpiration,
+		Authenticator:     authenticator,
+		Authorizer:        authorizer,
+	})
+}
+
+func writeJWKS(path string, server *tokenserver.Server) error {
+	jwks, err := json.MarshalIndent(server.JSONWebKeySet(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, jwks, 0o644)
+}
@@ -2,6 +2,7 @@ package distribution
 
 import (
 	"context"
+	"time"
 
 	"github.com/opencontainers/go-digest"
 	v1 "github.com/opencontainers/image-spec/specs-go/v1"
@@ -36,3 +37,26 @@ type TagManifestsProvider interface {
 	// includes currently linked digest. There is no ordering guaranteed
 	ManifestDigests(ctx context.Context, tag string) ([]digest.Digest, error)
 }
+
+// TagDetail describes a tag: the manifest it points to, and when it was last
+// tagged.
+type TagDetail struct {
+	Name       string
+	Descriptor v1.Descriptor
+
+	// Modified is when the tag last moved, or the zero time if unknown.
+	Modified time.Time
+}
+
+// TagDetailService lists the tags of a repository a page at a time, with the
+// manifests they point to.
+type TagDetailService interface {
+	// Page fills tags with the tags following last, in lexical order,
+	// returning their number. It returns io.EOF once there are no more
+	// tags.
+	Page(ctx context.Context, tags []string, last string) (int, error)
+
+	// Details returns the details of the tags, skipping the ones which do
+	// not exist.
+	Details(ctx context.Context, tags []string) ([]TagDetail, error)
+}